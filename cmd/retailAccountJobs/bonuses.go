@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/jameswhoughton/cushon/database"
+	"github.com/jameswhoughton/cushon/internal/account"
+	"github.com/jameswhoughton/cushon/internal/app"
+)
+
+func newBonusService(conn *sql.DB) *account.BonusService {
+	var repo account.Repository = database.NewAccountRepository(conn)
+	var bonusRepo account.BonusRepository = database.NewBonusRepository(conn)
+
+	return account.NewBonusService(&bonusRepo, &repo, app.NewCustomerClient())
+}
+
+// Claim the LISA bonus on contributions made before the month containing
+// the date and write the claim file to submit to HMRC
+func claimBonuses(conn *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("claim-bonuses", flag.ExitOnError)
+	date := flags.String("date", time.Now().Format(time.DateOnly), "claim contributions made before the month containing this date (YYYY-MM-DD)")
+	out := flags.String("out", "", "path to write the claim file to")
+	flags.Parse(args)
+
+	if *out == "" {
+		return errors.New("claim-bonuses: -out is required")
+	}
+
+	claimDate, err := time.Parse(time.DateOnly, *date)
+
+	if err != nil {
+		return err
+	}
+
+	result, err := newBonusService(conn).ClaimBonuses(context.Background(), claimDate)
+
+	if err != nil {
+		return err
+	}
+
+	for accountId, err := range result.Failed {
+		log.Printf("account %s failed: %v", accountId, err)
+	}
+
+	file, err := os.Create(*out)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	err = account.WriteBonusClaimFile(file, result.Claims)
+
+	if err != nil {
+		return err
+	}
+
+	log.Printf("claimed %d accounts, skipped %d, failed %d", len(result.Claims), result.Skipped, len(result.Failed))
+
+	return file.Close()
+}
+
+// Ingest HMRC's response to the bonus claims
+func ingestBonusResponses(conn *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("ingest-bonus-responses", flag.ExitOnError)
+	path := flags.String("file", "", "path to the HMRC response CSV")
+	flags.Parse(args)
+
+	if *path == "" {
+		return errors.New("ingest-bonus-responses: -file is required")
+	}
+
+	file, err := os.Open(*path)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	responses, err := account.ParseBonusResponses(file)
+
+	if err != nil {
+		return err
+	}
+
+	result := newBonusService(conn).IngestResponses(context.Background(), responses)
+
+	for claimId, err := range result.Failed {
+		log.Printf("claim %s failed: %v", claimId, err)
+	}
+
+	log.Printf("paid %d claims, rejected %d, skipped %d, failed %d", result.Paid, result.Rejected, result.Skipped, len(result.Failed))
+
+	return nil
+}
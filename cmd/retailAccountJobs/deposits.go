@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"github.com/jameswhoughton/cushon/internal/app"
+)
+
+// Fetch the latest status of every pending deposit from the payments service,
+// settled deposits are invested.
+func syncDeposits(conn *sql.DB) error {
+	serviceFactory, err := newServiceFactory(conn)
+
+	if err != nil {
+		return err
+	}
+
+	service, err := app.NewDepositService(conn, serviceFactory)
+
+	if err != nil {
+		return err
+	}
+
+	failed, err := service.SyncPendingDeposits(context.Background())
+
+	if err != nil {
+		return err
+	}
+
+	for depositId, err := range failed {
+		log.Printf("deposit %s failed to sync: %v", depositId, err)
+	}
+
+	log.Printf("synced pending deposits, %d failed", len(failed))
+
+	return nil
+}
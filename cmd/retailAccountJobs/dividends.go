@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"log"
+
+	"github.com/jameswhoughton/cushon/database"
+	"github.com/jameswhoughton/cushon/internal/account"
+)
+
+// Reinvest dividends declared by accumulation funds
+func reinvestDividends(conn *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("reinvest-dividends", flag.ExitOnError)
+	file := flags.String("file", "", "path to a CSV file of dividend declarations")
+	flags.Parse(args)
+
+	if *file == "" {
+		return errors.New("reinvest-dividends: -file is required")
+	}
+
+	var repo account.Repository = database.NewAccountRepository(conn)
+
+	service := account.NewDividendService(&repo)
+
+	result, err := service.ReinvestDividends(context.Background(), account.NewFileDeclarationSource(*file))
+
+	if err != nil {
+		return err
+	}
+
+	for declarationId, err := range result.Failed {
+		log.Printf("declaration %s failed: %v", declarationId, err)
+	}
+
+	log.Printf("paid %d dividends, skipped %d, failed %d declarations", result.Paid, result.Skipped, len(result.Failed))
+
+	return nil
+}
+
+// Pay distributions declared by income funds to cash or the customer's bank
+func payDistributions(conn *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("pay-distributions", flag.ExitOnError)
+	file := flags.String("file", "", "path to a CSV file of distribution declarations")
+	flags.Parse(args)
+
+	if *file == "" {
+		return errors.New("pay-distributions: -file is required")
+	}
+
+	var repo account.Repository = database.NewAccountRepository(conn)
+
+	service := account.NewDistributionService(&repo)
+
+	result, err := service.PayDistributions(context.Background(), account.NewFileDeclarationSource(*file))
+
+	if err != nil {
+		return err
+	}
+
+	for declarationId, err := range result.Failed {
+		log.Printf("declaration %s failed: %v", declarationId, err)
+	}
+
+	log.Printf("paid %d distributions, skipped %d, failed %d declarations", result.Paid, result.Skipped, len(result.Failed))
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"log"
+	"time"
+)
+
+// Charge the platform fee for the month containing the date
+func chargeFees(conn *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("charge-fees", flag.ExitOnError)
+	date := flags.String("date", time.Now().Format(time.DateOnly), "date in the month to charge for (YYYY-MM-DD)")
+	flags.Parse(args)
+
+	chargeDate, err := time.Parse(time.DateOnly, *date)
+
+	if err != nil {
+		return err
+	}
+
+	service, err := newFeeService(conn)
+
+	if err != nil {
+		return err
+	}
+
+	result, err := service.ChargeFees(context.Background(), chargeDate)
+
+	if err != nil {
+		return err
+	}
+
+	for accountId, err := range result.Failed {
+		log.Printf("account %s failed: %v", accountId, err)
+	}
+
+	log.Printf("charged %d accounts, skipped %d, failed %d", result.Charged, result.Skipped, len(result.Failed))
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"log"
+	"time"
+
+	"github.com/jameswhoughton/cushon/database"
+	"github.com/jameswhoughton/cushon/internal/account"
+	"github.com/jameswhoughton/cushon/internal/app"
+)
+
+// Convert every Junior ISA whose holder has turned 18 by the date into an ISA
+//
+// Run daily so accounts are converted on the holder's birthday.
+func convertJISAs(conn *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("convert-jisas", flag.ExitOnError)
+	date := flags.String("date", time.Now().Format(time.DateOnly), "date to convert accounts on (YYYY-MM-DD)")
+	flags.Parse(args)
+
+	conversionDate, err := time.Parse(time.DateOnly, *date)
+
+	if err != nil {
+		return err
+	}
+
+	var repo account.Repository = database.NewAccountRepository(conn)
+
+	service := account.NewJISAConversionService(&repo, app.NewCustomerClient(), app.LogNotifier{})
+
+	result, err := service.ConvertAdultAccounts(context.Background(), conversionDate)
+
+	if err != nil {
+		return err
+	}
+
+	for accountId, err := range result.Failed {
+		log.Printf("account %s failed: %v", accountId, err)
+	}
+
+	log.Printf("converted %d accounts, failed %d", result.Converted, len(result.Failed))
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// Batch jobs for the retail account service
+//
+// Each job is a sub command, e.g. `retailAccountJobs ingest-prices -file prices.csv`.
+// The database connection is read from the DATABASE_DSN environment variable
+// (parseTime=true is required).
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: retailAccountJobs <job> [flags]")
+		os.Exit(2)
+	}
+
+	conn, err := sql.Open("mysql", os.Getenv("DATABASE_DSN"))
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	switch os.Args[1] {
+	case "ingest-prices":
+		err = ingestPrices(conn, os.Args[2:])
+	case "run-plans":
+		err = runPlans(conn, os.Args[2:])
+	case "sync-deposits":
+		err = syncDeposits(conn)
+	case "reinvest-dividends":
+		err = reinvestDividends(conn, os.Args[2:])
+	case "pay-distributions":
+		err = payDistributions(conn, os.Args[2:])
+	case "charge-fees":
+		err = chargeFees(conn, os.Args[2:])
+	case "generate-statements":
+		err = generateStatements(conn, os.Args[2:])
+	case "isa-return":
+		err = isaReturn(conn, os.Args[2:])
+	case "claim-bonuses":
+		err = claimBonuses(conn, os.Args[2:])
+	case "ingest-bonus-responses":
+		err = ingestBonusResponses(conn, os.Args[2:])
+	case "convert-jisas":
+		err = convertJISAs(conn, os.Args[2:])
+	default:
+		err = fmt.Errorf("unknown job '%s'", os.Args[1])
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
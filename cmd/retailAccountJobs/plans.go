@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"log"
+	"time"
+
+	"github.com/jameswhoughton/cushon/database"
+	"github.com/jameswhoughton/cushon/internal/account"
+	"github.com/jameswhoughton/cushon/internal/app"
+)
+
+// Make the payments for every regular investment plan due on the date
+func runPlans(conn *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("run-plans", flag.ExitOnError)
+	date := flags.String("date", time.Now().Format(time.DateOnly), "date to run the plans for (YYYY-MM-DD)")
+	flags.Parse(args)
+
+	runDate, err := time.Parse(time.DateOnly, *date)
+
+	if err != nil {
+		return err
+	}
+
+	var repo account.PlanRepository = database.NewPlanRepository(conn)
+
+	serviceFactory, err := newServiceFactory(conn)
+
+	if err != nil {
+		return err
+	}
+
+	deposits, err := app.NewDepositService(conn, serviceFactory)
+
+	if err != nil {
+		return err
+	}
+
+	service := account.NewPlanService(&repo, serviceFactory, deposits, app.LogNotifier{})
+
+	result, err := service.RunDuePlans(context.Background(), runDate)
+
+	if err != nil {
+		return err
+	}
+
+	for planId, err := range result.Failed {
+		log.Printf("plan %s failed: %v", planId, err)
+	}
+
+	log.Printf("deposited %d plans, stopped %d, ended %d, failed %d", result.Deposited, result.Stopped, result.Ended, len(result.Failed))
+
+	return nil
+}
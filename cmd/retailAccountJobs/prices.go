@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jameswhoughton/cushon/database"
+	"github.com/jameswhoughton/cushon/internal/fund"
+)
+
+// Load daily fund prices from a local file or an HTTP endpoint
+func ingestPrices(conn *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("ingest-prices", flag.ExitOnError)
+	file := flags.String("file", "", "path to a local price feed")
+	url := flags.String("url", "", "URL of a remote price feed")
+	format := flags.String("format", fund.FEED_FORMAT_CSV, "feed format (csv or json)")
+	maxAge := flags.Duration("max-age", 7*24*time.Hour, "prices older than this are rejected as stale")
+	flags.Parse(args)
+
+	var feed fund.Feed
+
+	switch {
+	case *file != "":
+		feed = fund.NewFileFeed(*file, *format)
+	case *url != "":
+		feed = fund.NewHTTPFeed(*url, *format, &http.Client{Timeout: 30 * time.Second})
+	default:
+		return errors.New("ingest-prices: one of -file or -url is required")
+	}
+
+	var repo fund.PriceRepository = database.NewPriceRepository(conn)
+
+	service := fund.NewPriceService(&repo, *maxAge)
+
+	result, err := service.Ingest(context.Background(), feed)
+
+	if err != nil {
+		return err
+	}
+
+	for _, rejected := range result.Rejected {
+		log.Printf("rejected price for fund %s on %s: %s", rejected.Price.FundId, rejected.Price.Date.Format(fund.PRICE_DATE_FORMAT), rejected.Reason)
+	}
+
+	log.Printf("stored %d prices, rejected %d", result.Stored, len(result.Rejected))
+
+	return nil
+}
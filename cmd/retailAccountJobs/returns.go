@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/jameswhoughton/cushon/database"
+	"github.com/jameswhoughton/cushon/internal/account"
+	"github.com/jameswhoughton/cushon/internal/app"
+)
+
+// Build the annual ISA return for HMRC
+//
+// The file is only written if every record passes validation, otherwise
+// each problem is logged so they can be fixed before re-running.
+func isaReturn(conn *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("isa-return", flag.ExitOnError)
+	taxYear := flags.Int("tax-year", 0, "year the tax year starts in (e.g. 2024 for 2024-25)")
+	format := flags.String("format", account.ISA_RETURN_FORMAT_XML, "output format (xml or csv)")
+	out := flags.String("out", "", "path to write the return to")
+	flags.Parse(args)
+
+	if *taxYear == 0 || *out == "" {
+		return errors.New("isa-return: -tax-year and -out are required")
+	}
+
+	if *format != account.ISA_RETURN_FORMAT_XML && *format != account.ISA_RETURN_FORMAT_CSV {
+		return fmt.Errorf("isa-return: unknown format '%s'", *format)
+	}
+
+	var repo account.Repository = database.NewAccountRepository(conn)
+
+	rules, err := app.NewRulesService()
+
+	if err != nil {
+		return err
+	}
+
+	service := account.NewISAReturnService(&repo, app.NewCustomerClient(), rules, app.NewPriceService(conn), app.START_OF_TAX_YEAR)
+
+	result, errs, err := service.Return(context.Background(), *taxYear)
+
+	for _, e := range errs {
+		log.Printf("invalid record: %v", e)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(*out)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	if *format == account.ISA_RETURN_FORMAT_CSV {
+		err = result.WriteCSV(file)
+	} else {
+		err = result.WriteXML(file)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	log.Printf("ISA return %s: %d accounts, subscriptions %d, market value %d (pence)", account.TaxYearLabel(*taxYear), result.Summary.Accounts, result.Summary.TotalSubscriptions, result.Summary.TotalMarketValue)
+
+	return file.Close()
+}
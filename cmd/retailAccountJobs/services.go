@@ -0,0 +1,41 @@
+package main
+
+import (
+	"database/sql"
+
+	"github.com/jameswhoughton/cushon/database"
+	"github.com/jameswhoughton/cushon/internal/account"
+	"github.com/jameswhoughton/cushon/internal/app"
+)
+
+// Platform fee charged on the value of each account
+//
+// 0.35% on the first £250,000, 0.25% up to £1m and 0.1% above that, capped
+// at £1,500 a year.
+var PLATFORM_FEE_SCHEDULE = account.FeeSchedule{
+	Tiers: []account.FeeTier{
+		{UpTo: 25000000, BasisPoints: 35},
+		{UpTo: 100000000, BasisPoints: 25},
+		{BasisPoints: 10},
+	},
+	AnnualCap: 150000,
+}
+
+// Create the account services used by the jobs
+func newServiceFactory(conn *sql.DB) (*account.ServiceFactory, error) {
+	rules, err := app.NewRulesService()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return app.NewServiceFactory(conn, rules, app.NewCustomerClient())
+}
+
+// Create the fee service used by the jobs
+func newFeeService(conn *sql.DB) (*account.FeeService, error) {
+	var repo account.FeeRepository = database.NewFeeRepository(conn)
+	var accounts account.Repository = database.NewAccountRepository(conn)
+
+	return account.NewFeeService(&repo, &accounts, app.NewPriceService(conn), PLATFORM_FEE_SCHEDULE)
+}
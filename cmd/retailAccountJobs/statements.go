@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"log"
+
+	"github.com/jameswhoughton/cushon/database"
+	"github.com/jameswhoughton/cushon/internal/account"
+	"github.com/jameswhoughton/cushon/internal/app"
+	"github.com/jameswhoughton/cushon/internal/fund"
+)
+
+// Generate the annual statement of every account for a tax year
+func generateStatements(conn *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("generate-statements", flag.ExitOnError)
+	taxYear := flags.Int("tax-year", 0, "year the tax year starts in (e.g. 2024 for 2024-25)")
+	flags.Parse(args)
+
+	if *taxYear == 0 {
+		return errors.New("generate-statements: -tax-year is required")
+	}
+
+	var repo account.StatementRepository = database.NewStatementRepository(conn)
+	var accounts account.Repository = database.NewAccountRepository(conn)
+	var fees account.FeeRepository = database.NewFeeRepository(conn)
+	var catalogue fund.Catalogue = database.NewFundRepository(conn)
+
+	rules, err := app.NewRulesService()
+
+	if err != nil {
+		return err
+	}
+
+	service := account.NewStatementService(&repo, &accounts, &fees, &catalogue, app.NewPriceService(conn), rules, app.START_OF_TAX_YEAR)
+
+	result, err := service.GenerateStatements(context.Background(), *taxYear)
+
+	if err != nil {
+		return err
+	}
+
+	for accountId, err := range result.Failed {
+		log.Printf("account %s failed: %v", accountId, err)
+	}
+
+	log.Printf("generated %d statements for %s, failed %d", result.Generated, account.TaxYearLabel(*taxYear), len(result.Failed))
+
+	return nil
+}
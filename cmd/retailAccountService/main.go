@@ -1,5 +1,100 @@
 package main
 
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"os"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jameswhoughton/cushon/database"
+	"github.com/jameswhoughton/cushon/internal/account"
+	"github.com/jameswhoughton/cushon/internal/app"
+	"github.com/jameswhoughton/cushon/internal/fund"
+)
+
+// REST API for the retail account service
+//
+// The database connection is read from the DATABASE_DSN environment variable
+// (parseTime=true is required) and the server listens on ADDR, :8080 by default.
+// The database is migrated on start up. Customers are read from the retail
+// customer service and the API gateway forwards the customer in the session
+// in the X-Customer-Id header, see internal/app for the rest of the
+// configuration.
 func main() {
-	// Here I would create instances of all the repositories/services, define the routes and start the server.
+	conn, err := sql.Open("mysql", os.Getenv("DATABASE_DSN"))
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	err = database.Migrate(conn)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	rules, err := app.NewRulesService()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	customers := app.NewCustomerClient()
+
+	serviceFactory, err := app.NewServiceFactory(conn, rules, customers)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	priceService := app.NewPriceService(conn)
+
+	depositService, err := app.NewDepositService(conn, serviceFactory)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var planRepository account.PlanRepository = database.NewPlanRepository(conn)
+	planService := account.NewPlanService(&planRepository, serviceFactory, depositService, app.LogNotifier{})
+
+	var accountRepository account.Repository = database.NewAccountRepository(conn)
+	distributionService := account.NewDistributionService(&accountRepository)
+
+	var statementRepository account.StatementRepository = database.NewStatementRepository(conn)
+	var feeRepository account.FeeRepository = database.NewFeeRepository(conn)
+	var catalogue fund.Catalogue = database.NewFundRepository(conn)
+	statementService := account.NewStatementService(&statementRepository, &accountRepository, &feeRepository, &catalogue, priceService, rules, app.START_OF_TAX_YEAR)
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /api/v1/products", account.GetProductsHandler(*serviceFactory, app.START_OF_TAX_YEAR))
+	mux.HandleFunc("POST /api/v1/account", account.PostAccountHandler(*serviceFactory, customers))
+	mux.HandleFunc("GET /api/v1/customer/{id}/eligibility", account.GetEligibilityHandler(*serviceFactory, customers))
+	mux.HandleFunc("POST /api/v1/account/{id}/invest", account.PostInvestHandler(*serviceFactory, depositService))
+	mux.HandleFunc("POST /api/v1/account/{id}/switch", account.PostSwitchHandler(*serviceFactory))
+	mux.HandleFunc("GET /api/v1/account/{id}/deposits/{depositId}", account.GetDepositHandler(*serviceFactory, depositService))
+	mux.HandleFunc("PUT /api/v1/account/{id}/income-preference", account.PutIncomePreferenceHandler(*serviceFactory, distributionService))
+	mux.HandleFunc("GET /api/v1/account/{id}/statements/{taxYear}", account.GetStatementHandler(*serviceFactory, statementService))
+	mux.HandleFunc("POST /api/v1/account/{id}/withdraw", account.PostWithdrawHandler(*serviceFactory))
+	mux.HandleFunc("GET /api/v1/account/{id}/withdraw/quote", account.GetWithdrawalQuoteHandler(*serviceFactory))
+
+	mux.HandleFunc("GET /api/v1/account/{id}/plans", account.GetPlansHandler(*serviceFactory, planService))
+	mux.HandleFunc("POST /api/v1/account/{id}/plans", account.PostPlanHandler(*serviceFactory, planService))
+	mux.HandleFunc("GET /api/v1/account/{id}/plans/{planId}", account.GetPlanHandler(*serviceFactory, planService))
+	mux.HandleFunc("PUT /api/v1/account/{id}/plans/{planId}", account.PutPlanHandler(*serviceFactory, planService))
+	mux.HandleFunc("DELETE /api/v1/account/{id}/plans/{planId}", account.DeletePlanHandler(*serviceFactory, planService))
+
+	mux.HandleFunc("GET /api/v1/fund/{id}/price", fund.GetFundPriceHandler(priceService))
+
+	addr := os.Getenv("ADDR")
+
+	if addr == "" {
+		addr = ":8080"
+	}
+
+	log.Fatal(http.ListenAndServe(addr, mux))
 }
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"os"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jameswhoughton/cushon/database"
+	"github.com/jameswhoughton/cushon/internal/customer"
+	"github.com/jameswhoughton/cushon/internal/encryption"
+)
+
+// REST API for the retail customer service
+//
+// The database connection is read from the DATABASE_DSN environment variable
+// (parseTime=true is required) and the server listens on ADDR, :8080 by default.
+// The service has its own database which is migrated on start up.
+//
+// Personal data is encrypted with keys from the keyring file at KEYRING_FILE.
+// After making a new key current, run `retailCustomerService rotate-keys` to
+// re-encrypt existing customers with it.
+func main() {
+	conn, err := sql.Open("mysql", os.Getenv("DATABASE_DSN"))
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	err = database.MigrateCustomers(conn)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	keyring, err := encryption.LoadKeyringFile(os.Getenv("KEYRING_FILE"))
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	customerRepository := database.NewCustomerRepository(conn, encryption.NewCipher(keyring))
+
+	// Customers stored before encryption was added can't be read until they're encrypted
+	encrypted, err := customerRepository.EncryptExisting(context.Background())
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if encrypted > 0 {
+		log.Printf("Encrypted %d existing customers", encrypted)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "rotate-keys" {
+		rotated, err := customerRepository.RotateKeys(context.Background())
+
+		log.Printf("Re-encrypted %d customers", rotated)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
+
+	var repository customer.Repository = customerRepository
+
+	service := customer.NewService(&repository)
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /api/v1/customer", customer.PostCustomerHandler(service))
+	mux.HandleFunc("GET /api/v1/customer/{id}", customer.GetCustomerHandler(service))
+	mux.HandleFunc("PUT /api/v1/customer/{id}", customer.PutCustomerHandler(service))
+
+	addr := os.Getenv("ADDR")
+
+	if addr == "" {
+		addr = ":8080"
+	}
+
+	log.Fatal(http.ListenAndServe(addr, mux))
+}
@@ -3,11 +3,14 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/google/uuid"
 	"github.com/jameswhoughton/cushon/internal/account"
+	"github.com/jameswhoughton/cushon/internal/fund"
 )
 
 type AccountRepository struct {
@@ -21,13 +24,15 @@ func NewAccountRepository(conn *sql.DB) *AccountRepository {
 func (r *AccountRepository) Create(ctx context.Context, account *account.Account) error {
 	_, err := r.db.ExecContext(ctx, `
 		INSERT INTO accounts
-		(id, customer_id, account_type)
+		(id, customer_id, guardian_id, account_type, income_preference)
 		VALUES (
 			UUID_TO_BIN(?),
 			UUID_TO_BIN(?),
+			UUID_TO_BIN(?),
+			?,
 			?
 		)
-	`, account.Id.String(), account.CustomerId.String(), account.AccountType)
+	`, account.Id.String(), account.CustomerId.String(), account.GuardianId, account.AccountType, account.IncomePreference)
 
 	if err != nil {
 		return fmt.Errorf("AccountRepository.Create: Unable to create account: %v", err)
@@ -36,6 +41,99 @@ func (r *AccountRepository) Create(ctx context.Context, account *account.Account
 	return nil
 }
 
+func (r *AccountRepository) GetAccount(ctx context.Context, accountId uuid.UUID) (account.Account, error) {
+	var a account.Account
+
+	row := r.db.QueryRowContext(ctx, `
+		SELECT BIN_TO_UUID(id), BIN_TO_UUID(customer_id), BIN_TO_UUID(guardian_id), account_type, income_preference, created_at
+		FROM accounts
+		WHERE id = UUID_TO_BIN(?)
+	`, accountId)
+
+	err := row.Scan(&a.Id, &a.CustomerId, &a.GuardianId, &a.AccountType, &a.IncomePreference, &a.CreatedAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return account.Account{}, account.ErrAccountNotFound
+	}
+
+	if err != nil {
+		return account.Account{}, fmt.Errorf("AccountRepository.GetAccount: Unable to fetch account: %v", err)
+	}
+
+	return a, nil
+}
+
+func (r *AccountRepository) GetAccountsByType(ctx context.Context, accountType string, openedBefore time.Time) ([]account.Account, error) {
+	var accounts []account.Account
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT BIN_TO_UUID(id), BIN_TO_UUID(customer_id), BIN_TO_UUID(guardian_id), account_type, income_preference, created_at
+		FROM accounts
+		WHERE account_type = ?
+		AND created_at < ?
+		ORDER BY created_at, id
+	`, accountType, openedBefore)
+
+	if err != nil {
+		return []account.Account{}, fmt.Errorf("AccountRepository.GetAccountsByType: Unable to fetch accounts: %v", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var a account.Account
+
+		err := rows.Scan(&a.Id, &a.CustomerId, &a.GuardianId, &a.AccountType, &a.IncomePreference, &a.CreatedAt)
+
+		if err != nil {
+			return []account.Account{}, fmt.Errorf("AccountRepository.GetAccountsByType: Unable to fetch accounts: %v", err)
+		}
+
+		accounts = append(accounts, a)
+	}
+
+	return accounts, nil
+}
+
+func (r *AccountRepository) ConvertAccount(ctx context.Context, accountId uuid.UUID, from string, to string, date time.Time) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE accounts
+		SET account_type = ?, guardian_id = NULL, converted_at = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = UUID_TO_BIN(?)
+		AND account_type = ?
+	`, to, date, accountId, from)
+
+	if err != nil {
+		return fmt.Errorf("AccountRepository.ConvertAccount: Unable to update account: %v", err)
+	}
+
+	updated, err := result.RowsAffected()
+
+	if err != nil {
+		return fmt.Errorf("AccountRepository.ConvertAccount: Unable to update account: %v", err)
+	}
+
+	if updated == 0 {
+		return account.ErrAccountNotFound
+	}
+
+	return nil
+}
+
+func (r *AccountRepository) UpdateIncomePreference(ctx context.Context, accountId uuid.UUID, preference string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE accounts
+		SET income_preference = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = UUID_TO_BIN(?)
+	`, preference, accountId)
+
+	if err != nil {
+		return fmt.Errorf("AccountRepository.UpdateIncomePreference: Unable to update account: %v", err)
+	}
+
+	return nil
+}
+
 func (r *AccountRepository) Invest(ctx context.Context, accountId uuid.UUID, investments []account.Investment) error {
 	// Use a transaction to ensure tables are updated atomically
 	tx, err := r.db.BeginTx(ctx, nil)
@@ -46,11 +144,93 @@ func (r *AccountRepository) Invest(ctx context.Context, accountId uuid.UUID, inv
 
 	defer tx.Rollback()
 
+	err = invest(ctx, tx, accountId, investments)
+
+	if errors.Is(err, account.ErrTradeDuplicate) || errors.Is(err, account.ErrInsufficientBalance) {
+		return err
+	}
+
+	if err != nil {
+		return fmt.Errorf("AccountRepository.Invest: %v", err)
+	}
+
+	err = tx.Commit()
+
+	if err != nil {
+		return fmt.Errorf("AccountRepository.Invest: Unable to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+func (r *AccountRepository) Withdraw(ctx context.Context, withdrawal account.Withdrawal) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return fmt.Errorf("AccountRepository.Withdraw: Unable to start transaction: %v", err)
+	}
+
+	defer tx.Rollback()
+
+	err = invest(ctx, tx, withdrawal.AccountId, withdrawal.Sales)
+
+	if errors.Is(err, account.ErrInsufficientBalance) {
+		return err
+	}
+
+	if err != nil {
+		return fmt.Errorf("AccountRepository.Withdraw: %v", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO payout_instructions
+		(account_id, reference, amount, status)
+		VALUES (UUID_TO_BIN(?), UUID_TO_BIN(?), ?, ?)
+	`, withdrawal.AccountId, withdrawal.Id, withdrawal.Payout, account.PAYOUT_STATUS_PENDING)
+
+	if err != nil {
+		return fmt.Errorf("AccountRepository.Withdraw: Unable to create payout instruction: %v", err)
+	}
+
+	err = tx.Commit()
+
+	if err != nil {
+		return fmt.Errorf("AccountRepository.Withdraw: Unable to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+// Make investments as part of an existing transaction
+//
+// Shared by any repository that needs to invest alongside other changes.
+// Sales (negative amounts) are checked against the fund's balance as it is
+// updated, so two concurrent sales can't overdraw it. Returns
+// ErrInsufficientBalance if they would.
+func invest(ctx context.Context, tx *sql.Tx, accountId uuid.UUID, investments []account.Investment) error {
 	for _, investment := range investments {
 		// As a minor optimisation we don't need to update the fund balance if the fund is new
 		// as we can insert the balance directly.
 		var newFund bool
 
+		// The account fund may not have been looked up by the caller
+		if investment.AccountFundId == 0 {
+			err := tx.QueryRowContext(ctx, `
+				SELECT id FROM account_funds
+				WHERE account_id = UUID_TO_BIN(?)
+				AND fund_id = UUID_TO_BIN(?)
+			`, accountId, investment.FundId.String()).Scan(&investment.AccountFundId)
+
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("Unable to fetch account fund: %v", err)
+			}
+		}
+
+		// Nothing can be sold from a fund that isn't held
+		if investment.AccountFundId == 0 && investment.Amount < 0 {
+			return account.ErrInsufficientBalance
+		}
+
 		// If the fund is new, create an entry in account_funds
 		if investment.AccountFundId == 0 {
 			result, err := tx.ExecContext(ctx, `
@@ -60,13 +240,13 @@ func (r *AccountRepository) Invest(ctx context.Context, accountId uuid.UUID, inv
 			`, accountId, investment.FundId.String(), investment.Amount)
 
 			if err != nil {
-				return fmt.Errorf("AccountRepository.Invest: Unable to create an account fund: %v", err)
+				return fmt.Errorf("Unable to create an account fund: %v", err)
 			}
 
 			investment.AccountFundId, err = result.LastInsertId()
 
 			if err != nil {
-				return fmt.Errorf("AccountRepository.Invest: Unable to fetch new account_funds Id: %v", err)
+				return fmt.Errorf("Unable to fetch new account_funds Id: %v", err)
 			}
 
 			newFund = true
@@ -79,36 +259,281 @@ func (r *AccountRepository) Invest(ctx context.Context, accountId uuid.UUID, inv
 		VALUES (?, UUID_TO_BIN(?), ?, ?)
 		`, investment.AccountFundId, investment.TradeId, investment.TransactionType, investment.Amount)
 
+		var mysqlErr *mysql.MySQLError
+
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == ER_DUP_ENTRY {
+			return account.ErrTradeDuplicate
+		}
+
 		if err != nil {
-			return fmt.Errorf("AccountRepository.Invest: Unable to create an account transaction: %v", err)
+			return fmt.Errorf("Unable to create an account transaction: %v", err)
 		}
 
-		// Update the account_funds table if the fund is not new
+		// Update the account_funds table if the fund is not new, the row is
+		// locked until the transaction ends so the balance checked is current
 		if !newFund {
-			_, err = tx.ExecContext(ctx, `
+			result, err := tx.ExecContext(ctx, `
 				UPDATE account_funds SET balance = balance + ?
-			`, investment.Amount)
+				WHERE id = ?
+				AND balance + ? >= 0
+			`, investment.Amount, investment.AccountFundId, investment.Amount)
 
 			if err != nil {
-				return fmt.Errorf("AccountRepository.Invest: Unable to update fund balance: %v", err)
+				return fmt.Errorf("Unable to update fund balance: %v", err)
 			}
+
+			updated, err := result.RowsAffected()
+
+			if err != nil {
+				return fmt.Errorf("Unable to update fund balance: %v", err)
+			}
+
+			// Only rows that change are counted, so an empty investment updates none
+			if updated == 0 && investment.Amount < 0 {
+				return account.ErrInsufficientBalance
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *AccountRepository) GetAccountFunds(ctx context.Context, accountId uuid.UUID) ([]account.AccountFund, error) {
+	var accountFunds []account.AccountFund
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, BIN_TO_UUID(account_id), BIN_TO_UUID(fund_id), balance
+		FROM account_funds
+		WHERE account_id = UUID_TO_BIN(?)
+	`, accountId)
+
+	if err != nil {
+		return []account.AccountFund{}, fmt.Errorf("AccountRepository.GetAccountFunds: Unable to fetch account funds: %v", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var accountFund account.AccountFund
+
+		err := rows.Scan(&accountFund.Id, &accountFund.AccountId, &accountFund.FundId, &accountFund.Balance)
+
+		if err != nil {
+			return []account.AccountFund{}, fmt.Errorf("AccountRepository.GetAccountFunds: Unable to fetch account funds: %v", err)
+		}
+
+		accountFunds = append(accountFunds, accountFund)
+	}
+
+	return accountFunds, nil
+}
+
+// Units bought or sold by each transaction at the price of the fund on the day
+//
+// The price is the most recent on or before the day, unpriced counts the
+// transactions made before the fund had a price.
+const unitsSelect = `
+	SELECT f.id, BIN_TO_UUID(f.account_id), BIN_TO_UUID(f.fund_id), SUM(t.amount) AS balance,
+	COALESCE(SUM(ROUND(t.amount * ? / p.price)), 0) AS units,
+	SUM(p.price IS NULL) AS unpriced
+	FROM account_funds f
+	JOIN fund_transactions t
+	ON f.id = t.account_fund_id
+	LEFT JOIN fund_prices p
+	ON p.id = (
+		SELECT id FROM fund_prices
+		WHERE fund_id = f.fund_id
+		AND price_date <= DATE(t.created_at)
+		ORDER BY price_date DESC
+		LIMIT 1
+	)
+`
+
+func scanUnits(rows *sql.Rows) ([]account.AccountFund, error) {
+	var holdings []account.AccountFund
+
+	for rows.Next() {
+		var holding account.AccountFund
+		var unpriced int
+
+		err := rows.Scan(&holding.Id, &holding.AccountId, &holding.FundId, &holding.Balance, &holding.Units, &unpriced)
+
+		if err != nil {
+			return []account.AccountFund{}, err
+		}
+
+		if unpriced > 0 {
+			return []account.AccountFund{}, fmt.Errorf("%w on the day of %d transactions in fund %s", fund.ErrPriceNotFound, unpriced, holding.FundId)
+		}
+
+		holdings = append(holdings, holding)
+	}
+
+	return holdings, rows.Err()
+}
+
+func (r *AccountRepository) GetAccountUnitsAt(ctx context.Context, accountId uuid.UUID, date time.Time) ([]account.AccountFund, error) {
+	// Include every transaction made on the date itself
+	endOfDay := time.Date(date.Year(), date.Month(), date.Day()+1, 0, 0, 0, 0, date.Location())
+
+	rows, err := r.db.QueryContext(ctx, unitsSelect+`
+		WHERE f.account_id = UUID_TO_BIN(?)
+		AND t.created_at < ?
+		GROUP BY f.id
+		HAVING units > 0 OR unpriced > 0
+		ORDER BY f.id
+	`, account.UNIT_SCALE, accountId, endOfDay)
+
+	if err != nil {
+		return []account.AccountFund{}, fmt.Errorf("AccountRepository.GetAccountUnitsAt: Unable to fetch account funds: %v", err)
+	}
+
+	defer rows.Close()
+
+	holdings, err := scanUnits(rows)
+
+	if errors.Is(err, fund.ErrPriceNotFound) {
+		return []account.AccountFund{}, err
+	}
+
+	if err != nil {
+		return []account.AccountFund{}, fmt.Errorf("AccountRepository.GetAccountUnitsAt: Unable to fetch account funds: %v", err)
+	}
+
+	return holdings, nil
+}
+
+func (r *AccountRepository) GetFundUnitsBefore(ctx context.Context, fundId uuid.UUID, date time.Time) ([]account.AccountFund, error) {
+	startOfDay := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+
+	rows, err := r.db.QueryContext(ctx, unitsSelect+`
+		WHERE f.fund_id = UUID_TO_BIN(?)
+		AND t.created_at < ?
+		GROUP BY f.id
+		HAVING units > 0 OR unpriced > 0
+		ORDER BY f.id
+	`, account.UNIT_SCALE, fundId, startOfDay)
+
+	if err != nil {
+		return []account.AccountFund{}, fmt.Errorf("AccountRepository.GetFundUnitsBefore: Unable to fetch holdings: %v", err)
+	}
+
+	defer rows.Close()
+
+	holdings, err := scanUnits(rows)
+
+	if errors.Is(err, fund.ErrPriceNotFound) {
+		return []account.AccountFund{}, err
+	}
+
+	if err != nil {
+		return []account.AccountFund{}, fmt.Errorf("AccountRepository.GetFundUnitsBefore: Unable to fetch holdings: %v", err)
+	}
+
+	return holdings, nil
+}
+
+func (r *AccountRepository) PayDistribution(ctx context.Context, distribution account.Distribution) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return fmt.Errorf("AccountRepository.PayDistribution: Unable to start transaction: %v", err)
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO cash_transactions
+		(account_id, reference, fund_id, transaction_type, amount)
+		VALUES (UUID_TO_BIN(?), UUID_TO_BIN(?), UUID_TO_BIN(?), ?, ?)
+	`, distribution.AccountId, distribution.Reference, distribution.FundId, account.TRANSACTION_TYPE_DISTRIBUTION, distribution.Amount)
+
+	var mysqlErr *mysql.MySQLError
+
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == ER_DUP_ENTRY {
+		return account.ErrDistributionDuplicate
+	}
+
+	if err != nil {
+		return fmt.Errorf("AccountRepository.PayDistribution: Unable to credit distribution: %v", err)
+	}
+
+	if distribution.IncomePreference == account.INCOME_PREFERENCE_PAYOUT {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO cash_transactions
+			(account_id, reference, fund_id, transaction_type, amount)
+			VALUES (UUID_TO_BIN(?), UUID_TO_BIN(?), UUID_TO_BIN(?), ?, ?)
+		`, distribution.AccountId, distribution.Reference, distribution.FundId, account.TRANSACTION_TYPE_PAYOUT, -distribution.Amount)
+
+		if err != nil {
+			return fmt.Errorf("AccountRepository.PayDistribution: Unable to debit payout: %v", err)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO payout_instructions
+			(account_id, reference, amount, status)
+			VALUES (UUID_TO_BIN(?), UUID_TO_BIN(?), ?, ?)
+		`, distribution.AccountId, distribution.Reference, distribution.Amount, account.PAYOUT_STATUS_PENDING)
+
+		if err != nil {
+			return fmt.Errorf("AccountRepository.PayDistribution: Unable to create payout instruction: %v", err)
 		}
 	}
 
 	err = tx.Commit()
 
 	if err != nil {
-		return fmt.Errorf("AccountRepository.Invest: Unable to commit transaction: %v", err)
+		return fmt.Errorf("AccountRepository.PayDistribution: Unable to commit transaction: %v", err)
 	}
 
 	return nil
 }
 
+func (r *AccountRepository) GetCashBalance(ctx context.Context, accountId uuid.UUID) (int, error) {
+	row := r.db.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(amount), 0) AS balance
+		FROM cash_transactions
+		WHERE account_id = UUID_TO_BIN(?)
+	`, accountId)
+
+	var balance int
+
+	err := row.Scan(&balance)
+
+	if err != nil {
+		return 0, fmt.Errorf("AccountRepository.GetCashBalance: Unable to fetch balance: %v", err)
+	}
+
+	return balance, nil
+}
+
+func (r *AccountRepository) GetCashBalanceAt(ctx context.Context, accountId uuid.UUID, date time.Time) (int, error) {
+	// Include every transaction made on the date itself
+	endOfDay := time.Date(date.Year(), date.Month(), date.Day()+1, 0, 0, 0, 0, date.Location())
+
+	row := r.db.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(amount), 0) AS balance
+		FROM cash_transactions
+		WHERE account_id = UUID_TO_BIN(?)
+		AND created_at < ?
+	`, accountId, endOfDay)
+
+	var balance int
+
+	err := row.Scan(&balance)
+
+	if err != nil {
+		return 0, fmt.Errorf("AccountRepository.GetCashBalanceAt: Unable to fetch balance: %v", err)
+	}
+
+	return balance, nil
+}
+
 func (r *AccountRepository) GetAccountTransactions(ctx context.Context, accountId uuid.UUID, filter account.TransactionFilter) ([]account.Transaction, error) {
 	var transactions []account.Transaction
 
 	rows, err := r.db.QueryContext(ctx, `
-		SELECT t.id, BIN_TO_UUID(f.fund_id), t.transaction_type, t.amount
+		SELECT t.id, BIN_TO_UUID(f.fund_id), t.transaction_type, t.amount, t.created_at
 		FROM accounts a
 		LEFT JOIN account_funds f
 		ON a.id = f.account_id
@@ -117,6 +542,7 @@ func (r *AccountRepository) GetAccountTransactions(ctx context.Context, accountI
 		WHERE a.id = UUID_TO_BIN(?)
 		AND t.created_at >= ?
 		AND t.created_at <= ?
+		ORDER BY t.created_at, t.id
 	`, accountId, filter.StartDate, filter.EndDate)
 
 	if err != nil {
@@ -128,7 +554,7 @@ func (r *AccountRepository) GetAccountTransactions(ctx context.Context, accountI
 	for rows.Next() {
 		var transaction account.Transaction
 
-		err := rows.Scan(&transaction.Id, &transaction.FundId, &transaction.TransactionType, &transaction.Amount)
+		err := rows.Scan(&transaction.Id, &transaction.FundId, &transaction.TransactionType, &transaction.Amount, &transaction.CreatedAt)
 
 		if err != nil {
 			return []account.Transaction{}, fmt.Errorf("AccountRepository.GetAccountTransactions: Unable to fetch transactions: %v", err)
@@ -142,20 +568,135 @@ func (r *AccountRepository) GetAccountTransactions(ctx context.Context, accountI
 
 func (r *AccountRepository) GetTotalInvestedToDate(ctx context.Context, accountId uuid.UUID, fromDate time.Time) (int, error) {
 	row := r.db.QueryRowContext(ctx, `
-		SELECT SUM(t.amount) AS total
-		FROM accounts a
-		LEFT JOIN account_funds f
-		ON a.id = f.account_id
-		LEFT JOIN fund_transactions  t
+		SELECT COALESCE(SUM(t.amount), 0) AS total
+		FROM account_funds f
+		JOIN fund_transactions t
 		ON f.id = t.account_fund_id
-		WHERE a.id = ?
-		AND transaction_type = 'customer'
+		WHERE f.account_id = UUID_TO_BIN(?)
+		AND t.transaction_type = ?
 		AND t.amount > 0
-	`, accountId)
+		AND COALESCE(t.subscribed_at, t.created_at) >= ?
+	`, accountId, account.TRANSACTION_TYPE_CUSTOMER, fromDate)
 
 	var total int
 
-	row.Scan(&total)
+	err := row.Scan(&total)
+
+	if err != nil {
+		return 0, fmt.Errorf("AccountRepository.GetTotalInvestedToDate: Unable to fetch total: %v", err)
+	}
 
 	return total, nil
 }
+
+func (r *AccountRepository) GetSubscriptionsBetween(ctx context.Context, accountId uuid.UUID, fromDate time.Time, toDate time.Time) (int, error) {
+	row := r.db.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(t.amount), 0) AS total
+		FROM account_funds f
+		JOIN fund_transactions t
+		ON f.id = t.account_fund_id
+		WHERE f.account_id = UUID_TO_BIN(?)
+		AND t.transaction_type = ?
+		AND t.amount > 0
+		AND COALESCE(t.subscribed_at, t.created_at) >= ?
+		AND COALESCE(t.subscribed_at, t.created_at) < ?
+	`, accountId, account.TRANSACTION_TYPE_CUSTOMER, fromDate, toDate)
+
+	var total int
+
+	err := row.Scan(&total)
+
+	if err != nil {
+		return 0, fmt.Errorf("AccountRepository.GetSubscriptionsBetween: Unable to fetch total: %v", err)
+	}
+
+	return total, nil
+}
+
+func (r *AccountRepository) GetSubscriptionTransactions(ctx context.Context, accountId uuid.UUID, fromDate time.Time, toDate time.Time) ([]account.Transaction, error) {
+	var transactions []account.Transaction
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT t.id, BIN_TO_UUID(f.fund_id), t.transaction_type, t.amount, COALESCE(t.subscribed_at, t.created_at) AS subscribed_at
+		FROM account_funds f
+		JOIN fund_transactions t
+		ON f.id = t.account_fund_id
+		WHERE f.account_id = UUID_TO_BIN(?)
+		AND t.transaction_type = ?
+		AND COALESCE(t.subscribed_at, t.created_at) >= ?
+		AND COALESCE(t.subscribed_at, t.created_at) < ?
+		ORDER BY subscribed_at, t.id
+	`, accountId, account.TRANSACTION_TYPE_CUSTOMER, fromDate, toDate)
+
+	if err != nil {
+		return []account.Transaction{}, fmt.Errorf("AccountRepository.GetSubscriptionTransactions: Unable to fetch transactions: %v", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var transaction account.Transaction
+
+		err := rows.Scan(&transaction.Id, &transaction.FundId, &transaction.TransactionType, &transaction.Amount, &transaction.CreatedAt)
+
+		if err != nil {
+			return []account.Transaction{}, fmt.Errorf("AccountRepository.GetSubscriptionTransactions: Unable to fetch transactions: %v", err)
+		}
+
+		transactions = append(transactions, transaction)
+	}
+
+	return transactions, nil
+}
+
+func (r *AccountRepository) GetCustomerSubscriptions(ctx context.Context, customerId uuid.UUID, fromDate time.Time) ([]account.AccountSubscriptions, error) {
+	var subscriptions []account.AccountSubscriptions
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT BIN_TO_UUID(a.id), a.account_type, a.from_date,
+		(
+			SELECT COALESCE(SUM(t.amount), 0)
+			FROM account_funds f
+			JOIN fund_transactions t
+			ON f.id = t.account_fund_id
+			WHERE f.account_id = a.id
+			AND t.transaction_type = ?
+			AND t.amount > 0
+			AND COALESCE(t.subscribed_at, t.created_at) >= a.from_date
+		) AS subscribed,
+		(
+			SELECT COALESCE(SUM(d.amount), 0)
+			FROM deposits d
+			WHERE d.account_id = a.id
+			AND d.status IN (?, ?)
+			AND d.created_at >= a.from_date
+		) AS pending
+		FROM (
+			-- Subscriptions made before an account was converted count towards its old type
+			SELECT id, account_type, created_at, CAST(GREATEST(?, COALESCE(converted_at, ?)) AS DATETIME) AS from_date
+			FROM accounts
+			WHERE customer_id = UUID_TO_BIN(?)
+		) a
+		ORDER BY a.created_at, a.id
+	`, account.TRANSACTION_TYPE_CUSTOMER, account.DEPOSIT_STATUS_INITIATED, account.DEPOSIT_STATUS_AUTHORISED, fromDate, fromDate, customerId)
+
+	if err != nil {
+		return []account.AccountSubscriptions{}, fmt.Errorf("AccountRepository.GetCustomerSubscriptions: Unable to fetch subscriptions: %v", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var subscription account.AccountSubscriptions
+
+		err := rows.Scan(&subscription.AccountId, &subscription.AccountType, &subscription.From, &subscription.Subscribed, &subscription.Pending)
+
+		if err != nil {
+			return []account.AccountSubscriptions{}, fmt.Errorf("AccountRepository.GetCustomerSubscriptions: Unable to fetch subscriptions: %v", err)
+		}
+
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	return subscriptions, nil
+}
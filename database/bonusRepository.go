@@ -0,0 +1,169 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/internal/account"
+)
+
+type BonusRepository struct {
+	db *sql.DB
+}
+
+func NewBonusRepository(conn *sql.DB) *BonusRepository {
+	return &BonusRepository{db: conn}
+}
+
+func (r *BonusRepository) GetLastClaim(ctx context.Context, accountId uuid.UUID) (account.BonusClaim, error) {
+	var claim account.BonusClaim
+
+	row := r.db.QueryRowContext(ctx, `
+		SELECT BIN_TO_UUID(id), BIN_TO_UUID(account_id), period_from, period_to, contributions, bonus, status, amount, reason
+		FROM bonus_claims
+		WHERE account_id = UUID_TO_BIN(?)
+		ORDER BY period_to DESC
+		LIMIT 1
+	`, accountId)
+
+	err := row.Scan(&claim.Id, &claim.AccountId, &claim.PeriodFrom, &claim.PeriodTo, &claim.Contributions, &claim.Bonus, &claim.Status, &claim.Amount, &claim.Reason)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return account.BonusClaim{}, account.ErrBonusClaimNotFound
+	}
+
+	if err != nil {
+		return account.BonusClaim{}, fmt.Errorf("BonusRepository.GetLastClaim: Unable to fetch claim: %v", err)
+	}
+
+	return claim, nil
+}
+
+func (r *BonusRepository) CreateClaim(ctx context.Context, claim account.BonusClaim) error {
+	// The unique key on the account and end of the period stops the same
+	// contributions being claimed twice.
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO bonus_claims
+		(id, account_id, period_from, period_to, contributions, bonus, status)
+		VALUES (UUID_TO_BIN(?), UUID_TO_BIN(?), ?, ?, ?, ?, ?)
+	`, claim.Id, claim.AccountId, claim.PeriodFrom, claim.PeriodTo, claim.Contributions, claim.Bonus, claim.Status)
+
+	var mysqlErr *mysql.MySQLError
+
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == ER_DUP_ENTRY {
+		return account.ErrBonusAlreadyClaimed
+	}
+
+	if err != nil {
+		return fmt.Errorf("BonusRepository.CreateClaim: Unable to create claim: %v", err)
+	}
+
+	return nil
+}
+
+func (r *BonusRepository) PayClaim(ctx context.Context, claimId uuid.UUID, amount int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return fmt.Errorf("BonusRepository.PayClaim: Unable to start transaction: %v", err)
+	}
+
+	defer tx.Rollback()
+
+	accountId, err := lockSubmittedClaim(ctx, tx, claimId)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE bonus_claims SET status = ?, amount = ? WHERE id = UUID_TO_BIN(?)
+	`, account.BONUS_CLAIM_STATUS_PAID, amount, claimId)
+
+	if err != nil {
+		return fmt.Errorf("BonusRepository.PayClaim: Unable to update claim: %v", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO cash_transactions
+		(account_id, reference, transaction_type, amount)
+		VALUES (UUID_TO_BIN(?), UUID_TO_BIN(?), ?, ?)
+	`, accountId, claimId, account.TRANSACTION_TYPE_BONUS, amount)
+
+	if err != nil {
+		return fmt.Errorf("BonusRepository.PayClaim: Unable to credit cash: %v", err)
+	}
+
+	err = tx.Commit()
+
+	if err != nil {
+		return fmt.Errorf("BonusRepository.PayClaim: Unable to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+func (r *BonusRepository) RejectClaim(ctx context.Context, claimId uuid.UUID, reason string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return fmt.Errorf("BonusRepository.RejectClaim: Unable to start transaction: %v", err)
+	}
+
+	defer tx.Rollback()
+
+	_, err = lockSubmittedClaim(ctx, tx, claimId)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE bonus_claims SET status = ?, reason = ? WHERE id = UUID_TO_BIN(?)
+	`, account.BONUS_CLAIM_STATUS_REJECTED, reason, claimId)
+
+	if err != nil {
+		return fmt.Errorf("BonusRepository.RejectClaim: Unable to update claim: %v", err)
+	}
+
+	err = tx.Commit()
+
+	if err != nil {
+		return fmt.Errorf("BonusRepository.RejectClaim: Unable to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+// Lock the claim for update and return its account id
+//
+// Returns ErrBonusClaimNotSubmitted if the claim has already been paid or rejected.
+func lockSubmittedClaim(ctx context.Context, tx *sql.Tx, claimId uuid.UUID) (uuid.UUID, error) {
+	var accountId uuid.UUID
+	var status string
+
+	err := tx.QueryRowContext(ctx, `
+		SELECT BIN_TO_UUID(account_id), status
+		FROM bonus_claims
+		WHERE id = UUID_TO_BIN(?)
+		FOR UPDATE
+	`, claimId).Scan(&accountId, &status)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.UUID{}, account.ErrBonusClaimNotFound
+	}
+
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("Unable to fetch claim: %v", err)
+	}
+
+	if status != account.BONUS_CLAIM_STATUS_SUBMITTED {
+		return uuid.UUID{}, account.ErrBonusClaimNotSubmitted
+	}
+
+	return accountId, nil
+}
@@ -0,0 +1,455 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/internal/customer"
+	"github.com/jameswhoughton/cushon/internal/encryption"
+)
+
+// Number of customers re-encrypted per query when rotating keys
+const KEY_ROTATION_BATCH_SIZE = 100
+
+// Stores customers with their personal data encrypted
+//
+// The address, NI number and date of birth are encrypted with the cipher,
+// bound to the customer's id and column. A blind index of the NI number is
+// stored so customers can be found by NI number and duplicates rejected.
+type CustomerRepository struct {
+	db     *sql.DB
+	cipher *encryption.Cipher
+}
+
+func NewCustomerRepository(conn *sql.DB, cipher *encryption.Cipher) *CustomerRepository {
+	return &CustomerRepository{db: conn, cipher: cipher}
+}
+
+// Encrypted columns of a customer row
+type encryptedCustomer struct {
+	address       string
+	niNumber      string
+	niNumberIndex string
+	dateOfBirth   string
+}
+
+func (r *CustomerRepository) Create(ctx context.Context, c *customer.Customer) error {
+	e, err := r.encrypt(ctx, *c)
+
+	if err != nil {
+		return fmt.Errorf("CustomerRepository.Create: %v", err)
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO customers
+		(id, first_name, last_name, address, email, phone_number, ni_number, ni_number_index, date_of_birth, tax_residency)
+		VALUES (UUID_TO_BIN(?), ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, c.Id.String(), c.FirstName, c.LastName, e.address, c.Email, c.PhoneNumber, e.niNumber, e.niNumberIndex, e.dateOfBirth, c.TaxResidency)
+
+	var mysqlErr *mysql.MySQLError
+
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == ER_DUP_ENTRY {
+		return customer.ErrNINumberInUse
+	}
+
+	if err != nil {
+		return fmt.Errorf("CustomerRepository.Create: Unable to create customer: %v", err)
+	}
+
+	return r.timestamps(ctx, c)
+}
+
+func (r *CustomerRepository) Get(ctx context.Context, id uuid.UUID) (customer.Customer, error) {
+	row := r.db.QueryRowContext(ctx, `
+		SELECT BIN_TO_UUID(id), first_name, last_name, address, email, phone_number, ni_number, date_of_birth, tax_residency, created_at, updated_at
+		FROM customers
+		WHERE id = UUID_TO_BIN(?)
+	`, id)
+
+	c, err := r.scan(ctx, row)
+
+	if err != nil {
+		return customer.Customer{}, fmt.Errorf("CustomerRepository.Get: %w", err)
+	}
+
+	return c, nil
+}
+
+func (r *CustomerRepository) GetByNINumber(ctx context.Context, niNumber string) (customer.Customer, error) {
+	index, err := r.cipher.BlindIndex(ctx, niNumber)
+
+	if err != nil {
+		return customer.Customer{}, fmt.Errorf("CustomerRepository.GetByNINumber: %v", err)
+	}
+
+	row := r.db.QueryRowContext(ctx, `
+		SELECT BIN_TO_UUID(id), first_name, last_name, address, email, phone_number, ni_number, date_of_birth, tax_residency, created_at, updated_at
+		FROM customers
+		WHERE ni_number_index = ?
+	`, index)
+
+	c, err := r.scan(ctx, row)
+
+	if err != nil {
+		return customer.Customer{}, fmt.Errorf("CustomerRepository.GetByNINumber: %w", err)
+	}
+
+	return c, nil
+}
+
+func (r *CustomerRepository) Update(ctx context.Context, c *customer.Customer) error {
+	e, err := r.encrypt(ctx, *c)
+
+	if err != nil {
+		return fmt.Errorf("CustomerRepository.Update: %v", err)
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		UPDATE customers
+		SET first_name = ?, last_name = ?, address = ?, email = ?, phone_number = ?, ni_number = ?, ni_number_index = ?, date_of_birth = ?, tax_residency = ?
+		WHERE id = UUID_TO_BIN(?)
+	`, c.FirstName, c.LastName, e.address, c.Email, c.PhoneNumber, e.niNumber, e.niNumberIndex, e.dateOfBirth, c.TaxResidency, c.Id.String())
+
+	var mysqlErr *mysql.MySQLError
+
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == ER_DUP_ENTRY {
+		return customer.ErrNINumberInUse
+	}
+
+	if err != nil {
+		return fmt.Errorf("CustomerRepository.Update: Unable to update customer: %v", err)
+	}
+
+	// MySQL reports unchanged rows as unaffected, so a missing customer is
+	// picked up when fetching the timestamps instead
+	return r.timestamps(ctx, c)
+}
+
+// Re-encrypt customers whose personal data isn't encrypted with the current key
+//
+// Run after making a new key current, the old key must stay available until
+// this has finished. Returns the number of customers re-encrypted.
+func (r *CustomerRepository) RotateKeys(ctx context.Context) (int, error) {
+	keyId, err := r.cipher.CurrentKeyId(ctx)
+
+	if err != nil {
+		return 0, fmt.Errorf("CustomerRepository.RotateKeys: %v", err)
+	}
+
+	var rotated int
+
+	for {
+		ids, err := r.staleIds(ctx, keyId)
+
+		if err != nil {
+			return rotated, fmt.Errorf("CustomerRepository.RotateKeys: %v", err)
+		}
+
+		if len(ids) == 0 {
+			return rotated, nil
+		}
+
+		for _, id := range ids {
+			err := r.reencrypt(ctx, id)
+
+			if err != nil {
+				return rotated, fmt.Errorf("CustomerRepository.RotateKeys: Unable to re-encrypt customer %s: %v", id, err)
+			}
+
+			rotated++
+		}
+	}
+}
+
+// Encrypt the personal data of customers stored before encryption was added
+//
+// Must be run before the customers can be read, the service runs it on
+// start up. Returns the number of customers encrypted.
+func (r *CustomerRepository) EncryptExisting(ctx context.Context) (int, error) {
+	var encrypted int
+
+	for {
+		ids, err := r.unencryptedIds(ctx)
+
+		if err != nil {
+			return encrypted, fmt.Errorf("CustomerRepository.EncryptExisting: %v", err)
+		}
+
+		if len(ids) == 0 {
+			return encrypted, nil
+		}
+
+		for _, id := range ids {
+			err := r.encryptPlaintext(ctx, id)
+
+			if err != nil {
+				return encrypted, fmt.Errorf("CustomerRepository.EncryptExisting: Unable to encrypt customer %s: %v", id, err)
+			}
+
+			encrypted++
+		}
+	}
+}
+
+// Encrypt a customer's plain text personal data with the current key
+func (r *CustomerRepository) encryptPlaintext(ctx context.Context, id uuid.UUID) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return fmt.Errorf("Unable to start transaction: %v", err)
+	}
+
+	defer tx.Rollback()
+
+	var c customer.Customer
+	var address, dateOfBirth string
+
+	// Skip the customer if it was encrypted since the batch was fetched
+	err = tx.QueryRowContext(ctx, `
+		SELECT BIN_TO_UUID(id), address, ni_number, date_of_birth
+		FROM customers
+		WHERE id = UUID_TO_BIN(?)
+		AND ni_number_index IS NULL
+		FOR UPDATE
+	`, id).Scan(&c.Id, &address, &c.NINumber, &dateOfBirth)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("Unable to fetch customer: %v", err)
+	}
+
+	err = json.Unmarshal([]byte(address), &c.Address)
+
+	if err == nil {
+		c.DateOfBirth, err = time.Parse(time.DateOnly, dateOfBirth)
+	}
+
+	if err != nil {
+		return fmt.Errorf("Unable to parse customer: %v", err)
+	}
+
+	e, err := r.encrypt(ctx, c)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE customers
+		SET address = ?, ni_number = ?, ni_number_index = ?, date_of_birth = ?, updated_at = updated_at
+		WHERE id = UUID_TO_BIN(?)
+	`, e.address, e.niNumber, e.niNumberIndex, e.dateOfBirth, id)
+
+	if err != nil {
+		return fmt.Errorf("Unable to update customer: %v", err)
+	}
+
+	return tx.Commit()
+}
+
+// Return a batch of customers with an NI number encrypted with another key
+//
+// Every encrypted column is written at the same time so the NI number's key
+// is the key for the whole row.
+func (r *CustomerRepository) staleIds(ctx context.Context, keyId string) ([]uuid.UUID, error) {
+	return r.ids(ctx, `
+		SELECT BIN_TO_UUID(id)
+		FROM customers
+		WHERE ni_number_index IS NOT NULL
+		AND SUBSTRING_INDEX(ni_number, ':', 1) <> ?
+		ORDER BY id
+		LIMIT ?
+	`, keyId, KEY_ROTATION_BATCH_SIZE)
+}
+
+// Return a batch of customers stored before their personal data was encrypted
+//
+// These customers don't have an NI number index yet.
+func (r *CustomerRepository) unencryptedIds(ctx context.Context) ([]uuid.UUID, error) {
+	return r.ids(ctx, `
+		SELECT BIN_TO_UUID(id)
+		FROM customers
+		WHERE ni_number_index IS NULL
+		ORDER BY id
+		LIMIT ?
+	`, KEY_ROTATION_BATCH_SIZE)
+}
+
+func (r *CustomerRepository) ids(ctx context.Context, query string, args ...any) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return ids, fmt.Errorf("Unable to fetch customers: %v", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var id uuid.UUID
+
+		if err := rows.Scan(&id); err != nil {
+			return ids, fmt.Errorf("Unable to scan customer: %v", err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// Decrypt and encrypt a customer's personal data with the current key
+//
+// The row is locked so a concurrent update isn't overwritten.
+func (r *CustomerRepository) reencrypt(ctx context.Context, id uuid.UUID) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return fmt.Errorf("Unable to start transaction: %v", err)
+	}
+
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx, `
+		SELECT BIN_TO_UUID(id), first_name, last_name, address, email, phone_number, ni_number, date_of_birth, tax_residency, created_at, updated_at
+		FROM customers
+		WHERE id = UUID_TO_BIN(?)
+		FOR UPDATE
+	`, id)
+
+	c, err := r.scan(ctx, row)
+
+	if err != nil {
+		return err
+	}
+
+	e, err := r.encrypt(ctx, c)
+
+	if err != nil {
+		return err
+	}
+
+	// Leave updated_at alone, the customer's details haven't changed
+	_, err = tx.ExecContext(ctx, `
+		UPDATE customers
+		SET address = ?, ni_number = ?, date_of_birth = ?, updated_at = updated_at
+		WHERE id = UUID_TO_BIN(?)
+	`, e.address, e.niNumber, e.dateOfBirth, id)
+
+	if err != nil {
+		return fmt.Errorf("Unable to update customer: %v", err)
+	}
+
+	return tx.Commit()
+}
+
+// Encrypt the customer's personal data and build the NI number index
+func (r *CustomerRepository) encrypt(ctx context.Context, c customer.Customer) (encryptedCustomer, error) {
+	var e encryptedCustomer
+
+	address, err := json.Marshal(c.Address)
+
+	if err != nil {
+		return e, fmt.Errorf("Unable to encode address: %v", err)
+	}
+
+	e.address, err = r.cipher.Encrypt(ctx, string(address), associatedData("address", c.Id))
+
+	if err == nil {
+		e.niNumber, err = r.cipher.Encrypt(ctx, c.NINumber, associatedData("ni_number", c.Id))
+	}
+
+	if err == nil {
+		e.dateOfBirth, err = r.cipher.Encrypt(ctx, c.DateOfBirth.Format(time.DateOnly), associatedData("date_of_birth", c.Id))
+	}
+
+	if err == nil {
+		e.niNumberIndex, err = r.cipher.BlindIndex(ctx, c.NINumber)
+	}
+
+	if err != nil {
+		return e, fmt.Errorf("Unable to encrypt customer: %v", err)
+	}
+
+	return e, nil
+}
+
+// Scan a customer row and decrypt its personal data
+//
+// Returns ErrCustomerNotFound if there is no row.
+func (r *CustomerRepository) scan(ctx context.Context, row *sql.Row) (customer.Customer, error) {
+	var c customer.Customer
+	var e encryptedCustomer
+
+	err := row.Scan(&c.Id, &c.FirstName, &c.LastName, &e.address, &c.Email, &c.PhoneNumber, &e.niNumber, &e.dateOfBirth, &c.TaxResidency, &c.CreatedAt, &c.UpdatedAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return customer.Customer{}, customer.ErrCustomerNotFound
+	}
+
+	if err != nil {
+		return customer.Customer{}, fmt.Errorf("Unable to fetch customer: %v", err)
+	}
+
+	address, err := r.cipher.Decrypt(ctx, e.address, associatedData("address", c.Id))
+
+	if err == nil {
+		err = json.Unmarshal([]byte(address), &c.Address)
+	}
+
+	if err == nil {
+		c.NINumber, err = r.cipher.Decrypt(ctx, e.niNumber, associatedData("ni_number", c.Id))
+	}
+
+	var dateOfBirth string
+
+	if err == nil {
+		dateOfBirth, err = r.cipher.Decrypt(ctx, e.dateOfBirth, associatedData("date_of_birth", c.Id))
+	}
+
+	if err == nil {
+		c.DateOfBirth, err = time.Parse(time.DateOnly, dateOfBirth)
+	}
+
+	if err != nil {
+		return customer.Customer{}, fmt.Errorf("Unable to decrypt customer %s: %v", c.Id, err)
+	}
+
+	return c, nil
+}
+
+// Bind an encrypted value to the column and customer it belongs to
+func associatedData(column string, id uuid.UUID) string {
+	return "customers." + column + ":" + id.String()
+}
+
+// Populate the customer's timestamps from the stored row
+func (r *CustomerRepository) timestamps(ctx context.Context, c *customer.Customer) error {
+	row := r.db.QueryRowContext(ctx, `
+		SELECT created_at, updated_at
+		FROM customers
+		WHERE id = UUID_TO_BIN(?)
+	`, c.Id.String())
+
+	err := row.Scan(&c.CreatedAt, &c.UpdatedAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return customer.ErrCustomerNotFound
+	}
+
+	if err != nil {
+		return fmt.Errorf("CustomerRepository: Unable to fetch timestamps: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,19 @@
+CREATE TABLE customers (
+	id BINARY(16) NOT NULL,
+	first_name VARCHAR(255) NOT NULL,
+	last_name VARCHAR(255) NOT NULL,
+	address_line_1 VARCHAR(255) NOT NULL,
+	address_line_2 VARCHAR(255) NOT NULL DEFAULT '',
+	town VARCHAR(255) NOT NULL,
+	postcode VARCHAR(10) NOT NULL,
+	country CHAR(2) NOT NULL,
+	email VARCHAR(255) NOT NULL,
+	phone_number VARCHAR(20) NOT NULL,
+	ni_number CHAR(9) NOT NULL,
+	date_of_birth DATE NOT NULL,
+	tax_residency CHAR(2) NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+	PRIMARY KEY (id),
+	UNIQUE (ni_number)
+);
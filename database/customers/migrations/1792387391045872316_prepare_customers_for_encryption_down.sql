@@ -0,0 +1,7 @@
+ALTER TABLE customers
+	DROP INDEX ni_number_index,
+	DROP COLUMN ni_number_index,
+	DROP COLUMN address,
+	MODIFY COLUMN ni_number CHAR(9) NOT NULL,
+	MODIFY COLUMN date_of_birth DATE NOT NULL,
+	ADD UNIQUE (ni_number);
@@ -0,0 +1,7 @@
+ALTER TABLE customers
+	ADD COLUMN address TEXT NULL AFTER last_name,
+	MODIFY COLUMN ni_number VARCHAR(255) NOT NULL,
+	ADD COLUMN ni_number_index CHAR(64) NULL AFTER ni_number, -- NULL until the customer is encrypted
+	MODIFY COLUMN date_of_birth VARCHAR(255) NOT NULL,
+	DROP INDEX ni_number,
+	ADD UNIQUE (ni_number_index);
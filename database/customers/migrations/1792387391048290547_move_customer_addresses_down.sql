@@ -0,0 +1,2 @@
+UPDATE customers
+SET address_line_1 = address->>'$.line_1', address_line_2 = address->>'$.line_2', town = address->>'$.town', postcode = address->>'$.postcode', country = address->>'$.country';
@@ -0,0 +1,2 @@
+UPDATE customers
+SET address = JSON_OBJECT('line_1', address_line_1, 'line_2', address_line_2, 'town', town, 'postcode', postcode, 'country', country);
@@ -0,0 +1,7 @@
+ALTER TABLE customers
+	MODIFY COLUMN address TEXT NULL,
+	ADD COLUMN address_line_1 VARCHAR(255) NOT NULL DEFAULT '' AFTER address,
+	ADD COLUMN address_line_2 VARCHAR(255) NOT NULL DEFAULT '' AFTER address_line_1,
+	ADD COLUMN town VARCHAR(255) NOT NULL DEFAULT '' AFTER address_line_2,
+	ADD COLUMN postcode VARCHAR(10) NOT NULL DEFAULT '' AFTER town,
+	ADD COLUMN country CHAR(2) NOT NULL DEFAULT '' AFTER postcode;
@@ -0,0 +1,7 @@
+ALTER TABLE customers
+	MODIFY COLUMN address TEXT NOT NULL,
+	DROP COLUMN address_line_1,
+	DROP COLUMN address_line_2,
+	DROP COLUMN town,
+	DROP COLUMN postcode,
+	DROP COLUMN country;
@@ -0,0 +1,252 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/internal/account"
+)
+
+type DepositRepository struct {
+	db *sql.DB
+}
+
+func NewDepositRepository(conn *sql.DB) *DepositRepository {
+	return &DepositRepository{db: conn}
+}
+
+func (r *DepositRepository) CreateDeposit(ctx context.Context, deposit account.Deposit) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return fmt.Errorf("DepositRepository.CreateDeposit: Unable to start transaction: %v", err)
+	}
+
+	defer tx.Rollback()
+
+	// One-off deposits have no reference, NULLs aren't checked by the unique
+	// index. Failed deposits are left out of the index so their reference can
+	// be used again.
+	reference := sql.NullString{String: deposit.Reference, Valid: deposit.Reference != ""}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO deposits
+		(id, account_id, amount, payment_method, payment_reference, status, reference)
+		VALUES (UUID_TO_BIN(?), UUID_TO_BIN(?), ?, ?, ?, ?, ?)
+	`, deposit.Id, deposit.AccountId, deposit.Amount, deposit.PaymentMethod, deposit.PaymentReference, deposit.Status, reference)
+
+	var mysqlErr *mysql.MySQLError
+
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == ER_DUP_ENTRY {
+		return account.ErrDepositDuplicate
+	}
+
+	if err != nil {
+		return fmt.Errorf("DepositRepository.CreateDeposit: Unable to create deposit: %v", err)
+	}
+
+	for i, allocation := range deposit.Allocations {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO deposit_allocations
+			(deposit_id, fund_id, percentage, position)
+			VALUES (UUID_TO_BIN(?), UUID_TO_BIN(?), ?, ?)
+		`, deposit.Id, allocation.FundId, allocation.Percentage, i)
+
+		if err != nil {
+			return fmt.Errorf("DepositRepository.CreateDeposit: Unable to create deposit allocation: %v", err)
+		}
+	}
+
+	err = tx.Commit()
+
+	if err != nil {
+		return fmt.Errorf("DepositRepository.CreateDeposit: Unable to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+func (r *DepositRepository) GetDeposit(ctx context.Context, depositId uuid.UUID) (account.Deposit, error) {
+	deposits, err := r.queryDeposits(ctx, `WHERE d.id = UUID_TO_BIN(?)`, depositId)
+
+	if err != nil {
+		return account.Deposit{}, fmt.Errorf("DepositRepository.GetDeposit: %v", err)
+	}
+
+	if len(deposits) == 0 {
+		return account.Deposit{}, account.ErrDepositNotFound
+	}
+
+	return deposits[0], nil
+}
+
+func (r *DepositRepository) GetDepositByReference(ctx context.Context, reference string) (account.Deposit, error) {
+	deposits, err := r.queryDeposits(ctx, `
+		WHERE d.id = (
+			SELECT id FROM deposits
+			WHERE reference = ?
+			ORDER BY status = ?, created_at DESC
+			LIMIT 1
+		)
+	`, reference, account.DEPOSIT_STATUS_FAILED)
+
+	if err != nil {
+		return account.Deposit{}, fmt.Errorf("DepositRepository.GetDepositByReference: %v", err)
+	}
+
+	if len(deposits) == 0 {
+		return account.Deposit{}, account.ErrDepositNotFound
+	}
+
+	return deposits[0], nil
+}
+
+func (r *DepositRepository) GetPendingDeposits(ctx context.Context) ([]account.Deposit, error) {
+	deposits, err := r.queryDeposits(ctx, `WHERE d.status IN (?, ?)`, account.DEPOSIT_STATUS_INITIATED, account.DEPOSIT_STATUS_AUTHORISED)
+
+	if err != nil {
+		return []account.Deposit{}, fmt.Errorf("DepositRepository.GetPendingDeposits: %v", err)
+	}
+
+	return deposits, nil
+}
+
+func (r *DepositRepository) UpdateDeposit(ctx context.Context, deposit account.Deposit) error {
+	// Only update deposits that are still pending, this stops a deposit that
+	// has just settled being marked as failed if two updates race.
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE deposits
+		SET status = ?, payment_reference = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = UUID_TO_BIN(?)
+		AND status IN (?, ?)
+	`, deposit.Status, deposit.PaymentReference, deposit.Id, account.DEPOSIT_STATUS_INITIATED, account.DEPOSIT_STATUS_AUTHORISED)
+
+	if err != nil {
+		return fmt.Errorf("DepositRepository.UpdateDeposit: Unable to update deposit: %v", err)
+	}
+
+	updated, err := result.RowsAffected()
+
+	if err != nil {
+		return fmt.Errorf("DepositRepository.UpdateDeposit: Unable to update deposit: %v", err)
+	}
+
+	if updated == 0 {
+		return account.ErrDepositStatusInvalid
+	}
+
+	return nil
+}
+
+func (r *DepositRepository) SettleDeposit(ctx context.Context, deposit account.Deposit, investments []account.Investment) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return fmt.Errorf("DepositRepository.SettleDeposit: Unable to start transaction: %v", err)
+	}
+
+	defer tx.Rollback()
+
+	// Only settle deposits that are still pending, this guards against the
+	// investments being made twice if two updates race.
+	result, err := tx.ExecContext(ctx, `
+		UPDATE deposits
+		SET status = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = UUID_TO_BIN(?)
+		AND status IN (?, ?)
+	`, account.DEPOSIT_STATUS_SETTLED, deposit.Id, account.DEPOSIT_STATUS_INITIATED, account.DEPOSIT_STATUS_AUTHORISED)
+
+	if err != nil {
+		return fmt.Errorf("DepositRepository.SettleDeposit: Unable to update deposit: %v", err)
+	}
+
+	updated, err := result.RowsAffected()
+
+	if err != nil {
+		return fmt.Errorf("DepositRepository.SettleDeposit: Unable to update deposit: %v", err)
+	}
+
+	if updated == 0 {
+		return account.ErrDepositStatusInvalid
+	}
+
+	err = invest(ctx, tx, deposit.AccountId, investments)
+
+	if err != nil {
+		return fmt.Errorf("DepositRepository.SettleDeposit: %v", err)
+	}
+
+	// The money was subscribed when the deposit was made, which may have been
+	// in the previous tax year
+	for _, investment := range investments {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE fund_transactions
+			SET subscribed_at = ?
+			WHERE trade_id = UUID_TO_BIN(?)
+		`, deposit.CreatedAt, investment.TradeId)
+
+		if err != nil {
+			return fmt.Errorf("DepositRepository.SettleDeposit: Unable to date subscription: %v", err)
+		}
+	}
+
+	err = tx.Commit()
+
+	if err != nil {
+		return fmt.Errorf("DepositRepository.SettleDeposit: Unable to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+// Fetch deposits along with their allocations
+//
+// The where clause is appended to the query, allocations are returned
+// in the order they were created.
+func (r *DepositRepository) queryDeposits(ctx context.Context, where string, args ...any) ([]account.Deposit, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT BIN_TO_UUID(d.id), BIN_TO_UUID(d.account_id), d.amount, d.payment_method, COALESCE(d.payment_reference, ''), d.status, COALESCE(d.reference, ''), d.created_at,
+		BIN_TO_UUID(a.fund_id), a.percentage
+		FROM deposits d
+		JOIN deposit_allocations a
+		ON d.id = a.deposit_id
+		`+where+`
+		ORDER BY d.created_at, d.id, a.position
+	`, args...)
+
+	if err != nil {
+		return []account.Deposit{}, fmt.Errorf("Unable to fetch deposits: %v", err)
+	}
+
+	defer rows.Close()
+
+	var deposits []account.Deposit
+
+	for rows.Next() {
+		var deposit account.Deposit
+		var allocation account.Allocation
+
+		err := rows.Scan(&deposit.Id, &deposit.AccountId, &deposit.Amount, &deposit.PaymentMethod, &deposit.PaymentReference, &deposit.Status, &deposit.Reference, &deposit.CreatedAt, &allocation.FundId, &allocation.Percentage)
+
+		if err != nil {
+			return []account.Deposit{}, fmt.Errorf("Unable to fetch deposits: %v", err)
+		}
+
+		// Rows are ordered by deposit so each allocation belongs to either the
+		// previous deposit or a new one.
+		if len(deposits) > 0 && deposits[len(deposits)-1].Id == deposit.Id {
+			deposits[len(deposits)-1].Allocations = append(deposits[len(deposits)-1].Allocations, allocation)
+			continue
+		}
+
+		deposit.Allocations = []account.Allocation{allocation}
+
+		deposits = append(deposits, deposit)
+	}
+
+	return deposits, nil
+}
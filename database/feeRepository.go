@@ -0,0 +1,138 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/internal/account"
+)
+
+type FeeRepository struct {
+	db *sql.DB
+}
+
+func NewFeeRepository(conn *sql.DB) *FeeRepository {
+	return &FeeRepository{db: conn}
+}
+
+func (r *FeeRepository) GetChargeableAccounts(ctx context.Context) ([]uuid.UUID, error) {
+	var accountIds []uuid.UUID
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT BIN_TO_UUID(account_id) FROM account_funds WHERE balance > 0
+		UNION
+		SELECT BIN_TO_UUID(account_id) FROM cash_transactions GROUP BY account_id HAVING SUM(amount) > 0
+	`)
+
+	if err != nil {
+		return []uuid.UUID{}, fmt.Errorf("FeeRepository.GetChargeableAccounts: Unable to fetch accounts: %v", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var accountId uuid.UUID
+
+		err := rows.Scan(&accountId)
+
+		if err != nil {
+			return []uuid.UUID{}, fmt.Errorf("FeeRepository.GetChargeableAccounts: Unable to fetch accounts: %v", err)
+		}
+
+		accountIds = append(accountIds, accountId)
+	}
+
+	return accountIds, nil
+}
+
+func (r *FeeRepository) ChargeFee(ctx context.Context, charge account.FeeCharge) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return fmt.Errorf("FeeRepository.ChargeFee: Unable to start transaction: %v", err)
+	}
+
+	defer tx.Rollback()
+
+	// The unique key on the account and period stops an account being
+	// charged twice for the same month.
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO fee_charges
+		(id, account_id, period, holdings_value, amount, from_cash)
+		VALUES (UUID_TO_BIN(?), UUID_TO_BIN(?), ?, ?, ?, ?)
+	`, charge.Id, charge.AccountId, charge.Period.Format(time.DateOnly), charge.HoldingsValue, charge.Amount, charge.FromCash)
+
+	var mysqlErr *mysql.MySQLError
+
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == ER_DUP_ENTRY {
+		return account.ErrFeeAlreadyCharged
+	}
+
+	if err != nil {
+		return fmt.Errorf("FeeRepository.ChargeFee: Unable to create fee charge: %v", err)
+	}
+
+	if charge.FromCash > 0 {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO cash_transactions
+			(account_id, reference, transaction_type, amount)
+			VALUES (UUID_TO_BIN(?), UUID_TO_BIN(?), ?, ?)
+		`, charge.AccountId, charge.Id, account.TRANSACTION_TYPE_FEE, -charge.FromCash)
+
+		if err != nil {
+			return fmt.Errorf("FeeRepository.ChargeFee: Unable to debit cash: %v", err)
+		}
+	}
+
+	err = invest(ctx, tx, charge.AccountId, charge.Sales)
+
+	if err != nil {
+		return fmt.Errorf("FeeRepository.ChargeFee: %v", err)
+	}
+
+	err = tx.Commit()
+
+	if err != nil {
+		return fmt.Errorf("FeeRepository.ChargeFee: Unable to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+func (r *FeeRepository) GetFeeCharges(ctx context.Context, accountId uuid.UUID, from time.Time, to time.Time) ([]account.FeeCharge, error) {
+	var charges []account.FeeCharge
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT BIN_TO_UUID(id), BIN_TO_UUID(account_id), period, holdings_value, amount, from_cash
+		FROM fee_charges
+		WHERE account_id = UUID_TO_BIN(?)
+		AND period >= ?
+		AND period <= ?
+		ORDER BY period
+	`, accountId, from.Format(time.DateOnly), to.Format(time.DateOnly))
+
+	if err != nil {
+		return []account.FeeCharge{}, fmt.Errorf("FeeRepository.GetFeeCharges: Unable to fetch fee charges: %v", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var charge account.FeeCharge
+
+		err := rows.Scan(&charge.Id, &charge.AccountId, &charge.Period, &charge.HoldingsValue, &charge.Amount, &charge.FromCash)
+
+		if err != nil {
+			return []account.FeeCharge{}, fmt.Errorf("FeeRepository.GetFeeCharges: Unable to fetch fee charges: %v", err)
+		}
+
+		charges = append(charges, charge)
+	}
+
+	return charges, nil
+}
@@ -0,0 +1,105 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/internal/fund"
+)
+
+type FundRepository struct {
+	db *sql.DB
+}
+
+func NewFundRepository(conn *sql.DB) *FundRepository {
+	return &FundRepository{db: conn}
+}
+
+func (r *FundRepository) Create(ctx context.Context, f fund.Fund) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return fmt.Errorf("FundRepository.Create: Unable to start transaction: %v", err)
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO funds
+		(id, name, status, risk_rating)
+		VALUES (UUID_TO_BIN(?), ?, ?, ?)
+	`, f.Id.String(), f.Name, f.Status, f.RiskRating)
+
+	if err != nil {
+		return fmt.Errorf("FundRepository.Create: Unable to create fund: %v", err)
+	}
+
+	for _, accountType := range f.AccountTypes {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO fund_account_types
+			(fund_id, account_type)
+			VALUES (UUID_TO_BIN(?), ?)
+		`, f.Id.String(), accountType)
+
+		if err != nil {
+			return fmt.Errorf("FundRepository.Create: Unable to add fund account type: %v", err)
+		}
+	}
+
+	err = tx.Commit()
+
+	if err != nil {
+		return fmt.Errorf("FundRepository.Create: Unable to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+func (r *FundRepository) Fund(ctx context.Context, id uuid.UUID) (fund.Fund, error) {
+	var f fund.Fund
+
+	row := r.db.QueryRowContext(ctx, `
+		SELECT BIN_TO_UUID(id), name, status, risk_rating
+		FROM funds
+		WHERE id = UUID_TO_BIN(?)
+	`, id.String())
+
+	err := row.Scan(&f.Id, &f.Name, &f.Status, &f.RiskRating)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return fund.Fund{}, fund.ErrFundNotFound
+	}
+
+	if err != nil {
+		return fund.Fund{}, fmt.Errorf("FundRepository.Fund: Unable to fetch fund: %v", err)
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT account_type
+		FROM fund_account_types
+		WHERE fund_id = UUID_TO_BIN(?)
+	`, id.String())
+
+	if err != nil {
+		return fund.Fund{}, fmt.Errorf("FundRepository.Fund: Unable to fetch fund account types: %v", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var accountType string
+
+		err := rows.Scan(&accountType)
+
+		if err != nil {
+			return fund.Fund{}, fmt.Errorf("FundRepository.Fund: Unable to fetch fund account types: %v", err)
+		}
+
+		f.AccountTypes = append(f.AccountTypes, accountType)
+	}
+
+	return f, nil
+}
@@ -11,17 +11,49 @@ import (
 //go:embed migrations/*.sql
 var migrationFiles embed.FS
 
+//go:embed customers/migrations/*.sql
+var customerMigrationFiles embed.FS
+
 func Migrate(conn *sql.DB) error {
+	return MigrateFS(conn, migrationFiles)
+}
+
+func Rollback(conn *sql.DB) error {
+	return RollbackFS(conn, migrationFiles)
+}
+
+// Migrate the Retail Customer Service database
+func MigrateCustomers(conn *sql.DB) error {
+	files, err := fs.Sub(customerMigrationFiles, "customers")
+
+	if err != nil {
+		return err
+	}
 
-	migrationFS := fs.FS(migrationFiles)
+	return MigrateFS(conn, files)
+}
+
+func RollbackCustomers(conn *sql.DB) error {
+	files, err := fs.Sub(customerMigrationFiles, "customers")
+
+	if err != nil {
+		return err
+	}
+
+	return RollbackFS(conn, files)
+}
 
+// Run the migrations in the migrations directory of the file system
+//
+// Used by each service to migrate its own database.
+func MigrateFS(conn *sql.DB, files fs.FS) error {
 	migrationLog, err := migrate.NewLogMySQL(conn)
 
 	if err != nil {
 		return err
 	}
 
-	migrationsFiles, err := fs.Sub(migrationFS, "migrations")
+	migrationsFiles, err := fs.Sub(files, "migrations")
 
 	if err != nil {
 		return err
@@ -36,16 +68,15 @@ func Migrate(conn *sql.DB) error {
 	return nil
 }
 
-func Rollback(conn *sql.DB) error {
-	migrationFS := fs.FS(migrationFiles)
-
+// Roll back the migrations in the migrations directory of the file system
+func RollbackFS(conn *sql.DB, files fs.FS) error {
 	migrationLog, err := migrate.NewLogMySQL(conn)
 
 	if err != nil {
 		return err
 	}
 
-	migrationsFiles, err := fs.Sub(migrationFS, "migrations")
+	migrationsFiles, err := fs.Sub(files, "migrations")
 
 	if err != nil {
 		return err
@@ -0,0 +1 @@
+DROP TABLE fund_prices;
@@ -0,0 +1,9 @@
+CREATE TABLE fund_prices (
+	id INT NOT NULL AUTO_INCREMENT,
+	fund_id BINARY(16) NOT NULL,
+	price_date DATE NOT NULL,
+	price INT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (id),
+	UNIQUE KEY (fund_id, price_date)
+);
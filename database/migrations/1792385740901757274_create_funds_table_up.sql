@@ -0,0 +1,9 @@
+CREATE TABLE funds (
+	id BINARY(16) NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	status VARCHAR(15) NOT NULL,
+	risk_rating TINYINT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (id)
+);
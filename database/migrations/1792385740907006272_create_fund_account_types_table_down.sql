@@ -0,0 +1 @@
+DROP TABLE fund_account_types;
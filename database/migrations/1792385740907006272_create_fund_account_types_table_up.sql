@@ -0,0 +1,7 @@
+CREATE TABLE fund_account_types (
+	fund_id BINARY(16) NOT NULL,
+	account_type VARCHAR(10) NOT NULL,
+	PRIMARY KEY (fund_id, account_type),
+	FOREIGN KEY (fund_id)
+		REFERENCES funds(id)
+);
@@ -0,0 +1,15 @@
+CREATE TABLE investment_plans (
+	id BINARY(16) NOT NULL,
+	account_id BINARY(16) NOT NULL,
+	amount INT NOT NULL,
+	day_of_month TINYINT NOT NULL,
+	start_date DATE NOT NULL,
+	end_date DATE NULL,
+	status VARCHAR(10) NOT NULL,
+	last_run_on DATE NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (id),
+	FOREIGN KEY (account_id)
+		REFERENCES accounts(id)
+);
@@ -0,0 +1 @@
+DROP TABLE investment_plan_allocations;
@@ -0,0 +1,10 @@
+CREATE TABLE investment_plan_allocations (
+	plan_id BINARY(16) NOT NULL,
+	fund_id BINARY(16) NOT NULL,
+	percentage TINYINT NOT NULL,
+	position TINYINT NOT NULL,
+	PRIMARY KEY (plan_id, fund_id),
+	FOREIGN KEY (plan_id)
+		REFERENCES investment_plans(id)
+		ON DELETE CASCADE
+);
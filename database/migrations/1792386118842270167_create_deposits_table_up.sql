@@ -0,0 +1,14 @@
+CREATE TABLE deposits (
+	id BINARY(16) NOT NULL,
+	account_id BINARY(16) NOT NULL,
+	amount INT NOT NULL,
+	payment_method VARCHAR(25) NOT NULL,
+	payment_reference VARCHAR(255) NULL,
+	status VARCHAR(15) NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (id),
+	INDEX (status),
+	FOREIGN KEY (account_id)
+		REFERENCES accounts(id)
+);
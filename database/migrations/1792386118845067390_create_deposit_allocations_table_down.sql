@@ -0,0 +1 @@
+DROP TABLE deposit_allocations;
@@ -0,0 +1,9 @@
+CREATE TABLE deposit_allocations (
+	deposit_id BINARY(16) NOT NULL,
+	fund_id BINARY(16) NOT NULL,
+	percentage TINYINT NOT NULL,
+	position TINYINT NOT NULL,
+	PRIMARY KEY (deposit_id, fund_id),
+	FOREIGN KEY (deposit_id)
+		REFERENCES deposits(id)
+);
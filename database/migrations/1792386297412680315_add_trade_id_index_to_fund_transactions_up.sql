@@ -0,0 +1 @@
+ALTER TABLE fund_transactions ADD INDEX trade_id (trade_id);
@@ -0,0 +1 @@
+ALTER TABLE accounts ADD COLUMN income_preference VARCHAR(10) NOT NULL DEFAULT 'cash' AFTER account_type;
@@ -0,0 +1 @@
+DROP TABLE cash_transactions;
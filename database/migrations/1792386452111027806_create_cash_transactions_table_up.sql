@@ -0,0 +1,14 @@
+CREATE TABLE cash_transactions (
+	id INT NOT NULL AUTO_INCREMENT,
+	account_id BINARY(16) NOT NULL,
+	reference BINARY(16) NOT NULL, -- Assumed to be UUID
+	fund_id BINARY(16) NULL,
+	transaction_type VARCHAR(25) NOT NULL,
+	amount INT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (id),
+	UNIQUE (reference, transaction_type),
+	INDEX (account_id),
+	FOREIGN KEY (account_id)
+		REFERENCES accounts(id)
+);
@@ -0,0 +1,14 @@
+CREATE TABLE payout_instructions (
+	id INT NOT NULL AUTO_INCREMENT,
+	account_id BINARY(16) NOT NULL,
+	reference BINARY(16) NOT NULL, -- Assumed to be UUID
+	amount INT NOT NULL,
+	status VARCHAR(10) NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (id),
+	UNIQUE (reference),
+	INDEX (status),
+	FOREIGN KEY (account_id)
+		REFERENCES accounts(id)
+);
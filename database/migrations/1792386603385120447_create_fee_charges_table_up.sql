@@ -0,0 +1,13 @@
+CREATE TABLE fee_charges (
+	id BINARY(16) NOT NULL,
+	account_id BINARY(16) NOT NULL,
+	period DATE NOT NULL,
+	holdings_value INT NOT NULL,
+	amount INT NOT NULL,
+	from_cash INT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (id),
+	UNIQUE (account_id, period),
+	FOREIGN KEY (account_id)
+		REFERENCES accounts(id)
+);
@@ -0,0 +1,12 @@
+CREATE TABLE statements (
+	id INT NOT NULL AUTO_INCREMENT,
+	account_id BINARY(16) NOT NULL,
+	tax_year SMALLINT NOT NULL,
+	csv MEDIUMBLOB NOT NULL,
+	pdf MEDIUMBLOB NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (id),
+	UNIQUE (account_id, tax_year),
+	FOREIGN KEY (account_id)
+		REFERENCES accounts(id)
+);
@@ -0,0 +1 @@
+ALTER TABLE accounts DROP INDEX customer_id;
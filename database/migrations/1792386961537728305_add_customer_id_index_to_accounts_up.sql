@@ -0,0 +1 @@
+ALTER TABLE accounts ADD INDEX customer_id (customer_id);
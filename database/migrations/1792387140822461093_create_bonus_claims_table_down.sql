@@ -0,0 +1 @@
+DROP TABLE bonus_claims;
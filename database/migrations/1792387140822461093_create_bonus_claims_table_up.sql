@@ -0,0 +1,17 @@
+CREATE TABLE bonus_claims (
+	id BINARY(16) NOT NULL,
+	account_id BINARY(16) NOT NULL,
+	period_from DATETIME NOT NULL,
+	period_to DATETIME NOT NULL,
+	contributions INT NOT NULL,
+	bonus INT NOT NULL,
+	status VARCHAR(25) NOT NULL,
+	amount INT NOT NULL DEFAULT 0,
+	reason VARCHAR(255) NOT NULL DEFAULT '',
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+	PRIMARY KEY (id),
+	UNIQUE (account_id, period_to),
+	FOREIGN KEY (account_id)
+		REFERENCES accounts(id)
+);
@@ -0,0 +1 @@
+UPDATE fund_transactions t JOIN (SELECT id FROM (SELECT id, ROW_NUMBER() OVER (PARTITION BY trade_id ORDER BY id) AS n FROM fund_transactions WHERE trade_id IS NOT NULL) r WHERE n > 1) d ON t.id = d.id SET t.trade_id = UUID_TO_BIN(UUID());
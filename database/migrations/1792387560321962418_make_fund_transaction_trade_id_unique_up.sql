@@ -0,0 +1 @@
+ALTER TABLE fund_transactions DROP INDEX trade_id, ADD UNIQUE INDEX trade_id (trade_id);
@@ -0,0 +1 @@
+ALTER TABLE fund_transactions DROP COLUMN subscribed_at;
@@ -0,0 +1 @@
+ALTER TABLE fund_transactions ADD COLUMN subscribed_at DATETIME NULL;
@@ -0,0 +1 @@
+ALTER TABLE deposits DROP INDEX reference, DROP COLUMN reference;
@@ -0,0 +1 @@
+ALTER TABLE deposits ADD COLUMN reference VARCHAR(255) NULL, ADD UNIQUE INDEX reference (reference);
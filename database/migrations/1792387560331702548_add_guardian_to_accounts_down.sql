@@ -0,0 +1 @@
+ALTER TABLE accounts DROP COLUMN guardian_id, DROP COLUMN converted_at;
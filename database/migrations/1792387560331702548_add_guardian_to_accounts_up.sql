@@ -0,0 +1 @@
+ALTER TABLE accounts ADD COLUMN guardian_id BINARY(16) NULL AFTER customer_id, ADD COLUMN converted_at DATETIME NULL;
@@ -0,0 +1 @@
+ALTER TABLE deposits DROP INDEX pending_reference, DROP COLUMN pending_reference, ADD UNIQUE INDEX reference (reference);
@@ -0,0 +1 @@
+ALTER TABLE deposits DROP INDEX reference, ADD COLUMN pending_reference VARCHAR(255) AS (IF(status = 'failed', NULL, reference)) STORED, ADD UNIQUE INDEX pending_reference (pending_reference);
@@ -0,0 +1,225 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/internal/account"
+)
+
+type PlanRepository struct {
+	db *sql.DB
+}
+
+func NewPlanRepository(conn *sql.DB) *PlanRepository {
+	return &PlanRepository{db: conn}
+}
+
+func (r *PlanRepository) CreatePlan(ctx context.Context, plan *account.Plan) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return fmt.Errorf("PlanRepository.CreatePlan: Unable to start transaction: %v", err)
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO investment_plans
+		(id, account_id, amount, day_of_month, start_date, end_date, status, last_run_on)
+		VALUES (UUID_TO_BIN(?), UUID_TO_BIN(?), ?, ?, ?, ?, ?, ?)
+	`, plan.Id, plan.AccountId, plan.Amount, plan.DayOfMonth, plan.StartDate, nullTime(plan.EndDate), plan.Status, nullTime(plan.LastRunOn))
+
+	if err != nil {
+		return fmt.Errorf("PlanRepository.CreatePlan: Unable to create plan: %v", err)
+	}
+
+	err = insertPlanAllocations(ctx, tx, *plan)
+
+	if err != nil {
+		return fmt.Errorf("PlanRepository.CreatePlan: %v", err)
+	}
+
+	err = tx.Commit()
+
+	if err != nil {
+		return fmt.Errorf("PlanRepository.CreatePlan: Unable to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+func (r *PlanRepository) GetPlan(ctx context.Context, planId uuid.UUID) (account.Plan, error) {
+	plans, err := r.queryPlans(ctx, `WHERE p.id = UUID_TO_BIN(?)`, planId)
+
+	if err != nil {
+		return account.Plan{}, fmt.Errorf("PlanRepository.GetPlan: %v", err)
+	}
+
+	if len(plans) == 0 {
+		return account.Plan{}, account.ErrPlanNotFound
+	}
+
+	return plans[0], nil
+}
+
+func (r *PlanRepository) GetAccountPlans(ctx context.Context, accountId uuid.UUID) ([]account.Plan, error) {
+	plans, err := r.queryPlans(ctx, `WHERE p.account_id = UUID_TO_BIN(?)`, accountId)
+
+	if err != nil {
+		return []account.Plan{}, fmt.Errorf("PlanRepository.GetAccountPlans: %v", err)
+	}
+
+	return plans, nil
+}
+
+func (r *PlanRepository) GetActivePlans(ctx context.Context, date time.Time) ([]account.Plan, error) {
+	plans, err := r.queryPlans(ctx, `WHERE p.status = ? AND p.start_date <= ?`, account.PLAN_STATUS_ACTIVE, date)
+
+	if err != nil {
+		return []account.Plan{}, fmt.Errorf("PlanRepository.GetActivePlans: %v", err)
+	}
+
+	return plans, nil
+}
+
+func (r *PlanRepository) UpdatePlan(ctx context.Context, plan account.Plan) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return fmt.Errorf("PlanRepository.UpdatePlan: Unable to start transaction: %v", err)
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE investment_plans
+		SET amount = ?, day_of_month = ?, start_date = ?, end_date = ?, status = ?, last_run_on = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = UUID_TO_BIN(?)
+	`, plan.Amount, plan.DayOfMonth, plan.StartDate, nullTime(plan.EndDate), plan.Status, nullTime(plan.LastRunOn), plan.Id)
+
+	if err != nil {
+		return fmt.Errorf("PlanRepository.UpdatePlan: Unable to update plan: %v", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM investment_plan_allocations
+		WHERE plan_id = UUID_TO_BIN(?)
+	`, plan.Id)
+
+	if err != nil {
+		return fmt.Errorf("PlanRepository.UpdatePlan: Unable to remove plan allocations: %v", err)
+	}
+
+	err = insertPlanAllocations(ctx, tx, plan)
+
+	if err != nil {
+		return fmt.Errorf("PlanRepository.UpdatePlan: %v", err)
+	}
+
+	err = tx.Commit()
+
+	if err != nil {
+		return fmt.Errorf("PlanRepository.UpdatePlan: Unable to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+func (r *PlanRepository) DeletePlan(ctx context.Context, planId uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM investment_plans
+		WHERE id = UUID_TO_BIN(?)
+	`, planId)
+
+	if err != nil {
+		return fmt.Errorf("PlanRepository.DeletePlan: Unable to delete plan: %v", err)
+	}
+
+	deleted, err := result.RowsAffected()
+
+	if err != nil {
+		return fmt.Errorf("PlanRepository.DeletePlan: Unable to delete plan: %v", err)
+	}
+
+	if deleted == 0 {
+		return account.ErrPlanNotFound
+	}
+
+	return nil
+}
+
+// Fetch plans along with their allocations
+//
+// The where clause is appended to the query, allocations are returned
+// in the order they were created.
+func (r *PlanRepository) queryPlans(ctx context.Context, where string, args ...any) ([]account.Plan, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT BIN_TO_UUID(p.id), BIN_TO_UUID(p.account_id), p.amount, p.day_of_month, p.start_date, p.end_date, p.status, p.last_run_on,
+		BIN_TO_UUID(a.fund_id), a.percentage
+		FROM investment_plans p
+		JOIN investment_plan_allocations a
+		ON p.id = a.plan_id
+		`+where+`
+		ORDER BY p.created_at, p.id, a.position
+	`, args...)
+
+	if err != nil {
+		return []account.Plan{}, fmt.Errorf("Unable to fetch plans: %v", err)
+	}
+
+	defer rows.Close()
+
+	var plans []account.Plan
+
+	for rows.Next() {
+		var plan account.Plan
+		var endDate, lastRunOn sql.NullTime
+		var allocation account.Allocation
+
+		err := rows.Scan(&plan.Id, &plan.AccountId, &plan.Amount, &plan.DayOfMonth, &plan.StartDate, &endDate, &plan.Status, &lastRunOn, &allocation.FundId, &allocation.Percentage)
+
+		if err != nil {
+			return []account.Plan{}, fmt.Errorf("Unable to fetch plans: %v", err)
+		}
+
+		// Rows are ordered by plan so each allocation belongs to either the
+		// previous plan or a new one.
+		if len(plans) > 0 && plans[len(plans)-1].Id == plan.Id {
+			plans[len(plans)-1].Allocations = append(plans[len(plans)-1].Allocations, allocation)
+			continue
+		}
+
+		plan.EndDate = endDate.Time
+		plan.LastRunOn = lastRunOn.Time
+		plan.Allocations = []account.Allocation{allocation}
+
+		plans = append(plans, plan)
+	}
+
+	return plans, nil
+}
+
+func insertPlanAllocations(ctx context.Context, tx *sql.Tx, plan account.Plan) error {
+	for i, allocation := range plan.Allocations {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO investment_plan_allocations
+			(plan_id, fund_id, percentage, position)
+			VALUES (UUID_TO_BIN(?), UUID_TO_BIN(?), ?, ?)
+		`, plan.Id, allocation.FundId, allocation.Percentage, i)
+
+		if err != nil {
+			return fmt.Errorf("Unable to create plan allocation: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// Convert a zero time into NULL
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
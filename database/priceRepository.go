@@ -0,0 +1,69 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/internal/fund"
+)
+
+// MySQL error number for a duplicate entry on a unique key
+const ER_DUP_ENTRY = 1062
+
+type PriceRepository struct {
+	db *sql.DB
+}
+
+func NewPriceRepository(conn *sql.DB) *PriceRepository {
+	return &PriceRepository{db: conn}
+}
+
+func (r *PriceRepository) Create(ctx context.Context, price fund.Price) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO fund_prices
+		(fund_id, price_date, price)
+		VALUES (UUID_TO_BIN(?), ?, ?)
+	`, price.FundId.String(), price.Date.Format(fund.PRICE_DATE_FORMAT), price.Price)
+
+	var mysqlErr *mysql.MySQLError
+
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == ER_DUP_ENTRY {
+		return fund.ErrPriceDuplicate
+	}
+
+	if err != nil {
+		return fmt.Errorf("PriceRepository.Create: Unable to create price: %v", err)
+	}
+
+	return nil
+}
+
+func (r *PriceRepository) PriceAt(ctx context.Context, fundId uuid.UUID, date time.Time) (fund.Price, error) {
+	row := r.db.QueryRowContext(ctx, `
+		SELECT BIN_TO_UUID(fund_id), price_date, price
+		FROM fund_prices
+		WHERE fund_id = UUID_TO_BIN(?)
+		AND price_date <= ?
+		ORDER BY price_date DESC
+		LIMIT 1
+	`, fundId.String(), date.Format(fund.PRICE_DATE_FORMAT))
+
+	var price fund.Price
+
+	err := row.Scan(&price.FundId, &price.Date, &price.Price)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return fund.Price{}, fund.ErrPriceNotFound
+	}
+
+	if err != nil {
+		return fund.Price{}, fmt.Errorf("PriceRepository.PriceAt: Unable to fetch price: %v", err)
+	}
+
+	return price, nil
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/internal/account"
+)
+
+type StatementRepository struct {
+	db *sql.DB
+}
+
+func NewStatementRepository(conn *sql.DB) *StatementRepository {
+	return &StatementRepository{db: conn}
+}
+
+func (r *StatementRepository) SaveStatement(ctx context.Context, statement account.StoredStatement) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO statements
+		(account_id, tax_year, csv, pdf)
+		VALUES (UUID_TO_BIN(?), ?, ?, ?)
+		ON DUPLICATE KEY UPDATE csv = VALUES(csv), pdf = VALUES(pdf), created_at = CURRENT_TIMESTAMP
+	`, statement.AccountId, statement.TaxYear, statement.CSV, statement.PDF)
+
+	if err != nil {
+		return fmt.Errorf("StatementRepository.SaveStatement: Unable to save statement: %v", err)
+	}
+
+	return nil
+}
+
+func (r *StatementRepository) GetStatement(ctx context.Context, accountId uuid.UUID, taxYear int) (account.StoredStatement, error) {
+	var statement account.StoredStatement
+
+	row := r.db.QueryRowContext(ctx, `
+		SELECT BIN_TO_UUID(account_id), tax_year, csv, pdf, created_at
+		FROM statements
+		WHERE account_id = UUID_TO_BIN(?)
+		AND tax_year = ?
+	`, accountId, taxYear)
+
+	err := row.Scan(&statement.AccountId, &statement.TaxYear, &statement.CSV, &statement.PDF, &statement.CreatedAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return account.StoredStatement{}, account.ErrStatementNotFound
+	}
+
+	if err != nil {
+		return account.StoredStatement{}, fmt.Errorf("StatementRepository.GetStatement: Unable to fetch statement: %v", err)
+	}
+
+	return statement, nil
+}
+
+func (r *StatementRepository) GetAccountsOpenedBefore(ctx context.Context, date time.Time) ([]uuid.UUID, error) {
+	var accountIds []uuid.UUID
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT BIN_TO_UUID(id)
+		FROM accounts
+		WHERE created_at < ?
+	`, date)
+
+	if err != nil {
+		return []uuid.UUID{}, fmt.Errorf("StatementRepository.GetAccountsOpenedBefore: Unable to fetch accounts: %v", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var accountId uuid.UUID
+
+		err := rows.Scan(&accountId)
+
+		if err != nil {
+			return []uuid.UUID{}, fmt.Errorf("StatementRepository.GetAccountsOpenedBefore: Unable to fetch accounts: %v", err)
+		}
+
+		accountIds = append(accountIds, accountId)
+	}
+
+	return accountIds, nil
+}
@@ -3,8 +3,9 @@ module github.com/jameswhoughton/cushon
 go 1.23.0
 
 require (
-	filippo.io/edwards25519 v1.1.0 // indirect
-	github.com/go-sql-driver/mysql v1.9.2 // indirect
-	github.com/google/uuid v1.6.0 // indirect
-	github.com/jameswhoughton/migrate v0.0.0-20250513135207-f0b3b1220564 // indirect
+	github.com/go-sql-driver/mysql v1.9.2
+	github.com/google/uuid v1.6.0
+	github.com/jameswhoughton/migrate v0.0.0-20250513135207-f0b3b1220564
 )
+
+require filippo.io/edwards25519 v1.1.0 // indirect
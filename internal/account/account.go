@@ -9,22 +9,29 @@ import (
 )
 
 const (
-	ACCOUNT_TYPE_ISA string = "isa"
+	ACCOUNT_TYPE_ISA  string = "isa"
+	ACCOUNT_TYPE_LISA string = "lisa"
+	ACCOUNT_TYPE_JISA string = "jisa"
 )
 
 type Account struct {
-	Id          uuid.UUID         `json:"id"`
-	CustomerId  uuid.UUID         `json:"customer_id"`
-	AccountType string            `json:"account_type"`
-	CreatedAt   time.Time         `json:"created_at"`
-	Errors      map[string]string `json:"errors"`
+	Id         uuid.UUID `json:"id"`
+	CustomerId uuid.UUID `json:"customer_id"`
+	// Customer managing the account for the holder, only set for Junior ISAs
+	GuardianId  uuid.NullUUID `json:"guardian_id"`
+	AccountType string        `json:"account_type"`
+	// How distributions from income funds are received, see INCOME_PREFERENCE_*
+	IncomePreference string            `json:"income_preference"`
+	CreatedAt        time.Time         `json:"created_at"`
+	Errors           map[string]string `json:"errors"`
 }
 
 // Validate a new Account entity
 //
 // Any errors are stored in a map using the json struct tag
-// so that they can be returned straight back to the UI.
-func (a *Account) Validate() bool {
+// so that they can be returned straight back to the UI. The account type must
+// be a product in the rules.
+func (a *Account) Validate(rules *RulesService) bool {
 	if a.Errors == nil {
 		a.Errors = make(map[string]string, 2)
 	}
@@ -33,11 +40,35 @@ func (a *Account) Validate() bool {
 		a.Errors["customer_id"] = "Customer ID missing"
 	}
 
-	if !slices.Contains([]string{ACCOUNT_TYPE_ISA}, a.AccountType) {
+	product, ok := rules.Product(a.AccountType)
+
+	if !ok {
 		a.Errors["account_type"] = "Account type invalid or missing"
 	}
 
+	if definition, _ := lookupService(product.Service); ok && definition.Validate != nil {
+		definition.Validate(a)
+	}
+
+	// The preference defaults to cash when the account is created
+	if a.IncomePreference != "" && !slices.Contains([]string{INCOME_PREFERENCE_CASH, INCOME_PREFERENCE_PAYOUT}, a.IncomePreference) {
+		a.Errors["income_preference"] = "Income preference invalid"
+	}
+
 	return len(a.Errors) == 0
 }
 
+// Return the customer in control of the account
+//
+// A Junior ISA is controlled by the guardian until it is converted to an ISA,
+// the holder has no control over it before then.
+func (a Account) ControlledBy() uuid.UUID {
+	if a.GuardianId.Valid {
+		return a.GuardianId.UUID
+	}
+
+	return a.CustomerId
+}
+
 var ErrAccountInvalid = errors.New("Account invalid")
+var ErrAccountNotFound = errors.New("Account not found")
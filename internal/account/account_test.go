@@ -34,6 +34,24 @@ func TestAccountValidation(t *testing.T) {
 			isValid:        false,
 			expectedErrors: []string{"account_type"},
 		},
+		{
+			name:           "IncomePreference invalid",
+			account:        account.Account{CustomerId: uuid.New(), AccountType: account.ACCOUNT_TYPE_ISA, IncomePreference: "reinvest"},
+			isValid:        false,
+			expectedErrors: []string{"income_preference"},
+		},
+		{
+			name:           "Guardian missing",
+			account:        account.Account{CustomerId: uuid.New(), AccountType: account.ACCOUNT_TYPE_JISA},
+			isValid:        false,
+			expectedErrors: []string{"guardian_id"},
+		},
+		{
+			name:           "Valid guarded account",
+			account:        account.Account{CustomerId: uuid.New(), AccountType: account.ACCOUNT_TYPE_JISA, GuardianId: uuid.NullUUID{UUID: uuid.New(), Valid: true}},
+			isValid:        true,
+			expectedErrors: []string{},
+		},
 		{
 			name:           "Valid account",
 			account:        account.Account{CustomerId: uuid.New(), AccountType: account.ACCOUNT_TYPE_ISA},
@@ -44,7 +62,7 @@ func TestAccountValidation(t *testing.T) {
 
 	for _, testCase := range cases {
 		t.Run(testCase.name, func(t *testing.T) {
-			isValid := testCase.account.Validate()
+			isValid := testCase.account.Validate(NewTestRules(0, 0))
 
 			if isValid != testCase.isValid {
 				t.Errorf("Expected Validate to return %t, got %t", testCase.isValid, isValid)
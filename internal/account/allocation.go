@@ -0,0 +1,189 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+var ErrAllocationInvalid = errors.New("Allocation invalid")
+
+// Percentage of a deposit to invest in a single fund
+type Allocation struct {
+	FundId     uuid.UUID `json:"fund_id"`
+	Percentage int       `json:"percentage"`
+}
+
+// Request to invest a total amount split across one or more funds
+//
+// Customers think in terms of "£1,000, 60% equities and 40% bonds" rather
+// than an amount per fund, the request is converted into individual
+// investments by Investments().
+type AllocationRequest struct {
+	Amount      int               `json:"amount"`
+	Allocations []Allocation      `json:"allocations"`
+	Errors      map[string]string `json:"errors"`
+}
+
+// Validate an AllocationRequest
+//
+// Any errors are stored in a map using the json struct tag, errors for
+// individual allocations are keyed by their index (e.g. allocations.0.fund_id).
+func (r *AllocationRequest) Validate() bool {
+	if r.Errors == nil {
+		r.Errors = make(map[string]string, 2)
+	}
+
+	if r.Amount <= 0 {
+		r.Errors["amount"] = "Amount must be greater than zero"
+	}
+
+	if len(r.Allocations) == 0 {
+		r.Errors["allocations"] = "At least one fund must be selected"
+	}
+
+	var total int
+	seen := make(map[uuid.UUID]bool, len(r.Allocations))
+
+	for i, allocation := range r.Allocations {
+		if allocation.FundId == (uuid.UUID{}) {
+			r.Errors[fmt.Sprintf("allocations.%d.fund_id", i)] = "Fund ID missing"
+		} else if seen[allocation.FundId] {
+			r.Errors[fmt.Sprintf("allocations.%d.fund_id", i)] = "Fund has already been selected"
+		}
+
+		if allocation.Percentage <= 0 || allocation.Percentage > 100 {
+			r.Errors[fmt.Sprintf("allocations.%d.percentage", i)] = "Percentage must be between 1 and 100"
+		}
+
+		seen[allocation.FundId] = true
+		total += allocation.Percentage
+	}
+
+	if len(r.Allocations) > 0 && total != 100 {
+		r.Errors["allocations"] = "Percentages must add up to 100"
+	}
+
+	return len(r.Errors) == 0
+}
+
+// Split the request into an Investment per fund
+//
+// Each fund receives its percentage of the amount, see apportion() for the
+// penny-rounding rule. The request is assumed to be valid.
+func (r AllocationRequest) Investments(transactionType string) []Investment {
+	percentages := make([]int, len(r.Allocations))
+
+	for i, allocation := range r.Allocations {
+		percentages[i] = allocation.Percentage
+	}
+
+	amounts := apportion(r.Amount, percentages)
+	investments := make([]Investment, len(r.Allocations))
+
+	for i, allocation := range r.Allocations {
+		investments[i] = Investment{
+			FundId: allocation.FundId,
+			// The trade id is generated here and used as the order reference
+			// when the trade is placed with the trading service.
+			TradeId:         uuid.New(),
+			TransactionType: transactionType,
+			Amount:          amounts[i],
+		}
+	}
+
+	return investments
+}
+
+// Split an amount in proportion to the weights
+//
+// Each share is rounded down to the penny, any pennies left over are given
+// one at a time to the shares with the largest remainders. Ties are broken
+// by the order of the weights so the result is deterministic and always
+// adds up to the amount. The weights must be positive.
+func apportion(amount int, weights []int) []int {
+	var totalWeight int
+
+	for _, weight := range weights {
+		totalWeight += weight
+	}
+
+	shares := make([]int, len(weights))
+	remainders := make([]int, len(weights))
+
+	if totalWeight == 0 {
+		return shares
+	}
+
+	allocated := 0
+
+	for i, weight := range weights {
+		share := amount * weight
+
+		shares[i] = share / totalWeight
+		remainders[i] = share % totalWeight
+		allocated += shares[i]
+	}
+
+	for left := amount - allocated; left > 0; left-- {
+		largest := 0
+
+		for i := range remainders {
+			if remainders[i] > remainders[largest] {
+				largest = i
+			}
+		}
+
+		shares[largest]++
+		remainders[largest] = -1
+	}
+
+	return shares
+}
+
+// Generic function to invest a percentage allocation
+//
+// This function is designed to be used across all different account types,
+// the investments are made through the account's Service so they are subject
+// to the same rules as any other investment. Errors on the individual
+// investments are copied back to the request.
+func investAllocation(ctx context.Context, service Service, accountId uuid.UUID, request *AllocationRequest) error {
+	if !request.Validate() {
+		return ErrAllocationInvalid
+	}
+
+	investments := request.Investments(TRANSACTION_TYPE_CUSTOMER)
+
+	return allocationErrors(request, investments, service.Invest(ctx, accountId, investments))
+}
+
+// Generic function to check a percentage allocation could be invested
+//
+// Works in the same way as investAllocation but only runs the account
+// Service's checks, no investments are made.
+func checkAllocation(ctx context.Context, service Service, accountId uuid.UUID, request *AllocationRequest) error {
+	if !request.Validate() {
+		return ErrAllocationInvalid
+	}
+
+	investments := request.Investments(TRANSACTION_TYPE_CUSTOMER)
+
+	return allocationErrors(request, investments, service.CheckInvestment(ctx, accountId, investments))
+}
+
+// Copy any investment validation errors back to the request
+func allocationErrors(request *AllocationRequest, investments []Investment, err error) error {
+	if !errors.Is(err, ErrInvestmentInvalid) {
+		return err
+	}
+
+	for i, investment := range investments {
+		for field, message := range investment.Errors {
+			request.Errors[fmt.Sprintf("allocations.%d.%s", i, field)] = message
+		}
+	}
+
+	return ErrAllocationInvalid
+}
@@ -0,0 +1,203 @@
+package account_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/internal/account"
+)
+
+func TestAllocationRequestValidation(t *testing.T) {
+	type testCase struct {
+		name           string
+		request        account.AllocationRequest
+		isValid        bool
+		expectedErrors []string
+	}
+
+	fundA := uuid.New()
+	fundB := uuid.New()
+
+	cases := []testCase{
+		{
+			name:           "Amount missing",
+			request:        account.AllocationRequest{Allocations: []account.Allocation{{fundA, 100}}},
+			isValid:        false,
+			expectedErrors: []string{"amount"},
+		},
+		{
+			name:           "Allocations missing",
+			request:        account.AllocationRequest{Amount: 100},
+			isValid:        false,
+			expectedErrors: []string{"allocations"},
+		},
+		{
+			name:           "Percentages do not add up to 100",
+			request:        account.AllocationRequest{Amount: 100, Allocations: []account.Allocation{{fundA, 60}, {fundB, 30}}},
+			isValid:        false,
+			expectedErrors: []string{"allocations"},
+		},
+		{
+			name:           "Fund selected twice",
+			request:        account.AllocationRequest{Amount: 100, Allocations: []account.Allocation{{fundA, 50}, {fundA, 50}}},
+			isValid:        false,
+			expectedErrors: []string{"allocations.1.fund_id"},
+		},
+		{
+			name:           "Fund ID missing",
+			request:        account.AllocationRequest{Amount: 100, Allocations: []account.Allocation{{uuid.UUID{}, 100}}},
+			isValid:        false,
+			expectedErrors: []string{"allocations.0.fund_id"},
+		},
+		{
+			name:           "Negative percentage",
+			request:        account.AllocationRequest{Amount: 100, Allocations: []account.Allocation{{fundA, 110}, {fundB, -10}}},
+			isValid:        false,
+			expectedErrors: []string{"allocations.0.percentage", "allocations.1.percentage"},
+		},
+		{
+			name:           "Valid request",
+			request:        account.AllocationRequest{Amount: 100, Allocations: []account.Allocation{{fundA, 60}, {fundB, 40}}},
+			isValid:        true,
+			expectedErrors: []string{},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			isValid := testCase.request.Validate()
+
+			if isValid != testCase.isValid {
+				t.Errorf("Expected Validate to return %t, got %t", testCase.isValid, isValid)
+			}
+
+			if len(testCase.request.Errors) != len(testCase.expectedErrors) {
+				t.Errorf("Expected %d validation errors, got %d", len(testCase.expectedErrors), len(testCase.request.Errors))
+			}
+
+			for _, field := range testCase.expectedErrors {
+				if _, ok := testCase.request.Errors[field]; !ok {
+					t.Errorf("expected validation error field '%s' missing", field)
+				}
+			}
+		})
+	}
+}
+
+func TestAllocationRequestSplitsAmountToThePenny(t *testing.T) {
+	type testCase struct {
+		name        string
+		amount      int
+		percentages []int
+		expected    []int
+	}
+
+	cases := []testCase{
+		{name: "Exact split", amount: 100000, percentages: []int{60, 40}, expected: []int{60000, 40000}},
+		{name: "Remainder goes to largest fraction", amount: 1001, percentages: []int{35, 65}, expected: []int{350, 651}},
+		{name: "Remainder across three funds", amount: 1001, percentages: []int{33, 33, 34}, expected: []int{330, 330, 341}},
+		{name: "Ties go to the first fund", amount: 101, percentages: []int{50, 50}, expected: []int{51, 50}},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			request := account.AllocationRequest{Amount: testCase.amount}
+
+			for _, percentage := range testCase.percentages {
+				request.Allocations = append(request.Allocations, account.Allocation{FundId: uuid.New(), Percentage: percentage})
+			}
+
+			investments := request.Investments(account.TRANSACTION_TYPE_CUSTOMER)
+
+			total := 0
+
+			for i, investment := range investments {
+				if investment.Amount != testCase.expected[i] {
+					t.Errorf("Expected investment %d to be %d, got %d", i, testCase.expected[i], investment.Amount)
+				}
+
+				if investment.FundId != request.Allocations[i].FundId {
+					t.Errorf("Expected investment %d to be in fund %s, got %s", i, request.Allocations[i].FundId, investment.FundId)
+				}
+
+				total += investment.Amount
+			}
+
+			if total != testCase.amount {
+				t.Errorf("Expected investments to total %d, got %d", testCase.amount, total)
+			}
+		})
+	}
+}
+
+func TestISAServiceCanInvestAnAllocationAcrossFunds(t *testing.T) {
+	repo, closeDown := NewTestRepository()
+	defer closeDown()
+
+	passingNiValidator := func(_ context.Context, _ string) error {
+		return nil
+	}
+
+	equities := NewTestFund()
+	bonds := NewTestFund()
+	catalogue := NewTestCatalogue(equities, bonds)
+
+	service := account.NewISAService(&repo, &catalogue, NewTestRules(200000, 0), account.ACCOUNT_TYPE_ISA, false, account.StartOfTaxYear{1, 1}, passingNiValidator)
+
+	ctx := context.Background()
+
+	customer := account.Customer{
+		Id:           uuid.New(),
+		TaxResidency: "uk",
+		NINumber:     "AB123456A",
+		DateOfBirth:  time.Now().AddDate(-20, 0, 0),
+	}
+
+	newAccount, err := service.CreateAccount(ctx, customer)
+
+	if err != nil {
+		t.Errorf("unexpected error when creating ISA account: %v", err)
+	}
+
+	request := account.AllocationRequest{
+		Amount: 100000,
+		Allocations: []account.Allocation{
+			{FundId: equities.Id, Percentage: 60},
+			{FundId: bonds.Id, Percentage: 40},
+		},
+	}
+
+	err = service.InvestAllocation(ctx, newAccount.Id, &request)
+
+	if err != nil {
+		t.Errorf("unexpected error when investing allocation: %v", err)
+	}
+
+	accountFunds, err := repo.GetAccountFunds(ctx, newAccount.Id)
+
+	if err != nil {
+		t.Errorf("unexpected error fetching account funds: %v", err)
+	}
+
+	if len(accountFunds) != 2 {
+		t.Errorf("Expected 2 account funds, found %d", len(accountFunds))
+	}
+
+	invalidRequest := account.AllocationRequest{
+		Amount:      100000,
+		Allocations: []account.Allocation{{FundId: uuid.New(), Percentage: 100}},
+	}
+
+	err = service.InvestAllocation(ctx, newAccount.Id, &invalidRequest)
+
+	if !errors.Is(err, account.ErrAllocationInvalid) {
+		t.Errorf("Expected error %v, got %v", account.ErrAllocationInvalid, err)
+	}
+
+	if _, ok := invalidRequest.Errors["allocations.0.fund_id"]; !ok {
+		t.Error("expected validation error field 'allocations.0.fund_id' missing")
+	}
+}
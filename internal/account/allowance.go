@@ -0,0 +1,153 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrSubscriptionTypeLimit = errors.New("Another ISA of this type has already been paid into this tax year")
+
+// Returned when a subscription would exceed the annual limit of its account type
+type ErrExceededAccountTypeLimit struct {
+	AccountType string
+}
+
+func (e ErrExceededAccountTypeLimit) Error() string {
+	return "Annual limit for account type '" + e.AccountType + "' will be exceeded by transaction"
+}
+
+// Subscriptions made into one of a customer's accounts during a tax year
+//
+// Subscribed follows the same rules as GetTotalInvestedToDate, Pending is the
+// total of deposits that haven't settled or failed yet. Both are counted from
+// the start of the tax year, or from when the account was converted to its
+// current type if later.
+type AccountSubscriptions struct {
+	AccountId   uuid.UUID
+	AccountType string
+	From        time.Time
+	Subscribed  int
+	Pending     int
+}
+
+// Returns true if the error means a subscription would break the allowance rules
+func IsAllowanceError(err error) bool {
+	return errors.Is(err, ErrExceededISALimit) || errors.As(err, &ErrExceededAccountTypeLimit{}) || errors.Is(err, ErrSubscriptionTypeLimit)
+}
+
+// Returns the allowance used by an account's transactions during a tax year
+//
+// Only customer transactions are counted. For a flexible ISA money that is
+// withdrawn can be replaced later in the same tax year without using more
+// allowance, so each subscription first replaces any earlier withdrawals.
+// The amount that can still be replaced is returned alongside (always zero
+// if the account isn't flexible). The transactions must be in the order they
+// were made and all fall within the tax year.
+func AllowanceUsed(transactions []Transaction, flexible bool) (used int, replaceable int) {
+	for _, transaction := range transactions {
+		if transaction.TransactionType != TRANSACTION_TYPE_CUSTOMER {
+			continue
+		}
+
+		if transaction.Amount < 0 {
+			if flexible {
+				replaceable -= transaction.Amount
+			}
+
+			continue
+		}
+
+		replaced := min(replaceable, transaction.Amount)
+
+		replaceable -= replaced
+		used += transaction.Amount - replaced
+	}
+
+	return used, replaceable
+}
+
+// Generic function to check a subscription against the customer's allowances
+//
+// The allowance is shared across every account the customer holds:
+//   - The total subscribed to all ISAs (including LISAs) cannot exceed the overallLimit.
+//   - The total subscribed to accounts of this type cannot exceed the typeLimit (zero for no separate limit).
+//   - Only one account of each type can be paid into each tax year.
+//
+// Deposits that haven't settled yet provisionally use up the allowance. If
+// the account is flexible, withdrawals made earlier in the tax year are
+// netted against its pending deposits and then the new amount.
+func checkAllowance(ctx context.Context, repo Repository, rules *RulesService, account Account, amount int, startOfTaxYear time.Time, overallLimit int, typeLimit int, flexible bool) error {
+	// Withdrawals never use up the allowance
+	if amount <= 0 {
+		return nil
+	}
+
+	subscriptions, err := repo.GetCustomerSubscriptions(ctx, account.CustomerId, startOfTaxYear)
+
+	if err != nil {
+		return fmt.Errorf("Unable to fetch subscriptions: %w", err)
+	}
+
+	var overall, sameType int
+
+	for _, subscription := range subscriptions {
+		total := subscription.Subscribed + subscription.Pending
+
+		if subscription.AccountId == account.Id && flexible {
+			total, amount, err = flexibleAllowance(ctx, repo, subscription, amount)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		if rules.SharesISAAllowance(subscription.AccountType) {
+			overall += total
+		}
+
+		if subscription.AccountType != account.AccountType {
+			continue
+		}
+
+		sameType += total
+
+		if subscription.AccountId != account.Id && total > 0 {
+			return ErrSubscriptionTypeLimit
+		}
+	}
+
+	if overall+amount > overallLimit {
+		return ErrExceededISALimit
+	}
+
+	if typeLimit > 0 && sameType+amount > typeLimit {
+		return ErrExceededAccountTypeLimit{account.AccountType}
+	}
+
+	return nil
+}
+
+// Net the account's withdrawals against its pending deposits and the new amount
+//
+// Returns the allowance used by the account, including pending deposits,
+// and the part of the new amount that uses up more allowance.
+func flexibleAllowance(ctx context.Context, repo Repository, subscription AccountSubscriptions, amount int) (int, int, error) {
+	transactions, err := repo.GetSubscriptionTransactions(ctx, subscription.AccountId, subscription.From, subscription.From.AddDate(1, 0, 0))
+
+	if err != nil {
+		return 0, 0, fmt.Errorf("Unable to fetch transactions: %w", err)
+	}
+
+	used, replaceable := AllowanceUsed(transactions, true)
+
+	// Pending deposits will settle after the existing transactions
+	replaced := min(replaceable, subscription.Pending)
+	used += subscription.Pending - replaced
+	replaceable -= replaced
+
+	return used, amount - min(replaceable, amount), nil
+}
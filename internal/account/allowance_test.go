@@ -0,0 +1,119 @@
+package account_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/internal/account"
+)
+
+func TestAllowanceIsSharedAcrossACustomersAccounts(t *testing.T) {
+	repo, closeDown := NewTestRepository()
+	defer closeDown()
+
+	passingNiValidator := func(_ context.Context, _ string) error {
+		return nil
+	}
+
+	testFund := NewTestFund()
+	testFund.AccountTypes = []string{account.ACCOUNT_TYPE_ISA, account.ACCOUNT_TYPE_LISA}
+	catalogue := NewTestCatalogue(testFund)
+
+	isa := account.NewISAService(&repo, &catalogue, NewTestRules(1000, 0), account.ACCOUNT_TYPE_ISA, false, account.StartOfTaxYear{1, 1}, passingNiValidator)
+	lisa := account.NewLISAService(&repo, &catalogue, NewTestRules(1000, 400), account.StartOfTaxYear{1, 1}, passingNiValidator, account.NewStubCustomerClient())
+
+	ctx := context.Background()
+
+	customer := account.Customer{
+		Id:           uuid.New(),
+		TaxResidency: "uk",
+		NINumber:     "AB123456A",
+		DateOfBirth:  time.Now().AddDate(-20, 0, 0),
+	}
+
+	isaAccount, err := isa.CreateAccount(ctx, customer)
+
+	if err != nil {
+		t.Fatalf("unexpected error when creating ISA account: %v", err)
+	}
+
+	secondISAAccount, err := isa.CreateAccount(ctx, customer)
+
+	if err != nil {
+		t.Fatalf("unexpected error when creating ISA account: %v", err)
+	}
+
+	lisaAccount, err := lisa.CreateAccount(ctx, customer)
+
+	if err != nil {
+		t.Fatalf("unexpected error when creating LISA account: %v", err)
+	}
+
+	investment := func(amount int) []account.Investment {
+		return []account.Investment{
+			{
+				FundId:          testFund.Id,
+				TradeId:         uuid.New(),
+				TransactionType: account.TRANSACTION_TYPE_CUSTOMER,
+				Amount:          amount,
+			},
+		}
+	}
+
+	type step struct {
+		name      string
+		service   account.Service
+		accountId uuid.UUID
+		amount    int
+		expected  error
+	}
+
+	steps := []step{
+		{name: "Invest in the ISA", service: isa, accountId: isaAccount.Id, amount: 500},
+		{name: "LISA over its own limit", service: lisa, accountId: lisaAccount.Id, amount: 450, expected: account.ErrExceededAccountTypeLimit{AccountType: account.ACCOUNT_TYPE_LISA}},
+		{name: "LISA up to its own limit", service: lisa, accountId: lisaAccount.Id, amount: 400},
+		{name: "ISA over the shared limit", service: isa, accountId: isaAccount.Id, amount: 150, expected: account.ErrExceededISALimit},
+		{name: "Second ISA in the same year", service: isa, accountId: secondISAAccount.Id, amount: 50, expected: account.ErrSubscriptionTypeLimit},
+		{name: "ISA up to the shared limit", service: isa, accountId: isaAccount.Id, amount: 100},
+	}
+
+	for _, step := range steps {
+		err := step.service.Invest(ctx, step.accountId, investment(step.amount))
+
+		if step.expected == nil && err != nil {
+			t.Errorf("%s: unexpected error: %v", step.name, err)
+		}
+
+		if step.expected != nil && !errors.Is(err, step.expected) {
+			t.Errorf("%s: expected error %v, got %v", step.name, step.expected, err)
+		}
+	}
+}
+
+func TestLISAIsOnlyAvailableToCustomersUnder40(t *testing.T) {
+	// The customer is rejected before the repository is used
+	var repo account.Repository
+
+	passingNiValidator := func(_ context.Context, _ string) error {
+		return nil
+	}
+
+	catalogue := NewTestCatalogue()
+	lisa := account.NewLISAService(&repo, &catalogue, NewTestRules(1000, 400), account.StartOfTaxYear{1, 1}, passingNiValidator, account.NewStubCustomerClient())
+
+	_, err := lisa.CreateAccount(context.Background(), account.Customer{
+		Id:           uuid.New(),
+		TaxResidency: "uk",
+		NINumber:     "AB123456A",
+		DateOfBirth:  time.Now().AddDate(-40, 0, 0),
+	})
+
+	var permissionErr account.ErrAccountCreatePermission
+
+	if !errors.As(err, &permissionErr) {
+		t.Errorf("Expected error %T, got %v", permissionErr, err)
+	}
+}
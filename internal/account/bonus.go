@@ -0,0 +1,330 @@
+package account
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/internal/nivalidation"
+)
+
+const (
+	BONUS_CLAIM_STATUS_SUBMITTED string = "submitted"
+	BONUS_CLAIM_STATUS_PAID      string = "paid"
+	BONUS_CLAIM_STATUS_REJECTED  string = "rejected"
+)
+
+// Government bonus as a percentage of LISA contributions
+const LISA_BONUS_PERCENT = 25
+
+var ErrBonusClaimNotFound = errors.New("Bonus claim not found")
+var ErrBonusAlreadyClaimed = errors.New("Bonus already claimed for the period")
+var ErrBonusClaimNotSubmitted = errors.New("Bonus claim has already been paid or rejected")
+
+// A claim to HMRC for the government bonus on a LISA
+//
+// Covers contributions made between PeriodFrom (inclusive) and PeriodTo
+// (exclusive). The next claim for the account starts where this one ends,
+// whatever its status, so contributions are never claimed twice. Amount is
+// the bonus paid by HMRC, which is only known once the claim is paid. The
+// NI number is only populated when the claim is made, for the claim file.
+type BonusClaim struct {
+	Id            uuid.UUID `json:"id"`
+	AccountId     uuid.UUID `json:"account_id"`
+	NINumber      string    `json:"-"`
+	PeriodFrom    time.Time `json:"period_from"`
+	PeriodTo      time.Time `json:"period_to"`
+	Contributions int       `json:"contributions"`
+	Bonus         int       `json:"bonus"`
+	Status        string    `json:"status"`
+	Amount        int       `json:"amount"`
+	Reason        string    `json:"reason"`
+}
+
+// Return the bonus due on LISA contributions, rounded to the nearest penny
+func LISABonus(contributions int) int {
+	if contributions <= 0 {
+		return 0
+	}
+
+	return (contributions*LISA_BONUS_PERCENT + 50) / 100
+}
+
+// Write the claims to submit to HMRC as CSV
+//
+// Dates are formatted as YYYY-MM-DD with the end of the period exclusive,
+// amounts are in pence.
+func WriteBonusClaimFile(w io.Writer, claims []BonusClaim) error {
+	rows := [][]string{{"claim_id", "account_id", "ni_number", "period_from", "period_to", "contributions", "bonus"}}
+
+	for _, claim := range claims {
+		rows = append(rows, []string{
+			claim.Id.String(),
+			claim.AccountId.String(),
+			claim.NINumber,
+			claim.PeriodFrom.Format(time.DateOnly),
+			claim.PeriodTo.Format(time.DateOnly),
+			strconv.Itoa(claim.Contributions),
+			strconv.Itoa(claim.Bonus),
+		})
+	}
+
+	if err := csv.NewWriter(w).WriteAll(rows); err != nil {
+		return fmt.Errorf("Unable to write bonus claim file: %w", err)
+	}
+
+	return nil
+}
+
+// HMRC's response to a single claim
+//
+// Amount is the bonus paid in pence, Reason explains a rejection.
+type BonusClaimResponse struct {
+	ClaimId uuid.UUID
+	Status  string
+	Amount  int
+	Reason  string
+}
+
+// Parse HMRC's response file
+//
+// The file must have a header row followed by rows of
+// claim_id,status,amount,reason where the status is either paid or rejected.
+func ParseBonusResponses(r io.Reader) ([]BonusClaimResponse, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+
+	if err != nil {
+		return []BonusClaimResponse{}, fmt.Errorf("Unable to parse bonus responses: %v", err)
+	}
+
+	if len(rows) == 0 {
+		return []BonusClaimResponse{}, nil
+	}
+
+	responses := make([]BonusClaimResponse, 0, len(rows)-1)
+
+	for i, row := range rows[1:] {
+		if len(row) != 4 {
+			return []BonusClaimResponse{}, fmt.Errorf("Unable to parse bonus responses: row %d has %d columns, expected 4", i+2, len(row))
+		}
+
+		response := BonusClaimResponse{
+			Status: strings.ToLower(row[1]),
+			Reason: row[3],
+		}
+
+		response.ClaimId, err = uuid.Parse(row[0])
+
+		if err == nil && response.Status != BONUS_CLAIM_STATUS_PAID && response.Status != BONUS_CLAIM_STATUS_REJECTED {
+			err = fmt.Errorf("unknown status '%s'", row[1])
+		}
+
+		if err == nil && response.Status == BONUS_CLAIM_STATUS_PAID {
+			response.Amount, err = strconv.Atoi(row[2])
+		}
+
+		if err != nil {
+			return []BonusClaimResponse{}, fmt.Errorf("Unable to parse bonus responses: row %d: %v", i+2, err)
+		}
+
+		responses = append(responses, response)
+	}
+
+	return responses, nil
+}
+
+// Responsible for storing bonus claims, any params passed in are assumed
+// to be valid.
+//
+// Methods should be accessed through the BonusService
+type BonusRepository interface {
+	// Return the claim for the account with the latest period
+	//
+	// Returns ErrBonusClaimNotFound if no claim has been made.
+	GetLastClaim(ctx context.Context, accountId uuid.UUID) (BonusClaim, error)
+
+	// Store a submitted claim
+	//
+	// Returns ErrBonusAlreadyClaimed if the account already has a claim
+	// ending at the same time.
+	CreateClaim(ctx context.Context, claim BonusClaim) error
+
+	// Mark a submitted claim as paid and credit the bonus to cash atomically
+	//
+	// Returns ErrBonusClaimNotSubmitted if the claim is not submitted.
+	PayClaim(ctx context.Context, claimId uuid.UUID, amount int) error
+
+	// Mark a submitted claim as rejected
+	//
+	// Returns ErrBonusClaimNotSubmitted if the claim is not submitted.
+	RejectClaim(ctx context.Context, claimId uuid.UUID, reason string) error
+}
+
+// Summary of a single run of the bonus claim job
+type BonusClaimRunResult struct {
+	Claims  []BonusClaim
+	Skipped int
+	Failed  map[uuid.UUID]error
+}
+
+// Summary of ingesting a response file, failures are keyed by claim id
+type BonusResponseResult struct {
+	Paid     int
+	Rejected int
+	Skipped  int
+	Failed   map[uuid.UUID]error
+}
+
+// Service to claim the government bonus on LISA contributions
+//
+// Claims are made monthly for the contributions since the previous claim.
+// The bonus is credited to the account's cash when HMRC pays the claim.
+type BonusService struct {
+	repository BonusRepository
+	accounts   Repository
+	customers  CustomerClient
+}
+
+func NewBonusService(repository *BonusRepository, accounts *Repository, customers CustomerClient) *BonusService {
+	return &BonusService{
+		repository: *repository,
+		accounts:   *accounts,
+		customers:  customers,
+	}
+}
+
+// Claim the bonus on contributions made before the month containing the date
+//
+// Accounts without new contributions since their last claim are skipped, as
+// are accounts that have already been claimed for the period, so the job
+// can be safely re-run. The claims made are returned for the claim file.
+func (s *BonusService) ClaimBonuses(ctx context.Context, date time.Time) (BonusClaimRunResult, error) {
+	result := BonusClaimRunResult{Failed: make(map[uuid.UUID]error)}
+
+	date = date.In(taxYearLocation)
+	periodTo := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, taxYearLocation)
+
+	accounts, err := s.accounts.GetAccountsByType(ctx, ACCOUNT_TYPE_LISA, periodTo)
+
+	if err != nil {
+		return result, fmt.Errorf("Unable to fetch accounts: %w", err)
+	}
+
+	for _, account := range accounts {
+		claim, err := s.claim(ctx, account, periodTo)
+
+		switch {
+		case errors.Is(err, ErrBonusAlreadyClaimed):
+			result.Skipped++
+		case err != nil:
+			result.Failed[account.Id] = err
+		case claim.Contributions == 0:
+			result.Skipped++
+		default:
+			result.Claims = append(result.Claims, claim)
+		}
+	}
+
+	return result, nil
+}
+
+func (s *BonusService) claim(ctx context.Context, account Account, periodTo time.Time) (BonusClaim, error) {
+	claim := BonusClaim{
+		Id:         uuid.New(),
+		AccountId:  account.Id,
+		PeriodFrom: account.CreatedAt,
+		PeriodTo:   periodTo,
+		Status:     BONUS_CLAIM_STATUS_SUBMITTED,
+	}
+
+	last, err := s.repository.GetLastClaim(ctx, account.Id)
+
+	if err != nil && !errors.Is(err, ErrBonusClaimNotFound) {
+		return BonusClaim{}, fmt.Errorf("Unable to fetch last claim: %w", err)
+	}
+
+	if err == nil {
+		claim.PeriodFrom = last.PeriodTo
+	}
+
+	if !claim.PeriodFrom.Before(periodTo) {
+		return BonusClaim{}, ErrBonusAlreadyClaimed
+	}
+
+	claim.Contributions, err = s.accounts.GetSubscriptionsBetween(ctx, account.Id, claim.PeriodFrom, periodTo)
+
+	if err != nil {
+		return BonusClaim{}, fmt.Errorf("Unable to fetch contributions: %w", err)
+	}
+
+	if claim.Contributions == 0 {
+		return claim, nil
+	}
+
+	claim.Bonus = LISABonus(claim.Contributions)
+
+	customer, err := s.customers.GetCustomer(ctx, account.CustomerId)
+
+	if err != nil {
+		return BonusClaim{}, fmt.Errorf("Unable to fetch customer: %w", err)
+	}
+
+	claim.NINumber = nivalidation.Normalise(customer.NINumber)
+
+	err = s.repository.CreateClaim(ctx, claim)
+
+	if errors.Is(err, ErrBonusAlreadyClaimed) {
+		return BonusClaim{}, err
+	}
+
+	if err != nil {
+		return BonusClaim{}, fmt.Errorf("Unable to store claim: %w", err)
+	}
+
+	return claim, nil
+}
+
+// Apply HMRC's responses to the submitted claims
+//
+// Paid claims have the bonus credited to cash. Responses for claims that
+// were already paid or rejected are skipped so a file can be ingested again.
+func (s *BonusService) IngestResponses(ctx context.Context, responses []BonusClaimResponse) BonusResponseResult {
+	result := BonusResponseResult{Failed: make(map[uuid.UUID]error)}
+
+	for _, response := range responses {
+		var err error
+
+		switch response.Status {
+		case BONUS_CLAIM_STATUS_PAID:
+			if response.Amount <= 0 {
+				err = errors.New("Paid amount must be greater than zero")
+				break
+			}
+
+			err = s.repository.PayClaim(ctx, response.ClaimId, response.Amount)
+		case BONUS_CLAIM_STATUS_REJECTED:
+			err = s.repository.RejectClaim(ctx, response.ClaimId, response.Reason)
+		default:
+			err = fmt.Errorf("Unknown status '%s'", response.Status)
+		}
+
+		switch {
+		case errors.Is(err, ErrBonusClaimNotSubmitted):
+			result.Skipped++
+		case err != nil:
+			result.Failed[response.ClaimId] = err
+		case response.Status == BONUS_CLAIM_STATUS_PAID:
+			result.Paid++
+		default:
+			result.Rejected++
+		}
+	}
+
+	return result
+}
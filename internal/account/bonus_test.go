@@ -0,0 +1,206 @@
+package account_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/database"
+	"github.com/jameswhoughton/cushon/internal/account"
+)
+
+func TestLISABonus(t *testing.T) {
+	cases := map[int]int{
+		-100:   0,
+		0:      0,
+		400000: 100000,
+		1001:   250,
+		1002:   251,
+	}
+
+	for contributions, expected := range cases {
+		if bonus := account.LISABonus(contributions); bonus != expected {
+			t.Errorf("Expected a bonus of %d on %d, got %d", expected, contributions, bonus)
+		}
+	}
+}
+
+func TestParseBonusResponses(t *testing.T) {
+	paidId := uuid.New()
+	rejectedId := uuid.New()
+
+	file := "claim_id,status,amount,reason\n" +
+		paidId.String() + ",paid,250,\n" +
+		rejectedId.String() + ",REJECTED,,Account holder not eligible\n"
+
+	responses, err := account.ParseBonusResponses(strings.NewReader(file))
+
+	if err != nil {
+		t.Fatalf("unexpected error parsing responses: %v", err)
+	}
+
+	expected := []account.BonusClaimResponse{
+		{ClaimId: paidId, Status: account.BONUS_CLAIM_STATUS_PAID, Amount: 250},
+		{ClaimId: rejectedId, Status: account.BONUS_CLAIM_STATUS_REJECTED, Reason: "Account holder not eligible"},
+	}
+
+	if len(responses) != len(expected) {
+		t.Fatalf("Expected %d responses, got %d", len(expected), len(responses))
+	}
+
+	for i := range expected {
+		if responses[i] != expected[i] {
+			t.Errorf("Expected response %v, got %v", expected[i], responses[i])
+		}
+	}
+
+	invalid := []string{
+		"claim_id,status,amount,reason\nnot-a-uuid,paid,250,\n",
+		"claim_id,status,amount,reason\n" + paidId.String() + ",pending,250,\n",
+		"claim_id,status,amount,reason\n" + paidId.String() + ",paid,abc,\n",
+		"claim_id,status,amount\n" + paidId.String() + ",paid,250\n",
+	}
+
+	for _, file := range invalid {
+		if _, err := account.ParseBonusResponses(strings.NewReader(file)); err == nil {
+			t.Errorf("Expected an error parsing %q", file)
+		}
+	}
+}
+
+func TestWriteBonusClaimFile(t *testing.T) {
+	claim := account.BonusClaim{
+		Id:            uuid.MustParse("5b0e1f8e-3c1a-4c1e-9d0b-0c6f2e8a1b2c"),
+		AccountId:     uuid.MustParse("9a7d2c4b-1e3f-4a5b-8c6d-7e8f9a0b1c2d"),
+		NINumber:      "AB123456A",
+		PeriodFrom:    time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+		PeriodTo:      time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		Contributions: 1000,
+		Bonus:         250,
+	}
+
+	var buf bytes.Buffer
+
+	err := account.WriteBonusClaimFile(&buf, []account.BonusClaim{claim})
+
+	if err != nil {
+		t.Fatalf("unexpected error writing claim file: %v", err)
+	}
+
+	expected := "claim_id,account_id,ni_number,period_from,period_to,contributions,bonus\n" +
+		"5b0e1f8e-3c1a-4c1e-9d0b-0c6f2e8a1b2c,9a7d2c4b-1e3f-4a5b-8c6d-7e8f9a0b1c2d,AB123456A,2024-04-01,2024-05-01,1000,250\n"
+
+	if buf.String() != expected {
+		t.Errorf("Expected claim file %q, got %q", expected, buf.String())
+	}
+}
+
+func TestBonusesAreClaimedOnceAndCreditedWhenPaid(t *testing.T) {
+	conn, closeDown := NewTestDatabase()
+	defer closeDown()
+
+	var repo account.Repository = database.NewAccountRepository(conn)
+	var bonusRepo account.BonusRepository = database.NewBonusRepository(conn)
+
+	passingNiValidator := func(_ context.Context, _ string) error {
+		return nil
+	}
+
+	customer := account.Customer{
+		Id:           uuid.New(),
+		TaxResidency: "uk",
+		NINumber:     "ab 12 34 56 a",
+		DateOfBirth:  time.Now().AddDate(-30, 0, 0),
+	}
+
+	customers := account.NewStubCustomerClient(customer)
+
+	testFund := NewTestFund()
+	testFund.AccountTypes = append(testFund.AccountTypes, account.ACCOUNT_TYPE_LISA)
+	catalogue := NewTestCatalogue(testFund)
+
+	lisa := account.NewLISAService(&repo, &catalogue, NewTestRules(2000, 1000), account.StartOfTaxYear{1, 1}, passingNiValidator, customers)
+	service := account.NewBonusService(&bonusRepo, &repo, customers)
+
+	ctx := context.Background()
+
+	newAccount, err := lisa.CreateAccount(ctx, customer)
+
+	if err != nil {
+		t.Fatalf("unexpected error when creating LISA account: %v", err)
+	}
+
+	err = lisa.Invest(ctx, newAccount.Id, []account.Investment{
+		{FundId: testFund.Id, TradeId: uuid.New(), TransactionType: account.TRANSACTION_TYPE_CUSTOMER, Amount: 1000},
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error when investing in fund: %v", err)
+	}
+
+	// Contributions made this month aren't claimed until next month
+	result, err := service.ClaimBonuses(ctx, time.Now())
+
+	if err != nil {
+		t.Fatalf("unexpected error claiming bonuses: %v", err)
+	}
+
+	if len(result.Claims) != 0 {
+		t.Errorf("Expected no claims before the end of the month, got %v", result.Claims)
+	}
+
+	nextMonth := time.Now().AddDate(0, 1, 0)
+
+	result, err = service.ClaimBonuses(ctx, nextMonth)
+
+	if err != nil {
+		t.Fatalf("unexpected error claiming bonuses: %v", err)
+	}
+
+	if len(result.Claims) != 1 {
+		t.Fatalf("Expected a single claim, got %v (failed %v)", result.Claims, result.Failed)
+	}
+
+	claim := result.Claims[0]
+
+	if claim.Contributions != 1000 || claim.Bonus != 250 || claim.NINumber != "AB123456A" || claim.Status != account.BONUS_CLAIM_STATUS_SUBMITTED {
+		t.Errorf("Unexpected claim %+v", claim)
+	}
+
+	// Running the job again doesn't claim the same contributions
+	result, err = service.ClaimBonuses(ctx, nextMonth)
+
+	if err != nil {
+		t.Fatalf("unexpected error claiming bonuses: %v", err)
+	}
+
+	if len(result.Claims) != 0 || result.Skipped != 1 {
+		t.Errorf("Expected the account to be skipped, got %d claims and %d skipped", len(result.Claims), result.Skipped)
+	}
+
+	responses := []account.BonusClaimResponse{
+		{ClaimId: claim.Id, Status: account.BONUS_CLAIM_STATUS_PAID, Amount: 250},
+	}
+
+	// Ingesting the same response twice only pays the bonus once
+	for i, expected := range []account.BonusResponseResult{{Paid: 1}, {Skipped: 1}} {
+		ingested := service.IngestResponses(ctx, responses)
+
+		if ingested.Paid != expected.Paid || ingested.Skipped != expected.Skipped || len(ingested.Failed) != 0 {
+			t.Errorf("Ingest %d: expected %+v, got %+v", i+1, expected, ingested)
+		}
+	}
+
+	cash, err := repo.GetCashBalance(ctx, newAccount.Id)
+
+	if err != nil {
+		t.Fatalf("unexpected error fetching cash balance: %v", err)
+	}
+
+	if cash != 250 {
+		t.Errorf("Expected a cash balance of 250, got %d", cash)
+	}
+}
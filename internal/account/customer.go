@@ -1,9 +1,16 @@
 package account
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/internal/nivalidation"
 )
 
 // Retail account service representation of a Customer
@@ -11,32 +18,202 @@ import (
 // For brevity I have only included fields required to verify
 // a customer for an ISA account.
 type Customer struct {
-	Id           uuid.UUID
-	NINumber     string
-	TaxResidency string
-	DateOfBirth  time.Time
+	Id           uuid.UUID         `json:"id"`
+	NINumber     string            `json:"ni_number"`
+	TaxResidency string            `json:"tax_residency"`
+	DateOfBirth  time.Time         `json:"date_of_birth"`
+	Errors       map[string]string `json:"errors"`
 }
 
-// Ensure NI number is Valid
+// Validate the customer's details before they are checked externally
 //
-// This a a dummy function to make an external call to an existing
-// service in order to verify a National insurance number.
-// Returns an error if the number is not valid.
-func ValidateNINumber(ni string) error {
+// Any errors are stored in a map using the json struct tag
+// so that they can be returned straight back to the UI.
+func (c *Customer) Validate() bool {
+	if c.Errors == nil {
+		c.Errors = make(map[string]string, 1)
+	}
 
-	// make external call to NI Validation service
+	if err := nivalidation.CheckFormat(c.NINumber); err != nil {
+		c.Errors["ni_number"] = err.Error()
+	}
+
+	return len(c.Errors) == 0
+}
+
+// Check the customer's NI number with the validator
+//
+// The number is normalised and its format checked before the validator is
+// called. A number that is rejected returns ErrAccountCreatePermission. If
+// the validation service can't be reached the error is returned as is so an
+// outage isn't mistaken for an invalid number.
+func verifyNINumber(ctx context.Context, niValidator func(context.Context, string) error, customer *Customer) error {
+	customer.NINumber = nivalidation.Normalise(customer.NINumber)
+
+	if !customer.Validate() {
+		return ErrAccountCreatePermission{"Customer NI number could not be verified: " + customer.Errors["ni_number"]}
+	}
+
+	err := niValidator(ctx, customer.NINumber)
+
+	if errors.Is(err, nivalidation.ErrUnavailable) {
+		return fmt.Errorf("Unable to verify NI number: %w", err)
+	}
+
+	if err != nil {
+		return ErrAccountCreatePermission{"Customer NI number could not be verified: " + err.Error()}
+	}
 
 	return nil
 }
 
-// Retrieve customer entity by id
+var ErrCustomerNotFound = errors.New("Customer not found")
+
+// Client for the retail customer service
+type CustomerClient interface {
+	// Return the customer with the id
+	//
+	// Returns ErrCustomerNotFound if the customer does not exist.
+	GetCustomer(ctx context.Context, id uuid.UUID) (Customer, error)
+}
+
+// Customer client for the retail customer service's REST API
+//
+// Customers are fetched from GET {url}/api/v1/customer/{id}.
+type HTTPCustomerClient struct {
+	url    string
+	client *http.Client
+}
+
+func NewHTTPCustomerClient(url string, client *http.Client) *HTTPCustomerClient {
+	return &HTTPCustomerClient{
+		url:    url,
+		client: client,
+	}
+}
+
+func (c *HTTPCustomerClient) GetCustomer(ctx context.Context, id uuid.UUID) (Customer, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+"/api/v1/customer/"+id.String(), nil)
+
+	if err != nil {
+		return Customer{}, fmt.Errorf("HTTPCustomerClient.GetCustomer: Unable to create request: %v", err)
+	}
+
+	resp, err := c.client.Do(req)
+
+	if err != nil {
+		return Customer{}, fmt.Errorf("HTTPCustomerClient.GetCustomer: Unable to fetch customer: %v", err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return Customer{}, ErrCustomerNotFound
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return Customer{}, fmt.Errorf("HTTPCustomerClient.GetCustomer: Unexpected status code %d", resp.StatusCode)
+	}
+
+	var customer Customer
+
+	err = json.NewDecoder(resp.Body).Decode(&customer)
+
+	if err != nil {
+		return Customer{}, fmt.Errorf("HTTPCustomerClient.GetCustomer: Unable to decode customer: %v", err)
+	}
+
+	return customer, nil
+}
+
+// Customer client returning a fixed set of customers
+//
+// Used locally and in tests in place of the retail customer service.
+type StubCustomerClient struct {
+	customers map[uuid.UUID]Customer
+}
+
+func NewStubCustomerClient(customers ...Customer) *StubCustomerClient {
+	c := &StubCustomerClient{customers: make(map[uuid.UUID]Customer, len(customers))}
+
+	for _, customer := range customers {
+		c.customers[customer.Id] = customer
+	}
+
+	return c
+}
+
+func (c *StubCustomerClient) GetCustomer(_ context.Context, id uuid.UUID) (Customer, error) {
+	customer, ok := c.customers[id]
+
+	if !ok {
+		return Customer{}, ErrCustomerNotFound
+	}
+
+	return customer, nil
+}
+
+type cachedCustomer struct {
+	customer  Customer
+	expiresAt time.Time
+}
+
+// Customer client that caches customers from another client
 //
-// This is another dummy function to make a call to the external
-// retail customer service to fetch the Customer information.
-// Returns an error if the customer does not exist.
-func GetCustomer(id uuid.UUID) (Customer, error) {
+// Customers are kept for the TTL, which should be short so changes made in
+// the retail customer service are picked up quickly. Errors (including
+// ErrCustomerNotFound) aren't cached. Safe for concurrent use.
+type CachedCustomerClient struct {
+	client    CustomerClient
+	ttl       time.Duration
+	now       func() time.Time
+	mu        sync.Mutex
+	customers map[uuid.UUID]cachedCustomer
+	swept     time.Time
+}
+
+func NewCachedCustomerClient(client CustomerClient, ttl time.Duration) *CachedCustomerClient {
+	return &CachedCustomerClient{
+		client:    client,
+		ttl:       ttl,
+		now:       time.Now,
+		customers: make(map[uuid.UUID]cachedCustomer),
+	}
+}
+
+func (c *CachedCustomerClient) GetCustomer(ctx context.Context, id uuid.UUID) (Customer, error) {
+	now := c.now()
+
+	c.mu.Lock()
+	cached, ok := c.customers[id]
+	c.mu.Unlock()
+
+	if ok && now.Before(cached.expiresAt) {
+		return cached.customer, nil
+	}
+
+	customer, err := c.client.GetCustomer(ctx, id)
+
+	if err != nil {
+		return Customer{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Remove expired customers at most once per TTL so the cache doesn't
+	// grow with customers that aren't looked up again.
+	if !now.Before(c.swept.Add(c.ttl)) {
+		for cachedId, cached := range c.customers {
+			if !now.Before(cached.expiresAt) {
+				delete(c.customers, cachedId)
+			}
+		}
+
+		c.swept = now
+	}
 
-	// Make external call to retail customer service
+	c.customers[id] = cachedCustomer{customer: customer, expiresAt: now.Add(c.ttl)}
 
-	return Customer{}, nil
+	return customer, nil
 }
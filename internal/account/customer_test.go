@@ -0,0 +1,186 @@
+package account_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/internal/account"
+)
+
+func TestCustomerValidation(t *testing.T) {
+	type testCase struct {
+		name           string
+		customer       account.Customer
+		isValid        bool
+		expectedErrors []string
+	}
+
+	cases := []testCase{
+		{
+			name:           "NI number missing",
+			customer:       account.Customer{},
+			isValid:        false,
+			expectedErrors: []string{"ni_number"},
+		},
+		{
+			name:           "NI number has the wrong format",
+			customer:       account.Customer{NINumber: "AB12345"},
+			isValid:        false,
+			expectedErrors: []string{"ni_number"},
+		},
+		{
+			name:           "NI number prefix not used by HMRC",
+			customer:       account.Customer{NINumber: "GB123456A"},
+			isValid:        false,
+			expectedErrors: []string{"ni_number"},
+		},
+		{
+			name:           "Valid customer",
+			customer:       account.Customer{NINumber: "ab 12 34 56 a"},
+			isValid:        true,
+			expectedErrors: []string{},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			isValid := testCase.customer.Validate()
+
+			if isValid != testCase.isValid {
+				t.Errorf("Expected Validate to return %t, got %t", testCase.isValid, isValid)
+			}
+
+			if len(testCase.customer.Errors) != len(testCase.expectedErrors) {
+				t.Errorf("Expected %d validation errors, got %d", len(testCase.expectedErrors), len(testCase.customer.Errors))
+			}
+
+			for _, field := range testCase.expectedErrors {
+				if _, ok := testCase.customer.Errors[field]; !ok {
+					t.Errorf("expected validation error field '%s' missing", field)
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPCustomerClient(t *testing.T) {
+	customer := account.Customer{
+		Id:           uuid.New(),
+		NINumber:     "AB123456A",
+		TaxResidency: "uk",
+		DateOfBirth:  time.Date(1990, 1, 2, 0, 0, 0, 0, time.UTC),
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/customer/"+customer.Id.String() {
+			http.NotFound(w, r)
+			return
+		}
+
+		json.NewEncoder(w).Encode(customer)
+	}))
+	defer server.Close()
+
+	client := account.NewHTTPCustomerClient(server.URL, server.Client())
+
+	ctx := context.Background()
+
+	fetched, err := client.GetCustomer(ctx, customer.Id)
+
+	if err != nil {
+		t.Fatalf("unexpected error fetching customer: %v", err)
+	}
+
+	if fetched.TaxResidency != "uk" || !fetched.DateOfBirth.Equal(customer.DateOfBirth) {
+		t.Errorf("Expected customer %+v, got %+v", customer, fetched)
+	}
+
+	_, err = client.GetCustomer(ctx, uuid.New())
+
+	if !errors.Is(err, account.ErrCustomerNotFound) {
+		t.Errorf("Expected error %v, got %v", account.ErrCustomerNotFound, err)
+	}
+}
+
+// Customer client that counts the lookups made
+type countingCustomerClient struct {
+	account.CustomerClient
+	lookups int
+}
+
+func (c *countingCustomerClient) GetCustomer(ctx context.Context, id uuid.UUID) (account.Customer, error) {
+	c.lookups++
+
+	return c.CustomerClient.GetCustomer(ctx, id)
+}
+
+func TestCachedCustomerClient(t *testing.T) {
+	customer := account.Customer{Id: uuid.New(), NINumber: "AB123456A"}
+
+	stub := &countingCustomerClient{CustomerClient: account.NewStubCustomerClient(customer)}
+
+	ttl := 50 * time.Millisecond
+	client := account.NewCachedCustomerClient(stub, ttl)
+
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetCustomer(ctx, customer.Id); err != nil {
+			t.Fatalf("unexpected error fetching customer: %v", err)
+		}
+	}
+
+	if stub.lookups != 1 {
+		t.Errorf("Expected the customer to be fetched once, got %d lookups", stub.lookups)
+	}
+
+	// Customers that don't exist aren't cached
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetCustomer(ctx, uuid.New()); !errors.Is(err, account.ErrCustomerNotFound) {
+			t.Errorf("Expected error %v, got %v", account.ErrCustomerNotFound, err)
+		}
+	}
+
+	time.Sleep(ttl)
+
+	if _, err := client.GetCustomer(ctx, customer.Id); err != nil {
+		t.Fatalf("unexpected error fetching customer: %v", err)
+	}
+
+	if stub.lookups != 4 {
+		t.Errorf("Expected the customer to be fetched again after the TTL, got %d lookups", stub.lookups)
+	}
+}
+
+func TestPostAccountHandlerReturnsNotFoundForUnknownCustomer(t *testing.T) {
+	// The customer is rejected before the repository is used
+	var repo account.Repository
+
+	passingNiValidator := func(_ context.Context, _ string) error {
+		return nil
+	}
+
+	catalogue := NewTestCatalogue()
+	isa := account.NewISAService(&repo, &catalogue, NewTestRules(0, 0), account.ACCOUNT_TYPE_ISA, false, account.StartOfTaxYear{1, 1}, passingNiValidator)
+	serviceFactory := NewTestServiceFactory(&repo, isa)
+
+	handler := account.PostAccountHandler(*serviceFactory, account.NewStubCustomerClient())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/account", strings.NewReader(`{"account_type": "isa"}`))
+	req.Header.Set(account.SESSION_CUSTOMER_HEADER, uuid.New().String())
+
+	w := httptest.NewRecorder()
+
+	handler(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
@@ -0,0 +1,471 @@
+package account
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/internal/fund"
+)
+
+const (
+	// The payment has been requested from the payments service
+	DEPOSIT_STATUS_INITIATED string = "initiated"
+	// The payment has been authorised but the money has not arrived
+	DEPOSIT_STATUS_AUTHORISED string = "authorised"
+	// The money has arrived and the investments have been made
+	DEPOSIT_STATUS_SETTLED string = "settled"
+	// The payment failed, no investments were made
+	DEPOSIT_STATUS_FAILED string = "failed"
+)
+
+const (
+	PAYMENT_METHOD_CARD          string = "card"
+	PAYMENT_METHOD_BANK_TRANSFER string = "bank_transfer"
+	// Used by regular investment plans
+	PAYMENT_METHOD_DIRECT_DEBIT string = "direct_debit"
+)
+
+var ErrDepositInvalid = errors.New("Deposit invalid")
+var ErrDepositNotFound = errors.New("Deposit not found")
+var ErrDepositStatusInvalid = errors.New("Deposit cannot move to this status")
+var ErrDepositDuplicate = errors.New("Deposit has already been made")
+
+// Valid status transitions for a deposit, settled and failed are final
+var depositTransitions = map[string][]string{
+	DEPOSIT_STATUS_INITIATED:  {DEPOSIT_STATUS_AUTHORISED, DEPOSIT_STATUS_SETTLED, DEPOSIT_STATUS_FAILED},
+	DEPOSIT_STATUS_AUTHORISED: {DEPOSIT_STATUS_SETTLED, DEPOSIT_STATUS_FAILED},
+}
+
+// Request to pay money into an account and invest it
+type DepositRequest struct {
+	AllocationRequest
+	PaymentMethod string `json:"payment_method"`
+}
+
+// Validate a DepositRequest
+//
+// Any errors are stored in a map using the json struct tag
+// so that they can be returned straight back to the UI.
+func (r *DepositRequest) Validate() bool {
+	r.AllocationRequest.Validate()
+
+	if !slices.Contains([]string{PAYMENT_METHOD_CARD, PAYMENT_METHOD_BANK_TRANSFER, PAYMENT_METHOD_DIRECT_DEBIT}, r.PaymentMethod) {
+		r.Errors["payment_method"] = "Payment method invalid or missing"
+	}
+
+	return len(r.Errors) == 0
+}
+
+// Money paid into an account that is waiting to be invested
+//
+// The allocations are only invested once the payment has settled.
+type Deposit struct {
+	Id               uuid.UUID    `json:"id"`
+	AccountId        uuid.UUID    `json:"account_id"`
+	Amount           int          `json:"amount"`
+	Allocations      []Allocation `json:"allocations"`
+	PaymentMethod    string       `json:"payment_method"`
+	PaymentReference string       `json:"payment_reference"`
+	Status           string       `json:"status"`
+	// Identifies the payment for deposits made on a schedule (e.g. a plan's
+	// due date), empty for one-off deposits
+	Reference string    `json:"reference,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Client for the existing payments service
+//
+// The payments service takes card and bank transfer payments, the status
+// of a payment is one of the DEPOSIT_STATUS_* constants.
+type PaymentsClient interface {
+	// Request a payment for the deposit, returns the payment reference
+	InitiatePayment(ctx context.Context, deposit Deposit) (string, error)
+
+	// Return the current status of a payment
+	PaymentStatus(ctx context.Context, reference string) (string, error)
+}
+
+type paymentRequest struct {
+	DepositId     uuid.UUID `json:"deposit_id"`
+	AccountId     uuid.UUID `json:"account_id"`
+	Amount        int       `json:"amount"`
+	PaymentMethod string    `json:"payment_method"`
+}
+
+type paymentResponse struct {
+	Reference string `json:"reference"`
+	Status    string `json:"status"`
+}
+
+// Client for the payments service over HTTP
+type HTTPPaymentsClient struct {
+	url    string
+	client *http.Client
+}
+
+func NewHTTPPaymentsClient(url string, client *http.Client) *HTTPPaymentsClient {
+	return &HTTPPaymentsClient{
+		url:    url,
+		client: client,
+	}
+}
+
+func (c *HTTPPaymentsClient) InitiatePayment(ctx context.Context, deposit Deposit) (string, error) {
+	body, err := json.Marshal(paymentRequest{
+		DepositId:     deposit.Id,
+		AccountId:     deposit.AccountId,
+		Amount:        deposit.Amount,
+		PaymentMethod: deposit.PaymentMethod,
+	})
+
+	if err != nil {
+		return "", fmt.Errorf("HTTPPaymentsClient.InitiatePayment: Unable to encode request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+"/api/v1/payments", bytes.NewReader(body))
+
+	if err != nil {
+		return "", fmt.Errorf("HTTPPaymentsClient.InitiatePayment: Unable to create request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	payment, err := c.do(req, http.StatusCreated)
+
+	if err != nil {
+		return "", fmt.Errorf("HTTPPaymentsClient.InitiatePayment: %v", err)
+	}
+
+	if payment.Reference == "" {
+		return "", errors.New("HTTPPaymentsClient.InitiatePayment: No payment reference returned")
+	}
+
+	return payment.Reference, nil
+}
+
+func (c *HTTPPaymentsClient) PaymentStatus(ctx context.Context, reference string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+"/api/v1/payments/"+url.PathEscape(reference), nil)
+
+	if err != nil {
+		return "", fmt.Errorf("HTTPPaymentsClient.PaymentStatus: Unable to create request: %v", err)
+	}
+
+	payment, err := c.do(req, http.StatusOK)
+
+	if err != nil {
+		return "", fmt.Errorf("HTTPPaymentsClient.PaymentStatus: %v", err)
+	}
+
+	if !slices.Contains([]string{DEPOSIT_STATUS_INITIATED, DEPOSIT_STATUS_AUTHORISED, DEPOSIT_STATUS_SETTLED, DEPOSIT_STATUS_FAILED}, payment.Status) {
+		return "", fmt.Errorf("HTTPPaymentsClient.PaymentStatus: Unknown status '%s'", payment.Status)
+	}
+
+	return payment.Status, nil
+}
+
+func (c *HTTPPaymentsClient) do(req *http.Request, expectedStatus int) (paymentResponse, error) {
+	resp, err := c.client.Do(req)
+
+	if err != nil {
+		return paymentResponse{}, fmt.Errorf("Unable to reach the payments service: %v", err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != expectedStatus {
+		return paymentResponse{}, fmt.Errorf("Unexpected status code %d", resp.StatusCode)
+	}
+
+	var payment paymentResponse
+
+	err = json.NewDecoder(resp.Body).Decode(&payment)
+
+	if err != nil {
+		return paymentResponse{}, fmt.Errorf("Unable to decode payment: %v", err)
+	}
+
+	return payment, nil
+}
+
+// Local stand-in for the payments service, only used in tests
+//
+// Payments are initiated with the status DEPOSIT_STATUS_INITIATED and only
+// move on when SetStatus is called. Safe for concurrent use.
+type StubPaymentsClient struct {
+	mu       sync.Mutex
+	statuses map[string]string
+}
+
+func NewStubPaymentsClient() *StubPaymentsClient {
+	return &StubPaymentsClient{statuses: make(map[string]string)}
+}
+
+func (c *StubPaymentsClient) InitiatePayment(_ context.Context, deposit Deposit) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	reference := "stub-" + deposit.Id.String()
+
+	c.statuses[reference] = DEPOSIT_STATUS_INITIATED
+
+	return reference, nil
+}
+
+func (c *StubPaymentsClient) PaymentStatus(_ context.Context, reference string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	status, ok := c.statuses[reference]
+
+	if !ok {
+		return "", fmt.Errorf("StubPaymentsClient.PaymentStatus: unknown reference '%s'", reference)
+	}
+
+	return status, nil
+}
+
+func (c *StubPaymentsClient) SetStatus(reference string, status string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.statuses[reference] = status
+}
+
+// Responsible for storing deposits, any params passed in are assumed
+// to be valid.
+//
+// Methods should be accessed through the DepositService
+type DepositRepository interface {
+	// Create a new deposit
+	//
+	// Returns ErrDepositDuplicate if a deposit with the same reference exists
+	// that hasn't failed.
+	CreateDeposit(ctx context.Context, deposit Deposit) error
+
+	// Return the deposit with the given id
+	//
+	// Returns ErrDepositNotFound if the deposit does not exist.
+	GetDeposit(ctx context.Context, depositId uuid.UUID) (Deposit, error)
+
+	// Return the deposit with the given reference
+	//
+	// A deposit that hasn't failed is returned over any that have, otherwise
+	// the latest. Returns ErrDepositNotFound if no deposit has the reference.
+	GetDepositByReference(ctx context.Context, reference string) (Deposit, error)
+
+	// Return every deposit that has not settled or failed
+	GetPendingDeposits(ctx context.Context) ([]Deposit, error)
+
+	// Update the status and payment reference of a deposit
+	//
+	// Only deposits that have not settled or failed are updated, returns
+	// ErrDepositStatusInvalid otherwise.
+	UpdateDeposit(ctx context.Context, deposit Deposit) error
+
+	// Mark the deposit as settled and make the investments
+	//
+	// Both happen in a single transaction so the investments are made exactly once.
+	// The investments count as subscriptions made when the deposit was created.
+	SettleDeposit(ctx context.Context, deposit Deposit, investments []Investment) error
+}
+
+// Service to take payments into accounts
+//
+// Investments are checked by the account's Service when the deposit is made,
+// from that point the deposit provisionally uses up any allowance in the tax
+// year it was made. The investments are only made once the payment has
+// settled, if the payment fails the provisional allowance is released.
+type DepositService struct {
+	repository     DepositRepository
+	serviceFactory *ServiceFactory
+	catalogue      fund.Catalogue
+	payments       PaymentsClient
+}
+
+func NewDepositService(repository *DepositRepository, serviceFactory *ServiceFactory, catalogue *fund.Catalogue, payments PaymentsClient) *DepositService {
+	return &DepositService{
+		repository:     *repository,
+		serviceFactory: serviceFactory,
+		catalogue:      *catalogue,
+		payments:       payments,
+	}
+}
+
+// Pay money into an account to be invested once the payment settles
+//
+// Returns ErrDepositInvalid if the request or resulting investments are
+// invalid (errors are stored on the request), or the account Service's
+// error if the investments would break its rules (e.g. ErrExceededISALimit).
+func (s *DepositService) CreateDeposit(ctx context.Context, accountId uuid.UUID, request *DepositRequest) (Deposit, error) {
+	return s.createDeposit(ctx, accountId, request, "")
+}
+
+// Create a deposit with a reference unique to its source
+//
+// Returns ErrDepositDuplicate without taking a payment if a deposit with the
+// reference has already been made. Failed deposits don't hold on to their
+// reference so a payment that couldn't be taken can be tried again.
+func (s *DepositService) createDeposit(ctx context.Context, accountId uuid.UUID, request *DepositRequest, reference string) (Deposit, error) {
+	if !request.Validate() {
+		return Deposit{}, ErrDepositInvalid
+	}
+
+	service, _, err := s.serviceFactory.ServiceForAccount(ctx, accountId)
+
+	if err != nil {
+		return Deposit{}, err
+	}
+
+	err = checkAllocation(ctx, service, accountId, &request.AllocationRequest)
+
+	if errors.Is(err, ErrAllocationInvalid) {
+		return Deposit{}, ErrDepositInvalid
+	}
+
+	if err != nil {
+		return Deposit{}, err
+	}
+
+	deposit := Deposit{
+		Id:            uuid.New(),
+		AccountId:     accountId,
+		Amount:        request.Amount,
+		Allocations:   request.Allocations,
+		PaymentMethod: request.PaymentMethod,
+		Status:        DEPOSIT_STATUS_INITIATED,
+		Reference:     reference,
+	}
+
+	// The deposit is stored before the payment is requested so the allowance
+	// is reserved even if the payment settles straight away.
+	err = s.repository.CreateDeposit(ctx, deposit)
+
+	if errors.Is(err, ErrDepositDuplicate) {
+		return Deposit{}, err
+	}
+
+	if err != nil {
+		return Deposit{}, fmt.Errorf("Unable to create deposit: %w", err)
+	}
+
+	deposit.PaymentReference, err = s.payments.InitiatePayment(ctx, deposit)
+
+	if err != nil {
+		deposit.Status = DEPOSIT_STATUS_FAILED
+
+		if updateErr := s.repository.UpdateDeposit(ctx, deposit); updateErr != nil {
+			return deposit, fmt.Errorf("Unable to mark deposit as failed: %w", updateErr)
+		}
+
+		return deposit, fmt.Errorf("Unable to initiate payment: %w", err)
+	}
+
+	err = s.repository.UpdateDeposit(ctx, deposit)
+
+	if err != nil {
+		return deposit, fmt.Errorf("Unable to store payment reference: %w", err)
+	}
+
+	return deposit, nil
+}
+
+func (s *DepositService) Deposit(ctx context.Context, depositId uuid.UUID) (Deposit, error) {
+	return s.repository.GetDeposit(ctx, depositId)
+}
+
+// Move a deposit to a new status
+//
+// When the deposit settles the investments are made, when it fails nothing
+// is invested and the deposit no longer counts towards any allowance.
+// Returns ErrDepositStatusInvalid if the transition is not allowed. If a fund
+// is no longer available to the account when the deposit settles, the deposit
+// fails instead and ErrInvestmentInvalid is returned so the payment can be
+// refunded.
+func (s *DepositService) UpdateStatus(ctx context.Context, depositId uuid.UUID, status string) error {
+	deposit, err := s.repository.GetDeposit(ctx, depositId)
+
+	if err != nil {
+		return err
+	}
+
+	if deposit.Status == status {
+		return nil
+	}
+
+	if !slices.Contains(depositTransitions[deposit.Status], status) {
+		return ErrDepositStatusInvalid
+	}
+
+	deposit.Status = status
+
+	if status != DEPOSIT_STATUS_SETTLED {
+		return s.repository.UpdateDeposit(ctx, deposit)
+	}
+
+	request := AllocationRequest{Amount: deposit.Amount, Allocations: deposit.Allocations}
+	investments := request.Investments(TRANSACTION_TYPE_CUSTOMER)
+
+	// Funds may have closed since the deposit was made
+	account, err := s.serviceFactory.repository.GetAccount(ctx, deposit.AccountId)
+
+	if err != nil {
+		return err
+	}
+
+	err = validateInvestments(ctx, s.serviceFactory.repository, s.catalogue, account.AccountType, account.Id, investments)
+
+	if errors.Is(err, ErrInvestmentInvalid) {
+		deposit.Status = DEPOSIT_STATUS_FAILED
+
+		if updateErr := s.repository.UpdateDeposit(ctx, deposit); updateErr != nil {
+			return fmt.Errorf("Unable to mark deposit as failed: %w", updateErr)
+		}
+
+		return fmt.Errorf("Deposit failed as its investments are no longer valid: %w", err)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return s.repository.SettleDeposit(ctx, deposit, investments)
+}
+
+// Fetch the latest status of every pending deposit from the payments service
+//
+// Returns a map of deposit ids to errors for any deposits that could not be updated.
+func (s *DepositService) SyncPendingDeposits(ctx context.Context) (map[uuid.UUID]error, error) {
+	failed := make(map[uuid.UUID]error)
+
+	deposits, err := s.repository.GetPendingDeposits(ctx)
+
+	if err != nil {
+		return failed, fmt.Errorf("Unable to fetch pending deposits: %w", err)
+	}
+
+	for _, deposit := range deposits {
+		status, err := s.payments.PaymentStatus(ctx, deposit.PaymentReference)
+
+		if err != nil {
+			failed[deposit.Id] = err
+			continue
+		}
+
+		err = s.UpdateStatus(ctx, deposit.Id, status)
+
+		if err != nil {
+			failed[deposit.Id] = err
+		}
+	}
+
+	return failed, nil
+}
@@ -0,0 +1,454 @@
+package account_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/database"
+	"github.com/jameswhoughton/cushon/internal/account"
+	"github.com/jameswhoughton/cushon/internal/fund"
+)
+
+func TestDepositRequestValidation(t *testing.T) {
+	allocations := []account.Allocation{{FundId: uuid.New(), Percentage: 100}}
+
+	type testCase struct {
+		name           string
+		request        account.DepositRequest
+		isValid        bool
+		expectedErrors []string
+	}
+
+	cases := []testCase{
+		{
+			name:           "Payment method missing",
+			request:        account.DepositRequest{AllocationRequest: account.AllocationRequest{Amount: 100, Allocations: allocations}},
+			isValid:        false,
+			expectedErrors: []string{"payment_method"},
+		},
+		{
+			name:           "Payment method and amount invalid",
+			request:        account.DepositRequest{AllocationRequest: account.AllocationRequest{Allocations: allocations}, PaymentMethod: "cash"},
+			isValid:        false,
+			expectedErrors: []string{"amount", "payment_method"},
+		},
+		{
+			name:           "Valid request",
+			request:        account.DepositRequest{AllocationRequest: account.AllocationRequest{Amount: 100, Allocations: allocations}, PaymentMethod: account.PAYMENT_METHOD_CARD},
+			isValid:        true,
+			expectedErrors: []string{},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			isValid := testCase.request.Validate()
+
+			if isValid != testCase.isValid {
+				t.Errorf("Expected Validate to return %t, got %t", testCase.isValid, isValid)
+			}
+
+			if len(testCase.request.Errors) != len(testCase.expectedErrors) {
+				t.Errorf("Expected %d validation errors, got %d", len(testCase.expectedErrors), len(testCase.request.Errors))
+			}
+
+			for _, field := range testCase.expectedErrors {
+				if _, ok := testCase.request.Errors[field]; !ok {
+					t.Errorf("expected validation error field '%s' missing", field)
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPPaymentsClient(t *testing.T) {
+	deposit := account.Deposit{Id: uuid.New(), AccountId: uuid.New(), Amount: 1000, PaymentMethod: account.PAYMENT_METHOD_CARD}
+
+	statuses := map[string]string{"pay-1": account.DEPOSIT_STATUS_SETTLED, "pay-2": "refunded"}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /api/v1/payments", func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]any
+
+		json.NewDecoder(r.Body).Decode(&request)
+
+		if request["deposit_id"] != deposit.Id.String() || request["amount"] != float64(1000) {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"reference": "pay-1"})
+	})
+
+	mux.HandleFunc("GET /api/v1/payments/{reference}", func(w http.ResponseWriter, r *http.Request) {
+		status, ok := statuses[r.PathValue("reference")]
+
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{"reference": r.PathValue("reference"), "status": status})
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := account.NewHTTPPaymentsClient(server.URL, server.Client())
+
+	ctx := context.Background()
+
+	reference, err := client.InitiatePayment(ctx, deposit)
+
+	if err != nil || reference != "pay-1" {
+		t.Fatalf("Expected the reference pay-1, got '%s' (%v)", reference, err)
+	}
+
+	status, err := client.PaymentStatus(ctx, reference)
+
+	if err != nil || status != account.DEPOSIT_STATUS_SETTLED {
+		t.Errorf("Expected the status %s, got '%s' (%v)", account.DEPOSIT_STATUS_SETTLED, status, err)
+	}
+
+	if _, err := client.PaymentStatus(ctx, "pay-2"); err == nil {
+		t.Error("Expected an error for an unknown status")
+	}
+
+	if _, err := client.PaymentStatus(ctx, "pay-3"); err == nil {
+		t.Error("Expected an error for an unknown payment")
+	}
+}
+
+func TestDepositsAreOnlyInvestedOnceSettled(t *testing.T) {
+	conn, closeDown := NewTestDatabase()
+	defer closeDown()
+
+	var repo account.Repository = database.NewAccountRepository(conn)
+	var depositRepo account.DepositRepository = database.NewDepositRepository(conn)
+
+	passingNiValidator := func(_ context.Context, _ string) error {
+		return nil
+	}
+
+	testFund := NewTestFund()
+	catalogue := NewTestCatalogue(testFund)
+
+	isa := account.NewISAService(&repo, &catalogue, NewTestRules(150, 0), account.ACCOUNT_TYPE_ISA, false, account.StartOfTaxYear{1, 1}, passingNiValidator)
+	payments := account.NewStubPaymentsClient()
+	service := account.NewDepositService(&depositRepo, NewTestServiceFactory(&repo, isa), &catalogue, payments)
+
+	ctx := context.Background()
+
+	customer := account.Customer{
+		Id:           uuid.New(),
+		TaxResidency: "uk",
+		NINumber:     "AB123456A",
+		DateOfBirth:  time.Now().AddDate(-20, 0, 0),
+	}
+
+	newAccount, err := isa.CreateAccount(ctx, customer)
+
+	if err != nil {
+		t.Errorf("unexpected error when creating ISA account: %v", err)
+	}
+
+	request := account.DepositRequest{
+		AllocationRequest: account.AllocationRequest{
+			Amount:      100,
+			Allocations: []account.Allocation{{FundId: testFund.Id, Percentage: 100}},
+		},
+		PaymentMethod: account.PAYMENT_METHOD_CARD,
+	}
+
+	deposit, err := service.CreateDeposit(ctx, newAccount.Id, &request)
+
+	if err != nil {
+		t.Fatalf("unexpected error when creating deposit: %v", err)
+	}
+
+	accountFunds, err := repo.GetAccountFunds(ctx, newAccount.Id)
+
+	if err != nil {
+		t.Errorf("unexpected error fetching account funds: %v", err)
+	}
+
+	if len(accountFunds) != 0 {
+		t.Errorf("Expected nothing to be invested before settlement, found %d account funds", len(accountFunds))
+	}
+
+	payments.SetStatus(deposit.PaymentReference, account.DEPOSIT_STATUS_AUTHORISED)
+	payments.SetStatus(deposit.PaymentReference, account.DEPOSIT_STATUS_SETTLED)
+
+	failed, err := service.SyncPendingDeposits(ctx)
+
+	if err != nil || len(failed) > 0 {
+		t.Errorf("unexpected error syncing deposits: %v %v", err, failed)
+	}
+
+	settled, err := service.Deposit(ctx, deposit.Id)
+
+	if err != nil {
+		t.Errorf("unexpected error fetching deposit: %v", err)
+	}
+
+	if settled.Status != account.DEPOSIT_STATUS_SETTLED {
+		t.Errorf("Expected deposit status %s, got %s", account.DEPOSIT_STATUS_SETTLED, settled.Status)
+	}
+
+	accountFunds, err = repo.GetAccountFunds(ctx, newAccount.Id)
+
+	if err != nil {
+		t.Errorf("unexpected error fetching account funds: %v", err)
+	}
+
+	if len(accountFunds) != 1 || accountFunds[0].Balance != 100 {
+		t.Errorf("Expected a balance of 100 after settlement, got %v", accountFunds)
+	}
+
+	err = service.UpdateStatus(ctx, deposit.Id, account.DEPOSIT_STATUS_FAILED)
+
+	if !errors.Is(err, account.ErrDepositStatusInvalid) {
+		t.Errorf("Expected error %v, got %v", account.ErrDepositStatusInvalid, err)
+	}
+}
+
+func TestAFailedDepositReleasesTheAllowance(t *testing.T) {
+	conn, closeDown := NewTestDatabase()
+	defer closeDown()
+
+	var repo account.Repository = database.NewAccountRepository(conn)
+	var depositRepo account.DepositRepository = database.NewDepositRepository(conn)
+
+	passingNiValidator := func(_ context.Context, _ string) error {
+		return nil
+	}
+
+	testFund := NewTestFund()
+	catalogue := NewTestCatalogue(testFund)
+
+	isa := account.NewISAService(&repo, &catalogue, NewTestRules(150, 0), account.ACCOUNT_TYPE_ISA, false, account.StartOfTaxYear{1, 1}, passingNiValidator)
+	service := account.NewDepositService(&depositRepo, NewTestServiceFactory(&repo, isa), &catalogue, account.NewStubPaymentsClient())
+
+	ctx := context.Background()
+
+	customer := account.Customer{
+		Id:           uuid.New(),
+		TaxResidency: "uk",
+		NINumber:     "AB123456A",
+		DateOfBirth:  time.Now().AddDate(-20, 0, 0),
+	}
+
+	newAccount, err := isa.CreateAccount(ctx, customer)
+
+	if err != nil {
+		t.Errorf("unexpected error when creating ISA account: %v", err)
+	}
+
+	newRequest := func() *account.DepositRequest {
+		return &account.DepositRequest{
+			AllocationRequest: account.AllocationRequest{
+				Amount:      100,
+				Allocations: []account.Allocation{{FundId: testFund.Id, Percentage: 100}},
+			},
+			PaymentMethod: account.PAYMENT_METHOD_BANK_TRANSFER,
+		}
+	}
+
+	deposit, err := service.CreateDeposit(ctx, newAccount.Id, newRequest())
+
+	if err != nil {
+		t.Fatalf("unexpected error when creating deposit: %v", err)
+	}
+
+	// The pending deposit uses up the allowance
+	_, err = service.CreateDeposit(ctx, newAccount.Id, newRequest())
+
+	if !errors.Is(err, account.ErrExceededISALimit) {
+		t.Errorf("Expected error %v, got %v", account.ErrExceededISALimit, err)
+	}
+
+	err = service.UpdateStatus(ctx, deposit.Id, account.DEPOSIT_STATUS_FAILED)
+
+	if err != nil {
+		t.Errorf("unexpected error failing deposit: %v", err)
+	}
+
+	_, err = service.CreateDeposit(ctx, newAccount.Id, newRequest())
+
+	if err != nil {
+		t.Errorf("unexpected error when creating deposit after failure: %v", err)
+	}
+}
+
+func TestDepositsFailIfTheFundClosesBeforeSettlement(t *testing.T) {
+	conn, closeDown := NewTestDatabase()
+	defer closeDown()
+
+	var repo account.Repository = database.NewAccountRepository(conn)
+	var depositRepo account.DepositRepository = database.NewDepositRepository(conn)
+
+	passingNiValidator := func(_ context.Context, _ string) error {
+		return nil
+	}
+
+	testFund := NewTestFund()
+	catalogue := NewTestCatalogue(testFund)
+
+	isa := account.NewISAService(&repo, &catalogue, NewTestRules(150, 0), account.ACCOUNT_TYPE_ISA, false, account.StartOfTaxYear{1, 1}, passingNiValidator)
+	service := account.NewDepositService(&depositRepo, NewTestServiceFactory(&repo, isa), &catalogue, account.NewStubPaymentsClient())
+
+	ctx := context.Background()
+
+	customer := account.Customer{
+		Id:           uuid.New(),
+		TaxResidency: "uk",
+		NINumber:     "AB123456A",
+		DateOfBirth:  time.Now().AddDate(-20, 0, 0),
+	}
+
+	newAccount, err := isa.CreateAccount(ctx, customer)
+
+	if err != nil {
+		t.Errorf("unexpected error when creating ISA account: %v", err)
+	}
+
+	request := account.DepositRequest{
+		AllocationRequest: account.AllocationRequest{
+			Amount:      100,
+			Allocations: []account.Allocation{{FundId: testFund.Id, Percentage: 100}},
+		},
+		PaymentMethod: account.PAYMENT_METHOD_BANK_TRANSFER,
+	}
+
+	deposit, err := service.CreateDeposit(ctx, newAccount.Id, &request)
+
+	if err != nil {
+		t.Fatalf("unexpected error when creating deposit: %v", err)
+	}
+
+	testFund.Status = fund.FUND_STATUS_CLOSED
+	catalogue.Create(ctx, testFund)
+
+	err = service.UpdateStatus(ctx, deposit.Id, account.DEPOSIT_STATUS_SETTLED)
+
+	if !errors.Is(err, account.ErrInvestmentInvalid) {
+		t.Errorf("Expected error %v, got %v", account.ErrInvestmentInvalid, err)
+	}
+
+	failed, err := service.Deposit(ctx, deposit.Id)
+
+	if err != nil {
+		t.Errorf("unexpected error fetching deposit: %v", err)
+	}
+
+	if failed.Status != account.DEPOSIT_STATUS_FAILED {
+		t.Errorf("Expected deposit status %s, got %s", account.DEPOSIT_STATUS_FAILED, failed.Status)
+	}
+
+	accountFunds, err := repo.GetAccountFunds(ctx, newAccount.Id)
+
+	if err != nil {
+		t.Errorf("unexpected error fetching account funds: %v", err)
+	}
+
+	if len(accountFunds) != 0 {
+		t.Errorf("Expected nothing to be invested, found %d account funds", len(accountFunds))
+	}
+}
+
+func TestSettledDepositsCountTowardsTheTaxYearTheyWereMade(t *testing.T) {
+	conn, closeDown := NewTestDatabase()
+	defer closeDown()
+
+	var repo account.Repository = database.NewAccountRepository(conn)
+	var depositRepo account.DepositRepository = database.NewDepositRepository(conn)
+
+	passingNiValidator := func(_ context.Context, _ string) error {
+		return nil
+	}
+
+	testFund := NewTestFund()
+	catalogue := NewTestCatalogue(testFund)
+
+	isa := account.NewISAService(&repo, &catalogue, NewTestRules(150, 0), account.ACCOUNT_TYPE_ISA, false, account.StartOfTaxYear{1, 1}, passingNiValidator)
+	service := account.NewDepositService(&depositRepo, NewTestServiceFactory(&repo, isa), &catalogue, account.NewStubPaymentsClient())
+
+	ctx := context.Background()
+
+	customer := account.Customer{
+		Id:           uuid.New(),
+		TaxResidency: "uk",
+		NINumber:     "AB123456A",
+		DateOfBirth:  time.Now().AddDate(-20, 0, 0),
+	}
+
+	newAccount, err := isa.CreateAccount(ctx, customer)
+
+	if err != nil {
+		t.Errorf("unexpected error when creating ISA account: %v", err)
+	}
+
+	request := account.DepositRequest{
+		AllocationRequest: account.AllocationRequest{
+			Amount:      100,
+			Allocations: []account.Allocation{{FundId: testFund.Id, Percentage: 100}},
+		},
+		PaymentMethod: account.PAYMENT_METHOD_BANK_TRANSFER,
+	}
+
+	deposit, err := service.CreateDeposit(ctx, newAccount.Id, &request)
+
+	if err != nil {
+		t.Fatalf("unexpected error when creating deposit: %v", err)
+	}
+
+	// The deposit was made last tax year but settles this tax year
+	_, err = conn.Exec("UPDATE deposits SET created_at = created_at - INTERVAL 1 YEAR")
+
+	if err != nil {
+		t.Fatalf("unexpected error backdating deposit: %v", err)
+	}
+
+	err = service.UpdateStatus(ctx, deposit.Id, account.DEPOSIT_STATUS_SETTLED)
+
+	if err != nil {
+		t.Fatalf("unexpected error settling deposit: %v", err)
+	}
+
+	startOfYear := time.Date(time.Now().Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	thisYear, err := repo.GetSubscriptionsBetween(ctx, newAccount.Id, startOfYear, startOfYear.AddDate(1, 0, 0))
+
+	if err != nil {
+		t.Errorf("unexpected error fetching subscriptions: %v", err)
+	}
+
+	lastYear, err := repo.GetSubscriptionsBetween(ctx, newAccount.Id, startOfYear.AddDate(-1, 0, 0), startOfYear)
+
+	if err != nil {
+		t.Errorf("unexpected error fetching subscriptions: %v", err)
+	}
+
+	if thisYear != 0 || lastYear != 100 {
+		t.Errorf("Expected 100 subscribed last tax year and nothing this year, got %d and %d", lastYear, thisYear)
+	}
+
+	// Flexible ISAs net withdrawals against the same transactions
+	transactions, err := repo.GetSubscriptionTransactions(ctx, newAccount.Id, startOfYear.AddDate(-1, 0, 0), startOfYear)
+
+	if err != nil {
+		t.Errorf("unexpected error fetching transactions: %v", err)
+	}
+
+	if len(transactions) != 1 || transactions[0].Amount != 100 || !transactions[0].CreatedAt.Before(startOfYear) {
+		t.Errorf("Expected the subscription to be dated last tax year, got %+v", transactions)
+	}
+}
@@ -0,0 +1,141 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Distributions are credited to the account's cash balance
+	INCOME_PREFERENCE_CASH string = "cash"
+	// Distributions are paid out to the customer's nominated bank account
+	INCOME_PREFERENCE_PAYOUT string = "payout"
+)
+
+const (
+	// A payout instruction waiting to be sent to the payments provider
+	PAYOUT_STATUS_PENDING string = "pending"
+)
+
+var ErrIncomePreferenceInvalid = errors.New("Income preference invalid")
+var ErrDistributionDuplicate = errors.New("Distribution has already been paid")
+
+// A distribution from an income fund to a single account
+//
+// The distribution is always credited to cash as a TRANSACTION_TYPE_DISTRIBUTION
+// transaction. If the account's income preference is INCOME_PREFERENCE_PAYOUT
+// the cash is immediately debited again and a payout instruction is created.
+// The Reference is derived from the declaration and account so a distribution
+// can only be paid once.
+type Distribution struct {
+	Reference        uuid.UUID
+	AccountId        uuid.UUID
+	FundId           uuid.UUID
+	Amount           int
+	IncomePreference string
+}
+
+// Service to pay distributions from income funds
+//
+// Unlike accumulation funds the income isn't reinvested, so no units are
+// bought and the distribution doesn't count towards any subscription limits.
+type DistributionService struct {
+	repository Repository
+}
+
+func NewDistributionService(repository *Repository) *DistributionService {
+	return &DistributionService{repository: *repository}
+}
+
+// Set how an account receives distributions from income funds
+//
+// Returns ErrIncomePreferenceInvalid if the preference isn't recognised.
+func (s *DistributionService) SetIncomePreference(ctx context.Context, accountId uuid.UUID, preference string) error {
+	if !slices.Contains([]string{INCOME_PREFERENCE_CASH, INCOME_PREFERENCE_PAYOUT}, preference) {
+		return ErrIncomePreferenceInvalid
+	}
+
+	return s.repository.UpdateIncomePreference(ctx, accountId, preference)
+}
+
+// Pay each declaration to the accounts that held the fund before the record date
+//
+// Shares are calculated in the same way as accumulation dividends. Payments
+// that were already made are skipped so a failed run can safely be repeated.
+// Failures are keyed by declaration id.
+func (s *DistributionService) PayDistributions(ctx context.Context, source DeclarationSource) (DividendResult, error) {
+	result := DividendResult{Failed: make(map[uuid.UUID]error)}
+
+	declarations, err := source.Declarations(ctx)
+
+	if err != nil {
+		return result, fmt.Errorf("Unable to read declarations: %w", err)
+	}
+
+	for _, declaration := range declarations {
+		paid, skipped, err := s.pay(ctx, declaration)
+
+		result.Paid += paid
+		result.Skipped += skipped
+
+		if err != nil {
+			result.Failed[declaration.Id] = err
+		}
+	}
+
+	return result, nil
+}
+
+func (s *DistributionService) pay(ctx context.Context, declaration DividendDeclaration) (int, int, error) {
+	var paid, skipped int
+
+	if declaration.Amount <= 0 {
+		return paid, skipped, errors.New("Declaration amount must be greater than zero")
+	}
+
+	holdings, err := s.repository.GetFundUnitsBefore(ctx, declaration.FundId, declaration.RecordDate)
+
+	if err != nil {
+		return paid, skipped, fmt.Errorf("Unable to fetch holdings: %w", err)
+	}
+
+	shares := dividendShares(declaration, holdings)
+
+	for i, holding := range holdings {
+		if shares[i] == 0 {
+			skipped++
+			continue
+		}
+
+		account, err := s.repository.GetAccount(ctx, holding.AccountId)
+
+		if err != nil {
+			return paid, skipped, fmt.Errorf("Unable to fetch account: %w", err)
+		}
+
+		err = s.repository.PayDistribution(ctx, Distribution{
+			Reference:        dividendTradeId(declaration.Id, holding.AccountId),
+			AccountId:        holding.AccountId,
+			FundId:           declaration.FundId,
+			Amount:           shares[i],
+			IncomePreference: account.IncomePreference,
+		})
+
+		if errors.Is(err, ErrDistributionDuplicate) {
+			skipped++
+			continue
+		}
+
+		if err != nil {
+			return paid, skipped, fmt.Errorf("Unable to pay distribution: %w", err)
+		}
+
+		paid++
+	}
+
+	return paid, skipped, nil
+}
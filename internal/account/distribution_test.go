@@ -0,0 +1,124 @@
+package account_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/database"
+	"github.com/jameswhoughton/cushon/internal/account"
+)
+
+func TestDistributionsArePaidToCashOrOutOnce(t *testing.T) {
+	conn, closeDown := NewTestDatabase()
+	defer closeDown()
+
+	var repo account.Repository = database.NewAccountRepository(conn)
+
+	passingNiValidator := func(_ context.Context, _ string) error {
+		return nil
+	}
+
+	testFund := NewTestFund()
+	catalogue := NewTestCatalogue(testFund)
+
+	NewTestPrice(conn, testFund.Id, 100)
+
+	isa := account.NewISAService(&repo, &catalogue, NewTestRules(1000, 0), account.ACCOUNT_TYPE_ISA, false, account.StartOfTaxYear{1, 1}, passingNiValidator)
+	service := account.NewDistributionService(&repo)
+
+	ctx := context.Background()
+
+	preferences := []string{account.INCOME_PREFERENCE_CASH, account.INCOME_PREFERENCE_PAYOUT}
+	accountIds := make([]uuid.UUID, len(preferences))
+
+	for i, preference := range preferences {
+		newAccount, err := isa.CreateAccount(ctx, account.Customer{
+			Id:           uuid.New(),
+			TaxResidency: "uk",
+			NINumber:     "AB123456A",
+			DateOfBirth:  time.Now().AddDate(-20, 0, 0),
+		})
+
+		if err != nil {
+			t.Fatalf("unexpected error when creating ISA account: %v", err)
+		}
+
+		err = service.SetIncomePreference(ctx, newAccount.Id, preference)
+
+		if err != nil {
+			t.Fatalf("unexpected error setting income preference: %v", err)
+		}
+
+		err = isa.Invest(ctx, newAccount.Id, []account.Investment{
+			{
+				FundId:          testFund.Id,
+				TradeId:         uuid.New(),
+				TransactionType: account.TRANSACTION_TYPE_CUSTOMER,
+				Amount:          100,
+			},
+		})
+
+		if err != nil {
+			t.Fatalf("unexpected error when investing in fund: %v", err)
+		}
+
+		accountIds[i] = newAccount.Id
+	}
+
+	err := service.SetIncomePreference(ctx, accountIds[0], "reinvest")
+
+	if !errors.Is(err, account.ErrIncomePreferenceInvalid) {
+		t.Errorf("Expected error %v, got %v", account.ErrIncomePreferenceInvalid, err)
+	}
+
+	BackdateTestTransactions(conn)
+
+	source := account.NewStubDeclarationSource(account.DividendDeclaration{
+		Id:         uuid.New(),
+		FundId:     testFund.Id,
+		RecordDate: time.Now(),
+		Amount:     20,
+	})
+
+	for run := 0; run < 2; run++ {
+		result, err := service.PayDistributions(ctx, source)
+
+		if err != nil || len(result.Failed) > 0 {
+			t.Fatalf("unexpected error paying distributions: %v %v", err, result.Failed)
+		}
+
+		expectedPaid := 2 - 2*run
+
+		if result.Paid != expectedPaid {
+			t.Errorf("Expected %d distributions to be paid on run %d, got %d", expectedPaid, run+1, result.Paid)
+		}
+	}
+
+	// Only the cash account keeps the distribution, the other is paid out
+	expected := []int{10, 0}
+
+	for i, accountId := range accountIds {
+		balance, err := repo.GetCashBalance(ctx, accountId)
+
+		if err != nil {
+			t.Errorf("unexpected error fetching cash balance: %v", err)
+		}
+
+		if balance != expected[i] {
+			t.Errorf("Expected a cash balance of %d, got %d", expected[i], balance)
+		}
+
+		totalInvested, err := repo.GetTotalInvestedToDate(ctx, accountId, time.Now().AddDate(0, 0, -2))
+
+		if err != nil {
+			t.Errorf("unexpected error fetching total invested: %v", err)
+		}
+
+		if totalInvested != 100 {
+			t.Errorf("Expected distributions to be excluded from the total invested, got %d", totalInvested)
+		}
+	}
+}
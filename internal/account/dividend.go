@@ -0,0 +1,235 @@
+package account
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Namespace used to derive the trade id of a dividend payment
+var dividendNamespace = uuid.MustParse("6f1c3c2e-7d4b-4d8e-9b0a-2f5e8c1d4a7b")
+
+// A dividend declared by a fund
+//
+// Amount is the total paid to the platform in pence for every account
+// holding the fund on the record date.
+type DividendDeclaration struct {
+	Id         uuid.UUID
+	FundId     uuid.UUID
+	RecordDate time.Time
+	Amount     int
+}
+
+// Source of dividend declarations (e.g. a file from the fund manager)
+type DeclarationSource interface {
+	Declarations(ctx context.Context) ([]DividendDeclaration, error)
+}
+
+// Declaration source backed by a local CSV file
+//
+// The file must have a header row followed by rows of
+// declaration_id,fund_id,record_date,amount where the record date is
+// formatted as YYYY-MM-DD and the amount is in pence.
+type FileDeclarationSource struct {
+	path string
+}
+
+func NewFileDeclarationSource(path string) *FileDeclarationSource {
+	return &FileDeclarationSource{path: path}
+}
+
+func (s *FileDeclarationSource) Declarations(ctx context.Context) ([]DividendDeclaration, error) {
+	file, err := os.Open(s.path)
+
+	if err != nil {
+		return []DividendDeclaration{}, fmt.Errorf("FileDeclarationSource.Declarations: Unable to open file: %v", err)
+	}
+
+	defer file.Close()
+
+	return parseDeclarations(file)
+}
+
+// Declaration source returning a fixed set of declarations
+//
+// This would be replaced by a client for the fund manager's API.
+type StubDeclarationSource struct {
+	declarations []DividendDeclaration
+}
+
+func NewStubDeclarationSource(declarations ...DividendDeclaration) *StubDeclarationSource {
+	return &StubDeclarationSource{declarations: declarations}
+}
+
+func (s *StubDeclarationSource) Declarations(_ context.Context) ([]DividendDeclaration, error) {
+	return s.declarations, nil
+}
+
+func parseDeclarations(r io.Reader) ([]DividendDeclaration, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+
+	if err != nil {
+		return []DividendDeclaration{}, fmt.Errorf("Unable to parse declarations: %v", err)
+	}
+
+	if len(rows) == 0 {
+		return []DividendDeclaration{}, nil
+	}
+
+	declarations := make([]DividendDeclaration, 0, len(rows)-1)
+
+	for i, row := range rows[1:] {
+		if len(row) != 4 {
+			return []DividendDeclaration{}, fmt.Errorf("Unable to parse declarations: row %d has %d columns, expected 4", i+2, len(row))
+		}
+
+		var declaration DividendDeclaration
+
+		declaration.Id, err = uuid.Parse(row[0])
+
+		if err == nil {
+			declaration.FundId, err = uuid.Parse(row[1])
+		}
+
+		if err == nil {
+			declaration.RecordDate, err = time.Parse(time.DateOnly, row[2])
+		}
+
+		if err == nil {
+			declaration.Amount, err = strconv.Atoi(row[3])
+		}
+
+		if err != nil {
+			return []DividendDeclaration{}, fmt.Errorf("Unable to parse declarations: row %d: %v", i+2, err)
+		}
+
+		declarations = append(declarations, declaration)
+	}
+
+	return declarations, nil
+}
+
+// Split a declaration between the accounts holding the fund
+//
+// Each account receives a share in proportion to the units it held before
+// the record date, rounded to the penny using apportion().
+func dividendShares(declaration DividendDeclaration, holdings []AccountFund) []int {
+	units := make([]int, len(holdings))
+
+	for i, holding := range holdings {
+		units[i] = holding.Units
+	}
+
+	return apportion(declaration.Amount, units)
+}
+
+// Trade id used when paying a declaration to an account
+//
+// The id is derived from the declaration and account so a payment can be
+// recognised if the job is run again.
+func dividendTradeId(declarationId uuid.UUID, accountId uuid.UUID) uuid.UUID {
+	return uuid.NewSHA1(dividendNamespace, append(declarationId[:], accountId[:]...))
+}
+
+// Summary of a single dividend run
+type DividendResult struct {
+	Paid    int
+	Skipped int
+	Failed  map[uuid.UUID]error
+}
+
+// Service to reinvest dividends from accumulation funds
+//
+// The dividend is reinvested in the same fund as an accumulation
+// transaction which doesn't count towards any subscription limits.
+type DividendService struct {
+	repository Repository
+}
+
+func NewDividendService(repository *Repository) *DividendService {
+	return &DividendService{repository: *repository}
+}
+
+// Reinvest each declaration in the accounts that held the fund before the record date
+//
+// Processing is idempotent per declaration and account: each payment is made
+// with a trade id derived from both, payments that were already made are
+// skipped so a failed run can safely be repeated. Failures are keyed by
+// declaration id.
+func (s *DividendService) ReinvestDividends(ctx context.Context, source DeclarationSource) (DividendResult, error) {
+	result := DividendResult{Failed: make(map[uuid.UUID]error)}
+
+	declarations, err := source.Declarations(ctx)
+
+	if err != nil {
+		return result, fmt.Errorf("Unable to read declarations: %w", err)
+	}
+
+	for _, declaration := range declarations {
+		paid, skipped, err := s.reinvest(ctx, declaration)
+
+		result.Paid += paid
+		result.Skipped += skipped
+
+		if err != nil {
+			result.Failed[declaration.Id] = err
+		}
+	}
+
+	return result, nil
+}
+
+func (s *DividendService) reinvest(ctx context.Context, declaration DividendDeclaration) (int, int, error) {
+	var paid, skipped int
+
+	if declaration.Amount <= 0 {
+		return paid, skipped, errors.New("Declaration amount must be greater than zero")
+	}
+
+	// Payments made by an earlier run are after the record date so don't
+	// change the shares
+	holdings, err := s.repository.GetFundUnitsBefore(ctx, declaration.FundId, declaration.RecordDate)
+
+	if err != nil {
+		return paid, skipped, fmt.Errorf("Unable to fetch holdings: %w", err)
+	}
+
+	shares := dividendShares(declaration, holdings)
+
+	for i, holding := range holdings {
+		if shares[i] == 0 {
+			skipped++
+			continue
+		}
+
+		err = s.repository.Invest(ctx, holding.AccountId, []Investment{
+			{
+				FundId:          declaration.FundId,
+				AccountFundId:   holding.Id,
+				TradeId:         dividendTradeId(declaration.Id, holding.AccountId),
+				TransactionType: TRANSACTION_TYPE_ACCUMULATION,
+				Amount:          shares[i],
+			},
+		})
+
+		if errors.Is(err, ErrTradeDuplicate) {
+			skipped++
+			continue
+		}
+
+		if err != nil {
+			return paid, skipped, fmt.Errorf("Unable to reinvest dividend: %w", err)
+		}
+
+		paid++
+	}
+
+	return paid, skipped, nil
+}
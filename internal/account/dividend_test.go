@@ -0,0 +1,165 @@
+package account_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/database"
+	"github.com/jameswhoughton/cushon/internal/account"
+)
+
+func TestFileDeclarationSourceParsesDeclarations(t *testing.T) {
+	declarationId := uuid.New()
+	fundId := uuid.New()
+
+	path := filepath.Join(t.TempDir(), "declarations.csv")
+
+	err := os.WriteFile(path, []byte("declaration_id,fund_id,record_date,amount\n"+declarationId.String()+","+fundId.String()+",2025-03-31,1250\n"), 0o600)
+
+	if err != nil {
+		t.Fatalf("unexpected error writing declarations: %v", err)
+	}
+
+	declarations, err := account.NewFileDeclarationSource(path).Declarations(context.Background())
+
+	if err != nil {
+		t.Fatalf("unexpected error reading declarations: %v", err)
+	}
+
+	expected := account.DividendDeclaration{
+		Id:         declarationId,
+		FundId:     fundId,
+		RecordDate: time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC),
+		Amount:     1250,
+	}
+
+	if len(declarations) != 1 || declarations[0] != expected {
+		t.Errorf("Expected %v, got %v", expected, declarations)
+	}
+
+	err = os.WriteFile(path, []byte("declaration_id,fund_id,record_date,amount\n"+declarationId.String()+","+fundId.String()+",31/03/2025,1250\n"), 0o600)
+
+	if err != nil {
+		t.Fatalf("unexpected error writing declarations: %v", err)
+	}
+
+	_, err = account.NewFileDeclarationSource(path).Declarations(context.Background())
+
+	if err == nil {
+		t.Error("Expected an error for an invalid record date")
+	}
+}
+
+func TestDividendsAreReinvestedProRataOnce(t *testing.T) {
+	conn, closeDown := NewTestDatabase()
+	defer closeDown()
+
+	var repo account.Repository = database.NewAccountRepository(conn)
+
+	passingNiValidator := func(_ context.Context, _ string) error {
+		return nil
+	}
+
+	testFund := NewTestFund()
+	catalogue := NewTestCatalogue(testFund)
+
+	NewTestPrice(conn, testFund.Id, 100)
+
+	isa := account.NewISAService(&repo, &catalogue, NewTestRules(1000, 0), account.ACCOUNT_TYPE_ISA, false, account.StartOfTaxYear{1, 1}, passingNiValidator)
+
+	ctx := context.Background()
+
+	holdings := []int{100, 200}
+	accountIds := make([]uuid.UUID, len(holdings))
+
+	for i, amount := range holdings {
+		newAccount, err := isa.CreateAccount(ctx, account.Customer{
+			Id:           uuid.New(),
+			TaxResidency: "uk",
+			NINumber:     "AB123456A",
+			DateOfBirth:  time.Now().AddDate(-20, 0, 0),
+		})
+
+		if err != nil {
+			t.Fatalf("unexpected error when creating ISA account: %v", err)
+		}
+
+		err = isa.Invest(ctx, newAccount.Id, []account.Investment{
+			{
+				FundId:          testFund.Id,
+				TradeId:         uuid.New(),
+				TransactionType: account.TRANSACTION_TYPE_CUSTOMER,
+				Amount:          amount,
+			},
+		})
+
+		if err != nil {
+			t.Fatalf("unexpected error when investing in fund: %v", err)
+		}
+
+		accountIds[i] = newAccount.Id
+	}
+
+	// The holdings are bought before the record date, the reinvested dividends
+	// are bought on it so don't change the shares when the job is re-run
+	BackdateTestTransactions(conn)
+
+	service := account.NewDividendService(&repo)
+
+	source := account.NewStubDeclarationSource(account.DividendDeclaration{
+		Id:         uuid.New(),
+		FundId:     testFund.Id,
+		RecordDate: time.Now(),
+		Amount:     10,
+	})
+
+	result, err := service.ReinvestDividends(ctx, source)
+
+	if err != nil || len(result.Failed) > 0 {
+		t.Fatalf("unexpected error reinvesting dividends: %v %v", err, result.Failed)
+	}
+
+	if result.Paid != 2 {
+		t.Errorf("Expected 2 dividends to be paid, got %d", result.Paid)
+	}
+
+	// Re-running the same declaration must not pay it again
+	result, err = service.ReinvestDividends(ctx, source)
+
+	if err != nil || len(result.Failed) > 0 {
+		t.Fatalf("unexpected error reinvesting dividends: %v %v", err, result.Failed)
+	}
+
+	if result.Paid != 0 || result.Skipped != 2 {
+		t.Errorf("Expected 0 paid and 2 skipped on re-run, got %d and %d", result.Paid, result.Skipped)
+	}
+
+	// 10p split 1:2 is 3.33p and 6.67p, the spare penny goes to the larger remainder
+	expected := []int{103, 207}
+
+	for i, accountId := range accountIds {
+		accountFunds, err := repo.GetAccountFunds(ctx, accountId)
+
+		if err != nil {
+			t.Errorf("unexpected error fetching account funds: %v", err)
+		}
+
+		if len(accountFunds) != 1 || accountFunds[0].Balance != expected[i] {
+			t.Errorf("Expected a balance of %d, got %v", expected[i], accountFunds)
+		}
+
+		totalInvested, err := repo.GetTotalInvestedToDate(ctx, accountId, time.Now().AddDate(0, 0, -2))
+
+		if err != nil {
+			t.Errorf("unexpected error fetching total invested: %v", err)
+		}
+
+		if totalInvested != holdings[i] {
+			t.Errorf("Expected dividends to be excluded from the total invested, expected %d, got %d", holdings[i], totalInvested)
+		}
+	}
+}
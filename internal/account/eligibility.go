@@ -0,0 +1,120 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ELIGIBILITY_RULE_RESIDENCY string = "residency"
+	ELIGIBILITY_RULE_AGE       string = "age"
+	ELIGIBILITY_RULE_NI_NUMBER string = "ni_number"
+)
+
+// The outcome of a single eligibility rule, Message explains a failure
+type EligibilityCheck struct {
+	Rule    string `json:"rule"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message,omitempty"`
+}
+
+// Whether a customer can open an account type
+//
+// Every rule is evaluated so the customer can be told all the reasons they
+// don't qualify, not just the first. RulesVersion is the version of the
+// rules the decision was made with.
+type Eligibility struct {
+	AccountType  string             `json:"account_type"`
+	Eligible     bool               `json:"eligible"`
+	Checks       []EligibilityCheck `json:"checks"`
+	RulesVersion string             `json:"rules_version"`
+}
+
+func newEligibility(accountType string, checks ...EligibilityCheck) Eligibility {
+	eligibility := Eligibility{
+		AccountType: accountType,
+		Eligible:    true,
+		Checks:      checks,
+	}
+
+	for _, check := range checks {
+		if !check.Passed {
+			eligibility.Eligible = false
+		}
+	}
+
+	return eligibility
+}
+
+// Return an ErrAccountCreatePermission for the first failed check, or nil
+// if the customer is eligible
+func (e Eligibility) Err() error {
+	for _, check := range e.Checks {
+		if !check.Passed {
+			return ErrAccountCreatePermission{check.Message}
+		}
+	}
+
+	return nil
+}
+
+// Check the customer is a tax resident of one of the residencies, any
+// residency passes if none are given
+func checkResidency(customer Customer, residencies []string, product string) EligibilityCheck {
+	if len(residencies) > 0 && !slices.Contains(residencies, customer.TaxResidency) {
+		names := make([]string, len(residencies))
+
+		for i, residency := range residencies {
+			names[i] = strings.ToUpper(residency)
+		}
+
+		return EligibilityCheck{Rule: ELIGIBILITY_RULE_RESIDENCY, Message: "Only " + strings.Join(names, " or ") + " tax residents can open " + product}
+	}
+
+	return EligibilityCheck{Rule: ELIGIBILITY_RULE_RESIDENCY, Passed: true}
+}
+
+// Check the customer is at least minAge and, if maxAge is set, under it
+//
+// A minAge of zero means there is no minimum. If there is an age rule the
+// customer must have a date of birth.
+func checkAge(customer Customer, product string, minAge int, maxAge int) EligibilityCheck {
+	now := time.Now()
+
+	if (minAge > 0 || maxAge > 0) && customer.DateOfBirth.IsZero() {
+		return EligibilityCheck{Rule: ELIGIBILITY_RULE_AGE, Message: "A date of birth is required to open " + product}
+	}
+
+	if minAge > 0 && customer.DateOfBirth.After(now.AddDate(-minAge, 0, 0)) {
+		return EligibilityCheck{Rule: ELIGIBILITY_RULE_AGE, Message: "Only customers who are over the age of " + strconv.Itoa(minAge) + " can open " + product}
+	}
+
+	if maxAge > 0 && !customer.DateOfBirth.After(now.AddDate(-maxAge, 0, 0)) {
+		return EligibilityCheck{Rule: ELIGIBILITY_RULE_AGE, Message: "Only customers who are under the age of " + strconv.Itoa(maxAge) + " can open " + product}
+	}
+
+	return EligibilityCheck{Rule: ELIGIBILITY_RULE_AGE, Passed: true}
+}
+
+// Check the customer's NI number, see verifyNINumber
+//
+// An error is only returned if the number couldn't be checked.
+func checkNINumber(ctx context.Context, niValidator func(context.Context, string) error, customer Customer) (EligibilityCheck, error) {
+	err := verifyNINumber(ctx, niValidator, &customer)
+
+	var permissionErr ErrAccountCreatePermission
+
+	if errors.As(err, &permissionErr) {
+		return EligibilityCheck{Rule: ELIGIBILITY_RULE_NI_NUMBER, Message: permissionErr.message}, nil
+	}
+
+	if err != nil {
+		return EligibilityCheck{}, err
+	}
+
+	return EligibilityCheck{Rule: ELIGIBILITY_RULE_NI_NUMBER, Passed: true}, nil
+}
@@ -0,0 +1,178 @@
+package account_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/internal/account"
+)
+
+func TestEligibilityEvaluatesEveryRule(t *testing.T) {
+	// Eligibility doesn't use the repository
+	var repo account.Repository
+
+	niValidator := func(_ context.Context, ni string) error {
+		if ni != "AB123456A" {
+			return errors.New("NI number not recognised")
+		}
+
+		return nil
+	}
+
+	catalogue := NewTestCatalogue()
+
+	isa := account.NewISAService(&repo, &catalogue, NewTestRules(0, 0), account.ACCOUNT_TYPE_ISA, false, account.StartOfTaxYear{1, 1}, niValidator)
+	lisa := account.NewLISAService(&repo, &catalogue, NewTestRules(0, 0), account.StartOfTaxYear{1, 1}, niValidator, account.NewStubCustomerClient())
+
+	type testCase struct {
+		name           string
+		service        account.Service
+		customer       account.Customer
+		expectedFailed []string
+	}
+
+	cases := []testCase{
+		{
+			name:           "Eligible for an ISA",
+			service:        isa,
+			customer:       account.Customer{TaxResidency: "uk", DateOfBirth: time.Now().AddDate(-45, 0, 0), NINumber: "AB123456A"},
+			expectedFailed: []string{},
+		},
+		{
+			name:           "Fails every ISA rule",
+			service:        isa,
+			customer:       account.Customer{TaxResidency: "fr", DateOfBirth: time.Now().AddDate(-17, 0, 0), NINumber: "AB123456B"},
+			expectedFailed: []string{account.ELIGIBILITY_RULE_RESIDENCY, account.ELIGIBILITY_RULE_AGE, account.ELIGIBILITY_RULE_NI_NUMBER},
+		},
+		{
+			name:           "Date of birth missing",
+			service:        isa,
+			customer:       account.Customer{TaxResidency: "uk", NINumber: "AB123456A"},
+			expectedFailed: []string{account.ELIGIBILITY_RULE_AGE},
+		},
+		{
+			name:           "Too old for a LISA",
+			service:        lisa,
+			customer:       account.Customer{TaxResidency: "uk", DateOfBirth: time.Now().AddDate(-45, 0, 0), NINumber: "AB123456A"},
+			expectedFailed: []string{account.ELIGIBILITY_RULE_AGE},
+		},
+		{
+			name:           "NI number with an invalid format",
+			service:        lisa,
+			customer:       account.Customer{TaxResidency: "uk", DateOfBirth: time.Now().AddDate(-25, 0, 0), NINumber: "INVALID"},
+			expectedFailed: []string{account.ELIGIBILITY_RULE_NI_NUMBER},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			eligibility, err := testCase.service.Eligibility(context.Background(), testCase.customer)
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(eligibility.Checks) != 3 {
+				t.Errorf("Expected 3 checks, got %v", eligibility.Checks)
+			}
+
+			var failed []string
+
+			for _, check := range eligibility.Checks {
+				if !check.Passed {
+					failed = append(failed, check.Rule)
+
+					if check.Message == "" {
+						t.Errorf("Expected a message for the failed %s check", check.Rule)
+					}
+				}
+			}
+
+			if len(failed) != len(testCase.expectedFailed) {
+				t.Fatalf("Expected failed checks %v, got %v", testCase.expectedFailed, failed)
+			}
+
+			for i, rule := range testCase.expectedFailed {
+				if failed[i] != rule {
+					t.Errorf("Expected failed checks %v, got %v", testCase.expectedFailed, failed)
+				}
+			}
+
+			if eligibility.Eligible != (len(failed) == 0) {
+				t.Errorf("Expected eligible to be %t, got %t", len(failed) == 0, eligibility.Eligible)
+			}
+
+			if (eligibility.Err() == nil) != eligibility.Eligible {
+				t.Errorf("Expected Err to be nil only when eligible, got %v", eligibility.Err())
+			}
+		})
+	}
+}
+
+func TestGetEligibilityHandlerListsEachAccountType(t *testing.T) {
+	var repo account.Repository
+
+	passingNiValidator := func(_ context.Context, _ string) error {
+		return nil
+	}
+
+	catalogue := NewTestCatalogue()
+	isa := account.NewISAService(&repo, &catalogue, NewTestRules(0, 0), account.ACCOUNT_TYPE_ISA, false, account.StartOfTaxYear{1, 1}, passingNiValidator)
+	lisa := account.NewLISAService(&repo, &catalogue, NewTestRules(0, 0), account.StartOfTaxYear{1, 1}, passingNiValidator, account.NewStubCustomerClient())
+	serviceFactory := NewTestServiceFactory(&repo, isa, lisa)
+
+	customer := account.Customer{
+		Id:           uuid.New(),
+		TaxResidency: "uk",
+		DateOfBirth:  time.Now().AddDate(-45, 0, 0),
+		NINumber:     "AB123456A",
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/customer/{id}/eligibility", account.GetEligibilityHandler(*serviceFactory, account.NewStubCustomerClient(customer)))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/customer/"+customer.Id.String()+"/eligibility", nil)
+	req.Header.Set(account.SESSION_CUSTOMER_HEADER, customer.Id.String())
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var eligibility []account.Eligibility
+
+	if err := json.NewDecoder(w.Body).Decode(&eligibility); err != nil {
+		t.Fatalf("unexpected error decoding response: %v", err)
+	}
+
+	expected := map[string]bool{account.ACCOUNT_TYPE_ISA: true, account.ACCOUNT_TYPE_LISA: false}
+
+	if len(eligibility) != len(expected) {
+		t.Fatalf("Expected %d account types, got %+v", len(expected), eligibility)
+	}
+
+	for _, e := range eligibility {
+		if e.Eligible != expected[e.AccountType] {
+			t.Errorf("Expected eligible for %s to be %t, got %t", e.AccountType, expected[e.AccountType], e.Eligible)
+		}
+	}
+
+	// Customers can only check their own eligibility
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/customer/"+customer.Id.String()+"/eligibility", nil)
+	req.Header.Set(account.SESSION_CUSTOMER_HEADER, uuid.New().String())
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
@@ -0,0 +1,298 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrFeeScheduleInvalid = errors.New("Fee schedule invalid")
+var ErrFeeAlreadyCharged = errors.New("Fee has already been charged for the period")
+
+// A band of the fee schedule
+//
+// The rate is charged on the part of the holdings value that falls within
+// the band. UpTo is the top of the band in pence, zero means the band has
+// no upper limit and must be the last one.
+type FeeTier struct {
+	UpTo        int
+	BasisPoints int
+}
+
+// Annual platform fee charged as a tiered percentage of the holdings value
+//
+// A zero AnnualCap means the fee is uncapped.
+type FeeSchedule struct {
+	Tiers     []FeeTier
+	AnnualCap int
+}
+
+// Check the tiers are in order and the last tier has no upper limit
+func (s FeeSchedule) Validate() error {
+	if len(s.Tiers) == 0 {
+		return fmt.Errorf("%w: at least one tier is required", ErrFeeScheduleInvalid)
+	}
+
+	var previous int
+
+	for i, tier := range s.Tiers {
+		last := i == len(s.Tiers)-1
+
+		if tier.BasisPoints < 0 {
+			return fmt.Errorf("%w: tier %d has a negative rate", ErrFeeScheduleInvalid, i)
+		}
+
+		if last && tier.UpTo != 0 {
+			return fmt.Errorf("%w: the last tier must have no upper limit", ErrFeeScheduleInvalid)
+		}
+
+		if !last && tier.UpTo <= previous {
+			return fmt.Errorf("%w: tier %d must end above the previous tier", ErrFeeScheduleInvalid, i)
+		}
+
+		previous = tier.UpTo
+	}
+
+	if s.AnnualCap < 0 {
+		return fmt.Errorf("%w: the cap cannot be negative", ErrFeeScheduleInvalid)
+	}
+
+	return nil
+}
+
+// Returns the fee for one month on the given holdings value
+//
+// The fee is a twelfth of the annual fee (after the cap is applied) rounded
+// to the nearest penny. The schedule is assumed to be valid.
+func (s FeeSchedule) MonthlyFee(value int) int {
+	// The annual fee is kept in ten-thousandths of a penny to avoid rounding until the end
+	var annual int
+	var bottom int
+
+	for _, tier := range s.Tiers {
+		if value <= bottom {
+			break
+		}
+
+		top := value
+
+		if tier.UpTo != 0 {
+			top = min(value, tier.UpTo)
+		}
+
+		annual += (top - bottom) * tier.BasisPoints
+		bottom = top
+	}
+
+	if s.AnnualCap > 0 {
+		annual = min(annual, s.AnnualCap*10000)
+	}
+
+	return (annual + 60000) / 120000
+}
+
+// The platform fee charged to an account for a single month
+//
+// The fee is taken from cash where possible, any remainder is raised by
+// selling units in each fund in proportion to the holdings. Period is the
+// first day of the month being charged.
+type FeeCharge struct {
+	Id            uuid.UUID    `json:"id"`
+	AccountId     uuid.UUID    `json:"account_id"`
+	Period        time.Time    `json:"period"`
+	HoldingsValue int          `json:"holdings_value"`
+	Amount        int          `json:"amount"`
+	FromCash      int          `json:"from_cash"`
+	Sales         []Investment `json:"sales"`
+}
+
+// Responsible for storing fee charges, any params passed in are assumed
+// to be valid.
+//
+// Methods should be accessed through the FeeService
+type FeeRepository interface {
+	// Return every account that holds a fund or cash
+	GetChargeableAccounts(ctx context.Context) ([]uuid.UUID, error)
+
+	// Record the charge, debit the cash and make the sales atomically
+	//
+	// Returns ErrFeeAlreadyCharged if the account has already been charged
+	// for the period.
+	ChargeFee(ctx context.Context, charge FeeCharge) error
+
+	// Return the charges for the account with a period between the dates (inclusive)
+	GetFeeCharges(ctx context.Context, accountId uuid.UUID, from time.Time, to time.Time) ([]FeeCharge, error)
+}
+
+// Summary of a single run of the fee job
+type FeeRunResult struct {
+	Charged int
+	Skipped int
+	Failed  map[uuid.UUID]error
+}
+
+// Costs and charges for an account over a period
+//
+// Used for the annual costs-and-charges disclosure, the platform fee is
+// also expressed as a percentage (in basis points) of the average value
+// the fee was charged on.
+type ChargesBreakdown struct {
+	AccountId            uuid.UUID   `json:"account_id"`
+	From                 time.Time   `json:"from"`
+	To                   time.Time   `json:"to"`
+	PlatformFees         int         `json:"platform_fees"`
+	AverageHoldingsValue int         `json:"average_holdings_value"`
+	BasisPoints          int         `json:"basis_points"`
+	Charges              []FeeCharge `json:"charges"`
+}
+
+// Service to charge the platform fee
+//
+// The fee is charged on the value of the funds and cash held. Funds are
+// valued at their price on the day the fee is charged.
+type FeeService struct {
+	repository FeeRepository
+	accounts   Repository
+	prices     PriceSource
+	schedule   FeeSchedule
+}
+
+func NewFeeService(repository *FeeRepository, accounts *Repository, prices PriceSource, schedule FeeSchedule) (*FeeService, error) {
+	if err := schedule.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &FeeService{
+		repository: *repository,
+		accounts:   *accounts,
+		prices:     prices,
+		schedule:   schedule,
+	}, nil
+}
+
+// Charge every account the fee for the month containing the date
+//
+// Holdings are valued on the date. Accounts that have already been charged
+// for the month are skipped so the job can be safely re-run, accounts holding
+// a fund without a price fail.
+func (s *FeeService) ChargeFees(ctx context.Context, date time.Time) (FeeRunResult, error) {
+	result := FeeRunResult{Failed: make(map[uuid.UUID]error)}
+
+	accountIds, err := s.repository.GetChargeableAccounts(ctx)
+
+	if err != nil {
+		return result, fmt.Errorf("Unable to fetch accounts: %w", err)
+	}
+
+	period := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	for _, accountId := range accountIds {
+		err := s.chargeAccount(ctx, accountId, period, date)
+
+		switch {
+		case errors.Is(err, ErrFeeAlreadyCharged):
+			result.Skipped++
+		case err != nil:
+			result.Failed[accountId] = err
+		default:
+			result.Charged++
+		}
+	}
+
+	return result, nil
+}
+
+func (s *FeeService) chargeAccount(ctx context.Context, accountId uuid.UUID, period time.Time, date time.Time) error {
+	accountFunds, err := s.accounts.GetAccountUnitsAt(ctx, accountId, date)
+
+	if err != nil {
+		return fmt.Errorf("Unable to fetch account funds: %w", err)
+	}
+
+	values, err := valueHoldings(ctx, s.prices, accountFunds, date)
+
+	if err != nil {
+		return err
+	}
+
+	cash, err := s.accounts.GetCashBalance(ctx, accountId)
+
+	if err != nil {
+		return fmt.Errorf("Unable to fetch cash balance: %w", err)
+	}
+
+	cash = max(cash, 0)
+
+	charge := FeeCharge{
+		Id:        uuid.New(),
+		AccountId: accountId,
+		Period:    period,
+	}
+
+	for _, value := range values {
+		charge.HoldingsValue += value
+	}
+
+	charge.HoldingsValue += cash
+	charge.Amount = min(s.schedule.MonthlyFee(charge.HoldingsValue), charge.HoldingsValue)
+	charge.FromCash = min(cash, charge.Amount)
+
+	// The charge is still recorded when there's nothing to pay so that the
+	// account isn't picked up again for the period
+	for i, amount := range apportion(charge.Amount-charge.FromCash, values) {
+		if amount == 0 {
+			continue
+		}
+
+		charge.Sales = append(charge.Sales, Investment{
+			FundId:          accountFunds[i].FundId,
+			AccountFundId:   accountFunds[i].Id,
+			TradeId:         uuid.New(),
+			TransactionType: TRANSACTION_TYPE_FEE,
+			Amount:          -amount,
+		})
+	}
+
+	return s.repository.ChargeFee(ctx, charge)
+}
+
+// Return the costs and charges for an account between two dates
+//
+// Charges are included if the month they were charged for starts within the dates.
+func (s *FeeService) ChargesBreakdown(ctx context.Context, accountId uuid.UUID, from time.Time, to time.Time) (ChargesBreakdown, error) {
+	charges, err := s.repository.GetFeeCharges(ctx, accountId, from, to)
+
+	if err != nil {
+		return ChargesBreakdown{}, fmt.Errorf("Unable to fetch fee charges: %w", err)
+	}
+
+	breakdown := ChargesBreakdown{
+		AccountId: accountId,
+		From:      from,
+		To:        to,
+		Charges:   charges,
+	}
+
+	if len(charges) == 0 {
+		return breakdown, nil
+	}
+
+	var totalValue int
+
+	for _, charge := range charges {
+		breakdown.PlatformFees += charge.Amount
+		totalValue += charge.HoldingsValue
+	}
+
+	breakdown.AverageHoldingsValue = totalValue / len(charges)
+
+	// The fees are monthly so scale up to an annual rate
+	if totalValue > 0 {
+		breakdown.BasisPoints = (breakdown.PlatformFees*12*10000 + totalValue/2) / totalValue
+	}
+
+	return breakdown, nil
+}
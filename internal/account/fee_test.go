@@ -0,0 +1,200 @@
+package account_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/database"
+	"github.com/jameswhoughton/cushon/internal/account"
+)
+
+func TestFeeScheduleValidation(t *testing.T) {
+	type testCase struct {
+		name     string
+		schedule account.FeeSchedule
+		isValid  bool
+	}
+
+	cases := []testCase{
+		{
+			name:     "No tiers",
+			schedule: account.FeeSchedule{},
+			isValid:  false,
+		},
+		{
+			name:     "Last tier has an upper limit",
+			schedule: account.FeeSchedule{Tiers: []account.FeeTier{{UpTo: 100, BasisPoints: 35}}},
+			isValid:  false,
+		},
+		{
+			name:     "Tiers out of order",
+			schedule: account.FeeSchedule{Tiers: []account.FeeTier{{UpTo: 100, BasisPoints: 35}, {UpTo: 50, BasisPoints: 25}, {BasisPoints: 10}}},
+			isValid:  false,
+		},
+		{
+			name:     "Valid schedule",
+			schedule: account.FeeSchedule{Tiers: []account.FeeTier{{UpTo: 100, BasisPoints: 35}, {BasisPoints: 10}}, AnnualCap: 1000},
+			isValid:  true,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := testCase.schedule.Validate()
+
+			if testCase.isValid && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			if !testCase.isValid && !errors.Is(err, account.ErrFeeScheduleInvalid) {
+				t.Errorf("Expected error %v, got %v", account.ErrFeeScheduleInvalid, err)
+			}
+		})
+	}
+}
+
+func TestFeeScheduleMonthlyFee(t *testing.T) {
+	schedule := account.FeeSchedule{
+		Tiers: []account.FeeTier{
+			{UpTo: 25000000, BasisPoints: 35},
+			{UpTo: 100000000, BasisPoints: 25},
+			{BasisPoints: 10},
+		},
+		AnnualCap: 150000,
+	}
+
+	type testCase struct {
+		name     string
+		value    int
+		expected int
+	}
+
+	cases := []testCase{
+		{name: "Nothing held", value: 0, expected: 0},
+		{name: "First tier", value: 1200000, expected: 350},
+		{name: "Across two tiers", value: 30000000, expected: 8333},
+		{name: "Reaches the cap", value: 50000000, expected: 12500},
+		{name: "Above the cap", value: 200000000, expected: 12500},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			fee := schedule.MonthlyFee(testCase.value)
+
+			if fee != testCase.expected {
+				t.Errorf("Expected a fee of %d, got %d", testCase.expected, fee)
+			}
+		})
+	}
+}
+
+func TestFeesAreTakenFromCashThenUnitsOncePerMonth(t *testing.T) {
+	conn, closeDown := NewTestDatabase()
+	defer closeDown()
+
+	var repo account.Repository = database.NewAccountRepository(conn)
+	var feeRepo account.FeeRepository = database.NewFeeRepository(conn)
+
+	passingNiValidator := func(_ context.Context, _ string) error {
+		return nil
+	}
+
+	equities := NewTestFund()
+	bonds := NewTestFund()
+	catalogue := NewTestCatalogue(equities, bonds)
+
+	// Units are bought at £1 so the funds are valued at the amount invested
+	NewTestPrice(conn, equities.Id, 100)
+	prices := NewTestPrice(conn, bonds.Id, 100)
+
+	isa := account.NewISAService(&repo, &catalogue, NewTestRules(100000, 0), account.ACCOUNT_TYPE_ISA, false, account.StartOfTaxYear{1, 1}, passingNiValidator)
+
+	// 12% a year is 1% a month
+	service, err := account.NewFeeService(&feeRepo, &repo, prices, account.FeeSchedule{Tiers: []account.FeeTier{{BasisPoints: 1200}}})
+
+	if err != nil {
+		t.Fatalf("unexpected error creating fee service: %v", err)
+	}
+
+	ctx := context.Background()
+
+	newAccount, err := isa.CreateAccount(ctx, account.Customer{
+		Id:           uuid.New(),
+		TaxResidency: "uk",
+		NINumber:     "AB123456A",
+		DateOfBirth:  time.Now().AddDate(-20, 0, 0),
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error when creating ISA account: %v", err)
+	}
+
+	err = isa.Invest(ctx, newAccount.Id, []account.Investment{
+		{FundId: equities.Id, TradeId: uuid.New(), TransactionType: account.TRANSACTION_TYPE_CUSTOMER, Amount: 30000},
+		{FundId: bonds.Id, TradeId: uuid.New(), TransactionType: account.TRANSACTION_TYPE_CUSTOMER, Amount: 10000},
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error when investing in funds: %v", err)
+	}
+
+	// The account is the only holder of the fund so receives the whole distribution as cash
+	_, err = account.NewDistributionService(&repo).PayDistributions(ctx, account.NewStubDeclarationSource(account.DividendDeclaration{
+		Id:         uuid.New(),
+		FundId:     equities.Id,
+		RecordDate: time.Now().AddDate(0, 0, 1),
+		Amount:     100,
+	}))
+
+	if err != nil {
+		t.Fatalf("unexpected error paying distribution: %v", err)
+	}
+
+	chargeDate := time.Now()
+
+	for run := 0; run < 2; run++ {
+		result, err := service.ChargeFees(ctx, chargeDate)
+
+		if err != nil || len(result.Failed) > 0 {
+			t.Fatalf("unexpected error charging fees: %v %v", err, result.Failed)
+		}
+	}
+
+	// 1% of 40,100 is 401, 100 comes from cash and 301 is raised 3:1 from the funds
+	cash, err := repo.GetCashBalance(ctx, newAccount.Id)
+
+	if err != nil {
+		t.Errorf("unexpected error fetching cash balance: %v", err)
+	}
+
+	if cash != 0 {
+		t.Errorf("Expected a cash balance of 0, got %d", cash)
+	}
+
+	accountFunds, err := repo.GetAccountFunds(ctx, newAccount.Id)
+
+	if err != nil {
+		t.Errorf("unexpected error fetching account funds: %v", err)
+	}
+
+	expected := map[uuid.UUID]int{equities.Id: 29774, bonds.Id: 9925}
+
+	for _, accountFund := range accountFunds {
+		if accountFund.Balance != expected[accountFund.FundId] {
+			t.Errorf("Expected fund %s to have a balance of %d, got %d", accountFund.FundId, expected[accountFund.FundId], accountFund.Balance)
+		}
+	}
+
+	breakdown, err := service.ChargesBreakdown(ctx, newAccount.Id, chargeDate.AddDate(0, -1, 0), chargeDate)
+
+	if err != nil {
+		t.Errorf("unexpected error fetching charges breakdown: %v", err)
+	}
+
+	if breakdown.PlatformFees != 401 || len(breakdown.Charges) != 1 || breakdown.BasisPoints != 1200 {
+		t.Errorf("Expected a single charge of 401 at 1200bps, got %+v", breakdown)
+	}
+}
@@ -1,28 +1,669 @@
 package account
 
-import "net/http"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/internal/nivalidation"
+)
+
+// Header used by the API gateway to forward the customer id of the session
+const SESSION_CUSTOMER_HEADER = "X-Customer-Id"
 
 // Handler to create an account
 // POST /api/v1/account
 //
-// I have included this just to show where I would store the handlers
-// and how I would structure them.
-func PostAccountHandler(serviceFactory ServiceFactory) http.HandlerFunc {
+// The body contains the account_type, the account is opened for the customer
+// in the session. Returns 200 with the account on success, 404 if the
+// customer doesn't exist or 422 if they can't open the account.
+func PostAccountHandler(serviceFactory ServiceFactory, customers CustomerClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		customerId, err := uuid.Parse(r.Header.Get(SESSION_CUSTOMER_HEADER))
+
+		if err != nil {
+			http.Error(w, "Unauthorised", http.StatusUnauthorized)
+			return
+		}
+
+		var request struct {
+			AccountType string `json:"account_type"`
+		}
+
+		err = json.NewDecoder(r.Body).Decode(&request)
+
+		if err != nil {
+			http.Error(w, "Unable to parse request body", http.StatusBadRequest)
+			return
+		}
+
+		service := serviceFactory.Service(request.AccountType)
+
+		if service == nil {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]map[string]string{
+				"errors": {"account_type": "Account type invalid or missing"},
+			})
+			return
+		}
+
+		customer, err := customers.GetCustomer(r.Context(), customerId)
+
+		if errors.Is(err, ErrCustomerNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		if err != nil {
+			http.Error(w, "Unable to fetch customer", http.StatusInternalServerError)
+			return
+		}
+
+		account, err := service.CreateAccount(r.Context(), customer)
+
+		if errors.As(err, &ErrAccountCreatePermission{}) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		if errors.Is(err, ErrAccountInvalid) {
+			writeJSON(w, http.StatusUnprocessableEntity, account)
+			return
+		}
+
+		if errors.Is(err, nivalidation.ErrUnavailable) {
+			http.Error(w, "Unable to verify NI number, please try again later", http.StatusServiceUnavailable)
+			return
+		}
+
+		if err != nil {
+			http.Error(w, "Unable to create account", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, account)
+	}
+}
+
+// List the account types the customer can open
+// GET /api/v1/customer/{id}/eligibility
+//
+// Returns 200 with the eligibility for each account type offered, including
+// every rule checked, so the UI only offers products the customer qualifies
+// for. The customer must be the customer in the session.
+func GetEligibilityHandler(serviceFactory ServiceFactory, customers CustomerClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sessionCustomerId, err := uuid.Parse(r.Header.Get(SESSION_CUSTOMER_HEADER))
+
+		if err != nil {
+			http.Error(w, "Unauthorised", http.StatusUnauthorized)
+			return
+		}
+
+		customerId, err := uuid.Parse(r.PathValue("id"))
+
+		if err != nil || customerId != sessionCustomerId {
+			http.Error(w, ErrCustomerNotFound.Error(), http.StatusNotFound)
+			return
+		}
+
+		customer, err := customers.GetCustomer(r.Context(), customerId)
+
+		if errors.Is(err, ErrCustomerNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		if err != nil {
+			http.Error(w, "Unable to fetch customer", http.StatusInternalServerError)
+			return
+		}
+
+		eligibility, err := serviceFactory.Eligibility(r.Context(), customer)
+
+		if errors.Is(err, nivalidation.ErrUnavailable) {
+			http.Error(w, "Unable to verify NI number, please try again later", http.StatusServiceUnavailable)
+			return
+		}
+
+		if err != nil {
+			http.Error(w, "Unable to check eligibility", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, eligibility)
+	}
+}
+
+// List the account types offered
+// GET /api/v1/products
+//
+// Returns 200 with each account type offered, its name and the subscription
+// limits for the current tax year.
+func GetProductsHandler(serviceFactory ServiceFactory, startOfTaxYear StartOfTaxYear) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		products, err := serviceFactory.Products(r.Context(), startOfTaxYear.YearOf(time.Now()))
+
+		if err != nil {
+			http.Error(w, "Unable to list products", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, products)
+	}
+}
+
+// Pay money into an account and invest it in one or more funds
+// POST /api/v1/account/{id}/invest
+//
+// The body is a DepositRequest, a total amount in pence, the percentage to
+// invest in each fund and the payment method. The investments are made once
+// the payment settles so 202 is returned along with the pending deposit.
+func PostInvestHandler(serviceFactory ServiceFactory, depositService *DepositService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, account, ok := sessionAccountService(w, r, serviceFactory)
+
+		if !ok {
+			return
+		}
+
+		var request DepositRequest
+
+		err := json.NewDecoder(r.Body).Decode(&request)
+
+		if err != nil {
+			http.Error(w, "Unable to parse request body", http.StatusBadRequest)
+			return
+		}
+
+		deposit, err := depositService.CreateDeposit(r.Context(), account.Id, &request)
+
+		if errors.Is(err, ErrDepositInvalid) {
+			writeJSON(w, http.StatusUnprocessableEntity, request)
+			return
+		}
+
+		if IsAllowanceError(err) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		if err != nil {
+			http.Error(w, "Unable to take payment", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusAccepted, deposit)
+	}
+}
+
+// Get the status of a deposit
+// GET /api/v1/account/{id}/deposits/{depositId}
+func GetDepositHandler(serviceFactory ServiceFactory, depositService *DepositService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, account, ok := sessionAccountService(w, r, serviceFactory)
+
+		if !ok {
+			return
+		}
+
+		depositId, err := uuid.Parse(r.PathValue("depositId"))
+
+		if err != nil {
+			http.Error(w, ErrDepositNotFound.Error(), http.StatusNotFound)
+			return
+		}
+
+		deposit, err := depositService.Deposit(r.Context(), depositId)
+
+		if errors.Is(err, ErrDepositNotFound) || (err == nil && deposit.AccountId != account.Id) {
+			http.Error(w, ErrDepositNotFound.Error(), http.StatusNotFound)
+			return
+		}
+
+		if err != nil {
+			http.Error(w, "Unable to fetch deposit", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, deposit)
+	}
+}
+
+// Switch money from one fund to another
+// POST /api/v1/account/{id}/switch
+func PostSwitchHandler(serviceFactory ServiceFactory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		service, account, ok := sessionAccountService(w, r, serviceFactory)
+
+		if !ok {
+			return
+		}
+
+		var request struct {
+			From   uuid.UUID `json:"from"`
+			To     uuid.UUID `json:"to"`
+			Amount int       `json:"amount"`
+		}
+
+		err := json.NewDecoder(r.Body).Decode(&request)
+
+		if err != nil {
+			http.Error(w, "Unable to parse request body", http.StatusBadRequest)
+			return
+		}
+
+		err = service.Switch(r.Context(), account.Id, request.From, request.To, request.Amount)
+
+		if errors.Is(err, ErrSwitchInvalid) || errors.Is(err, ErrInsufficientBalance) || errors.Is(err, ErrInvestmentInvalid) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		if err != nil {
+			http.Error(w, "Unable to complete switch", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// Withdraw money from a fund
+// POST /api/v1/account/{id}/withdraw
+//
+// The proceeds are paid to the customer's nominated bank account, 202 is
+// returned along with the withdrawal as the payment is made later.
+//
+// The body is a WithdrawalRequest, LISA withdrawals must include a reason.
+func PostWithdrawHandler(serviceFactory ServiceFactory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		service, account, ok := sessionAccountService(w, r, serviceFactory)
+
+		if !ok {
+			return
+		}
+
+		var request WithdrawalRequest
+
+		err := json.NewDecoder(r.Body).Decode(&request)
+
+		if err != nil {
+			http.Error(w, "Unable to parse request body", http.StatusBadRequest)
+			return
+		}
+
+		withdrawal, err := service.Withdraw(r.Context(), account.Id, request)
+
+		if isWithdrawalError(err) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		if err != nil {
+			http.Error(w, "Unable to complete withdrawal", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusAccepted, withdrawal)
+	}
+}
+
+// Quote a withdrawal without making it
+// GET /api/v1/account/{id}/withdraw/quote?fund_id=&amount=&reason=
+//
+// Returns the withdrawal that would be made, including any penalty and the
+// net payout, so the customer can confirm it.
+func GetWithdrawalQuoteHandler(serviceFactory ServiceFactory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		service, account, ok := sessionAccountService(w, r, serviceFactory)
+
+		if !ok {
+			return
+		}
+
+		query := r.URL.Query()
+
+		fundId, err := uuid.Parse(query.Get("fund_id"))
+
+		if err != nil {
+			http.Error(w, "Invalid fund id", http.StatusBadRequest)
+			return
+		}
+
+		amount, err := strconv.Atoi(query.Get("amount"))
+
+		if err != nil {
+			http.Error(w, "Invalid amount", http.StatusBadRequest)
+			return
+		}
+
+		quote, err := service.QuoteWithdrawal(r.Context(), account.Id, WithdrawalRequest{
+			FundId: fundId,
+			Amount: amount,
+			Reason: query.Get("reason"),
+		})
+
+		if isWithdrawalError(err) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		if err != nil {
+			http.Error(w, "Unable to quote withdrawal", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, quote)
+	}
+}
+
+func isWithdrawalError(err error) bool {
+	return errors.Is(err, ErrWithdrawalInvalid) || errors.Is(err, ErrWithdrawalReasonInvalid) || errors.Is(err, ErrWithdrawalNotAllowed) || errors.Is(err, ErrLISAWithdrawalUnder60) || errors.Is(err, ErrInsufficientBalance)
+}
+
+// List the regular investment plans for an account
+// GET /api/v1/account/{id}/plans
+func GetPlansHandler(serviceFactory ServiceFactory, planService *PlanService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Parse the post data.
+		_, account, ok := sessionAccountService(w, r, serviceFactory)
 
-		// create the correct account instance of the serviceFactory
+		if !ok {
+			return
+		}
 
-		// Fetch the user from the session
+		plans, err := planService.AccountPlans(r.Context(), account.Id)
 
-		// call service.CreateAccount(...)
+		if err != nil {
+			http.Error(w, "Unable to fetch plans", http.StatusInternalServerError)
+			return
+		}
 
-		// Return 200 on success or 422 on validation error.
+		writeJSON(w, http.StatusOK, plans)
 	}
 }
 
-// Invest in a fund
-// POST /api/v1/account/{account id}/invest
+// Create a regular investment plan
+// POST /api/v1/account/{id}/plans
+func PostPlanHandler(serviceFactory ServiceFactory, planService *PlanService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, account, ok := sessionAccountService(w, r, serviceFactory)
+
+		if !ok {
+			return
+		}
+
+		var plan Plan
+
+		err := json.NewDecoder(r.Body).Decode(&plan)
+
+		if err != nil {
+			http.Error(w, "Unable to parse request body", http.StatusBadRequest)
+			return
+		}
+
+		plan.AccountId = account.Id
+
+		err = planService.CreatePlan(r.Context(), &plan)
+
+		if errors.Is(err, ErrPlanInvalid) {
+			writeJSON(w, http.StatusUnprocessableEntity, plan)
+			return
+		}
+
+		if err != nil {
+			http.Error(w, "Unable to create plan", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, plan)
+	}
+}
+
+// Get a regular investment plan
+// GET /api/v1/account/{id}/plans/{planId}
+func GetPlanHandler(serviceFactory ServiceFactory, planService *PlanService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		plan, ok := sessionAccountPlan(w, r, serviceFactory, planService)
+
+		if !ok {
+			return
+		}
+
+		writeJSON(w, http.StatusOK, plan)
+	}
+}
+
+// Update a regular investment plan
+// PUT /api/v1/account/{id}/plans/{planId}
+func PutPlanHandler(serviceFactory ServiceFactory, planService *PlanService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		existing, ok := sessionAccountPlan(w, r, serviceFactory, planService)
+
+		if !ok {
+			return
+		}
+
+		var plan Plan
+
+		err := json.NewDecoder(r.Body).Decode(&plan)
+
+		if err != nil {
+			http.Error(w, "Unable to parse request body", http.StatusBadRequest)
+			return
+		}
+
+		plan.Id = existing.Id
+
+		err = planService.UpdatePlan(r.Context(), &plan)
+
+		if errors.Is(err, ErrPlanInvalid) {
+			writeJSON(w, http.StatusUnprocessableEntity, plan)
+			return
+		}
+
+		if err != nil {
+			http.Error(w, "Unable to update plan", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, plan)
+	}
+}
+
+// Delete a regular investment plan
+// DELETE /api/v1/account/{id}/plans/{planId}
+func DeletePlanHandler(serviceFactory ServiceFactory, planService *PlanService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		plan, ok := sessionAccountPlan(w, r, serviceFactory, planService)
+
+		if !ok {
+			return
+		}
+
+		err := planService.DeletePlan(r.Context(), plan.Id)
+
+		if err != nil {
+			http.Error(w, "Unable to delete plan", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Set how the account receives distributions from income funds
+// PUT /api/v1/account/{id}/income-preference
+func PutIncomePreferenceHandler(serviceFactory ServiceFactory, distributionService *DistributionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, account, ok := sessionAccountService(w, r, serviceFactory)
+
+		if !ok {
+			return
+		}
+
+		var request struct {
+			IncomePreference string `json:"income_preference"`
+		}
+
+		err := json.NewDecoder(r.Body).Decode(&request)
+
+		if err != nil {
+			http.Error(w, "Unable to parse request body", http.StatusBadRequest)
+			return
+		}
+
+		err = distributionService.SetIncomePreference(r.Context(), account.Id, request.IncomePreference)
+
+		if errors.Is(err, ErrIncomePreferenceInvalid) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		if err != nil {
+			http.Error(w, "Unable to update income preference", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// Download the annual statement for a tax year
+// GET /api/v1/account/{id}/statements/{taxYear}?format=pdf
+//
+// The tax year is the year it starts in (e.g. 2024 for 2024-25). The
+// statement is returned as a PDF unless the format is csv.
+func GetStatementHandler(serviceFactory ServiceFactory, statementService *StatementService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, account, ok := sessionAccountService(w, r, serviceFactory)
+
+		if !ok {
+			return
+		}
+
+		taxYear, err := strconv.Atoi(r.PathValue("taxYear"))
+
+		if err != nil {
+			http.Error(w, ErrStatementNotFound.Error(), http.StatusNotFound)
+			return
+		}
+
+		format := r.URL.Query().Get("format")
+
+		if format == "" {
+			format = STATEMENT_FORMAT_PDF
+		}
+
+		statement, err := statementService.StoredStatement(r.Context(), account.Id, taxYear)
+
+		if errors.Is(err, ErrStatementNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		if err != nil {
+			http.Error(w, "Unable to fetch statement", http.StatusInternalServerError)
+			return
+		}
+
+		body, ok := statement.Format(format)
+
+		if !ok {
+			http.Error(w, "Format must be pdf or csv", http.StatusBadRequest)
+			return
+		}
+
+		contentType := "application/pdf"
+
+		if format == STATEMENT_FORMAT_CSV {
+			contentType = "text/csv"
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="statement-%s.%s"`, TaxYearLabel(taxYear), format))
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+	}
+}
 
 // Get account transactions
 // GET /api/v1/account/{account id}
+
+// Fetch the account in the path along with its Service
+//
+// The account must be controlled by the customer in the session (the guardian
+// for a Junior ISA), if it is not (or does not exist) a 404 is written and ok
+// is false.
+func sessionAccountService(w http.ResponseWriter, r *http.Request, serviceFactory ServiceFactory) (Service, Account, bool) {
+	customerId, err := uuid.Parse(r.Header.Get(SESSION_CUSTOMER_HEADER))
+
+	if err != nil {
+		http.Error(w, "Unauthorised", http.StatusUnauthorized)
+		return nil, Account{}, false
+	}
+
+	accountId, err := uuid.Parse(r.PathValue("id"))
+
+	if err != nil {
+		http.Error(w, ErrAccountNotFound.Error(), http.StatusNotFound)
+		return nil, Account{}, false
+	}
+
+	service, account, err := serviceFactory.ServiceForAccount(r.Context(), accountId)
+
+	if errors.Is(err, ErrAccountNotFound) || (err == nil && account.ControlledBy() != customerId) {
+		http.Error(w, ErrAccountNotFound.Error(), http.StatusNotFound)
+		return nil, Account{}, false
+	}
+
+	if err != nil {
+		http.Error(w, "Unable to fetch account", http.StatusInternalServerError)
+		return nil, Account{}, false
+	}
+
+	return service, account, true
+}
+
+// Fetch the plan in the path
+//
+// The plan must belong to the account in the path, which in turn must be
+// controlled by the customer in the session. If not a 404 is written and ok
+// is false.
+func sessionAccountPlan(w http.ResponseWriter, r *http.Request, serviceFactory ServiceFactory, planService *PlanService) (Plan, bool) {
+	_, account, ok := sessionAccountService(w, r, serviceFactory)
+
+	if !ok {
+		return Plan{}, false
+	}
+
+	planId, err := uuid.Parse(r.PathValue("planId"))
+
+	if err != nil {
+		http.Error(w, ErrPlanNotFound.Error(), http.StatusNotFound)
+		return Plan{}, false
+	}
+
+	plan, err := planService.Plan(r.Context(), planId)
+
+	if errors.Is(err, ErrPlanNotFound) || (err == nil && plan.AccountId != account.Id) {
+		http.Error(w, ErrPlanNotFound.Error(), http.StatusNotFound)
+		return Plan{}, false
+	}
+
+	if err != nil {
+		http.Error(w, "Unable to fetch plan", http.StatusInternalServerError)
+		return Plan{}, false
+	}
+
+	return plan, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -5,82 +5,162 @@ import (
 	"errors"
 	"fmt"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/internal/fund"
 )
 
 var ErrExceededISALimit = errors.New("ISA limit will be exceeded by transaction")
 
+func init() {
+	RegisterService(ServiceDefinition{
+		Name: "isa",
+		New: func(product ProductRules, rules *RulesService, deps ServiceDependencies) Service {
+			return NewISAService(deps.Repository, deps.Catalogue, rules, product.AccountType, product.Flexible, deps.StartOfTaxYear, deps.NIValidator)
+		},
+	})
+}
+
+// The tax year starts at midnight UK time, which is an hour before midnight
+// UTC during British Summer Time.
+var taxYearLocation = mustLoadLocation("Europe/London")
+
+func mustLoadLocation(name string) *time.Location {
+	location, err := time.LoadLocation(name)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return location
+}
+
 type StartOfTaxYear struct {
 	Day   int
 	Month int
 }
 
+// Returns the first day of the tax year starting in the given year and
+// the first day of the following tax year
+func (s StartOfTaxYear) Bounds(year int) (time.Time, time.Time) {
+	start := time.Date(year, time.Month(s.Month), s.Day, 0, 0, 0, 0, taxYearLocation)
+
+	return start, time.Date(year+1, time.Month(s.Month), s.Day, 0, 0, 0, 0, taxYearLocation)
+}
+
+// Returns the year the tax year containing the date starts in
+func (s StartOfTaxYear) YearOf(date time.Time) int {
+	date = date.In(taxYearLocation)
+
+	start, _ := s.Bounds(date.Year())
+
+	if date.Before(start) {
+		return date.Year() - 1
+	}
+
+	return date.Year()
+}
+
 // Service to manage ISA accounts
 //
 // ISAs must adhere to the following rules
-// - Only available to UK tax residents over the age of 18
-// - The account holder must have a valid NI number
+// - Who can open an ISA and the limits each tax year are declared in the rules (see RulesService).
 // - The account holder is limited by how much they can deposit each tax year.
-// - There are no limits on withdrawals
+// - The limit is shared with any other ISAs they hold.
+// - Only one ISA can be paid into each tax year.
+// - There are no limits on withdrawals, for a flexible ISA they can be replaced in the same tax year.
 type ISAService struct {
 	repository     Repository
-	annualLimit    int
+	catalogue      fund.Catalogue
+	rules          *RulesService
+	accountType    string
+	flexible       bool
 	startOfTaxYear StartOfTaxYear
-	niValidator    func(string) error
+	niValidator    func(context.Context, string) error
 }
 
-func NewISAService(repository *Repository, annualLimit int, startOfTaxYear StartOfTaxYear, niValidator func(string) error) *ISAService {
+// A flexible ISA allows money that is withdrawn to be replaced later in the
+// same tax year without using up more of the allowance.
+func NewISAService(repository *Repository, catalogue *fund.Catalogue, rules *RulesService, accountType string, flexible bool, startOfTaxYear StartOfTaxYear, niValidator func(context.Context, string) error) *ISAService {
 	return &ISAService{
 		repository:     *repository,
-		annualLimit:    annualLimit,
+		catalogue:      *catalogue,
+		rules:          rules,
+		accountType:    accountType,
+		flexible:       flexible,
 		startOfTaxYear: startOfTaxYear,
 		niValidator:    niValidator,
 	}
 }
 
-func (s *ISAService) CreateAccount(ctx context.Context, customer Customer) (Account, error) {
+func (s *ISAService) AccountType() string {
+	return s.accountType
+}
 
-	// Ensure the customer is a UK tax resident
-	if customer.TaxResidency != "uk" {
-		return Account{}, ErrAccountCreatePermission{"Only UK tax residents can open an ISA"}
-	}
+func (s *ISAService) Eligibility(ctx context.Context, customer Customer) (Eligibility, error) {
+	return s.rules.Eligibility(ctx, s.accountType, customer, s.niValidator)
+}
+
+func (s *ISAService) CreateAccount(ctx context.Context, customer Customer) (Account, error) {
+	eligibility, err := s.Eligibility(ctx, customer)
 
-	// Ensure the customer is over 18
-	cutOff := time.Now().AddDate(-18, 0, 0)
-	if customer.DateOfBirth.After(cutOff) {
-		return Account{}, ErrAccountCreatePermission{"Only customers who are over the age of 18 can open an ISA"}
+	if err != nil {
+		return Account{}, err
 	}
 
-	// Ensure the customer's NI number is valid
-	if err := s.niValidator(customer.NINumber); err != nil {
-		return Account{}, ErrAccountCreatePermission{"Customer NI number could not be verified: " + err.Error()}
+	if err := eligibility.Err(); err != nil {
+		return Account{}, err
 	}
 
 	account := Account{
-		AccountType: ACCOUNT_TYPE_ISA,
+		AccountType: s.accountType,
 		CustomerId:  customer.Id,
 	}
 
-	return createAccount(ctx, s.repository, account)
+	return createAccount(ctx, s.repository, s.rules, account)
 }
 
-func (s *ISAService) Invest(ctx context.Context, accountId uuid.UUID, investments []Investment) error {
+func (s *ISAService) CheckInvestment(ctx context.Context, accountId uuid.UUID, investments []Investment) error {
+	err := validateInvestments(ctx, s.repository, s.catalogue, s.accountType, accountId, investments)
+
+	if err != nil {
+		return err
+	}
+
+	account, err := s.repository.GetAccount(ctx, accountId)
+
+	if err != nil {
+		return err
+	}
+
 	var totalToInvest int
 
 	for _, investment := range investments {
 		totalToInvest += investment.Amount
 	}
 
-	startOfTaxYear := time.Date(time.Now().Year(), time.Month(s.startOfTaxYear.Month), s.startOfTaxYear.Day, 0, 0, 0, 0, time.UTC)
+	taxYear := s.startOfTaxYear.YearOf(time.Now())
 
-	totalInvested, _ := s.repository.GetTotalInvestedToDate(ctx, accountId, startOfTaxYear)
+	limits, err := s.rules.Limits(ctx, s.accountType, taxYear)
 
-	if totalToInvest+totalInvested > s.annualLimit {
-		return ErrExceededISALimit
+	if err != nil {
+		return err
 	}
 
-	err := s.repository.Invest(ctx, accountId, investments)
+	startOfTaxYear, _ := s.startOfTaxYear.Bounds(taxYear)
+
+	return checkAllowance(ctx, s.repository, s.rules, account, totalToInvest, startOfTaxYear, limits.OverallLimit, limits.AnnualLimit, s.flexible)
+}
+
+func (s *ISAService) Invest(ctx context.Context, accountId uuid.UUID, investments []Investment) error {
+	err := s.CheckInvestment(ctx, accountId, investments)
+
+	if err != nil {
+		return err
+	}
+
+	err = s.repository.Invest(ctx, accountId, investments)
 
 	if err != nil {
 		return fmt.Errorf("Unable to complete investment: %w", err)
@@ -89,6 +169,22 @@ func (s *ISAService) Invest(ctx context.Context, accountId uuid.UUID, investment
 	return nil
 }
 
+func (s *ISAService) InvestAllocation(ctx context.Context, accountId uuid.UUID, request *AllocationRequest) error {
+	return investAllocation(ctx, s, accountId, request)
+}
+
+func (s *ISAService) Switch(ctx context.Context, accountId uuid.UUID, from uuid.UUID, to uuid.UUID, amount int) error {
+	return switchFunds(ctx, s.repository, s.catalogue, s.accountType, accountId, from, to, amount)
+}
+
+func (s *ISAService) QuoteWithdrawal(ctx context.Context, accountId uuid.UUID, request WithdrawalRequest) (Withdrawal, error) {
+	return newWithdrawal(ctx, s.repository, accountId, request, 0)
+}
+
+func (s *ISAService) Withdraw(ctx context.Context, accountId uuid.UUID, request WithdrawalRequest) (Withdrawal, error) {
+	return withdraw(ctx, s.repository, accountId, request)
+}
+
 func (s *ISAService) AccountTransactions(ctx context.Context, accountId uuid.UUID, filter TransactionFilter) ([]Transaction, error) {
 	return getAccountTransactions(ctx, s.repository, accountId, filter)
 }
@@ -9,6 +9,8 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/jameswhoughton/cushon/internal/account"
+	"github.com/jameswhoughton/cushon/internal/fund"
+	"github.com/jameswhoughton/cushon/internal/nivalidation"
 )
 
 func TestCannotCreateAnAccountIfCustomerDoesNotReachRequirements(t *testing.T) {
@@ -27,7 +29,7 @@ func TestCannotCreateAnAccountIfCustomerDoesNotReachRequirements(t *testing.T) {
 				Id:           uuid.New(),
 				TaxResidency: "fr",
 				DateOfBirth:  time.Now().AddDate(-19, 0, 0),
-				NINumber:     "SD000000B",
+				NINumber:     "AB123456B",
 			},
 		},
 		{
@@ -36,7 +38,7 @@ func TestCannotCreateAnAccountIfCustomerDoesNotReachRequirements(t *testing.T) {
 				Id:           uuid.New(),
 				TaxResidency: "uk",
 				DateOfBirth:  time.Now().AddDate(-17, 0, 0),
-				NINumber:     "SD000000B",
+				NINumber:     "AB123456B",
 			},
 		},
 		{
@@ -50,15 +52,17 @@ func TestCannotCreateAnAccountIfCustomerDoesNotReachRequirements(t *testing.T) {
 		},
 	}
 
-	niValidator := func(ni string) error {
-		if ni != "SD000000B" {
+	niValidator := func(_ context.Context, ni string) error {
+		if ni != "AB123456B" {
 			return fmt.Errorf("NI '%s' is invalid", ni)
 		}
 
 		return nil
 	}
 
-	service := account.NewISAService(&repo, 0, account.StartOfTaxYear{1, 1}, niValidator)
+	catalogue := NewTestCatalogue()
+
+	service := account.NewISAService(&repo, &catalogue, NewTestRules(0, 0), account.ACCOUNT_TYPE_ISA, false, account.StartOfTaxYear{1, 1}, niValidator)
 
 	ctx := context.Background()
 
@@ -77,22 +81,55 @@ func TestCannotCreateAnAccountIfCustomerDoesNotReachRequirements(t *testing.T) {
 	}
 }
 
+func TestNIValidationOutageIsNotAPermissionError(t *testing.T) {
+	// The customer is rejected before the repository is used
+	var repo account.Repository
+
+	server := nivalidation.NewFakeServer()
+	defer server.Close()
+
+	server.FailNext(3)
+
+	client := nivalidation.NewClient(server.URL, server.Client(), nivalidation.Config{MaxAttempts: 3, Backoff: time.Millisecond})
+
+	catalogue := NewTestCatalogue()
+
+	service := account.NewISAService(&repo, &catalogue, NewTestRules(0, 0), account.ACCOUNT_TYPE_ISA, false, account.StartOfTaxYear{1, 1}, client.Validate)
+
+	_, err := service.CreateAccount(context.Background(), account.Customer{
+		Id:           uuid.New(),
+		TaxResidency: "uk",
+		DateOfBirth:  time.Now().AddDate(-20, 0, 0),
+		NINumber:     "AB123456A",
+	})
+
+	if !errors.Is(err, nivalidation.ErrUnavailable) {
+		t.Errorf("Expected error %v, got %v", nivalidation.ErrUnavailable, err)
+	}
+
+	if errors.As(err, &account.ErrAccountCreatePermission{}) {
+		t.Errorf("Expected an outage not to be reported as %T", account.ErrAccountCreatePermission{})
+	}
+}
+
 func TestISAServiceCreatesAnIsaAccount(t *testing.T) {
 	repo, closeDown := NewTestRepository()
 	defer closeDown()
 
-	passingNiValidator := func(_ string) error {
+	passingNiValidator := func(_ context.Context, _ string) error {
 		return nil
 	}
 
-	service := account.NewISAService(&repo, 0, account.StartOfTaxYear{1, 1}, passingNiValidator)
+	catalogue := NewTestCatalogue()
+
+	service := account.NewISAService(&repo, &catalogue, NewTestRules(0, 0), account.ACCOUNT_TYPE_ISA, false, account.StartOfTaxYear{1, 1}, passingNiValidator)
 
 	ctx := context.Background()
 
 	customer := account.Customer{
 		Id:           uuid.New(),
 		TaxResidency: "uk",
-		NINumber:     "SD000000A",
+		NINumber:     "AB123456A",
 		DateOfBirth:  time.Now().AddDate(-20, 0, 0),
 	}
 
@@ -111,18 +148,21 @@ func TestISAServiceCanInvestMoneyInAFund(t *testing.T) {
 	repo, closeDown := NewTestRepository()
 	defer closeDown()
 
-	passingNiValidator := func(_ string) error {
+	passingNiValidator := func(_ context.Context, _ string) error {
 		return nil
 	}
 
-	service := account.NewISAService(&repo, 200, account.StartOfTaxYear{1, 1}, passingNiValidator)
+	testFund := NewTestFund()
+	catalogue := NewTestCatalogue(testFund)
+
+	service := account.NewISAService(&repo, &catalogue, NewTestRules(200, 0), account.ACCOUNT_TYPE_ISA, false, account.StartOfTaxYear{1, 1}, passingNiValidator)
 
 	ctx := context.Background()
 
 	customer := account.Customer{
 		Id:           uuid.New(),
 		TaxResidency: "uk",
-		NINumber:     "SD000000A",
+		NINumber:     "AB123456A",
 		DateOfBirth:  time.Now().AddDate(-20, 0, 0),
 	}
 
@@ -134,7 +174,7 @@ func TestISAServiceCanInvestMoneyInAFund(t *testing.T) {
 
 	investments := []account.Investment{
 		{
-			FundId:          uuid.New(),
+			FundId:          testFund.Id,
 			TradeId:         uuid.New(),
 			TransactionType: account.TRANSACTION_TYPE_CUSTOMER,
 			Amount:          100,
@@ -167,18 +207,21 @@ func TestICannotInvestInAFundIfIHaveReachedMyAnnualLimit(t *testing.T) {
 	repo, closeDown := NewTestRepository()
 	defer closeDown()
 
-	passingNiValidator := func(_ string) error {
+	passingNiValidator := func(_ context.Context, _ string) error {
 		return nil
 	}
 
-	service := account.NewISAService(&repo, 50, account.StartOfTaxYear{1, 1}, passingNiValidator)
+	testFund := NewTestFund()
+	catalogue := NewTestCatalogue(testFund)
+
+	service := account.NewISAService(&repo, &catalogue, NewTestRules(50, 0), account.ACCOUNT_TYPE_ISA, false, account.StartOfTaxYear{1, 1}, passingNiValidator)
 
 	ctx := context.Background()
 
 	customer := account.Customer{
 		Id:           uuid.New(),
 		TaxResidency: "uk",
-		NINumber:     "SD000000A",
+		NINumber:     "AB123456A",
 		DateOfBirth:  time.Now().AddDate(-20, 0, 0),
 	}
 
@@ -190,7 +233,7 @@ func TestICannotInvestInAFundIfIHaveReachedMyAnnualLimit(t *testing.T) {
 
 	investments := []account.Investment{
 		{
-			FundId:          uuid.New(),
+			FundId:          testFund.Id,
 			TradeId:         uuid.New(),
 			TransactionType: account.TRANSACTION_TYPE_CUSTOMER,
 			Amount:          100,
@@ -207,3 +250,75 @@ func TestICannotInvestInAFundIfIHaveReachedMyAnnualLimit(t *testing.T) {
 		t.Errorf("Expected error %v, got %T - %v", account.ErrExceededISALimit, err, err)
 	}
 }
+
+func TestICannotInvestInAFundThatIsNotAvailable(t *testing.T) {
+	repo, closeDown := NewTestRepository()
+	defer closeDown()
+
+	passingNiValidator := func(_ context.Context, _ string) error {
+		return nil
+	}
+
+	closedFund := NewTestFund()
+	closedFund.Status = fund.FUND_STATUS_CLOSED
+
+	softClosedFund := NewTestFund()
+	softClosedFund.Status = fund.FUND_STATUS_SOFT_CLOSED
+
+	pensionFund := NewTestFund()
+	pensionFund.AccountTypes = []string{"pension"}
+
+	catalogue := NewTestCatalogue(closedFund, softClosedFund, pensionFund)
+
+	service := account.NewISAService(&repo, &catalogue, NewTestRules(200, 0), account.ACCOUNT_TYPE_ISA, false, account.StartOfTaxYear{1, 1}, passingNiValidator)
+
+	ctx := context.Background()
+
+	customer := account.Customer{
+		Id:           uuid.New(),
+		TaxResidency: "uk",
+		NINumber:     "AB123456A",
+		DateOfBirth:  time.Now().AddDate(-20, 0, 0),
+	}
+
+	newAccount, err := service.CreateAccount(ctx, customer)
+
+	if err != nil {
+		t.Errorf("unexpected error when creating ISA account: %v", err)
+	}
+
+	type testCase struct {
+		name   string
+		fundId uuid.UUID
+	}
+
+	testCases := []testCase{
+		{name: "Unknown fund", fundId: uuid.New()},
+		{name: "Closed fund", fundId: closedFund.Id},
+		{name: "Soft-closed fund not already held", fundId: softClosedFund.Id},
+		{name: "Fund not available to ISAs", fundId: pensionFund.Id},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			investments := []account.Investment{
+				{
+					FundId:          testCase.fundId,
+					TradeId:         uuid.New(),
+					TransactionType: account.TRANSACTION_TYPE_CUSTOMER,
+					Amount:          100,
+				},
+			}
+
+			err := service.Invest(ctx, newAccount.Id, investments)
+
+			if !errors.Is(err, account.ErrInvestmentInvalid) {
+				t.Errorf("Expected error %v, got %v", account.ErrInvestmentInvalid, err)
+			}
+
+			if _, ok := investments[0].Errors["fund_id"]; !ok {
+				t.Error("expected validation error field 'fund_id' missing")
+			}
+		})
+	}
+}
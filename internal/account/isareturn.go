@@ -0,0 +1,320 @@
+package account
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/internal/nivalidation"
+)
+
+const (
+	ISA_RETURN_FORMAT_XML string = "xml"
+	ISA_RETURN_FORMAT_CSV string = "csv"
+)
+
+var ErrISAReturnInvalid = errors.New("ISA return invalid")
+
+// A single account in the annual ISA return
+//
+// Amounts are in pence, the market value is the value of the funds and cash
+// held at the end of the tax year.
+type ISAReturnRecord struct {
+	AccountId     uuid.UUID
+	AccountType   string
+	CustomerId    uuid.UUID
+	NINumber      string
+	Subscriptions int
+	MarketValue   int
+}
+
+// Totals across every record in the return
+type ISAReturnSummary struct {
+	Accounts           int
+	TotalSubscriptions int
+	TotalMarketValue   int
+}
+
+// A problem with a record that would cause the return to be rejected
+type ISAReturnError struct {
+	AccountId uuid.UUID
+	Field     string
+	Message   string
+}
+
+func (e ISAReturnError) Error() string {
+	return fmt.Sprintf("account %s: %s: %s", e.AccountId, e.Field, e.Message)
+}
+
+// Annual return of subscriptions and market values for every account sharing
+// the ISA allowance
+type ISAReturn struct {
+	TaxYear int
+	Records []ISAReturnRecord
+	Summary ISAReturnSummary
+}
+
+// Check every record against the rules of the return schema
+//
+// Subscriptions are checked against the annual limit for the record's
+// account type. Returns every problem found, the return should only be
+// submitted when there are none.
+func (r ISAReturn) Validate(annualLimits map[string]int) []ISAReturnError {
+	var errs []ISAReturnError
+
+	seen := make(map[uuid.UUID]bool, len(r.Records))
+
+	for _, record := range r.Records {
+		invalid := func(field string, message string) {
+			errs = append(errs, ISAReturnError{AccountId: record.AccountId, Field: field, Message: message})
+		}
+
+		if seen[record.AccountId] {
+			invalid("account_id", "Account is reported more than once")
+		}
+
+		seen[record.AccountId] = true
+
+		if err := nivalidation.CheckFormat(record.NINumber); err != nil {
+			invalid("ni_number", err.Error())
+		}
+
+		if record.Subscriptions < 0 {
+			invalid("subscriptions", "Subscriptions cannot be negative")
+		}
+
+		if record.Subscriptions > annualLimits[record.AccountType] {
+			invalid("subscriptions", "Subscriptions exceed the annual limit for account type '"+record.AccountType+"'")
+		}
+
+		if record.MarketValue < 0 {
+			invalid("market_value", "Market value cannot be negative")
+		}
+	}
+
+	return errs
+}
+
+// Format an amount in pence as pounds for the return, e.g. 123456 is 1234.56
+func isaReturnAmount(amount int) string {
+	sign := ""
+
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	return fmt.Sprintf("%s%d.%02d", sign, amount/100, amount%100)
+}
+
+type isaReturnXML struct {
+	XMLName  xml.Name              `xml:"ISAReturn"`
+	TaxYear  string                `xml:"TaxYear,attr"`
+	Summary  isaReturnSummaryXML   `xml:"Summary"`
+	Accounts []isaReturnAccountXML `xml:"Account"`
+}
+
+type isaReturnSummaryXML struct {
+	Accounts           int    `xml:"Accounts"`
+	TotalSubscriptions string `xml:"TotalSubscriptions"`
+	TotalMarketValue   string `xml:"TotalMarketValue"`
+}
+
+type isaReturnAccountXML struct {
+	AccountId     string `xml:"AccountId"`
+	AccountType   string `xml:"AccountType"`
+	NINumber      string `xml:"NINumber"`
+	Subscriptions string `xml:"Subscriptions"`
+	MarketValue   string `xml:"MarketValue"`
+}
+
+// Write the return as XML
+func (r ISAReturn) WriteXML(w io.Writer) error {
+	doc := isaReturnXML{
+		TaxYear: TaxYearLabel(r.TaxYear),
+		Summary: isaReturnSummaryXML{
+			Accounts:           r.Summary.Accounts,
+			TotalSubscriptions: isaReturnAmount(r.Summary.TotalSubscriptions),
+			TotalMarketValue:   isaReturnAmount(r.Summary.TotalMarketValue),
+		},
+	}
+
+	for _, record := range r.Records {
+		doc.Accounts = append(doc.Accounts, isaReturnAccountXML{
+			AccountId:     record.AccountId.String(),
+			AccountType:   record.AccountType,
+			NINumber:      record.NINumber,
+			Subscriptions: isaReturnAmount(record.Subscriptions),
+			MarketValue:   isaReturnAmount(record.MarketValue),
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("Unable to write ISA return: %w", err)
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	if err := encoder.Encode(doc); err != nil {
+		return fmt.Errorf("Unable to write ISA return: %w", err)
+	}
+
+	_, err := io.WriteString(w, "\n")
+
+	return err
+}
+
+// Write the return as CSV
+//
+// The summary is written as a final row with the account id 'TOTAL'.
+func (r ISAReturn) WriteCSV(w io.Writer) error {
+	rows := [][]string{{"tax_year", "account_id", "account_type", "ni_number", "subscriptions", "market_value"}}
+
+	for _, record := range r.Records {
+		rows = append(rows, []string{TaxYearLabel(r.TaxYear), record.AccountId.String(), record.AccountType, record.NINumber, isaReturnAmount(record.Subscriptions), isaReturnAmount(record.MarketValue)})
+	}
+
+	rows = append(rows, []string{TaxYearLabel(r.TaxYear), "TOTAL", "", strconv.Itoa(r.Summary.Accounts), isaReturnAmount(r.Summary.TotalSubscriptions), isaReturnAmount(r.Summary.TotalMarketValue)})
+
+	if err := csv.NewWriter(w).WriteAll(rows); err != nil {
+		return fmt.Errorf("Unable to write ISA return: %w", err)
+	}
+
+	return nil
+}
+
+// Service to build the annual ISA return for HMRC
+type ISAReturnService struct {
+	repository     Repository
+	customers      CustomerClient
+	rules          *RulesService
+	prices         PriceSource
+	startOfTaxYear StartOfTaxYear
+}
+
+func NewISAReturnService(repository *Repository, customers CustomerClient, rules *RulesService, prices PriceSource, startOfTaxYear StartOfTaxYear) *ISAReturnService {
+	return &ISAReturnService{
+		repository:     *repository,
+		customers:      customers,
+		rules:          rules,
+		prices:         prices,
+		startOfTaxYear: startOfTaxYear,
+	}
+}
+
+// Build the return for the tax year starting in the given year
+//
+// Every account of a type sharing the ISA allowance opened before the end of
+// the tax year is included. The return is validated against the schema, if
+// any record is invalid the errors are returned alongside ErrISAReturnInvalid
+// so they can all be fixed at once. Subscriptions are checked against the
+// limit for the account type that applied in the tax year, account types
+// without limits in the tax year weren't offered so are left out.
+func (s *ISAReturnService) Return(ctx context.Context, taxYear int) (ISAReturn, []ISAReturnError, error) {
+	start, end := s.startOfTaxYear.Bounds(taxYear)
+	lastDay := end.AddDate(0, 0, -1)
+
+	isaReturn := ISAReturn{TaxYear: taxYear}
+	annualLimits := make(map[string]int)
+
+	for _, product := range s.rules.Products() {
+		if !product.ISAAllowance {
+			continue
+		}
+
+		limits, err := s.rules.Limits(ctx, product.AccountType, taxYear)
+
+		if errors.Is(err, ErrLimitsNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return isaReturn, nil, fmt.Errorf("Unable to fetch limits: %w", err)
+		}
+
+		annualLimits[product.AccountType] = limits.OverallLimit
+
+		if limits.AnnualLimit > 0 {
+			annualLimits[product.AccountType] = limits.AnnualLimit
+		}
+
+		accounts, err := s.repository.GetAccountsByType(ctx, product.AccountType, end)
+
+		if err != nil {
+			return isaReturn, nil, fmt.Errorf("Unable to fetch accounts: %w", err)
+		}
+
+		for _, account := range accounts {
+			record, err := s.record(ctx, account, start, end, lastDay)
+
+			if err != nil {
+				return isaReturn, nil, fmt.Errorf("account %s: %w", account.Id, err)
+			}
+
+			isaReturn.Records = append(isaReturn.Records, record)
+			isaReturn.Summary.Accounts++
+			isaReturn.Summary.TotalSubscriptions += record.Subscriptions
+			isaReturn.Summary.TotalMarketValue += record.MarketValue
+		}
+	}
+
+	if errs := isaReturn.Validate(annualLimits); len(errs) > 0 {
+		return isaReturn, errs, ErrISAReturnInvalid
+	}
+
+	return isaReturn, nil, nil
+}
+
+// Funds are valued at their price on the last day of the tax year, the
+// record fails if a fund held hasn't been priced by then
+func (s *ISAReturnService) record(ctx context.Context, account Account, start time.Time, end time.Time, lastDay time.Time) (ISAReturnRecord, error) {
+	customer, err := s.customers.GetCustomer(ctx, account.CustomerId)
+
+	if err != nil {
+		return ISAReturnRecord{}, fmt.Errorf("Unable to fetch customer: %w", err)
+	}
+
+	subscriptions, err := s.repository.GetSubscriptionsBetween(ctx, account.Id, start, end)
+
+	if err != nil {
+		return ISAReturnRecord{}, fmt.Errorf("Unable to fetch subscriptions: %w", err)
+	}
+
+	holdings, err := s.repository.GetAccountUnitsAt(ctx, account.Id, lastDay)
+
+	if err != nil {
+		return ISAReturnRecord{}, fmt.Errorf("Unable to fetch holdings: %w", err)
+	}
+
+	values, err := valueHoldings(ctx, s.prices, holdings, lastDay)
+
+	if err != nil {
+		return ISAReturnRecord{}, err
+	}
+
+	marketValue, err := s.repository.GetCashBalanceAt(ctx, account.Id, lastDay)
+
+	if err != nil {
+		return ISAReturnRecord{}, fmt.Errorf("Unable to fetch cash balance: %w", err)
+	}
+
+	for _, value := range values {
+		marketValue += value
+	}
+
+	return ISAReturnRecord{
+		AccountId:     account.Id,
+		AccountType:   account.AccountType,
+		CustomerId:    account.CustomerId,
+		NINumber:      nivalidation.Normalise(customer.NINumber),
+		Subscriptions: subscriptions,
+		MarketValue:   marketValue,
+	}, nil
+}
@@ -0,0 +1,192 @@
+package account_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/database"
+	"github.com/jameswhoughton/cushon/internal/account"
+)
+
+func TestISAReturnValidation(t *testing.T) {
+	accountId := uuid.New()
+
+	type testCase struct {
+		name           string
+		record         account.ISAReturnRecord
+		expectedFields []string
+	}
+
+	cases := []testCase{
+		{
+			name:           "NI number missing",
+			record:         account.ISAReturnRecord{AccountId: accountId, AccountType: account.ACCOUNT_TYPE_ISA, Subscriptions: 100},
+			expectedFields: []string{"ni_number"},
+		},
+		{
+			name:           "Subscriptions over the limit and negative market value",
+			record:         account.ISAReturnRecord{AccountId: accountId, AccountType: account.ACCOUNT_TYPE_ISA, NINumber: "AB123456A", Subscriptions: 2001, MarketValue: -1},
+			expectedFields: []string{"subscriptions", "market_value"},
+		},
+		{
+			name:           "LISA subscriptions over the LISA limit",
+			record:         account.ISAReturnRecord{AccountId: accountId, AccountType: account.ACCOUNT_TYPE_LISA, NINumber: "AB123456A", Subscriptions: 401},
+			expectedFields: []string{"subscriptions"},
+		},
+		{
+			name:           "Valid record",
+			record:         account.ISAReturnRecord{AccountId: accountId, AccountType: account.ACCOUNT_TYPE_ISA, NINumber: "AB123456A", Subscriptions: 2000, MarketValue: 2500},
+			expectedFields: []string{},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			errs := account.ISAReturn{TaxYear: 2024, Records: []account.ISAReturnRecord{testCase.record}}.Validate(map[string]int{
+				account.ACCOUNT_TYPE_ISA:  2000,
+				account.ACCOUNT_TYPE_LISA: 400,
+			})
+
+			if len(errs) != len(testCase.expectedFields) {
+				t.Fatalf("Expected %d errors, got %v", len(testCase.expectedFields), errs)
+			}
+
+			for i, field := range testCase.expectedFields {
+				if errs[i].Field != field {
+					t.Errorf("Expected an error for '%s', got '%s'", field, errs[i].Field)
+				}
+			}
+		})
+	}
+}
+
+func TestISAReturnOutput(t *testing.T) {
+	accountId := uuid.MustParse("5d2b6f38-56a3-4c8e-8f2b-6c1d0e9a7b41")
+
+	isaReturn := account.ISAReturn{
+		TaxYear: 2024,
+		Records: []account.ISAReturnRecord{{AccountId: accountId, AccountType: account.ACCOUNT_TYPE_ISA, NINumber: "AB123456A", Subscriptions: 150000, MarketValue: 162345}},
+		Summary: account.ISAReturnSummary{Accounts: 1, TotalSubscriptions: 150000, TotalMarketValue: 162345},
+	}
+
+	var xmlOut bytes.Buffer
+
+	if err := isaReturn.WriteXML(&xmlOut); err != nil {
+		t.Fatalf("unexpected error writing XML: %v", err)
+	}
+
+	for _, expected := range []string{
+		`<ISAReturn TaxYear="2024-25">`,
+		`<TotalSubscriptions>1500.00</TotalSubscriptions>`,
+		`<AccountType>isa</AccountType>`,
+		`<NINumber>AB123456A</NINumber>`,
+		`<MarketValue>1623.45</MarketValue>`,
+	} {
+		if !strings.Contains(xmlOut.String(), expected) {
+			t.Errorf("Expected XML to contain %s, got:\n%s", expected, xmlOut.String())
+		}
+	}
+
+	var csvOut bytes.Buffer
+
+	if err := isaReturn.WriteCSV(&csvOut); err != nil {
+		t.Fatalf("unexpected error writing CSV: %v", err)
+	}
+
+	expected := "tax_year,account_id,account_type,ni_number,subscriptions,market_value\n" +
+		"2024-25,5d2b6f38-56a3-4c8e-8f2b-6c1d0e9a7b41,isa,AB123456A,1500.00,1623.45\n" +
+		"2024-25,TOTAL,,1,1500.00,1623.45\n"
+
+	if csvOut.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, csvOut.String())
+	}
+}
+
+func TestISAReturnReportsSubscriptionsAndMarketValue(t *testing.T) {
+	conn, closeDown := NewTestDatabase()
+	defer closeDown()
+
+	var repo account.Repository = database.NewAccountRepository(conn)
+
+	passingNiValidator := func(_ context.Context, _ string) error {
+		return nil
+	}
+
+	equities := NewTestFund()
+	bonds := NewTestFund()
+	catalogue := NewTestCatalogue(equities, bonds)
+
+	// Units are bought at £1 so the funds are valued at the amount invested
+	NewTestPrice(conn, equities.Id, 100)
+	prices := NewTestPrice(conn, bonds.Id, 100)
+
+	isa := account.NewISAService(&repo, &catalogue, NewTestRules(1000, 0), account.ACCOUNT_TYPE_ISA, false, account.StartOfTaxYear{1, 1}, passingNiValidator)
+
+	ctx := context.Background()
+
+	customer := account.Customer{
+		Id:           uuid.New(),
+		TaxResidency: "uk",
+		NINumber:     "ab 12 34 56 a",
+		DateOfBirth:  time.Now().AddDate(-20, 0, 0),
+	}
+
+	newAccount, err := isa.CreateAccount(ctx, customer)
+
+	if err != nil {
+		t.Fatalf("unexpected error when creating ISA account: %v", err)
+	}
+
+	err = isa.Invest(ctx, newAccount.Id, []account.Investment{
+		{FundId: equities.Id, TradeId: uuid.New(), TransactionType: account.TRANSACTION_TYPE_CUSTOMER, Amount: 400},
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error when investing in fund: %v", err)
+	}
+
+	// Switches change the market value split but not the subscriptions
+	err = isa.Switch(ctx, newAccount.Id, equities.Id, bonds.Id, 100)
+
+	if err != nil {
+		t.Fatalf("unexpected error when switching funds: %v", err)
+	}
+
+	// Use the calendar year so the transactions made now fall in the return
+	service := account.NewISAReturnService(&repo, account.NewStubCustomerClient(customer), NewTestRules(1000, 0), prices, account.StartOfTaxYear{1, 1})
+
+	isaReturn, errs, err := service.Return(ctx, time.Now().UTC().Year())
+
+	if err != nil && !errors.Is(err, account.ErrISAReturnInvalid) {
+		t.Fatalf("unexpected error building return: %v", err)
+	}
+
+	for _, e := range errs {
+		if e.AccountId == newAccount.Id {
+			t.Errorf("unexpected validation error: %v", e)
+		}
+	}
+
+	var found bool
+
+	for _, record := range isaReturn.Records {
+		if record.AccountId != newAccount.Id {
+			continue
+		}
+
+		found = true
+
+		if record.AccountType != account.ACCOUNT_TYPE_ISA || record.NINumber != "AB123456A" || record.Subscriptions != 400 || record.MarketValue != 400 {
+			t.Errorf("Expected an ISA for AB123456A with subscriptions and market value of 400, got %+v", record)
+		}
+	}
+
+	if !found {
+		t.Errorf("Expected account %s to be in the return", newAccount.Id)
+	}
+}
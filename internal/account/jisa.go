@@ -0,0 +1,162 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/internal/fund"
+)
+
+// Age at which a Junior ISA becomes an adult ISA
+const JISA_ADULT_AGE = 18
+
+func init() {
+	RegisterService(ServiceDefinition{
+		Name: "jisa",
+		// Junior ISAs are the only accounts with a guardian
+		AccountType: ACCOUNT_TYPE_JISA,
+		New: func(product ProductRules, rules *RulesService, deps ServiceDependencies) Service {
+			return NewJISAService(deps.Repository, deps.Catalogue, rules, deps.StartOfTaxYear)
+		},
+		Validate: func(a *Account) {
+			if !a.GuardianId.Valid || a.GuardianId.UUID == (uuid.UUID{}) {
+				a.Errors["guardian_id"] = "Guardian ID missing"
+			} else if a.GuardianId.UUID == a.CustomerId {
+				a.Errors["guardian_id"] = "The guardian cannot be the account holder"
+			}
+		},
+	})
+}
+
+// Service to manage Junior ISA accounts
+//
+// JISAs follow the same rules as an ISA with the following differences
+// - The account is held by a child and managed by their guardian.
+// - The subscription limits are separate from the adult ISA allowance.
+// - Nothing can be withdrawn until the holder turns 18, when the account becomes an ISA (see JISAConversionService).
+type JISAService struct {
+	*ISAService
+}
+
+func NewJISAService(repository *Repository, catalogue *fund.Catalogue, rules *RulesService, startOfTaxYear StartOfTaxYear) *JISAService {
+	return &JISAService{
+		// Children don't need an NI number to hold a JISA
+		ISAService: NewISAService(repository, catalogue, rules, ACCOUNT_TYPE_JISA, false, startOfTaxYear, nil),
+	}
+}
+
+// JISAs are opened by a guardian, see CreateGuardedAccount
+func (s *JISAService) CreateAccount(ctx context.Context, customer Customer) (Account, error) {
+	return Account{}, ErrAccountCreatePermission{"A Junior ISA must be opened by a guardian"}
+}
+
+// Open a JISA for the child, managed by the guardian
+//
+// The child must be eligible for a JISA. Returns ErrAccountInvalid if the
+// guardian is missing or is the child, errors are stored on the account.
+func (s *JISAService) CreateGuardedAccount(ctx context.Context, child Customer, guardianId uuid.UUID) (Account, error) {
+	eligibility, err := s.Eligibility(ctx, child)
+
+	if err != nil {
+		return Account{}, err
+	}
+
+	if err := eligibility.Err(); err != nil {
+		return Account{}, err
+	}
+
+	account := Account{
+		AccountType: ACCOUNT_TYPE_JISA,
+		CustomerId:  child.Id,
+		GuardianId:  uuid.NullUUID{UUID: guardianId, Valid: true},
+	}
+
+	return createAccount(ctx, s.repository, s.rules, account)
+}
+
+func (s *JISAService) QuoteWithdrawal(ctx context.Context, accountId uuid.UUID, request WithdrawalRequest) (Withdrawal, error) {
+	return Withdrawal{}, ErrWithdrawalNotAllowed
+}
+
+func (s *JISAService) Withdraw(ctx context.Context, accountId uuid.UUID, request WithdrawalRequest) (Withdrawal, error) {
+	return Withdrawal{}, ErrWithdrawalNotAllowed
+}
+
+// Summary of a single run of the JISA conversion job
+type JISAConversionResult struct {
+	Converted int
+	Failed    map[uuid.UUID]error
+}
+
+// Service to turn Junior ISAs into adult ISAs on the holder's 18th birthday
+//
+// The account type is changed in place so the funds and transaction history
+// stay with the account. The guardian no longer manages the account and
+// subscriptions from then on count towards the holder's adult ISA allowance.
+type JISAConversionService struct {
+	repository Repository
+	customers  CustomerClient
+	notifier   Notifier
+}
+
+func NewJISAConversionService(repository *Repository, customers CustomerClient, notifier Notifier) *JISAConversionService {
+	return &JISAConversionService{
+		repository: *repository,
+		customers:  customers,
+		notifier:   notifier,
+	}
+}
+
+// Convert every JISA whose holder is 18 or over on the date into an ISA
+//
+// Accounts that have already been converted are skipped, so the job can be
+// safely re-run. The holder is notified once their account is converted, if
+// the notification fails the account stays converted and the failure is
+// reported.
+func (s *JISAConversionService) ConvertAdultAccounts(ctx context.Context, date time.Time) (JISAConversionResult, error) {
+	result := JISAConversionResult{Failed: make(map[uuid.UUID]error)}
+
+	accounts, err := s.repository.GetAccountsByType(ctx, ACCOUNT_TYPE_JISA, date)
+
+	if err != nil {
+		return result, fmt.Errorf("Unable to fetch accounts: %w", err)
+	}
+
+	for _, account := range accounts {
+		customer, err := s.customers.GetCustomer(ctx, account.CustomerId)
+
+		if err != nil {
+			result.Failed[account.Id] = fmt.Errorf("Unable to fetch customer: %w", err)
+			continue
+		}
+
+		if customer.DateOfBirth.After(date.AddDate(-JISA_ADULT_AGE, 0, 0)) {
+			continue
+		}
+
+		err = s.repository.ConvertAccount(ctx, account.Id, ACCOUNT_TYPE_JISA, ACCOUNT_TYPE_ISA, date)
+
+		// Converted since the accounts were fetched
+		if errors.Is(err, ErrAccountNotFound) {
+			continue
+		}
+
+		if err != nil {
+			result.Failed[account.Id] = err
+			continue
+		}
+
+		result.Converted++
+
+		err = s.notifier.Notify(ctx, account.CustomerId, "Your Junior ISA is now a Stocks and Shares ISA. You now manage the account and can pay in up to your adult ISA allowance.")
+
+		if err != nil {
+			result.Failed[account.Id] = fmt.Errorf("Unable to notify customer: %w", err)
+		}
+	}
+
+	return result, nil
+}
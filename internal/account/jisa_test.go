@@ -0,0 +1,220 @@
+package account_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/database"
+	"github.com/jameswhoughton/cushon/internal/account"
+)
+
+func TestJISAsAreControlledByTheGuardian(t *testing.T) {
+	childId := uuid.New()
+	guardianId := uuid.New()
+
+	jisaAccount := account.Account{Id: uuid.New(), CustomerId: childId, GuardianId: uuid.NullUUID{UUID: guardianId, Valid: true}, AccountType: account.ACCOUNT_TYPE_JISA}
+	// Once converted the holder takes control
+	convertedAccount := account.Account{Id: uuid.New(), CustomerId: childId, AccountType: account.ACCOUNT_TYPE_ISA}
+
+	var repo account.Repository = memoryAccountRepository{accounts: map[uuid.UUID]account.Account{
+		jisaAccount.Id:      jisaAccount,
+		convertedAccount.Id: convertedAccount,
+	}}
+
+	catalogue := NewTestCatalogue()
+	isa := account.NewISAService(&repo, &catalogue, NewTestRules(0, 0), account.ACCOUNT_TYPE_ISA, false, account.StartOfTaxYear{1, 1}, nil)
+	jisa := account.NewJISAService(&repo, &catalogue, NewTestRules(0, 0), account.StartOfTaxYear{1, 1})
+	serviceFactory := NewTestServiceFactory(&repo, isa, jisa)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/account/{id}/withdraw/quote", account.GetWithdrawalQuoteHandler(*serviceFactory))
+
+	testCases := []struct {
+		name           string
+		accountId      uuid.UUID
+		customerId     uuid.UUID
+		expectedStatus int
+	}{
+		{
+			name:       "guardian controls the JISA",
+			accountId:  jisaAccount.Id,
+			customerId: guardianId,
+			// The account was found, the quote is missing its fund id
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "child does not control the JISA",
+			accountId:      jisaAccount.Id,
+			customerId:     childId,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "holder controls the converted ISA",
+			accountId:      convertedAccount.Id,
+			customerId:     childId,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "guardian does not control the converted ISA",
+			accountId:      convertedAccount.Id,
+			customerId:     guardianId,
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/account/"+test.accountId.String()+"/withdraw/quote", nil)
+			r.Header.Set(account.SESSION_CUSTOMER_HEADER, test.customerId.String())
+
+			w := httptest.NewRecorder()
+
+			mux.ServeHTTP(w, r)
+
+			if w.Code != test.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", test.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestJISAsAreOpenedByAGuardian(t *testing.T) {
+	var repo account.Repository
+
+	catalogue := NewTestCatalogue()
+	jisa := account.NewJISAService(&repo, &catalogue, NewTestRules(0, 0), account.StartOfTaxYear{1, 1})
+
+	_, err := jisa.CreateAccount(context.Background(), account.Customer{Id: uuid.New(), TaxResidency: "uk", DateOfBirth: time.Now().AddDate(-10, 0, 0)})
+
+	if !errors.As(err, &account.ErrAccountCreatePermission{}) {
+		t.Errorf("Expected ErrAccountCreatePermission, got %v", err)
+	}
+}
+
+func TestJISAWithdrawalsAreNotAllowed(t *testing.T) {
+	var repo account.Repository
+
+	catalogue := NewTestCatalogue()
+	jisa := account.NewJISAService(&repo, &catalogue, NewTestRules(0, 0), account.StartOfTaxYear{1, 1})
+
+	request := account.WithdrawalRequest{FundId: uuid.New(), Amount: 100}
+
+	if _, err := jisa.QuoteWithdrawal(context.Background(), uuid.New(), request); !errors.Is(err, account.ErrWithdrawalNotAllowed) {
+		t.Errorf("Expected error %v, got %v", account.ErrWithdrawalNotAllowed, err)
+	}
+
+	if _, err := jisa.Withdraw(context.Background(), uuid.New(), request); !errors.Is(err, account.ErrWithdrawalNotAllowed) {
+		t.Errorf("Expected error %v, got %v", account.ErrWithdrawalNotAllowed, err)
+	}
+}
+
+func TestJISAsAreConvertedToISAsAt18(t *testing.T) {
+	conn, closeDown := NewTestDatabase()
+	defer closeDown()
+
+	var repo account.Repository = database.NewAccountRepository(conn)
+
+	testFund := NewTestFund()
+	testFund.AccountTypes = []string{account.ACCOUNT_TYPE_ISA, account.ACCOUNT_TYPE_JISA}
+	catalogue := NewTestCatalogue(testFund)
+
+	jisa := account.NewJISAService(&repo, &catalogue, NewTestRules(1000, 0), account.StartOfTaxYear{1, 1})
+
+	ctx := context.Background()
+	now := time.Now()
+
+	// Turns 18 tomorrow
+	child := account.Customer{
+		Id:           uuid.New(),
+		TaxResidency: "uk",
+		DateOfBirth:  now.AddDate(-account.JISA_ADULT_AGE, 0, 1),
+	}
+
+	guardianId := uuid.New()
+
+	newAccount, err := jisa.CreateGuardedAccount(ctx, child, guardianId)
+
+	if err != nil {
+		t.Fatalf("unexpected error when creating JISA account: %v", err)
+	}
+
+	err = jisa.Invest(ctx, newAccount.Id, []account.Investment{{FundId: testFund.Id, TradeId: uuid.New(), TransactionType: account.TRANSACTION_TYPE_CUSTOMER, Amount: 100}})
+
+	if err != nil {
+		t.Fatalf("unexpected error investing: %v", err)
+	}
+
+	notifier := testNotifier{}
+	service := account.NewJISAConversionService(&repo, account.NewStubCustomerClient(child), notifier)
+
+	result, err := service.ConvertAdultAccounts(ctx, now.Add(time.Hour))
+
+	if err != nil || len(result.Failed) > 0 {
+		t.Errorf("unexpected error converting accounts: %v %v", err, result.Failed)
+	}
+
+	if result.Converted != 0 {
+		t.Errorf("Expected no accounts to be converted before the 18th birthday, got %d", result.Converted)
+	}
+
+	birthday := now.AddDate(0, 0, 1).Add(time.Hour)
+
+	result, err = service.ConvertAdultAccounts(ctx, birthday)
+
+	if err != nil || len(result.Failed) > 0 {
+		t.Errorf("unexpected error converting accounts: %v %v", err, result.Failed)
+	}
+
+	if result.Converted != 1 {
+		t.Errorf("Expected 1 account to be converted, got %d", result.Converted)
+	}
+
+	converted, err := repo.GetAccount(ctx, newAccount.Id)
+
+	if err != nil {
+		t.Fatalf("unexpected error fetching account: %v", err)
+	}
+
+	if converted.AccountType != account.ACCOUNT_TYPE_ISA || converted.GuardianId.Valid {
+		t.Errorf("Expected an ISA without a guardian, got %+v", converted)
+	}
+
+	if len(notifier[child.Id]) != 1 {
+		t.Errorf("Expected 1 notification, got %d", len(notifier[child.Id]))
+	}
+
+	accountFunds, err := repo.GetAccountFunds(ctx, newAccount.Id)
+
+	if err != nil {
+		t.Errorf("unexpected error fetching account funds: %v", err)
+	}
+
+	if len(accountFunds) != 1 || accountFunds[0].Balance != 100 {
+		t.Errorf("Expected the holdings to stay with the account, got %v", accountFunds)
+	}
+
+	// Subscriptions made into the JISA don't use up the adult allowance
+	startOfYear, _ := account.StartOfTaxYear{1, 1}.Bounds(account.StartOfTaxYear{1, 1}.YearOf(now))
+
+	subscriptions, err := repo.GetCustomerSubscriptions(ctx, child.Id, startOfYear)
+
+	if err != nil {
+		t.Errorf("unexpected error fetching subscriptions: %v", err)
+	}
+
+	if len(subscriptions) != 1 || subscriptions[0].AccountType != account.ACCOUNT_TYPE_ISA || subscriptions[0].Subscribed != 0 {
+		t.Errorf("Expected no subscriptions to the ISA, got %+v", subscriptions)
+	}
+
+	// Running again doesn't convert or notify twice
+	result, err = service.ConvertAdultAccounts(ctx, birthday)
+
+	if err != nil || result.Converted != 0 || len(notifier[child.Id]) != 1 {
+		t.Errorf("Expected nothing to be converted on re-run, got %+v %v", result, err)
+	}
+}
@@ -0,0 +1,68 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var ErrLimitsNotFound = errors.New("No subscription limits for account type")
+
+// Subscription limits in pence
+//
+// The limits apply from the tax year starting in FromTaxYear until the tax
+// year of the next limits for the account type.
+type SubscriptionLimits struct {
+	FromTaxYear int `json:"from_tax_year"`
+	// Total subscriptions across every ISA the customer holds (the ISA allowance)
+	OverallLimit int `json:"overall_limit"`
+	// Total subscriptions to accounts of this type, zero for no separate limit
+	AnnualLimit int `json:"annual_limit"`
+}
+
+// Source of the subscription limits for each account type and tax year
+//
+// Limits change over time, so past tax years (e.g. for returns and
+// statements) must be checked against the limits that applied back then.
+type LimitRegistry interface {
+	// Return the limits for the account type in the tax year starting in the year
+	//
+	// Returns ErrLimitsNotFound if the account type has no limits that apply
+	// as far back as the tax year.
+	Limits(ctx context.Context, accountType string, taxYear int) (SubscriptionLimits, error)
+}
+
+// LimitRegistry for the limits declared in the rules
+type ConfigLimits map[string][]SubscriptionLimits
+
+// The limits for each product must be in tax year order, as checked by
+// Rules.Validate
+func NewConfigLimits(rules Rules) ConfigLimits {
+	limits := make(ConfigLimits, len(rules.Products))
+
+	for _, product := range rules.Products {
+		limits[product.AccountType] = product.Limits
+	}
+
+	return limits
+}
+
+func (c ConfigLimits) Limits(_ context.Context, accountType string, taxYear int) (SubscriptionLimits, error) {
+	limits := c[accountType]
+
+	// The last limits to have started apply
+	i := slices.IndexFunc(limits, func(l SubscriptionLimits) bool {
+		return l.FromTaxYear > taxYear
+	})
+
+	if i == -1 {
+		i = len(limits)
+	}
+
+	if i == 0 {
+		return SubscriptionLimits{}, fmt.Errorf("%w '%s' in %s", ErrLimitsNotFound, accountType, TaxYearLabel(taxYear))
+	}
+
+	return limits[i-1], nil
+}
@@ -0,0 +1,113 @@
+package account_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jameswhoughton/cushon/internal/account"
+)
+
+func TestConfigLimitsForTaxYear(t *testing.T) {
+	limits := account.NewConfigLimits(account.Rules{
+		Version: "test",
+		Products: []account.ProductRules{
+			{
+				AccountType: "jisa",
+				Name:        "Junior ISA",
+				Limits: []account.SubscriptionLimits{
+					{FromTaxYear: 2017, AnnualLimit: 412800},
+					{FromTaxYear: 2018, AnnualLimit: 426000},
+					{FromTaxYear: 2020, AnnualLimit: 900000},
+				},
+			},
+			{AccountType: "gia", Name: "General Investment Account"},
+		},
+	})
+
+	ctx := context.Background()
+
+	type testCase struct {
+		taxYear  int
+		expected int
+	}
+
+	for _, testCase := range []testCase{{2017, 412800}, {2019, 426000}, {2020, 900000}, {2030, 900000}} {
+		l, err := limits.Limits(ctx, "jisa", testCase.taxYear)
+
+		if err != nil {
+			t.Fatalf("unexpected error for %d: %v", testCase.taxYear, err)
+		}
+
+		if l.AnnualLimit != testCase.expected {
+			t.Errorf("Expected the limit for %d to be %d, got %d", testCase.taxYear, testCase.expected, l.AnnualLimit)
+		}
+	}
+
+	if _, err := limits.Limits(ctx, "jisa", 2016); !errors.Is(err, account.ErrLimitsNotFound) {
+		t.Errorf("Expected %v before the first limits, got %v", account.ErrLimitsNotFound, err)
+	}
+
+	if _, err := limits.Limits(ctx, "gia", 2020); !errors.Is(err, account.ErrLimitsNotFound) {
+		t.Errorf("Expected %v for an account type without limits, got %v", account.ErrLimitsNotFound, err)
+	}
+
+	if _, err := limits.Limits(ctx, "isa", 2020); !errors.Is(err, account.ErrLimitsNotFound) {
+		t.Errorf("Expected %v for an account type without rules, got %v", account.ErrLimitsNotFound, err)
+	}
+}
+
+func TestDefaultRulesHistoricISALimits(t *testing.T) {
+	service, err := account.NewRulesService(account.DefaultRules())
+
+	if err != nil {
+		t.Fatalf("unexpected error creating the rules service: %v", err)
+	}
+
+	type testCase struct {
+		taxYear  int
+		expected int
+	}
+
+	for _, testCase := range []testCase{{2016, 1524000}, {2024, 2000000}} {
+		limits, err := service.Limits(context.Background(), account.ACCOUNT_TYPE_ISA, testCase.taxYear)
+
+		if err != nil {
+			t.Fatalf("unexpected error for %d: %v", testCase.taxYear, err)
+		}
+
+		if limits.OverallLimit != testCase.expected {
+			t.Errorf("Expected the ISA allowance in %s to be %d, got %d", account.TaxYearLabel(testCase.taxYear), testCase.expected, limits.OverallLimit)
+		}
+	}
+}
+
+func TestDefaultRulesHistoricJISALimits(t *testing.T) {
+	service, err := account.NewRulesService(account.DefaultRules())
+
+	if err != nil {
+		t.Fatalf("unexpected error creating the rules service: %v", err)
+	}
+
+	type testCase struct {
+		taxYear  int
+		expected int
+	}
+
+	for _, testCase := range []testCase{{2011, 360000}, {2013, 372000}, {2017, 412800}, {2019, 436800}, {2024, 900000}} {
+		limits, err := service.Limits(context.Background(), account.ACCOUNT_TYPE_JISA, testCase.taxYear)
+
+		if err != nil {
+			t.Fatalf("unexpected error for %d: %v", testCase.taxYear, err)
+		}
+
+		if limits.AnnualLimit != testCase.expected {
+			t.Errorf("Expected the JISA limit in %s to be %d, got %d", account.TaxYearLabel(testCase.taxYear), testCase.expected, limits.AnnualLimit)
+		}
+	}
+
+	// Junior ISAs were introduced in the 2011/12 tax year
+	if _, err := service.Limits(context.Background(), account.ACCOUNT_TYPE_JISA, 2010); !errors.Is(err, account.ErrLimitsNotFound) {
+		t.Errorf("Expected %v before Junior ISAs were introduced, got %v", account.ErrLimitsNotFound, err)
+	}
+}
@@ -0,0 +1,134 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/internal/fund"
+)
+
+const (
+	LISA_WITHDRAWAL_REASON_FIRST_HOME       string = "first-home"
+	LISA_WITHDRAWAL_REASON_AGE_60           string = "age-60"
+	LISA_WITHDRAWAL_REASON_TERMINAL_ILLNESS string = "terminal-illness"
+	LISA_WITHDRAWAL_REASON_OTHER            string = "other"
+)
+
+var lisaWithdrawalReasons = []string{
+	LISA_WITHDRAWAL_REASON_FIRST_HOME,
+	LISA_WITHDRAWAL_REASON_AGE_60,
+	LISA_WITHDRAWAL_REASON_TERMINAL_ILLNESS,
+	LISA_WITHDRAWAL_REASON_OTHER,
+}
+
+var ErrLISAWithdrawalUnder60 = errors.New("Customer must be 60 or over to withdraw for this reason")
+
+func init() {
+	RegisterService(ServiceDefinition{
+		Name: "lisa",
+		// Bonus claims and withdrawal charges are specific to LISAs
+		AccountType: ACCOUNT_TYPE_LISA,
+		New: func(product ProductRules, rules *RulesService, deps ServiceDependencies) Service {
+			return NewLISAService(deps.Repository, deps.Catalogue, rules, deps.StartOfTaxYear, deps.NIValidator, deps.Customers)
+		},
+	})
+}
+
+// Percentage of an unauthorised LISA withdrawal kept as a government charge
+const LISA_WITHDRAWAL_PENALTY_PERCENT = 25
+
+// Return the government charge due on a LISA withdrawal
+//
+// Withdrawals for a first home, after the age of 60 or due to terminal
+// illness are authorised and carry no charge. Otherwise the charge is 25% of
+// the amount withdrawn, rounded to the nearest penny.
+func LISAWithdrawalPenalty(amount int, reason string) int {
+	switch reason {
+	case LISA_WITHDRAWAL_REASON_FIRST_HOME, LISA_WITHDRAWAL_REASON_AGE_60, LISA_WITHDRAWAL_REASON_TERMINAL_ILLNESS:
+		return 0
+	}
+
+	if amount <= 0 {
+		return 0
+	}
+
+	return (amount*LISA_WITHDRAWAL_PENALTY_PERCENT + 50) / 100
+}
+
+// Service to manage Lifetime ISA accounts
+//
+// LISAs must adhere to the following rules
+// - Who can open a LISA and the limits each tax year are declared in the rules (see RulesService).
+// - The account holder is limited by how much they can deposit each tax year.
+// - Deposits also count towards the overall limit shared with any other ISAs they hold.
+// - Only one LISA can be paid into each tax year.
+// - Withdrawals must give a reason, unauthorised withdrawals carry a 25% government charge.
+//
+// Everything except withdrawals behaves as a (non-flexible) ISA.
+type LISAService struct {
+	*ISAService
+	customers CustomerClient
+}
+
+func NewLISAService(repository *Repository, catalogue *fund.Catalogue, rules *RulesService, startOfTaxYear StartOfTaxYear, niValidator func(context.Context, string) error, customers CustomerClient) *LISAService {
+	return &LISAService{
+		ISAService: NewISAService(repository, catalogue, rules, ACCOUNT_TYPE_LISA, false, startOfTaxYear, niValidator),
+		customers:  customers,
+	}
+}
+
+// Quote a LISA withdrawal, including any government charge
+//
+// Returns ErrWithdrawalReasonInvalid if the reason isn't recognised and
+// ErrLISAWithdrawalUnder60 if the customer claims to be 60 or over but isn't.
+func (s *LISAService) QuoteWithdrawal(ctx context.Context, accountId uuid.UUID, request WithdrawalRequest) (Withdrawal, error) {
+	if !slices.Contains(lisaWithdrawalReasons, request.Reason) {
+		return Withdrawal{}, ErrWithdrawalReasonInvalid
+	}
+
+	if request.Reason == LISA_WITHDRAWAL_REASON_AGE_60 {
+		account, err := s.repository.GetAccount(ctx, accountId)
+
+		if err != nil {
+			return Withdrawal{}, err
+		}
+
+		customer, err := s.customers.GetCustomer(ctx, account.CustomerId)
+
+		if err != nil {
+			return Withdrawal{}, fmt.Errorf("Unable to fetch customer: %w", err)
+		}
+
+		if customer.DateOfBirth.After(time.Now().AddDate(-60, 0, 0)) {
+			return Withdrawal{}, ErrLISAWithdrawalUnder60
+		}
+	}
+
+	return newWithdrawal(ctx, s.repository, accountId, request, LISAWithdrawalPenalty(request.Amount, request.Reason))
+}
+
+// Withdraw from a LISA, the government charge is recorded as a separate
+// penalty transaction and deducted from the payout.
+func (s *LISAService) Withdraw(ctx context.Context, accountId uuid.UUID, request WithdrawalRequest) (Withdrawal, error) {
+	withdrawal, err := s.QuoteWithdrawal(ctx, accountId, request)
+
+	if err != nil {
+		return Withdrawal{}, err
+	}
+
+	err = s.repository.Withdraw(ctx, withdrawal)
+
+	if errors.Is(err, ErrInsufficientBalance) {
+		return Withdrawal{}, err
+	}
+
+	if err != nil {
+		return Withdrawal{}, fmt.Errorf("Unable to complete withdrawal: %w", err)
+	}
+
+	return withdrawal, nil
+}
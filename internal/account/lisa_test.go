@@ -0,0 +1,155 @@
+package account_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/internal/account"
+)
+
+func TestLISAWithdrawalPenalty(t *testing.T) {
+	testCases := []struct {
+		name     string
+		amount   int
+		reason   string
+		expected int
+	}{
+		{
+			name:     "First home purchase is authorised",
+			amount:   10000,
+			reason:   account.LISA_WITHDRAWAL_REASON_FIRST_HOME,
+			expected: 0,
+		},
+		{
+			name:     "Age 60 or over is authorised",
+			amount:   10000,
+			reason:   account.LISA_WITHDRAWAL_REASON_AGE_60,
+			expected: 0,
+		},
+		{
+			name:     "Terminal illness is authorised",
+			amount:   10000,
+			reason:   account.LISA_WITHDRAWAL_REASON_TERMINAL_ILLNESS,
+			expected: 0,
+		},
+		{
+			name:     "Other withdrawals carry a 25% charge",
+			amount:   10000,
+			reason:   account.LISA_WITHDRAWAL_REASON_OTHER,
+			expected: 2500,
+		},
+		{
+			name:     "Charge is rounded to the nearest penny",
+			amount:   10002,
+			reason:   account.LISA_WITHDRAWAL_REASON_OTHER,
+			expected: 2501,
+		},
+		{
+			name:     "Charge is rounded down below half a penny",
+			amount:   10001,
+			reason:   account.LISA_WITHDRAWAL_REASON_OTHER,
+			expected: 2500,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			penalty := account.LISAWithdrawalPenalty(tc.amount, tc.reason)
+
+			if penalty != tc.expected {
+				t.Errorf("Expected penalty of %d, got %d", tc.expected, penalty)
+			}
+		})
+	}
+}
+
+func TestLISAWithdrawalQuotes(t *testing.T) {
+	fundId := uuid.New()
+
+	over60 := account.Customer{Id: uuid.New(), DateOfBirth: time.Now().AddDate(-61, 0, 0)}
+	under60 := account.Customer{Id: uuid.New(), DateOfBirth: time.Now().AddDate(-59, 0, 0)}
+
+	over60Account := account.Account{Id: uuid.New(), CustomerId: over60.Id, AccountType: account.ACCOUNT_TYPE_LISA}
+	under60Account := account.Account{Id: uuid.New(), CustomerId: under60.Id, AccountType: account.ACCOUNT_TYPE_LISA}
+
+	accounts := map[uuid.UUID]account.Account{over60Account.Id: over60Account, under60Account.Id: under60Account}
+	funds := map[uuid.UUID][]account.AccountFund{
+		over60Account.Id:  {{Id: 1, AccountId: over60Account.Id, FundId: fundId, Balance: 10000}},
+		under60Account.Id: {{Id: 2, AccountId: under60Account.Id, FundId: fundId, Balance: 10000}},
+	}
+
+	var repo account.Repository = memoryAccountRepository{accounts: accounts, funds: funds}
+
+	catalogue := NewTestCatalogue()
+	lisa := account.NewLISAService(&repo, &catalogue, NewTestRules(0, 0), account.StartOfTaxYear{1, 1}, nil, account.NewStubCustomerClient(over60, under60))
+
+	testCases := []struct {
+		name            string
+		accountId       uuid.UUID
+		reason          string
+		expectedPenalty int
+		expectedError   error
+	}{
+		{
+			name:            "Over 60s withdraw without a charge",
+			accountId:       over60Account.Id,
+			reason:          account.LISA_WITHDRAWAL_REASON_AGE_60,
+			expectedPenalty: 0,
+		},
+		{
+			name:          "Under 60s can't claim the age exemption",
+			accountId:     under60Account.Id,
+			reason:        account.LISA_WITHDRAWAL_REASON_AGE_60,
+			expectedError: account.ErrLISAWithdrawalUnder60,
+		},
+		{
+			name:            "First home purchases are exempt at any age",
+			accountId:       under60Account.Id,
+			reason:          account.LISA_WITHDRAWAL_REASON_FIRST_HOME,
+			expectedPenalty: 0,
+		},
+		{
+			name:            "Other withdrawals carry a 25% charge",
+			accountId:       under60Account.Id,
+			reason:          account.LISA_WITHDRAWAL_REASON_OTHER,
+			expectedPenalty: 1000,
+		},
+		{
+			name:            "Over 60s pay the charge if they don't claim the exemption",
+			accountId:       over60Account.Id,
+			reason:          account.LISA_WITHDRAWAL_REASON_OTHER,
+			expectedPenalty: 1000,
+		},
+		{
+			name:          "A reason must be given",
+			accountId:     over60Account.Id,
+			reason:        "",
+			expectedError: account.ErrWithdrawalReasonInvalid,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			quote, err := lisa.QuoteWithdrawal(context.Background(), tc.accountId, account.WithdrawalRequest{FundId: fundId, Amount: 4000, Reason: tc.reason})
+
+			if tc.expectedError != nil {
+				if !errors.Is(err, tc.expectedError) {
+					t.Errorf("Expected error %v, got %v", tc.expectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if quote.Penalty != tc.expectedPenalty || quote.Payout != 4000-tc.expectedPenalty {
+				t.Errorf("Expected penalty %d and payout %d, got %d and %d", tc.expectedPenalty, 4000-tc.expectedPenalty, quote.Penalty, quote.Payout)
+			}
+		})
+	}
+}
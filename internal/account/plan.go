@@ -0,0 +1,380 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// The plan will be executed on each due date
+	PLAN_STATUS_ACTIVE string = "active"
+	// The plan was stopped because a payment could not be made (e.g. it would exceed the allowance)
+	PLAN_STATUS_STOPPED string = "stopped"
+	// The plan has passed its end date
+	PLAN_STATUS_ENDED string = "ended"
+)
+
+var ErrPlanInvalid = errors.New("Plan invalid")
+var ErrPlanNotFound = errors.New("Plan not found")
+
+// Recurring monthly investment into one or more funds
+//
+// A DayOfMonth after the end of a shorter month (e.g. the 31st) is
+// executed on the last day of that month. A zero EndDate means the plan
+// runs until it is stopped. LastRunOn is the last due date paid.
+type Plan struct {
+	Id          uuid.UUID         `json:"id"`
+	AccountId   uuid.UUID         `json:"account_id"`
+	Amount      int               `json:"amount"`
+	Allocations []Allocation      `json:"allocations"`
+	DayOfMonth  int               `json:"day_of_month"`
+	StartDate   time.Time         `json:"start_date"`
+	EndDate     time.Time         `json:"end_date"`
+	Status      string            `json:"status"`
+	LastRunOn   time.Time         `json:"last_run_on"`
+	Errors      map[string]string `json:"errors"`
+}
+
+// Validate a Plan entity
+//
+// Any errors are stored in a map using the json struct tag
+// so that they can be returned straight back to the UI.
+func (p *Plan) Validate() bool {
+	if p.Errors == nil {
+		p.Errors = make(map[string]string, 4)
+	}
+
+	if p.AccountId == (uuid.UUID{}) {
+		p.Errors["account_id"] = "Account ID missing"
+	}
+
+	// The amount and allocations follow the same rules as a one-off investment
+	request := AllocationRequest{Amount: p.Amount, Allocations: p.Allocations}
+
+	if !request.Validate() {
+		for field, message := range request.Errors {
+			p.Errors[field] = message
+		}
+	}
+
+	if p.DayOfMonth < 1 || p.DayOfMonth > 31 {
+		p.Errors["day_of_month"] = "Day of month must be between 1 and 31"
+	}
+
+	if p.StartDate.IsZero() {
+		p.Errors["start_date"] = "Start date missing"
+	}
+
+	if !p.EndDate.IsZero() && !p.EndDate.After(p.StartDate) {
+		p.Errors["end_date"] = "End date must come after the start date"
+	}
+
+	return len(p.Errors) == 0
+}
+
+// Returns true if a payment should be made on the given date
+func (p Plan) DueOn(date time.Time) bool {
+	return slices.Contains(p.DueDates(date), truncateToDay(date))
+}
+
+// Returns every due date up to and including the given date that hasn't
+// been paid, oldest first
+//
+// A plan that hasn't run since before its last due date (e.g. the job
+// didn't run) has a payment for each due date missed.
+func (p Plan) DueDates(date time.Time) []time.Time {
+	date = truncateToDay(date)
+
+	if p.Status != PLAN_STATUS_ACTIVE {
+		return []time.Time{}
+	}
+
+	from := truncateToDay(p.StartDate)
+
+	if !p.LastRunOn.IsZero() && !truncateToDay(p.LastRunOn).Before(from) {
+		from = truncateToDay(p.LastRunOn).AddDate(0, 0, 1)
+	}
+
+	to := date
+
+	if !p.EndDate.IsZero() && truncateToDay(p.EndDate).Before(to) {
+		to = truncateToDay(p.EndDate)
+	}
+
+	dates := []time.Time{}
+
+	for month := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, date.Location()); !month.After(to); month = month.AddDate(0, 1, 0) {
+		lastDayOfMonth := time.Date(month.Year(), month.Month()+1, 0, 0, 0, 0, 0, date.Location()).Day()
+		due := time.Date(month.Year(), month.Month(), min(p.DayOfMonth, lastDayOfMonth), 0, 0, 0, 0, date.Location())
+
+		if !due.Before(from) && !due.After(to) {
+			dates = append(dates, due)
+		}
+	}
+
+	return dates
+}
+
+func truncateToDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// Responsible for storing investment plans, any params passed in are
+// assumed to be valid.
+//
+// Methods should be accessed through the PlanService
+type PlanRepository interface {
+	// Create a new plan
+	CreatePlan(ctx context.Context, plan *Plan) error
+
+	// Return the plan with the given id
+	//
+	// Returns ErrPlanNotFound if the plan does not exist.
+	GetPlan(ctx context.Context, planId uuid.UUID) (Plan, error)
+
+	// Return every plan for the account
+	GetAccountPlans(ctx context.Context, accountId uuid.UUID) ([]Plan, error)
+
+	// Return every active plan that has started on or before the date
+	GetActivePlans(ctx context.Context, date time.Time) ([]Plan, error)
+
+	// Update an existing plan, including its allocations
+	UpdatePlan(ctx context.Context, plan Plan) error
+
+	// Delete a plan
+	DeletePlan(ctx context.Context, planId uuid.UUID) error
+}
+
+// Sends notifications to customers
+//
+// This would be implemented by a client for an existing notification
+// service (email/post).
+type Notifier interface {
+	Notify(ctx context.Context, customerId uuid.UUID, message string) error
+}
+
+// Summary of a single run of the plan scheduler
+type PlanRunResult struct {
+	Deposited int
+	Stopped   int
+	Ended     int
+	Failed    map[uuid.UUID]error
+}
+
+// Service to manage regular investment plans
+//
+// Payments are taken by direct debit through the DepositService so each one
+// is subject to the same checks (e.g. allowances) as a one-off investment.
+type PlanService struct {
+	repository     PlanRepository
+	serviceFactory *ServiceFactory
+	deposits       *DepositService
+	notifier       Notifier
+}
+
+func NewPlanService(repository *PlanRepository, serviceFactory *ServiceFactory, deposits *DepositService, notifier Notifier) *PlanService {
+	return &PlanService{
+		repository:     *repository,
+		serviceFactory: serviceFactory,
+		deposits:       deposits,
+		notifier:       notifier,
+	}
+}
+
+// Create a new active plan
+//
+// Returns ErrPlanInvalid if the plan is invalid, errors are stored on the plan.
+func (s *PlanService) CreatePlan(ctx context.Context, plan *Plan) error {
+	plan.Id = uuid.New()
+	plan.Status = PLAN_STATUS_ACTIVE
+	plan.LastRunOn = time.Time{}
+
+	if !plan.Validate() {
+		return ErrPlanInvalid
+	}
+
+	return s.repository.CreatePlan(ctx, plan)
+}
+
+func (s *PlanService) Plan(ctx context.Context, planId uuid.UUID) (Plan, error) {
+	return s.repository.GetPlan(ctx, planId)
+}
+
+func (s *PlanService) AccountPlans(ctx context.Context, accountId uuid.UUID) ([]Plan, error) {
+	return s.repository.GetAccountPlans(ctx, accountId)
+}
+
+// Update the amount, allocations and dates of an existing plan
+//
+// Updating a stopped plan restarts it from today, payments due while it was
+// stopped aren't made. Returns ErrPlanInvalid if the plan is invalid, errors
+// are stored on the plan.
+func (s *PlanService) UpdatePlan(ctx context.Context, plan *Plan) error {
+	existing, err := s.repository.GetPlan(ctx, plan.Id)
+
+	if err != nil {
+		return err
+	}
+
+	plan.AccountId = existing.AccountId
+	plan.LastRunOn = existing.LastRunOn
+	plan.Status = PLAN_STATUS_ACTIVE
+
+	if existing.Status != PLAN_STATUS_ACTIVE {
+		plan.LastRunOn = truncateToDay(time.Now()).AddDate(0, 0, -1)
+	}
+
+	if !plan.Validate() {
+		return ErrPlanInvalid
+	}
+
+	return s.repository.UpdatePlan(ctx, *plan)
+}
+
+func (s *PlanService) DeletePlan(ctx context.Context, planId uuid.UUID) error {
+	return s.repository.DeletePlan(ctx, planId)
+}
+
+// Make the payments for every plan due on or before the given date
+//
+// Each due date since a plan last ran is paid in turn, so a missed run is
+// caught up on the next one. Plans that have already run on the date are
+// skipped so the job can be safely re-run. If a payment is rejected (e.g.
+// it would exceed the allowance or a fund has closed) the plan is stopped
+// and the customer is notified. Any other failure is left to be retried on
+// the next run.
+func (s *PlanService) RunDuePlans(ctx context.Context, date time.Time) (PlanRunResult, error) {
+	result := PlanRunResult{Failed: make(map[uuid.UUID]error)}
+
+	plans, err := s.repository.GetActivePlans(ctx, date)
+
+	if err != nil {
+		return result, fmt.Errorf("Unable to fetch active plans: %w", err)
+	}
+
+	for _, plan := range plans {
+		err := s.runDuePayments(ctx, &plan, date, &result)
+
+		if IsAllowanceError(err) || errors.Is(err, ErrDepositInvalid) {
+			if err := s.stopPlan(ctx, plan, err); err != nil {
+				result.Failed[plan.Id] = err
+				continue
+			}
+
+			result.Stopped++
+			continue
+		}
+
+		if err != nil {
+			result.Failed[plan.Id] = err
+			continue
+		}
+
+		if !plan.EndDate.IsZero() && truncateToDay(date).After(truncateToDay(plan.EndDate)) {
+			plan.Status = PLAN_STATUS_ENDED
+
+			if err := s.repository.UpdatePlan(ctx, plan); err != nil {
+				result.Failed[plan.Id] = err
+				continue
+			}
+
+			result.Ended++
+		}
+	}
+
+	return result, nil
+}
+
+// Make a payment for each of the plan's due dates, oldest first
+//
+// Stops at the first payment that fails, the plan's LastRunOn is the last
+// due date paid so the failed payment is the first made on the next run.
+func (s *PlanService) runDuePayments(ctx context.Context, plan *Plan, date time.Time, result *PlanRunResult) error {
+	for _, dueOn := range plan.DueDates(date) {
+		err := s.runPlan(ctx, plan, dueOn)
+
+		if errors.Is(err, ErrDepositDuplicate) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		result.Deposited++
+	}
+
+	return nil
+}
+
+// Make the payment due on the date
+//
+// The deposit's reference is unique to the plan and due date, so if the plan
+// couldn't be updated after a payment was taken the next run only records
+// the payment rather than taking it again, returning ErrDepositDuplicate.
+// A payment that failed (e.g. the payments service was unavailable) isn't
+// recorded and is tried again on the next run.
+func (s *PlanService) runPlan(ctx context.Context, plan *Plan, dueOn time.Time) error {
+	request := DepositRequest{
+		AllocationRequest: AllocationRequest{Amount: plan.Amount, Allocations: plan.Allocations},
+		PaymentMethod:     PAYMENT_METHOD_DIRECT_DEBIT,
+	}
+
+	reference := "plan:" + plan.Id.String() + ":" + dueOn.Format(time.DateOnly)
+
+	_, err := s.deposits.createDeposit(ctx, plan.AccountId, &request, reference)
+
+	if errors.Is(err, ErrDepositDuplicate) {
+		existing, fetchErr := s.deposits.repository.GetDepositByReference(ctx, reference)
+
+		if fetchErr != nil {
+			return fmt.Errorf("Unable to fetch deposit '%s': %w", reference, fetchErr)
+		}
+
+		// The existing deposit may have failed since it was checked
+		if !slices.Contains([]string{DEPOSIT_STATUS_INITIATED, DEPOSIT_STATUS_AUTHORISED, DEPOSIT_STATUS_SETTLED}, existing.Status) {
+			return fmt.Errorf("Deposit '%s' has %s, the payment will be retried", reference, existing.Status)
+		}
+	}
+
+	if err != nil && !errors.Is(err, ErrDepositDuplicate) {
+		return err
+	}
+
+	plan.LastRunOn = dueOn
+
+	if updateErr := s.repository.UpdatePlan(ctx, *plan); updateErr != nil {
+		return updateErr
+	}
+
+	return err
+}
+
+func (s *PlanService) stopPlan(ctx context.Context, plan Plan, reason error) error {
+	plan.Status = PLAN_STATUS_STOPPED
+
+	err := s.repository.UpdatePlan(ctx, plan)
+
+	if err != nil {
+		return err
+	}
+
+	_, account, err := s.serviceFactory.ServiceForAccount(ctx, plan.AccountId)
+
+	if err != nil {
+		return err
+	}
+
+	message := "Your regular investment has been stopped as the payment could not be made: " + reason.Error()
+
+	if errors.Is(reason, ErrExceededISALimit) || errors.As(reason, &ErrExceededAccountTypeLimit{}) {
+		message = "Your regular investment has been stopped as the next payment would exceed your annual allowance."
+	}
+
+	return s.notifier.Notify(ctx, account.ControlledBy(), message)
+}
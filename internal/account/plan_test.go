@@ -0,0 +1,518 @@
+package account_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/database"
+	"github.com/jameswhoughton/cushon/internal/account"
+)
+
+// Notifier that records the notifications sent to each customer
+type testNotifier map[uuid.UUID][]string
+
+func (n testNotifier) Notify(_ context.Context, customerId uuid.UUID, message string) error {
+	n[customerId] = append(n[customerId], message)
+
+	return nil
+}
+
+// Payments client that can't initiate payments while the service is unavailable
+type unavailablePaymentsClient struct {
+	*account.StubPaymentsClient
+	unavailable bool
+}
+
+func (c *unavailablePaymentsClient) InitiatePayment(ctx context.Context, deposit account.Deposit) (string, error) {
+	if c.unavailable {
+		return "", errors.New("payments service unavailable")
+	}
+
+	return c.StubPaymentsClient.InitiatePayment(ctx, deposit)
+}
+
+func TestPlanValidation(t *testing.T) {
+	type testCase struct {
+		name           string
+		plan           account.Plan
+		isValid        bool
+		expectedErrors []string
+	}
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	allocations := []account.Allocation{{FundId: uuid.New(), Percentage: 100}}
+
+	cases := []testCase{
+		{
+			name:           "AccountID missing",
+			plan:           account.Plan{Amount: 100, Allocations: allocations, DayOfMonth: 1, StartDate: start},
+			isValid:        false,
+			expectedErrors: []string{"account_id"},
+		},
+		{
+			name:           "Amount missing",
+			plan:           account.Plan{AccountId: uuid.New(), Allocations: allocations, DayOfMonth: 1, StartDate: start},
+			isValid:        false,
+			expectedErrors: []string{"amount"},
+		},
+		{
+			name:           "Day of month invalid",
+			plan:           account.Plan{AccountId: uuid.New(), Amount: 100, Allocations: allocations, DayOfMonth: 32, StartDate: start},
+			isValid:        false,
+			expectedErrors: []string{"day_of_month"},
+		},
+		{
+			name:           "End date before start date",
+			plan:           account.Plan{AccountId: uuid.New(), Amount: 100, Allocations: allocations, DayOfMonth: 1, StartDate: start, EndDate: start.AddDate(0, 0, -1)},
+			isValid:        false,
+			expectedErrors: []string{"end_date"},
+		},
+		{
+			name:           "Valid plan",
+			plan:           account.Plan{AccountId: uuid.New(), Amount: 100, Allocations: allocations, DayOfMonth: 1, StartDate: start},
+			isValid:        true,
+			expectedErrors: []string{},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			isValid := testCase.plan.Validate()
+
+			if isValid != testCase.isValid {
+				t.Errorf("Expected Validate to return %t, got %t", testCase.isValid, isValid)
+			}
+
+			if len(testCase.plan.Errors) != len(testCase.expectedErrors) {
+				t.Errorf("Expected %d validation errors, got %d", len(testCase.expectedErrors), len(testCase.plan.Errors))
+			}
+
+			for _, field := range testCase.expectedErrors {
+				if _, ok := testCase.plan.Errors[field]; !ok {
+					t.Errorf("expected validation error field '%s' missing", field)
+				}
+			}
+		})
+	}
+}
+
+func TestPlanDueOn(t *testing.T) {
+	type testCase struct {
+		name string
+		plan account.Plan
+		date time.Time
+		due  bool
+	}
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := []testCase{
+		{
+			name: "Due on the day of the month",
+			plan: account.Plan{Status: account.PLAN_STATUS_ACTIVE, DayOfMonth: 15, StartDate: start},
+			date: time.Date(2025, 3, 15, 9, 0, 0, 0, time.UTC),
+			due:  true,
+		},
+		{
+			name: "Not due on a different day",
+			plan: account.Plan{Status: account.PLAN_STATUS_ACTIVE, DayOfMonth: 15, StartDate: start},
+			date: time.Date(2025, 3, 16, 0, 0, 0, 0, time.UTC),
+			due:  false,
+		},
+		{
+			name: "Due on the last day of a shorter month",
+			plan: account.Plan{Status: account.PLAN_STATUS_ACTIVE, DayOfMonth: 31, StartDate: start},
+			date: time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC),
+			due:  true,
+		},
+		{
+			name: "Not due before the start date",
+			plan: account.Plan{Status: account.PLAN_STATUS_ACTIVE, DayOfMonth: 15, StartDate: start},
+			date: time.Date(2024, 12, 15, 0, 0, 0, 0, time.UTC),
+			due:  false,
+		},
+		{
+			name: "Not due after the end date",
+			plan: account.Plan{Status: account.PLAN_STATUS_ACTIVE, DayOfMonth: 15, StartDate: start, EndDate: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)},
+			date: time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC),
+			due:  false,
+		},
+		{
+			name: "Not due if already run today",
+			plan: account.Plan{Status: account.PLAN_STATUS_ACTIVE, DayOfMonth: 15, StartDate: start, LastRunOn: time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC)},
+			date: time.Date(2025, 3, 15, 12, 0, 0, 0, time.UTC),
+			due:  false,
+		},
+		{
+			name: "Not due if stopped",
+			plan: account.Plan{Status: account.PLAN_STATUS_STOPPED, DayOfMonth: 15, StartDate: start},
+			date: time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC),
+			due:  false,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			due := testCase.plan.DueOn(testCase.date)
+
+			if due != testCase.due {
+				t.Errorf("Expected DueOn to return %t, got %t", testCase.due, due)
+			}
+		})
+	}
+}
+
+func TestPlanDueDates(t *testing.T) {
+	type testCase struct {
+		name     string
+		plan     account.Plan
+		date     time.Time
+		expected []time.Time
+	}
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := []testCase{
+		{
+			name:     "Every due date since the start date",
+			plan:     account.Plan{Status: account.PLAN_STATUS_ACTIVE, DayOfMonth: 31, StartDate: start},
+			date:     time.Date(2025, 3, 30, 0, 0, 0, 0, time.UTC),
+			expected: []time.Time{time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC), time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:     "Every due date since the last run",
+			plan:     account.Plan{Status: account.PLAN_STATUS_ACTIVE, DayOfMonth: 15, StartDate: start, LastRunOn: time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)},
+			date:     time.Date(2025, 3, 15, 6, 0, 0, 0, time.UTC),
+			expected: []time.Time{time.Date(2025, 2, 15, 0, 0, 0, 0, time.UTC), time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:     "No due dates after the end date",
+			plan:     account.Plan{Status: account.PLAN_STATUS_ACTIVE, DayOfMonth: 15, StartDate: start, EndDate: time.Date(2025, 2, 20, 0, 0, 0, 0, time.UTC), LastRunOn: time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)},
+			date:     time.Date(2025, 4, 15, 0, 0, 0, 0, time.UTC),
+			expected: []time.Time{time.Date(2025, 2, 15, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:     "No due dates if stopped",
+			plan:     account.Plan{Status: account.PLAN_STATUS_STOPPED, DayOfMonth: 15, StartDate: start},
+			date:     time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC),
+			expected: []time.Time{},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			dates := testCase.plan.DueDates(testCase.date)
+
+			if !slices.EqualFunc(dates, testCase.expected, time.Time.Equal) {
+				t.Errorf("Expected due dates %v, got %v", testCase.expected, dates)
+			}
+		})
+	}
+}
+
+func TestPlansArePaidOnTheDueDateAndStoppedWhenTheAllowanceIsReached(t *testing.T) {
+	conn, closeDown := NewTestDatabase()
+	defer closeDown()
+
+	var repo account.Repository = database.NewAccountRepository(conn)
+	var planRepo account.PlanRepository = database.NewPlanRepository(conn)
+	var depositRepo account.DepositRepository = database.NewDepositRepository(conn)
+
+	passingNiValidator := func(_ context.Context, _ string) error {
+		return nil
+	}
+
+	testFund := NewTestFund()
+	catalogue := NewTestCatalogue(testFund)
+
+	isa := account.NewISAService(&repo, &catalogue, NewTestRules(150, 0), account.ACCOUNT_TYPE_ISA, false, account.StartOfTaxYear{1, 1}, passingNiValidator)
+	notifier := testNotifier{}
+	serviceFactory := NewTestServiceFactory(&repo, isa)
+	deposits := account.NewDepositService(&depositRepo, serviceFactory, &catalogue, account.NewStubPaymentsClient())
+	service := account.NewPlanService(&planRepo, serviceFactory, deposits, notifier)
+
+	ctx := context.Background()
+
+	customer := account.Customer{
+		Id:           uuid.New(),
+		TaxResidency: "uk",
+		NINumber:     "AB123456A",
+		DateOfBirth:  time.Now().AddDate(-20, 0, 0),
+	}
+
+	newAccount, err := isa.CreateAccount(ctx, customer)
+
+	if err != nil {
+		t.Errorf("unexpected error when creating ISA account: %v", err)
+	}
+
+	firstPayment := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	plan := account.Plan{
+		AccountId:   newAccount.Id,
+		Amount:      100,
+		Allocations: []account.Allocation{{FundId: testFund.Id, Percentage: 100}},
+		DayOfMonth:  15,
+		StartDate:   firstPayment,
+	}
+
+	err = service.CreatePlan(ctx, &plan)
+
+	if err != nil {
+		t.Errorf("unexpected error when creating plan: %v", err)
+	}
+
+	result, err := service.RunDuePlans(ctx, firstPayment)
+
+	if err != nil {
+		t.Errorf("unexpected error running plans: %v", err)
+	}
+
+	if result.Deposited != 1 {
+		t.Errorf("Expected 1 plan to be deposited, got %d", result.Deposited)
+	}
+
+	// Running again on the same day should not invest twice
+	result, err = service.RunDuePlans(ctx, firstPayment)
+
+	if err != nil {
+		t.Errorf("unexpected error running plans: %v", err)
+	}
+
+	if result.Deposited != 0 {
+		t.Errorf("Expected 0 plans to be deposited on re-run, got %d", result.Deposited)
+	}
+
+	// The first payment is still pending so the next payment takes the total over the allowance
+	result, err = service.RunDuePlans(ctx, firstPayment.AddDate(0, 1, 0))
+
+	if err != nil {
+		t.Errorf("unexpected error running plans: %v", err)
+	}
+
+	if result.Stopped != 1 {
+		t.Errorf("Expected 1 plan to be stopped, got %d", result.Stopped)
+	}
+
+	stoppedPlan, err := service.Plan(ctx, plan.Id)
+
+	if err != nil {
+		t.Errorf("unexpected error fetching plan: %v", err)
+	}
+
+	if stoppedPlan.Status != account.PLAN_STATUS_STOPPED {
+		t.Errorf("Expected plan status %s, got %s", account.PLAN_STATUS_STOPPED, stoppedPlan.Status)
+	}
+
+	if len(notifier[customer.Id]) != 1 {
+		t.Errorf("Expected 1 notification, got %d", len(notifier[customer.Id]))
+	}
+}
+
+func TestMissedPlanPaymentsAreCaughtUpOnce(t *testing.T) {
+	conn, closeDown := NewTestDatabase()
+	defer closeDown()
+
+	var repo account.Repository = database.NewAccountRepository(conn)
+	var planRepo account.PlanRepository = database.NewPlanRepository(conn)
+	var depositRepo account.DepositRepository = database.NewDepositRepository(conn)
+
+	passingNiValidator := func(_ context.Context, _ string) error {
+		return nil
+	}
+
+	testFund := NewTestFund()
+	catalogue := NewTestCatalogue(testFund)
+
+	isa := account.NewISAService(&repo, &catalogue, NewTestRules(1000, 0), account.ACCOUNT_TYPE_ISA, false, account.StartOfTaxYear{1, 1}, passingNiValidator)
+	serviceFactory := NewTestServiceFactory(&repo, isa)
+	deposits := account.NewDepositService(&depositRepo, serviceFactory, &catalogue, account.NewStubPaymentsClient())
+	service := account.NewPlanService(&planRepo, serviceFactory, deposits, testNotifier{})
+
+	ctx := context.Background()
+
+	customer := account.Customer{
+		Id:           uuid.New(),
+		TaxResidency: "uk",
+		NINumber:     "AB123456A",
+		DateOfBirth:  time.Now().AddDate(-20, 0, 0),
+	}
+
+	newAccount, err := isa.CreateAccount(ctx, customer)
+
+	if err != nil {
+		t.Errorf("unexpected error when creating ISA account: %v", err)
+	}
+
+	firstPayment := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	plan := account.Plan{
+		AccountId:   newAccount.Id,
+		Amount:      100,
+		Allocations: []account.Allocation{{FundId: testFund.Id, Percentage: 100}},
+		DayOfMonth:  15,
+		StartDate:   firstPayment,
+	}
+
+	err = service.CreatePlan(ctx, &plan)
+
+	if err != nil {
+		t.Errorf("unexpected error when creating plan: %v", err)
+	}
+
+	// The job didn't run for the first two due dates
+	result, err := service.RunDuePlans(ctx, firstPayment.AddDate(0, 2, 0))
+
+	if err != nil {
+		t.Errorf("unexpected error running plans: %v", err)
+	}
+
+	if result.Deposited != 3 {
+		t.Errorf("Expected 3 payments, got %d", result.Deposited)
+	}
+
+	// The plan wasn't updated after the payments were taken
+	_, err = conn.Exec("UPDATE investment_plans SET last_run_on = NULL")
+
+	if err != nil {
+		t.Fatalf("unexpected error resetting plan: %v", err)
+	}
+
+	result, err = service.RunDuePlans(ctx, firstPayment.AddDate(0, 2, 0))
+
+	if err != nil || len(result.Failed) > 0 {
+		t.Errorf("unexpected error running plans: %v %v", err, result.Failed)
+	}
+
+	if result.Deposited != 0 {
+		t.Errorf("Expected no payments to be taken again, got %d", result.Deposited)
+	}
+
+	pending, err := depositRepo.GetPendingDeposits(ctx)
+
+	if err != nil {
+		t.Errorf("unexpected error fetching deposits: %v", err)
+	}
+
+	if len(pending) != 3 {
+		t.Errorf("Expected 3 deposits, got %d", len(pending))
+	}
+
+	updated, err := service.Plan(ctx, plan.Id)
+
+	if err != nil {
+		t.Errorf("unexpected error fetching plan: %v", err)
+	}
+
+	if !updated.LastRunOn.Equal(firstPayment.AddDate(0, 2, 0)) {
+		t.Errorf("Expected the plan to have last run on %v, got %v", firstPayment.AddDate(0, 2, 0), updated.LastRunOn)
+	}
+}
+
+func TestFailedPlanPaymentsAreRetried(t *testing.T) {
+	conn, closeDown := NewTestDatabase()
+	defer closeDown()
+
+	var repo account.Repository = database.NewAccountRepository(conn)
+	var planRepo account.PlanRepository = database.NewPlanRepository(conn)
+	var depositRepo account.DepositRepository = database.NewDepositRepository(conn)
+
+	passingNiValidator := func(_ context.Context, _ string) error {
+		return nil
+	}
+
+	testFund := NewTestFund()
+	catalogue := NewTestCatalogue(testFund)
+	payments := &unavailablePaymentsClient{StubPaymentsClient: account.NewStubPaymentsClient(), unavailable: true}
+
+	isa := account.NewISAService(&repo, &catalogue, NewTestRules(1000, 0), account.ACCOUNT_TYPE_ISA, false, account.StartOfTaxYear{1, 1}, passingNiValidator)
+	serviceFactory := NewTestServiceFactory(&repo, isa)
+	deposits := account.NewDepositService(&depositRepo, serviceFactory, &catalogue, payments)
+	service := account.NewPlanService(&planRepo, serviceFactory, deposits, testNotifier{})
+
+	ctx := context.Background()
+
+	customer := account.Customer{
+		Id:           uuid.New(),
+		TaxResidency: "uk",
+		NINumber:     "AB123456A",
+		DateOfBirth:  time.Now().AddDate(-20, 0, 0),
+	}
+
+	newAccount, err := isa.CreateAccount(ctx, customer)
+
+	if err != nil {
+		t.Errorf("unexpected error when creating ISA account: %v", err)
+	}
+
+	dueOn := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	plan := account.Plan{
+		AccountId:   newAccount.Id,
+		Amount:      100,
+		Allocations: []account.Allocation{{FundId: testFund.Id, Percentage: 100}},
+		DayOfMonth:  15,
+		StartDate:   dueOn,
+	}
+
+	err = service.CreatePlan(ctx, &plan)
+
+	if err != nil {
+		t.Errorf("unexpected error when creating plan: %v", err)
+	}
+
+	result, err := service.RunDuePlans(ctx, dueOn)
+
+	if err != nil {
+		t.Errorf("unexpected error running plans: %v", err)
+	}
+
+	if result.Deposited != 0 || result.Failed[plan.Id] == nil {
+		t.Errorf("Expected the payment to fail, got %d payments and %v", result.Deposited, result.Failed)
+	}
+
+	failed, err := depositRepo.GetDepositByReference(ctx, "plan:"+plan.Id.String()+":2025-01-15")
+
+	if err != nil {
+		t.Errorf("unexpected error fetching deposit: %v", err)
+	}
+
+	if failed.Status != account.DEPOSIT_STATUS_FAILED {
+		t.Errorf("Expected the deposit to have failed, got %s", failed.Status)
+	}
+
+	payments.unavailable = false
+
+	// The failed deposit isn't treated as paid, the payment is taken once
+	for range 2 {
+		result, err = service.RunDuePlans(ctx, dueOn)
+
+		if err != nil || len(result.Failed) > 0 {
+			t.Errorf("unexpected error running plans: %v %v", err, result.Failed)
+		}
+	}
+
+	pending, err := depositRepo.GetPendingDeposits(ctx)
+
+	if err != nil {
+		t.Errorf("unexpected error fetching deposits: %v", err)
+	}
+
+	if len(pending) != 1 {
+		t.Errorf("Expected 1 deposit, got %d", len(pending))
+	}
+
+	updated, err := service.Plan(ctx, plan.Id)
+
+	if err != nil {
+		t.Errorf("unexpected error fetching plan: %v", err)
+	}
+
+	if updated.Status != account.PLAN_STATUS_ACTIVE || !updated.LastRunOn.Equal(dueOn) {
+		t.Errorf("Expected the active plan to have last run on %v, got %s %v", dueOn, updated.Status, updated.LastRunOn)
+	}
+}
@@ -0,0 +1,105 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jameswhoughton/cushon/internal/fund"
+)
+
+var ErrAccountTypeUnknown = errors.New("Account type not offered")
+var ErrAccountTypeDuplicate = errors.New("Account type offered more than once")
+
+// Definition of a Service implementation
+//
+// Products are declared in the rules (see Rules) and name the service that
+// implements them, so a new product behaving like an existing one is only a
+// change to the rules. Each implementation is registered once by the file
+// implementing it (see RegisterService).
+type ServiceDefinition struct {
+	// Name products use to refer to the service in the rules, e.g. 'isa'
+	Name string
+	// Account type the service is limited to, empty if any product can use it
+	AccountType string
+	// Creates the service for a product
+	New func(product ProductRules, rules *RulesService, deps ServiceDependencies) Service
+	// Extra validation of new accounts using the service, optional
+	//
+	// Any errors are added to the account's Errors.
+	Validate func(a *Account)
+}
+
+// Dependencies shared by every Service
+type ServiceDependencies struct {
+	Repository     *Repository
+	Catalogue      *fund.Catalogue
+	StartOfTaxYear StartOfTaxYear
+	NIValidator    func(context.Context, string) error
+	Customers      CustomerClient
+}
+
+var servicesMu sync.RWMutex
+var services = make(map[string]ServiceDefinition)
+
+// Make a Service implementation available to products
+//
+// Should be called from an init function. Panics if the definition has no
+// name or constructor, or the name is already registered.
+func RegisterService(definition ServiceDefinition) {
+	servicesMu.Lock()
+	defer servicesMu.Unlock()
+
+	if definition.Name == "" || definition.New == nil {
+		panic("account: RegisterService needs a name and constructor")
+	}
+
+	if _, ok := services[definition.Name]; ok {
+		panic("account: RegisterService called twice for " + definition.Name)
+	}
+
+	services[definition.Name] = definition
+}
+
+func lookupService(name string) (ServiceDefinition, bool) {
+	servicesMu.RLock()
+	defer servicesMu.RUnlock()
+
+	definition, ok := services[name]
+
+	return definition, ok
+}
+
+// An account type offered to customers along with its limits for a tax year
+//
+// Limits are zero if the account type has no subscription limits.
+type Product struct {
+	AccountType string             `json:"account_type"`
+	Name        string             `json:"name"`
+	Limits      SubscriptionLimits `json:"limits"`
+}
+
+// Returns the account types offered, in the order their services were given
+// to NewServiceFactory, with the limits for the tax year
+func (f *ServiceFactory) Products(ctx context.Context, taxYear int) ([]Product, error) {
+	products := make([]Product, 0, len(f.accountTypes))
+
+	for _, accountType := range f.accountTypes {
+		product, _ := f.rules.Product(accountType)
+
+		limits, err := f.rules.Limits(ctx, accountType, taxYear)
+
+		if err != nil && !errors.Is(err, ErrLimitsNotFound) {
+			return []Product{}, fmt.Errorf("Unable to fetch limits: %w", err)
+		}
+
+		products = append(products, Product{
+			AccountType: accountType,
+			Name:        product.Name,
+			Limits:      limits,
+		})
+	}
+
+	return products, nil
+}
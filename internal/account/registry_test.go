@@ -0,0 +1,149 @@
+package account_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/internal/account"
+)
+
+func TestProductsDeclaredInRules(t *testing.T) {
+	var repo account.Repository
+
+	catalogue := NewTestCatalogue()
+
+	// A product that only exists in config, using the ISA service
+	rules := account.DefaultRules()
+	rules.Products = append(rules.Products, account.ProductRules{
+		AccountType: "test-isa",
+		Name:        "Test ISA",
+		Service:     "isa",
+		Limits:      []account.SubscriptionLimits{{FromTaxYear: 2017, AnnualLimit: 100000}},
+	})
+
+	service, err := account.NewRulesService(rules)
+
+	if err != nil {
+		t.Fatalf("unexpected error creating the rules service: %v", err)
+	}
+
+	services := service.Services(account.ServiceDependencies{
+		Repository:     &repo,
+		Catalogue:      &catalogue,
+		StartOfTaxYear: account.StartOfTaxYear{1, 1},
+		Customers:      account.NewStubCustomerClient(),
+	})
+
+	expected := []string{account.ACCOUNT_TYPE_ISA, account.ACCOUNT_TYPE_LISA, account.ACCOUNT_TYPE_JISA, "test-isa"}
+
+	if len(services) != len(expected) {
+		t.Fatalf("Expected %d services, got %d", len(expected), len(services))
+	}
+
+	for i := range expected {
+		if services[i].AccountType() != expected[i] {
+			t.Errorf("Expected %s, got %s", expected[i], services[i].AccountType())
+		}
+	}
+
+	if !service.SharesISAAllowance(account.ACCOUNT_TYPE_LISA) || service.SharesISAAllowance("test-isa") {
+		t.Error("Expected only the products declaring it to share the ISA allowance")
+	}
+
+	serviceFactory, err := account.NewServiceFactory(&repo, service, services...)
+
+	if err != nil {
+		t.Fatalf("unexpected error creating the service factory: %v", err)
+	}
+
+	products, err := serviceFactory.Products(context.Background(), 2024)
+
+	if err != nil {
+		t.Fatalf("unexpected error listing products: %v", err)
+	}
+
+	if len(products) != 4 || products[3] != (account.Product{AccountType: "test-isa", Name: "Test ISA", Limits: account.SubscriptionLimits{FromTaxYear: 2017, AnnualLimit: 100000}}) {
+		t.Errorf("Expected the product from the rules, got %+v", products)
+	}
+
+	a := account.Account{CustomerId: uuid.New(), AccountType: "test-isa"}
+
+	if !a.Validate(service) {
+		t.Errorf("unexpected validation errors: %v", a.Errors)
+	}
+}
+
+func TestNewServiceFactoryRejectsUnknownAccountTypes(t *testing.T) {
+	var repo account.Repository
+
+	catalogue := NewTestCatalogue()
+	rules := NewTestRules(0, 0)
+	service := account.NewISAService(&repo, &catalogue, rules, "cash-isa", false, account.StartOfTaxYear{1, 1}, nil)
+
+	_, err := account.NewServiceFactory(&repo, rules, service)
+
+	if !errors.Is(err, account.ErrAccountTypeUnknown) {
+		t.Errorf("Expected %v, got %v", account.ErrAccountTypeUnknown, err)
+	}
+}
+
+func TestNewServiceFactoryRejectsDuplicateAccountTypes(t *testing.T) {
+	var repo account.Repository
+
+	catalogue := NewTestCatalogue()
+	isa := account.NewISAService(&repo, &catalogue, NewTestRules(0, 0), account.ACCOUNT_TYPE_ISA, false, account.StartOfTaxYear{1, 1}, nil)
+
+	_, err := account.NewServiceFactory(&repo, NewTestRules(0, 0), isa, isa)
+
+	if !errors.Is(err, account.ErrAccountTypeDuplicate) {
+		t.Errorf("Expected %v, got %v", account.ErrAccountTypeDuplicate, err)
+	}
+}
+
+func TestGetProductsHandler(t *testing.T) {
+	var repo account.Repository
+
+	catalogue := NewTestCatalogue()
+	rules := NewTestRules(2000000, 400000)
+	isa := account.NewISAService(&repo, &catalogue, rules, account.ACCOUNT_TYPE_ISA, false, account.StartOfTaxYear{1, 1}, nil)
+	lisa := account.NewLISAService(&repo, &catalogue, rules, account.StartOfTaxYear{1, 1}, nil, account.NewStubCustomerClient())
+
+	serviceFactory, err := account.NewServiceFactory(&repo, rules, lisa, isa)
+
+	if err != nil {
+		t.Fatalf("unexpected error creating the service factory: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	account.GetProductsHandler(*serviceFactory, account.StartOfTaxYear{1, 1})(w, httptest.NewRequest(http.MethodGet, "/api/v1/products", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var products []account.Product
+
+	if err := json.NewDecoder(w.Body).Decode(&products); err != nil {
+		t.Fatalf("unexpected error decoding response: %v", err)
+	}
+
+	expected := []account.Product{
+		{AccountType: account.ACCOUNT_TYPE_LISA, Name: "Lifetime ISA", Limits: account.SubscriptionLimits{OverallLimit: 2000000, AnnualLimit: 400000}},
+		{AccountType: account.ACCOUNT_TYPE_ISA, Name: "Stocks and Shares ISA", Limits: account.SubscriptionLimits{OverallLimit: 2000000}},
+	}
+
+	if len(products) != len(expected) {
+		t.Fatalf("Expected %d products, got %+v", len(expected), products)
+	}
+
+	for i := range expected {
+		if products[i] != expected[i] {
+			t.Errorf("Expected %+v, got %+v", expected[i], products[i])
+		}
+	}
+}
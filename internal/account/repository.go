@@ -12,13 +12,49 @@ import (
 // A positive amount represents a purchase whereas a negative amount represents a sale.
 // TransactionType provides further information about the transaction (for example whether
 // it was a customer action: 'cust' or an accumulation investment: 'acc').
-// The TradeId references the external trading service.
+// The TradeId references the external trading service. Both are set by the
+// platform, never by the client.
 type Investment struct {
-	FundId          uuid.UUID
-	AccountFundId   int64
-	TradeId         uuid.UUID
-	TransactionType string
-	Amount          int
+	FundId          uuid.UUID         `json:"fund_id"`
+	AccountFundId   int64             `json:"-"`
+	TradeId         uuid.UUID         `json:"-"`
+	TransactionType string            `json:"-"`
+	Amount          int               `json:"amount"`
+	Errors          map[string]string `json:"errors"`
+}
+
+// Validate an Investment
+//
+// Only the fields on the investment are checked here, checks against
+// the fund catalogue happen in the service layer. Only purchases are valid,
+// sales (e.g. withdrawals) are made through their own service methods.
+func (i *Investment) Validate() bool {
+	if i.Errors == nil {
+		i.Errors = make(map[string]string, 2)
+	}
+
+	if i.FundId == (uuid.UUID{}) {
+		i.Errors["fund_id"] = "Fund ID missing"
+	}
+
+	if i.Amount <= 0 {
+		i.Errors["amount"] = "Amount must be positive"
+	}
+
+	return len(i.Errors) == 0
+}
+
+// A fund held by an account along with the current balance
+//
+// The balance is the amount invested less the amount sold in pence. Units
+// (see UNIT_SCALE) are only calculated for the holdings returned by
+// GetAccountUnitsAt and GetFundUnitsBefore.
+type AccountFund struct {
+	Id        int64
+	AccountId uuid.UUID
+	FundId    uuid.UUID
+	Balance   int
+	Units     int
 }
 
 // Responsible for managing retail accounts, the repository is
@@ -32,19 +68,97 @@ type Repository interface {
 	// Returns error if the account cannot be created
 	Create(ctx context.Context, account *Account) error
 
+	// Return the account with the given id
+	//
+	// Returns ErrAccountNotFound if the account does not exist.
+	GetAccount(ctx context.Context, accountId uuid.UUID) (Account, error)
+
 	// Invests into one or more funds
 	//
 	// If the account is already invested in the fund, the total invested will be incremented
 	// Returns an error if any of the investments fail, if any do fail non of the investments
-	// will be processed.
+	// will be processed. Returns ErrTradeDuplicate if a transaction with one of the trade
+	// ids has already been made and ErrInsufficientBalance if a sale is more than the
+	// balance of the fund when it is made.
 	Invest(ctx context.Context, accountId uuid.UUID, investments []Investment) error
 
+	// Sell units and create a payout instruction for the withdrawal atomically
+	//
+	// Returns ErrInsufficientBalance if the fund's balance is less than the
+	// amount withdrawn when the sale is made.
+	Withdraw(ctx context.Context, withdrawal Withdrawal) error
+
+	// Returns a slice of the funds currently held by the account
+	GetAccountFunds(ctx context.Context, accountId uuid.UUID) ([]AccountFund, error)
+
+	// Return every account of the type opened before the date
+	GetAccountsByType(ctx context.Context, accountType string, openedBefore time.Time) ([]Account, error)
+
+	// Change the account type in place and remove any guardian
+	//
+	// The account keeps its funds and transactions, only subscriptions made from
+	// the date count towards the allowance of the new account type. Returns
+	// ErrAccountNotFound if the account is not of the 'from' type.
+	ConvertAccount(ctx context.Context, accountId uuid.UUID, from string, to string, date time.Time) error
+
+	// Set how the account receives distributions from income funds
+	UpdateIncomePreference(ctx context.Context, accountId uuid.UUID, preference string) error
+
+	// Returns the units of each fund held by the account at the end of the given date
+	//
+	// The units bought or sold by each transaction are calculated from the price
+	// of the fund on the day it was made. Returns fund.ErrPriceNotFound if a
+	// transaction was made before the fund had a price.
+	GetAccountUnitsAt(ctx context.Context, accountId uuid.UUID, date time.Time) ([]AccountFund, error)
+
+	// Returns every holding of the fund with units at the start of the given date
+	//
+	// Transactions made on the date itself are not included, units are
+	// calculated in the same way as GetAccountUnitsAt.
+	GetFundUnitsBefore(ctx context.Context, fundId uuid.UUID, date time.Time) ([]AccountFund, error)
+
+	// Credit a distribution to the account's cash balance
+	//
+	// If the distribution's income preference is INCOME_PREFERENCE_PAYOUT the cash
+	// is debited again and a payout instruction created in the same transaction.
+	// Returns ErrDistributionDuplicate if the reference has already been paid.
+	PayDistribution(ctx context.Context, distribution Distribution) error
+
+	// Returns the cash balance of the account in pence
+	GetCashBalance(ctx context.Context, accountId uuid.UUID) (int, error)
+
+	// Returns the cash balance of the account in pence at the end of the given date
+	GetCashBalanceAt(ctx context.Context, accountId uuid.UUID, date time.Time) (int, error)
+
 	// Returns a slice of transactions for the given account limited by the filter
+	//
+	// Transactions are returned in the order they were made.
 	GetAccountTransactions(ctx context.Context, accountId uuid.UUID, filter TransactionFilter) ([]Transaction, error)
 
 	// Return the total amount invested by a customer from the 'fromDate' to the current time.
 	//
 	// Any transactions due to dividends from accumulation funds are ignored.
+	// Any switches between funds are ignored.
+	// Any distributions from income funds are ignored.
+	// Any sales to pay fees are ignored.
 	// Any customer withdrawals (negative amounts) are ignored.
 	GetTotalInvestedToDate(ctx context.Context, accountId uuid.UUID, date time.Time) (int, error)
+
+	// Return the total subscribed by the customer from the 'fromDate' up to but not including the 'toDate'
+	//
+	// Follows the same rules as GetTotalInvestedToDate.
+	GetSubscriptionsBetween(ctx context.Context, accountId uuid.UUID, fromDate time.Time, toDate time.Time) (int, error)
+
+	// Return the customer transactions that count towards the allowance from the 'fromDate' up to but not including the 'toDate'
+	//
+	// Subscriptions and withdrawals are dated (CreatedAt) when they count
+	// towards the allowance, a deposit's investments when the deposit was made
+	// rather than when it settled, and are returned in that order.
+	GetSubscriptionTransactions(ctx context.Context, accountId uuid.UUID, fromDate time.Time, toDate time.Time) ([]Transaction, error)
+
+	// Return the subscriptions made from the 'fromDate' into every account held by the customer
+	//
+	// Every account is returned, including those with no subscriptions. Subscriptions
+	// made before an account was converted (see ConvertAccount) are not included.
+	GetCustomerSubscriptions(ctx context.Context, customerId uuid.UUID, fromDate time.Time) ([]AccountSubscriptions, error)
 }
@@ -1,22 +1,25 @@
 package account_test
 
 import (
+	"context"
 	"database/sql"
 	"log"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
 	"github.com/jameswhoughton/cushon/database"
 	"github.com/jameswhoughton/cushon/internal/account"
+	"github.com/jameswhoughton/cushon/internal/fund"
 )
 
 // Helper function to connect to the testing database
 //
 // The database is migrated when this function is called.
-// The database implementation of the repository is returned
-// along with a deferrable closedown function that rolls back
-// the database.
-func NewTestRepository() (account.Repository, func()) {
-	conn, err := sql.Open("mysql", "root@tcp(127.0.0.1:8002)/retail_accounts")
+// The connection is returned along with a deferrable closedown
+// function that rolls back the database.
+func NewTestDatabase() (*sql.DB, func()) {
+	conn, err := sql.Open("mysql", "root@tcp(127.0.0.1:8002)/retail_accounts?parseTime=true")
 
 	if err != nil {
 		log.Fatal(err)
@@ -36,5 +39,148 @@ func NewTestRepository() (account.Repository, func()) {
 		}
 	}
 
+	return conn, closeDown
+}
+
+// Helper function to connect to the testing database
+//
+// The database implementation of the repository is returned
+// along with a deferrable closedown function that rolls back
+// the database.
+func NewTestRepository() (account.Repository, func()) {
+	conn, closeDown := NewTestDatabase()
+
 	return database.NewAccountRepository(conn), closeDown
 }
+
+// In-memory fund catalogue standing in for the funds service
+type testCatalogue map[uuid.UUID]fund.Fund
+
+func (c testCatalogue) Create(_ context.Context, f fund.Fund) error {
+	c[f.Id] = f
+
+	return nil
+}
+
+func (c testCatalogue) Fund(_ context.Context, id uuid.UUID) (fund.Fund, error) {
+	f, ok := c[id]
+
+	if !ok {
+		return fund.Fund{}, fund.ErrFundNotFound
+	}
+
+	return f, nil
+}
+
+// Helper function to create a fund catalogue containing the given funds
+func NewTestCatalogue(funds ...fund.Fund) fund.Catalogue {
+	catalogue := make(testCatalogue, len(funds))
+
+	for _, f := range funds {
+		catalogue[f.Id] = f
+	}
+
+	return catalogue
+}
+
+// Helper function to create an open fund available to ISAs
+func NewTestFund() fund.Fund {
+	return fund.Fund{
+		Id:           uuid.New(),
+		Name:         "Test Fund",
+		Status:       fund.FUND_STATUS_OPEN,
+		RiskRating:   4,
+		AccountTypes: []string{account.ACCOUNT_TYPE_ISA},
+	}
+}
+
+// Helper function to price a fund from 2000 onwards
+//
+// Every transaction made in the tests can then be converted to units. The
+// prices are returned so they can be used to value the holdings.
+func NewTestPrice(conn *sql.DB, fundId uuid.UUID, price int) account.PriceSource {
+	prices := database.NewPriceRepository(conn)
+
+	err := prices.Create(context.Background(), fund.Price{
+		FundId: fundId,
+		Date:   time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		Price:  price,
+	})
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return prices
+}
+
+// Helper function to move every fund transaction back a day
+//
+// Used to make holdings that were bought before a record date of today.
+func BackdateTestTransactions(conn *sql.DB) {
+	_, err := conn.Exec("UPDATE fund_transactions SET created_at = created_at - INTERVAL 1 DAY")
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Helper function to create the default rules with the given limits
+//
+// The limits apply to every tax year, the overall limit to every account
+// type and the LISA limit to LISAs only.
+func NewTestRules(overallLimit int, lisaLimit int) *account.RulesService {
+	rules := account.DefaultRules()
+
+	for i, product := range rules.Products {
+		limits := account.SubscriptionLimits{OverallLimit: overallLimit}
+
+		if product.AccountType == account.ACCOUNT_TYPE_LISA {
+			limits.AnnualLimit = lisaLimit
+		}
+
+		rules.Products[i].Limits = []account.SubscriptionLimits{limits}
+	}
+
+	service, err := account.NewRulesService(rules)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return service
+}
+
+// Repository holding accounts and their funds in memory
+//
+// Only GetAccount and GetAccountFunds are implemented, for tests that don't
+// need a database.
+type memoryAccountRepository struct {
+	account.Repository
+	accounts map[uuid.UUID]account.Account
+	funds    map[uuid.UUID][]account.AccountFund
+}
+
+func (r memoryAccountRepository) GetAccount(_ context.Context, accountId uuid.UUID) (account.Account, error) {
+	a, ok := r.accounts[accountId]
+
+	if !ok {
+		return account.Account{}, account.ErrAccountNotFound
+	}
+
+	return a, nil
+}
+
+func (r memoryAccountRepository) GetAccountFunds(_ context.Context, accountId uuid.UUID) ([]account.AccountFund, error) {
+	return r.funds[accountId], nil
+}
+
+func NewTestServiceFactory(repo *account.Repository, services ...account.Service) *account.ServiceFactory {
+	serviceFactory, err := account.NewServiceFactory(repo, NewTestRules(0, 0), services...)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return serviceFactory
+}
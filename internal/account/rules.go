@@ -0,0 +1,290 @@
+package account
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var ErrRulesInvalid = errors.New("Account rules invalid")
+var ErrRulesNotFound = errors.New("No rules for account type")
+
+// Rules shipped with the service, see DefaultRules
+//
+//go:embed rules.json
+var defaultRules []byte
+
+// Tax residency as used by Customer, e.g. 'uk'
+var residencyCode = regexp.MustCompile(`^[a-z]{2}$`)
+
+// Who can open an account type
+type EligibilityRules struct {
+	// Minimum age in years, zero for no minimum
+	MinAge int `json:"min_age"`
+	// Customers must be under the maximum age in years, zero for no maximum
+	MaxAge int `json:"max_age"`
+	// Tax residencies that can open the account, empty for any
+	Residencies []string `json:"residencies"`
+	// Whether the customer's NI number must be verified
+	NINumberRequired bool `json:"ni_number_required"`
+}
+
+// The rules for a single account type (a product)
+type ProductRules struct {
+	AccountType string `json:"account_type"`
+	// Name shown to customers, e.g. 'Lifetime ISA'
+	Name string `json:"name"`
+	// The registered Service implementing the product, see RegisterService
+	Service string `json:"service"`
+	// Whether subscriptions use up the customer's overall ISA allowance
+	ISAAllowance bool `json:"isa_allowance"`
+	// Whether withdrawals can be replaced in the same tax year without using
+	// up more of the allowance
+	Flexible    bool             `json:"flexible"`
+	Eligibility EligibilityRules `json:"eligibility"`
+	// Empty if the product has no subscription limits
+	Limits []SubscriptionLimits `json:"limits"`
+}
+
+// Eligibility and subscription rules for every account type
+//
+// The rules are the only place products are declared, so a new year's limits,
+// a change to who can open an account or a new product using an existing
+// Service doesn't need new code. The version is reported with each
+// eligibility decision so it can be traced back to the rules used.
+type Rules struct {
+	Version  string         `json:"version"`
+	Products []ProductRules `json:"products"`
+}
+
+// Check every product is complete and its limits are in tax year order
+func (r Rules) Validate() error {
+	if r.Version == "" {
+		return fmt.Errorf("%w: a version is required", ErrRulesInvalid)
+	}
+
+	seen := make(map[string]bool, len(r.Products))
+
+	for _, product := range r.Products {
+		if product.AccountType == "" || product.Name == "" || product.Service == "" {
+			return fmt.Errorf("%w: every product needs an account type, name and service", ErrRulesInvalid)
+		}
+
+		if seen[product.AccountType] {
+			return fmt.Errorf("%w: '%s' is declared more than once", ErrRulesInvalid, product.AccountType)
+		}
+
+		seen[product.AccountType] = true
+
+		if err := product.validate(); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrRulesInvalid, product.AccountType, err)
+		}
+	}
+
+	return nil
+}
+
+func (p ProductRules) validate() error {
+	eligibility := p.Eligibility
+
+	if eligibility.MinAge < 0 || eligibility.MaxAge < 0 {
+		return errors.New("ages cannot be negative")
+	}
+
+	if eligibility.MaxAge != 0 && eligibility.MaxAge <= eligibility.MinAge {
+		return errors.New("the maximum age must be above the minimum age")
+	}
+
+	for _, residency := range eligibility.Residencies {
+		if !residencyCode.MatchString(residency) {
+			return fmt.Errorf("residency '%s' must be a lower case country code", residency)
+		}
+	}
+
+	for i, limits := range p.Limits {
+		if limits.OverallLimit < 0 || limits.AnnualLimit < 0 {
+			return fmt.Errorf("limits from %d cannot be negative", limits.FromTaxYear)
+		}
+
+		if i > 0 && limits.FromTaxYear <= p.Limits[i-1].FromTaxYear {
+			return fmt.Errorf("limits from %d must come after the limits from %d", limits.FromTaxYear, p.Limits[i-1].FromTaxYear)
+		}
+	}
+
+	return nil
+}
+
+// Parse rules from JSON, unknown fields are rejected to catch typos
+func ParseRules(r io.Reader) (Rules, error) {
+	var rules Rules
+
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&rules); err != nil {
+		return Rules{}, fmt.Errorf("%w: %v", ErrRulesInvalid, err)
+	}
+
+	return rules, nil
+}
+
+// Load rules from a JSON file, see ParseRules
+func LoadRules(path string) (Rules, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return Rules{}, fmt.Errorf("Unable to open rules: %v", err)
+	}
+
+	defer file.Close()
+
+	return ParseRules(file)
+}
+
+// Return the rules shipped with the service (rules.json)
+func DefaultRules() Rules {
+	rules, err := ParseRules(bytes.NewReader(defaultRules))
+
+	if err != nil {
+		panic(err)
+	}
+
+	return rules
+}
+
+// Service to evaluate the rules for each account type
+//
+// Also the registry of products offered, the ServiceFactory and account
+// validation only accept account types declared in the rules.
+type RulesService struct {
+	version  string
+	products []ProductRules
+	limits   ConfigLimits
+}
+
+// Returns ErrRulesInvalid if the rules are invalid or a product's service isn't
+// registered. Registered services needn't be used, a product can be withdrawn
+// by removing it from the rules.
+func NewRulesService(rules Rules) (*RulesService, error) {
+	if err := rules.Validate(); err != nil {
+		return nil, err
+	}
+
+	for _, product := range rules.Products {
+		definition, ok := lookupService(product.Service)
+
+		if !ok {
+			return nil, fmt.Errorf("%w: %s: service '%s' is not registered", ErrRulesInvalid, product.AccountType, product.Service)
+		}
+
+		if definition.AccountType != "" && definition.AccountType != product.AccountType {
+			return nil, fmt.Errorf("%w: %s: service '%s' can only be used for '%s'", ErrRulesInvalid, product.AccountType, product.Service, definition.AccountType)
+		}
+	}
+
+	return &RulesService{version: rules.Version, products: rules.Products, limits: NewConfigLimits(rules)}, nil
+}
+
+// Return the version of the rules being evaluated
+func (s *RulesService) Version() string {
+	return s.version
+}
+
+// Return the rules for the account type
+func (s *RulesService) Product(accountType string) (ProductRules, bool) {
+	i := slices.IndexFunc(s.products, func(p ProductRules) bool {
+		return p.AccountType == accountType
+	})
+
+	if i == -1 {
+		return ProductRules{}, false
+	}
+
+	return s.products[i], true
+}
+
+// Return the rules for every account type, in the order they're declared
+func (s *RulesService) Products() []ProductRules {
+	return slices.Clone(s.products)
+}
+
+// Returns true if subscriptions to the account type use up the overall ISA allowance
+func (s *RulesService) SharesISAAllowance(accountType string) bool {
+	product, ok := s.Product(accountType)
+
+	return ok && product.ISAAllowance
+}
+
+// Build the Service for every product, in the order they're declared
+func (s *RulesService) Services(deps ServiceDependencies) []Service {
+	services := make([]Service, len(s.products))
+
+	for i, product := range s.products {
+		// Every product's service was checked by NewRulesService
+		definition, _ := lookupService(product.Service)
+
+		services[i] = definition.New(product, s, deps)
+	}
+
+	return services
+}
+
+// Evaluate every eligibility rule for the account type
+//
+// Returns ErrRulesNotFound if the account type has no rules. Any other error
+// means the NI number couldn't be checked, see checkNINumber.
+func (s *RulesService) Eligibility(ctx context.Context, accountType string, customer Customer, niValidator func(context.Context, string) error) (Eligibility, error) {
+	product, ok := s.Product(accountType)
+
+	if !ok {
+		return Eligibility{}, fmt.Errorf("%w '%s'", ErrRulesNotFound, accountType)
+	}
+
+	rules := product.Eligibility
+	name := withArticle(product.Name)
+
+	checks := []EligibilityCheck{
+		checkResidency(customer, rules.Residencies, name),
+		checkAge(customer, name, rules.MinAge, rules.MaxAge),
+	}
+
+	if rules.NINumberRequired {
+		niNumber, err := checkNINumber(ctx, niValidator, customer)
+
+		if err != nil {
+			return Eligibility{}, err
+		}
+
+		checks = append(checks, niNumber)
+	}
+
+	eligibility := newEligibility(accountType, checks...)
+	eligibility.RulesVersion = s.version
+
+	return eligibility, nil
+}
+
+// Return the limits for the account type in the tax year starting in the year
+//
+// Returns ErrLimitsNotFound if the account type has no limits that apply as
+// far back as the tax year.
+func (s *RulesService) Limits(ctx context.Context, accountType string, taxYear int) (SubscriptionLimits, error) {
+	return s.limits.Limits(ctx, accountType, taxYear)
+}
+
+// Prefix the name with 'a' or 'an', e.g. 'an ISA'
+func withArticle(name string) string {
+	if strings.ContainsAny(strings.ToUpper(name[:1]), "AEIOU") {
+		return "an " + name
+	}
+
+	return "a " + name
+}
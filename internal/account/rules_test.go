@@ -0,0 +1,206 @@
+package account_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/internal/account"
+)
+
+func TestDefaultRulesAreValid(t *testing.T) {
+	if err := account.DefaultRules().Validate(); err != nil {
+		t.Errorf("unexpected error validating the default rules: %v", err)
+	}
+}
+
+func TestRulesValidation(t *testing.T) {
+	product := func(modify func(p *account.ProductRules)) account.Rules {
+		p := account.ProductRules{
+			AccountType: "jisa",
+			Name:        "Junior ISA",
+			Service:     "isa",
+			Eligibility: account.EligibilityRules{MaxAge: 18, Residencies: []string{"uk"}},
+			Limits:      []account.SubscriptionLimits{{FromTaxYear: 2020, AnnualLimit: 900000}},
+		}
+
+		modify(&p)
+
+		return account.Rules{Version: "test", Products: []account.ProductRules{p}}
+	}
+
+	type testCase struct {
+		name    string
+		rules   account.Rules
+		isValid bool
+	}
+
+	cases := []testCase{
+		{
+			name:    "Valid rules",
+			rules:   product(func(p *account.ProductRules) {}),
+			isValid: true,
+		},
+		{
+			name:  "Version missing",
+			rules: account.Rules{Products: product(func(p *account.ProductRules) {}).Products},
+		},
+		{
+			name:  "Name missing",
+			rules: product(func(p *account.ProductRules) { p.Name = "" }),
+		},
+		{
+			name:  "Service missing",
+			rules: product(func(p *account.ProductRules) { p.Service = "" }),
+		},
+		{
+			name:  "Maximum age below the minimum",
+			rules: product(func(p *account.ProductRules) { p.Eligibility.MinAge = 18 }),
+		},
+		{
+			name:  "Residency not a country code",
+			rules: product(func(p *account.ProductRules) { p.Eligibility.Residencies = []string{"UK"} }),
+		},
+		{
+			name: "Limits out of order",
+			rules: product(func(p *account.ProductRules) {
+				p.Limits = append(p.Limits, account.SubscriptionLimits{FromTaxYear: 2019, AnnualLimit: 436800})
+			}),
+		},
+		{
+			name:  "Negative limit",
+			rules: product(func(p *account.ProductRules) { p.Limits[0].AnnualLimit = -1 }),
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := testCase.rules.Validate()
+
+			if testCase.isValid && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			if !testCase.isValid && !errors.Is(err, account.ErrRulesInvalid) {
+				t.Errorf("Expected %v, got %v", account.ErrRulesInvalid, err)
+			}
+		})
+	}
+}
+
+func TestParseRulesRejectsUnknownFields(t *testing.T) {
+	_, err := account.ParseRules(strings.NewReader(`{"version": "test", "products": [{"account_type": "isa", "min_age": 18}]}`))
+
+	if !errors.Is(err, account.ErrRulesInvalid) {
+		t.Errorf("Expected %v, got %v", account.ErrRulesInvalid, err)
+	}
+}
+
+func TestRulesEligibilityIsDeclarative(t *testing.T) {
+	// A product that only exists in config
+	rules := account.DefaultRules()
+	rules.Version = "2025-test"
+	rules.Products = append(rules.Products, account.ProductRules{
+		AccountType: "junior-cash-isa",
+		Name:        "Junior Cash ISA",
+		Service:     "isa",
+		Eligibility: account.EligibilityRules{MaxAge: 18, Residencies: []string{"uk", "gg"}},
+		Limits:      []account.SubscriptionLimits{{FromTaxYear: 2020, AnnualLimit: 900000}},
+	})
+
+	service, err := account.NewRulesService(rules)
+
+	if err != nil {
+		t.Fatalf("unexpected error creating the rules service: %v", err)
+	}
+
+	niValidator := func(_ context.Context, _ string) error {
+		t.Error("Expected the NI number not to be checked")
+
+		return nil
+	}
+
+	eligibility, err := service.Eligibility(context.Background(), "junior-cash-isa", account.Customer{
+		Id:           uuid.New(),
+		TaxResidency: "fr",
+		DateOfBirth:  time.Now().AddDate(-20, 0, 0),
+	}, niValidator)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if eligibility.Eligible || len(eligibility.Checks) != 2 || eligibility.RulesVersion != "2025-test" {
+		t.Fatalf("Expected two failed checks with the rules version, got %+v", eligibility)
+	}
+
+	expected := []string{
+		"Only UK or GG tax residents can open a Junior Cash ISA",
+		"Only customers who are under the age of 18 can open a Junior Cash ISA",
+	}
+
+	for i, check := range eligibility.Checks {
+		if check.Message != expected[i] {
+			t.Errorf("Expected '%s', got '%s'", expected[i], check.Message)
+		}
+	}
+}
+
+func TestNewRulesServiceChecksRegisteredServices(t *testing.T) {
+	type testCase struct {
+		name    string
+		modify  func(r *account.Rules)
+		isValid bool
+	}
+
+	cases := []testCase{
+		{
+			name:    "Default rules",
+			modify:  func(r *account.Rules) {},
+			isValid: true,
+		},
+		{
+			name: "Product using an existing service",
+			modify: func(r *account.Rules) {
+				r.Products = append(r.Products, account.ProductRules{AccountType: "cash-isa", Name: "Cash ISA", Service: "isa"})
+			},
+			isValid: true,
+		},
+		{
+			name:   "Service not registered",
+			modify: func(r *account.Rules) { r.Products[0].Service = "gia" },
+		},
+		{
+			name:    "Registered service not used",
+			modify:  func(r *account.Rules) { r.Products = r.Products[:1] },
+			isValid: true,
+		},
+		{
+			name: "Service used for another account type",
+			modify: func(r *account.Rules) {
+				r.Products = append(r.Products, account.ProductRules{AccountType: "lisa-plus", Name: "Lifetime ISA Plus", Service: "lisa"})
+			},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			rules := account.DefaultRules()
+
+			testCase.modify(&rules)
+
+			_, err := account.NewRulesService(rules)
+
+			if testCase.isValid && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			if !testCase.isValid && !errors.Is(err, account.ErrRulesInvalid) {
+				t.Errorf("Expected %v, got %v", account.ErrRulesInvalid, err)
+			}
+		})
+	}
+}
@@ -7,9 +7,12 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/internal/fund"
 )
 
 var ErrTransactionFilterInValid = errors.New("Filter values are not valid")
+var ErrInvestmentInvalid = errors.New("Investment invalid")
+var ErrTradeDuplicate = errors.New("Trade has already been made")
 
 type ErrAccountCreatePermission struct {
 	message string
@@ -46,6 +49,18 @@ const (
 	TRANSACTION_TYPE_CUSTOMER string = "cust"
 	// Represents an internal transaction where dividends from a fund was reinvested
 	TRANSACTION_TYPE_ACCUMULATION string = "acc"
+	// Represents one leg of a switch between two funds in the same account
+	TRANSACTION_TYPE_SWITCH string = "switch"
+	// Represents a distribution from an income fund credited to cash
+	TRANSACTION_TYPE_DISTRIBUTION string = "dist"
+	// Represents cash paid out of the account to the customer's bank account
+	TRANSACTION_TYPE_PAYOUT string = "payout"
+	// Represents a platform fee taken from cash or raised by selling units
+	TRANSACTION_TYPE_FEE string = "fee"
+	// Represents a government charge deducted from a withdrawal (e.g. from a LISA)
+	TRANSACTION_TYPE_PENALTY string = "penalty"
+	// Represents a government bonus on LISA contributions credited to cash
+	TRANSACTION_TYPE_BONUS string = "bonus"
 
 	// There are likely other transaction types which can be added here
 )
@@ -55,6 +70,7 @@ type Transaction struct {
 	FundId          uuid.UUID
 	TransactionType string
 	Amount          int
+	CreatedAt       time.Time
 }
 
 // Interface representing an account Service
@@ -62,6 +78,11 @@ type Transaction struct {
 // This should be implemented by each type of account (e.g. ISA, LISA etc.)
 // and contain any rules associated with that type of account.
 type Service interface {
+	// Returns the account type managed by the service
+	//
+	// The account type must be a product in the rules, see RulesService.
+	AccountType() string
+
 	// Creates a new account for the customer
 	//
 	// Returns ErrAccountCreatePermission error if the customer is unable
@@ -69,44 +90,151 @@ type Service interface {
 	// Account validation happens here.
 	CreateAccount(ctx context.Context, customer Customer) (Account, error)
 
+	// Evaluates every rule for opening the account type
+	//
+	// Returns an error only if a rule couldn't be evaluated, e.g. the NI
+	// validation service is unavailable. CreateAccount uses the same rules.
+	Eligibility(ctx context.Context, customer Customer) (Eligibility, error)
+
+	// Runs every check Invest would make without making the investments
+	//
+	// Used to validate investments before taking payment for them, returns
+	// the same errors as Invest.
+	CheckInvestment(ctx context.Context, accountId uuid.UUID, investments []Investment) error
+
 	// Makes one or more fund investments
 	//
 	// Investments are validated here, if any of the investments fail, none
-	// are processed.
+	// are processed. Returns ErrInvestmentInvalid if any of the investments
+	// are invalid or in a fund that isn't available to the account.
 	Invest(ctx context.Context, accountId uuid.UUID, investments []Investment) error
 
+	// Invests a total amount split across one or more funds by percentage
+	//
+	// The per-fund investments are made through Invest. Returns ErrAllocationInvalid
+	// if the request or any of the resulting investments are invalid, the errors
+	// are stored on the request.
+	InvestAllocation(ctx context.Context, accountId uuid.UUID, request *AllocationRequest) error
+
+	// Moves an amount from one fund to another
+	//
+	// The sale and purchase are processed atomically and do not count towards
+	// any subscription limits. Returns ErrInsufficientBalance if the account
+	// does not hold enough of the 'from' fund.
+	Switch(ctx context.Context, accountId uuid.UUID, from uuid.UUID, to uuid.UUID, amount int) error
+
+	// Returns the withdrawal that would be made without making it
+	//
+	// Used to show the customer what they would receive, returns the same
+	// errors as Withdraw.
+	QuoteWithdrawal(ctx context.Context, accountId uuid.UUID, request WithdrawalRequest) (Withdrawal, error)
+
+	// Takes money out of a fund and pays it to the customer
+	//
+	// Returns ErrInsufficientBalance if the account does not hold enough of
+	// the fund. Account types may restrict or charge for withdrawals.
+	Withdraw(ctx context.Context, accountId uuid.UUID, request WithdrawalRequest) (Withdrawal, error)
+
 	// Get a list of transactions for an account
 	//
 	// Returns a filtered list of transactions for an account (limited to a 1 year window).
 	AccountTransactions(ctx context.Context, accountId uuid.UUID, filter TransactionFilter) ([]Transaction, error)
 }
 
+// Returns the Service for each account type offered
 type ServiceFactory struct {
-	isa *ISAService
-	// Other account types can be added here
+	repository Repository
+	rules      *RulesService
+	services   map[string]Service
+	// Account types in the order the services were given
+	accountTypes []string
 }
 
+// Returns the Service for the account type, or nil if it isn't offered
 func (f *ServiceFactory) Service(accountType string) Service {
-	switch accountType {
-	case ACCOUNT_TYPE_ISA:
-		return f.isa
-	default:
-		return nil
+	return f.services[accountType]
+}
+
+// Returns the eligibility of the customer for each account type offered
+func (f *ServiceFactory) Eligibility(ctx context.Context, customer Customer) ([]Eligibility, error) {
+	eligibility := make([]Eligibility, 0, len(f.accountTypes))
+
+	for _, accountType := range f.accountTypes {
+		e, err := f.services[accountType].Eligibility(ctx, customer)
+
+		if err != nil {
+			return []Eligibility{}, err
+		}
+
+		eligibility = append(eligibility, e)
 	}
+
+	return eligibility, nil
 }
 
-func NewServiceFactory(isa *ISAService) *ServiceFactory {
-	return &ServiceFactory{
-		isa: isa,
+// Returns the account along with the Service for its account type
+//
+// Returns ErrAccountNotFound if the account does not exist.
+func (f *ServiceFactory) ServiceForAccount(ctx context.Context, accountId uuid.UUID) (Service, Account, error) {
+	account, err := f.repository.GetAccount(ctx, accountId)
+
+	if err != nil {
+		return nil, Account{}, err
+	}
+
+	service := f.Service(account.AccountType)
+
+	if service == nil {
+		return nil, Account{}, fmt.Errorf("no service for account type '%s'", account.AccountType)
 	}
+
+	return service, account, nil
+}
+
+// Only the account types of the services given are offered, the rules are
+// used when listing the products offered
+//
+// Returns ErrAccountTypeUnknown if a service's account type isn't a product in
+// the rules or ErrAccountTypeDuplicate if two services have the same account type.
+func NewServiceFactory(repository *Repository, rules *RulesService, services ...Service) (*ServiceFactory, error) {
+	factory := &ServiceFactory{
+		repository: *repository,
+		rules:      rules,
+		services:   make(map[string]Service, len(services)),
+	}
+
+	for _, service := range services {
+		accountType := service.AccountType()
+
+		if _, ok := rules.Product(accountType); !ok {
+			return nil, fmt.Errorf("%w: '%s'", ErrAccountTypeUnknown, accountType)
+		}
+
+		if _, ok := factory.services[accountType]; ok {
+			return nil, fmt.Errorf("%w: '%s'", ErrAccountTypeDuplicate, accountType)
+		}
+
+		factory.services[accountType] = service
+		factory.accountTypes = append(factory.accountTypes, accountType)
+	}
+
+	return factory, nil
 }
 
 // Generic function to create a new account
 //
 // This function is designed to be used across all different account types.
 // If an account is invalid it will return a ErrorAccountInvalid error
-func createAccount(ctx context.Context, repo Repository, account Account) (Account, error) {
-	if !account.Validate() {
+func createAccount(ctx context.Context, repo Repository, rules *RulesService, account Account) (Account, error) {
+	if account.Id == (uuid.UUID{}) {
+		account.Id = uuid.New()
+	}
+
+	if account.IncomePreference == "" {
+		account.IncomePreference = INCOME_PREFERENCE_CASH
+	}
+
+	if !account.Validate(rules) {
 		return account, ErrAccountInvalid
 	}
 
@@ -126,3 +254,63 @@ func getAccountTransactions(ctx context.Context, repo Repository, accountId uuid
 
 	return repo.GetAccountTransactions(ctx, accountId, filter)
 }
+
+// Generic function to validate investments before they are made
+//
+// Each investment is validated and checked against the fund catalogue, funds
+// must exist and be available to the account type. Any errors are stored on
+// the investment's Errors map and ErrInvestmentInvalid is returned.
+// The AccountFundId of investments in funds already held by the account is
+// populated so the repository can update the existing balance.
+func validateInvestments(ctx context.Context, repo Repository, catalogue fund.Catalogue, accountType string, accountId uuid.UUID, investments []Investment) error {
+	accountFunds, err := repo.GetAccountFunds(ctx, accountId)
+
+	if err != nil {
+		return fmt.Errorf("unable to fetch account funds: %w", err)
+	}
+
+	held := make(map[uuid.UUID]int64, len(accountFunds))
+
+	for _, accountFund := range accountFunds {
+		held[accountFund.FundId] = accountFund.Id
+	}
+
+	valid := true
+
+	for i := range investments {
+		investment := &investments[i]
+
+		if !investment.Validate() {
+			valid = false
+			continue
+		}
+
+		f, err := catalogue.Fund(ctx, investment.FundId)
+
+		if errors.Is(err, fund.ErrFundNotFound) {
+			investment.Errors["fund_id"] = "Fund does not exist"
+			valid = false
+			continue
+		}
+
+		if err != nil {
+			return fmt.Errorf("unable to fetch fund: %w", err)
+		}
+
+		accountFundId, isHeld := held[investment.FundId]
+
+		if !f.AvailableFor(accountType, isHeld) {
+			investment.Errors["fund_id"] = "Fund is not available for this account"
+			valid = false
+			continue
+		}
+
+		investment.AccountFundId = accountFundId
+	}
+
+	if !valid {
+		return ErrInvestmentInvalid
+	}
+
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/jameswhoughton/cushon/internal/account"
 )
 
@@ -65,3 +66,59 @@ func TestTransactionFilterValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestInvestmentValidation(t *testing.T) {
+	type testCase struct {
+		name           string
+		investment     account.Investment
+		isValid        bool
+		expectedErrors []string
+	}
+
+	cases := []testCase{
+		{
+			name:           "Fund missing",
+			investment:     account.Investment{Amount: 100},
+			isValid:        false,
+			expectedErrors: []string{"fund_id"},
+		},
+		{
+			name:           "Zero amount",
+			investment:     account.Investment{FundId: uuid.New(), Amount: 0},
+			isValid:        false,
+			expectedErrors: []string{"amount"},
+		},
+		{
+			name:           "Negative amount would be a sale",
+			investment:     account.Investment{FundId: uuid.New(), Amount: -100},
+			isValid:        false,
+			expectedErrors: []string{"amount"},
+		},
+		{
+			name:           "Valid investment",
+			investment:     account.Investment{FundId: uuid.New(), Amount: 100},
+			isValid:        true,
+			expectedErrors: []string{},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			isValid := testCase.investment.Validate()
+
+			if isValid != testCase.isValid {
+				t.Errorf("Expected Validate to return %t, got %t", testCase.isValid, isValid)
+			}
+
+			if len(testCase.investment.Errors) != len(testCase.expectedErrors) {
+				t.Errorf("Expected %d validation errors, got %d", len(testCase.expectedErrors), len(testCase.investment.Errors))
+			}
+
+			for _, field := range testCase.expectedErrors {
+				if _, ok := testCase.investment.Errors[field]; !ok {
+					t.Errorf("expected validation error field '%s' missing", field)
+				}
+			}
+		})
+	}
+}
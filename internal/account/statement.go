@@ -0,0 +1,503 @@
+package account
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/internal/fund"
+	"github.com/jameswhoughton/cushon/internal/pdf"
+)
+
+const (
+	STATEMENT_FORMAT_PDF string = "pdf"
+	STATEMENT_FORMAT_CSV string = "csv"
+)
+
+var ErrStatementNotFound = errors.New("Statement not found")
+
+// Units of a fund held at the start or end of a statement
+//
+// The value is in pence at the fund's price on the day.
+type StatementHolding struct {
+	FundId uuid.UUID
+	Units  int
+	Value  int
+}
+
+// Everything that happened in an account over a tax year
+//
+// From and To are the first and last days of the tax year. The opening
+// holdings and cash are at the end of the day before From, the closing at
+// the end of To. Subscriptions is the amount paid in by the customer that
+// counts towards the allowance, Limits are the subscription limits that
+// applied in the tax year (zero if unknown).
+type Statement struct {
+	AccountId       uuid.UUID
+	TaxYear         int
+	From            time.Time
+	To              time.Time
+	FundNames       map[uuid.UUID]string
+	OpeningHoldings []StatementHolding
+	OpeningCash     int
+	Transactions    []Transaction
+	Subscriptions   int
+	Limits          SubscriptionLimits
+	Fees            []FeeCharge
+	TotalFees       int
+	ClosingHoldings []StatementHolding
+	ClosingCash     int
+}
+
+// Returns the tax year as it is usually written (e.g. 2024-25)
+func TaxYearLabel(year int) string {
+	return fmt.Sprintf("%d-%02d", year, (year+1)%100)
+}
+
+// Returns the total value of the holdings and cash at the start of the statement
+func (s Statement) OpeningValue() int {
+	return totalValue(s.OpeningHoldings, s.OpeningCash)
+}
+
+// Returns the total value of the holdings and cash at the end of the statement
+func (s Statement) ClosingValue() int {
+	return totalValue(s.ClosingHoldings, s.ClosingCash)
+}
+
+func totalValue(holdings []StatementHolding, cash int) int {
+	for _, holding := range holdings {
+		cash += holding.Value
+	}
+
+	return cash
+}
+
+func (s Statement) fundName(fundId uuid.UUID) string {
+	if name, ok := s.FundNames[fundId]; ok {
+		return name
+	}
+
+	return fundId.String()
+}
+
+// Render the statement as CSV
+//
+// Every row has the same columns, the section column says what the row
+// represents. Amounts are in pence, holdings are shown at their value and
+// fees are negative.
+func (s Statement) CSV() ([]byte, error) {
+	var buf bytes.Buffer
+
+	w := csv.NewWriter(&buf)
+
+	rows := [][]string{{"section", "date", "fund_id", "fund_name", "transaction_type", "amount"}}
+
+	for _, holding := range s.OpeningHoldings {
+		rows = append(rows, []string{"opening", s.From.Format(time.DateOnly), holding.FundId.String(), s.fundName(holding.FundId), "", strconv.Itoa(holding.Value)})
+	}
+
+	rows = append(rows, []string{"opening_cash", s.From.Format(time.DateOnly), "", "", "", strconv.Itoa(s.OpeningCash)})
+
+	for _, transaction := range s.Transactions {
+		rows = append(rows, []string{"transaction", transaction.CreatedAt.Format(time.DateOnly), transaction.FundId.String(), s.fundName(transaction.FundId), transaction.TransactionType, strconv.Itoa(transaction.Amount)})
+	}
+
+	for _, charge := range s.Fees {
+		rows = append(rows, []string{"fee", charge.Period.Format(time.DateOnly), "", "", TRANSACTION_TYPE_FEE, strconv.Itoa(-charge.Amount)})
+	}
+
+	for _, holding := range s.ClosingHoldings {
+		rows = append(rows, []string{"closing", s.To.Format(time.DateOnly), holding.FundId.String(), s.fundName(holding.FundId), "", strconv.Itoa(holding.Value)})
+	}
+
+	rows = append(rows, []string{"closing_cash", s.To.Format(time.DateOnly), "", "", "", strconv.Itoa(s.ClosingCash)})
+
+	rows = append(rows, []string{"subscriptions", s.To.Format(time.DateOnly), "", "", "", strconv.Itoa(s.Subscriptions)})
+
+	if s.Limits.OverallLimit > 0 {
+		rows = append(rows, []string{"overall_limit", s.To.Format(time.DateOnly), "", "", "", strconv.Itoa(s.Limits.OverallLimit)})
+	}
+
+	if s.Limits.AnnualLimit > 0 {
+		rows = append(rows, []string{"annual_limit", s.To.Format(time.DateOnly), "", "", "", strconv.Itoa(s.Limits.AnnualLimit)})
+	}
+
+	rows = append(rows, []string{"fees", s.To.Format(time.DateOnly), "", "", "", strconv.Itoa(-s.TotalFees)})
+
+	err := w.WriteAll(rows)
+
+	if err != nil {
+		return []byte{}, fmt.Errorf("Unable to write statement CSV: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Render the statement as a PDF
+func (s Statement) PDF() []byte {
+	doc := pdf.New()
+
+	doc.Heading("Annual statement " + TaxYearLabel(s.TaxYear))
+	doc.Text("Account: " + s.AccountId.String())
+	doc.Text("Period: " + s.From.Format("2 January 2006") + " to " + s.To.Format("2 January 2006"))
+	doc.Blank()
+
+	holdings := func(title string, holdings []StatementHolding, cash int, total int) {
+		doc.Heading(title)
+
+		if len(holdings) == 0 {
+			doc.Text("No holdings")
+		}
+
+		for _, holding := range holdings {
+			doc.Text(s.fundName(holding.FundId) + ": " + formatUnits(holding.Units) + " units, " + formatPence(holding.Value))
+		}
+
+		doc.Text("Cash: " + formatPence(cash))
+		doc.Text("Total value: " + formatPence(total))
+		doc.Blank()
+	}
+
+	holdings("Opening holdings", s.OpeningHoldings, s.OpeningCash, s.OpeningValue())
+
+	doc.Heading("Transactions")
+
+	if len(s.Transactions) == 0 {
+		doc.Text("No transactions")
+	}
+
+	for _, transaction := range s.Transactions {
+		doc.Text(transaction.CreatedAt.Format("02/01/2006") + "  " + transactionDescription(transaction.TransactionType) + "  " + s.fundName(transaction.FundId) + "  " + formatPence(transaction.Amount))
+	}
+
+	doc.Blank()
+	doc.Heading("Fees")
+
+	for _, charge := range s.Fees {
+		doc.Text(charge.Period.Format("January 2006") + " platform fee: " + formatPence(charge.Amount))
+	}
+
+	doc.Text("Total fees: " + formatPence(s.TotalFees))
+	doc.Blank()
+
+	holdings("Closing holdings", s.ClosingHoldings, s.ClosingCash, s.ClosingValue())
+
+	doc.Heading("Allowance")
+	doc.Text("Subscriptions this tax year: " + formatPence(s.Subscriptions))
+
+	if s.Limits.OverallLimit > 0 {
+		doc.Text("ISA allowance for the tax year: " + formatPence(s.Limits.OverallLimit))
+	}
+
+	if s.Limits.AnnualLimit > 0 {
+		doc.Text("Limit for this account for the tax year: " + formatPence(s.Limits.AnnualLimit))
+	}
+
+	return doc.Bytes()
+}
+
+func transactionDescription(transactionType string) string {
+	switch transactionType {
+	case TRANSACTION_TYPE_CUSTOMER:
+		return "Subscription"
+	case TRANSACTION_TYPE_ACCUMULATION:
+		return "Dividend reinvested"
+	case TRANSACTION_TYPE_SWITCH:
+		return "Switch"
+	case TRANSACTION_TYPE_FEE:
+		return "Sale to pay fees"
+	case TRANSACTION_TYPE_PENALTY:
+		return "Government withdrawal charge"
+	default:
+		return transactionType
+	}
+}
+
+// Format units (see UNIT_SCALE) to 4 decimal places, e.g. 12345 is 1.2345
+func formatUnits(units int) string {
+	return fmt.Sprintf("%d.%04d", units/UNIT_SCALE, units%UNIT_SCALE)
+}
+
+// Format an amount in pence as pounds, e.g. -123456 is -£1,234.56
+func formatPence(amount int) string {
+	sign := ""
+
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	pounds := strconv.Itoa(amount / 100)
+
+	for i := len(pounds) - 3; i > 0; i -= 3 {
+		pounds = pounds[:i] + "," + pounds[i:]
+	}
+
+	return fmt.Sprintf("%s£%s.%02d", sign, pounds, amount%100)
+}
+
+// A rendered statement stored for download
+type StoredStatement struct {
+	AccountId uuid.UUID
+	TaxYear   int
+	CSV       []byte
+	PDF       []byte
+	CreatedAt time.Time
+}
+
+// Returns the statement in the given format
+func (s StoredStatement) Format(format string) ([]byte, bool) {
+	switch format {
+	case STATEMENT_FORMAT_PDF:
+		return s.PDF, true
+	case STATEMENT_FORMAT_CSV:
+		return s.CSV, true
+	default:
+		return []byte{}, false
+	}
+}
+
+// Responsible for storing rendered statements, any params passed in are
+// assumed to be valid.
+//
+// Methods should be accessed through the StatementService
+type StatementRepository interface {
+	// Store a statement, replacing any existing statement for the account and tax year
+	SaveStatement(ctx context.Context, statement StoredStatement) error
+
+	// Return the statement for the account and tax year
+	//
+	// Returns ErrStatementNotFound if the statement has not been generated.
+	GetStatement(ctx context.Context, accountId uuid.UUID, taxYear int) (StoredStatement, error)
+
+	// Return every account opened before the date
+	GetAccountsOpenedBefore(ctx context.Context, date time.Time) ([]uuid.UUID, error)
+}
+
+// Summary of a single run of the statement job
+type StatementRunResult struct {
+	Generated int
+	Failed    map[uuid.UUID]error
+}
+
+// Service to generate annual statements
+type StatementService struct {
+	repository     StatementRepository
+	accounts       Repository
+	fees           FeeRepository
+	catalogue      fund.Catalogue
+	prices         PriceSource
+	limits         LimitRegistry
+	startOfTaxYear StartOfTaxYear
+}
+
+func NewStatementService(repository *StatementRepository, accounts *Repository, fees *FeeRepository, catalogue *fund.Catalogue, prices PriceSource, limits LimitRegistry, startOfTaxYear StartOfTaxYear) *StatementService {
+	return &StatementService{
+		repository:     *repository,
+		accounts:       *accounts,
+		fees:           *fees,
+		catalogue:      *catalogue,
+		prices:         prices,
+		limits:         limits,
+		startOfTaxYear: startOfTaxYear,
+	}
+}
+
+// Gather the contents of the statement for the tax year starting in the given year
+//
+// Holdings are valued at the price of each fund on the day, the statement
+// fails if a fund held hasn't been priced by then.
+func (s *StatementService) Statement(ctx context.Context, accountId uuid.UUID, taxYear int) (Statement, error) {
+	start, end := s.startOfTaxYear.Bounds(taxYear)
+
+	statement := Statement{
+		AccountId: accountId,
+		TaxYear:   taxYear,
+		From:      start,
+		To:        end.AddDate(0, 0, -1),
+		FundNames: make(map[uuid.UUID]string),
+	}
+
+	account, err := s.accounts.GetAccount(ctx, accountId)
+
+	if err != nil {
+		return Statement{}, fmt.Errorf("Unable to fetch account: %w", err)
+	}
+
+	statement.Limits, err = s.limits.Limits(ctx, account.AccountType, taxYear)
+
+	// Accounts without subscription limits show none
+	if err != nil && !errors.Is(err, ErrLimitsNotFound) {
+		return Statement{}, fmt.Errorf("Unable to fetch limits: %w", err)
+	}
+
+	statement.OpeningHoldings, statement.OpeningCash, err = s.holdingsAt(ctx, accountId, start.AddDate(0, 0, -1))
+
+	if err != nil {
+		return Statement{}, fmt.Errorf("Unable to value opening holdings: %w", err)
+	}
+
+	statement.Transactions, err = s.accounts.GetAccountTransactions(ctx, accountId, TransactionFilter{StartDate: start, EndDate: end.Add(-time.Nanosecond)})
+
+	if err != nil {
+		return Statement{}, fmt.Errorf("Unable to fetch transactions: %w", err)
+	}
+
+	// Deposits count towards the tax year they were made in, not when they settled
+	statement.Subscriptions, err = s.accounts.GetSubscriptionsBetween(ctx, accountId, start, end)
+
+	if err != nil {
+		return Statement{}, fmt.Errorf("Unable to fetch subscriptions: %w", err)
+	}
+
+	statement.Fees, err = s.fees.GetFeeCharges(ctx, accountId, start, statement.To)
+
+	if err != nil {
+		return Statement{}, fmt.Errorf("Unable to fetch fees: %w", err)
+	}
+
+	for _, charge := range statement.Fees {
+		statement.TotalFees += charge.Amount
+	}
+
+	statement.ClosingHoldings, statement.ClosingCash, err = s.holdingsAt(ctx, accountId, statement.To)
+
+	if err != nil {
+		return Statement{}, fmt.Errorf("Unable to value closing holdings: %w", err)
+	}
+
+	for _, holdings := range [][]StatementHolding{statement.OpeningHoldings, statement.ClosingHoldings} {
+		for _, holding := range holdings {
+			if err := s.addFundName(ctx, statement.FundNames, holding.FundId); err != nil {
+				return Statement{}, err
+			}
+		}
+	}
+
+	for _, transaction := range statement.Transactions {
+		if err := s.addFundName(ctx, statement.FundNames, transaction.FundId); err != nil {
+			return Statement{}, err
+		}
+	}
+
+	return statement, nil
+}
+
+// Return the holdings valued at the end of the date along with the cash balance
+func (s *StatementService) holdingsAt(ctx context.Context, accountId uuid.UUID, date time.Time) ([]StatementHolding, int, error) {
+	accountFunds, err := s.accounts.GetAccountUnitsAt(ctx, accountId, date)
+
+	if err != nil {
+		return []StatementHolding{}, 0, fmt.Errorf("Unable to fetch holdings: %w", err)
+	}
+
+	values, err := valueHoldings(ctx, s.prices, accountFunds, date)
+
+	if err != nil {
+		return []StatementHolding{}, 0, err
+	}
+
+	holdings := make([]StatementHolding, len(accountFunds))
+
+	for i, accountFund := range accountFunds {
+		holdings[i] = StatementHolding{FundId: accountFund.FundId, Units: accountFund.Units, Value: values[i]}
+	}
+
+	cash, err := s.accounts.GetCashBalanceAt(ctx, accountId, date)
+
+	if err != nil {
+		return []StatementHolding{}, 0, fmt.Errorf("Unable to fetch cash balance: %w", err)
+	}
+
+	return holdings, cash, nil
+}
+
+func (s *StatementService) addFundName(ctx context.Context, names map[uuid.UUID]string, fundId uuid.UUID) error {
+	if _, ok := names[fundId]; ok {
+		return nil
+	}
+
+	f, err := s.catalogue.Fund(ctx, fundId)
+
+	// Funds removed from the catalogue are shown by id
+	if errors.Is(err, fund.ErrFundNotFound) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("Unable to fetch fund: %w", err)
+	}
+
+	names[fundId] = f.Name
+
+	return nil
+}
+
+// Generate and store the statement for the tax year starting in the given year
+//
+// Generating a statement again replaces the stored copy.
+func (s *StatementService) GenerateStatement(ctx context.Context, accountId uuid.UUID, taxYear int) (StoredStatement, error) {
+	statement, err := s.Statement(ctx, accountId, taxYear)
+
+	if err != nil {
+		return StoredStatement{}, err
+	}
+
+	stored := StoredStatement{
+		AccountId: accountId,
+		TaxYear:   taxYear,
+		PDF:       statement.PDF(),
+	}
+
+	stored.CSV, err = statement.CSV()
+
+	if err != nil {
+		return StoredStatement{}, err
+	}
+
+	err = s.repository.SaveStatement(ctx, stored)
+
+	if err != nil {
+		return StoredStatement{}, fmt.Errorf("Unable to store statement: %w", err)
+	}
+
+	return stored, nil
+}
+
+// Generate statements for every account open during the tax year
+func (s *StatementService) GenerateStatements(ctx context.Context, taxYear int) (StatementRunResult, error) {
+	result := StatementRunResult{Failed: make(map[uuid.UUID]error)}
+
+	_, end := s.startOfTaxYear.Bounds(taxYear)
+
+	accountIds, err := s.repository.GetAccountsOpenedBefore(ctx, end)
+
+	if err != nil {
+		return result, fmt.Errorf("Unable to fetch accounts: %w", err)
+	}
+
+	for _, accountId := range accountIds {
+		_, err := s.GenerateStatement(ctx, accountId, taxYear)
+
+		if err != nil {
+			result.Failed[accountId] = err
+			continue
+		}
+
+		result.Generated++
+	}
+
+	return result, nil
+}
+
+// Return a previously generated statement
+//
+// Returns ErrStatementNotFound if the statement has not been generated.
+func (s *StatementService) StoredStatement(ctx context.Context, accountId uuid.UUID, taxYear int) (StoredStatement, error) {
+	return s.repository.GetStatement(ctx, accountId, taxYear)
+}
@@ -0,0 +1,149 @@
+package account_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/database"
+	"github.com/jameswhoughton/cushon/internal/account"
+)
+
+func TestStatementCSV(t *testing.T) {
+	fundId := uuid.MustParse("0b6c4d6e-5a77-4a8e-9d43-25f0c9c4e0a1")
+
+	statement := account.Statement{
+		AccountId:       uuid.New(),
+		TaxYear:         2024,
+		From:            time.Date(2024, 4, 6, 0, 0, 0, 0, time.UTC),
+		To:              time.Date(2025, 4, 5, 0, 0, 0, 0, time.UTC),
+		FundNames:       map[uuid.UUID]string{fundId: "Global Equity"},
+		OpeningHoldings: []account.StatementHolding{{FundId: fundId, Units: 10000, Value: 1000}},
+		Transactions: []account.Transaction{
+			{FundId: fundId, TransactionType: account.TRANSACTION_TYPE_CUSTOMER, Amount: 500, CreatedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)},
+		},
+		Subscriptions:   500,
+		Fees:            []account.FeeCharge{{Period: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), Amount: 5}},
+		TotalFees:       5,
+		ClosingHoldings: []account.StatementHolding{{FundId: fundId, Units: 14950, Value: 1794}},
+		ClosingCash:     20,
+	}
+
+	out, err := statement.CSV()
+
+	if err != nil {
+		t.Fatalf("unexpected error rendering CSV: %v", err)
+	}
+
+	expected := "section,date,fund_id,fund_name,transaction_type,amount\n" +
+		"opening,2024-04-06,0b6c4d6e-5a77-4a8e-9d43-25f0c9c4e0a1,Global Equity,,1000\n" +
+		"opening_cash,2024-04-06,,,,0\n" +
+		"transaction,2024-05-01,0b6c4d6e-5a77-4a8e-9d43-25f0c9c4e0a1,Global Equity,cust,500\n" +
+		"fee,2024-05-01,,,fee,-5\n" +
+		"closing,2025-04-05,0b6c4d6e-5a77-4a8e-9d43-25f0c9c4e0a1,Global Equity,,1794\n" +
+		"closing_cash,2025-04-05,,,,20\n" +
+		"subscriptions,2025-04-05,,,,500\n" +
+		"fees,2025-04-05,,,,-5\n"
+
+	if string(out) != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, out)
+	}
+
+	if statement.OpeningValue() != 1000 || statement.ClosingValue() != 1814 {
+		t.Errorf("Expected an opening value of 1000 and closing value of 1814, got %d and %d", statement.OpeningValue(), statement.ClosingValue())
+	}
+
+	if !bytes.HasPrefix(statement.PDF(), []byte("%PDF-")) {
+		t.Errorf("Expected the statement to render as a PDF")
+	}
+}
+
+func TestStatementsAreGeneratedAndStored(t *testing.T) {
+	conn, closeDown := NewTestDatabase()
+	defer closeDown()
+
+	var repo account.Repository = database.NewAccountRepository(conn)
+	var feeRepo account.FeeRepository = database.NewFeeRepository(conn)
+	var statementRepo account.StatementRepository = database.NewStatementRepository(conn)
+
+	passingNiValidator := func(_ context.Context, _ string) error {
+		return nil
+	}
+
+	testFund := NewTestFund()
+	catalogue := NewTestCatalogue(testFund)
+	prices := NewTestPrice(conn, testFund.Id, 100)
+
+	rules := NewTestRules(1000, 0)
+
+	isa := account.NewISAService(&repo, &catalogue, rules, account.ACCOUNT_TYPE_ISA, false, account.StartOfTaxYear{1, 1}, passingNiValidator)
+
+	// Use the calendar year so the transactions made now fall in the statement
+	service := account.NewStatementService(&statementRepo, &repo, &feeRepo, &catalogue, prices, rules, account.StartOfTaxYear{1, 1})
+
+	ctx := context.Background()
+
+	newAccount, err := isa.CreateAccount(ctx, account.Customer{
+		Id:           uuid.New(),
+		TaxResidency: "uk",
+		NINumber:     "AB123456A",
+		DateOfBirth:  time.Now().AddDate(-20, 0, 0),
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error when creating ISA account: %v", err)
+	}
+
+	err = isa.Invest(ctx, newAccount.Id, []account.Investment{
+		{FundId: testFund.Id, TradeId: uuid.New(), TransactionType: account.TRANSACTION_TYPE_CUSTOMER, Amount: 300},
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error when investing in fund: %v", err)
+	}
+
+	taxYear := time.Now().UTC().Year()
+
+	_, err = service.StoredStatement(ctx, newAccount.Id, taxYear)
+
+	if !errors.Is(err, account.ErrStatementNotFound) {
+		t.Errorf("Expected error %v, got %v", account.ErrStatementNotFound, err)
+	}
+
+	statement, err := service.Statement(ctx, newAccount.Id, taxYear)
+
+	if err != nil {
+		t.Fatalf("unexpected error gathering statement: %v", err)
+	}
+
+	if len(statement.OpeningHoldings) != 0 || len(statement.Transactions) != 1 || statement.Subscriptions != 300 {
+		t.Errorf("Expected no opening holdings and a single subscription of 300, got %+v", statement)
+	}
+
+	if len(statement.ClosingHoldings) != 1 || statement.ClosingHoldings[0].Value != 300 {
+		t.Errorf("Expected a closing holding worth 300, got %v", statement.ClosingHoldings)
+	}
+
+	if statement.Limits.OverallLimit != 1000 {
+		t.Errorf("Expected the ISA allowance of 1000, got %d", statement.Limits.OverallLimit)
+	}
+
+	_, err = service.GenerateStatement(ctx, newAccount.Id, taxYear)
+
+	if err != nil {
+		t.Fatalf("unexpected error generating statement: %v", err)
+	}
+
+	stored, err := service.StoredStatement(ctx, newAccount.Id, taxYear)
+
+	if err != nil {
+		t.Fatalf("unexpected error fetching statement: %v", err)
+	}
+
+	if !bytes.HasPrefix(stored.PDF, []byte("%PDF-")) || !bytes.Contains(stored.CSV, []byte(testFund.Name)) {
+		t.Errorf("Expected a stored PDF and CSV naming the fund")
+	}
+}
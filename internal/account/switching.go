@@ -0,0 +1,81 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/internal/fund"
+)
+
+var ErrSwitchInvalid = errors.New("Switch must be a positive amount between two different funds")
+var ErrInsufficientBalance = errors.New("Insufficient balance in fund")
+
+// Generic function to switch money from one fund to another
+//
+// This function is designed to be used across all different account types.
+// The sale and purchase are posted together as TRANSACTION_TYPE_SWITCH so
+// either both happen or neither does, and neither leg counts towards any
+// subscription limits. The fund being bought must be available to the
+// account type, the fund being sold only needs to be held.
+func switchFunds(ctx context.Context, repo Repository, catalogue fund.Catalogue, accountType string, accountId uuid.UUID, from uuid.UUID, to uuid.UUID, amount int) error {
+	if amount <= 0 || from == to {
+		return ErrSwitchInvalid
+	}
+
+	accountFunds, err := repo.GetAccountFunds(ctx, accountId)
+
+	if err != nil {
+		return fmt.Errorf("unable to fetch account funds: %w", err)
+	}
+
+	sale := Investment{
+		FundId:          from,
+		TradeId:         uuid.New(),
+		TransactionType: TRANSACTION_TYPE_SWITCH,
+		Amount:          -amount,
+	}
+
+	for _, accountFund := range accountFunds {
+		if accountFund.FundId == from {
+			sale.AccountFundId = accountFund.Id
+
+			if accountFund.Balance < amount {
+				return ErrInsufficientBalance
+			}
+		}
+	}
+
+	if sale.AccountFundId == 0 {
+		return ErrInsufficientBalance
+	}
+
+	purchase := []Investment{
+		{
+			FundId:          to,
+			TradeId:         uuid.New(),
+			TransactionType: TRANSACTION_TYPE_SWITCH,
+			Amount:          amount,
+		},
+	}
+
+	err = validateInvestments(ctx, repo, catalogue, accountType, accountId, purchase)
+
+	if err != nil {
+		return err
+	}
+
+	// The balance is checked again as the sale is made in case it has changed
+	err = repo.Invest(ctx, accountId, []Investment{sale, purchase[0]})
+
+	if errors.Is(err, ErrInsufficientBalance) {
+		return err
+	}
+
+	if err != nil {
+		return fmt.Errorf("Unable to complete switch: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,262 @@
+package account_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/internal/account"
+)
+
+func TestISAServiceCanSwitchBetweenFundsWithoutUsingAllowance(t *testing.T) {
+	repo, closeDown := NewTestRepository()
+	defer closeDown()
+
+	passingNiValidator := func(_ context.Context, _ string) error {
+		return nil
+	}
+
+	equities := NewTestFund()
+	bonds := NewTestFund()
+	catalogue := NewTestCatalogue(equities, bonds)
+
+	service := account.NewISAService(&repo, &catalogue, NewTestRules(200, 0), account.ACCOUNT_TYPE_ISA, false, account.StartOfTaxYear{1, 1}, passingNiValidator)
+
+	ctx := context.Background()
+
+	customer := account.Customer{
+		Id:           uuid.New(),
+		TaxResidency: "uk",
+		NINumber:     "AB123456A",
+		DateOfBirth:  time.Now().AddDate(-20, 0, 0),
+	}
+
+	newAccount, err := service.CreateAccount(ctx, customer)
+
+	if err != nil {
+		t.Errorf("unexpected error when creating ISA account: %v", err)
+	}
+
+	err = service.Invest(ctx, newAccount.Id, []account.Investment{
+		{
+			FundId:          equities.Id,
+			TradeId:         uuid.New(),
+			TransactionType: account.TRANSACTION_TYPE_CUSTOMER,
+			Amount:          100,
+		},
+	})
+
+	if err != nil {
+		t.Errorf("unexpected error when investing in fund: %v", err)
+	}
+
+	err = service.Switch(ctx, newAccount.Id, equities.Id, bonds.Id, 60)
+
+	if err != nil {
+		t.Errorf("unexpected error when switching funds: %v", err)
+	}
+
+	accountFunds, err := repo.GetAccountFunds(ctx, newAccount.Id)
+
+	if err != nil {
+		t.Errorf("unexpected error fetching account funds: %v", err)
+	}
+
+	expected := map[uuid.UUID]int{equities.Id: 40, bonds.Id: 60}
+
+	for _, accountFund := range accountFunds {
+		if accountFund.Balance != expected[accountFund.FundId] {
+			t.Errorf("Expected fund %s to have a balance of %d, got %d", accountFund.FundId, expected[accountFund.FundId], accountFund.Balance)
+		}
+	}
+
+	totalInvested, err := repo.GetTotalInvestedToDate(ctx, newAccount.Id, time.Now().AddDate(0, 0, -1))
+
+	if err != nil {
+		t.Errorf("unexpected error fetching total invested: %v", err)
+	}
+
+	if totalInvested != 100 {
+		t.Errorf("Expected total invested to be 100, got %d", totalInvested)
+	}
+}
+
+func TestICannotSwitchMoreThanIHoldInAFund(t *testing.T) {
+	repo, closeDown := NewTestRepository()
+	defer closeDown()
+
+	passingNiValidator := func(_ context.Context, _ string) error {
+		return nil
+	}
+
+	equities := NewTestFund()
+	bonds := NewTestFund()
+	catalogue := NewTestCatalogue(equities, bonds)
+
+	service := account.NewISAService(&repo, &catalogue, NewTestRules(200, 0), account.ACCOUNT_TYPE_ISA, false, account.StartOfTaxYear{1, 1}, passingNiValidator)
+
+	ctx := context.Background()
+
+	customer := account.Customer{
+		Id:           uuid.New(),
+		TaxResidency: "uk",
+		NINumber:     "AB123456A",
+		DateOfBirth:  time.Now().AddDate(-20, 0, 0),
+	}
+
+	newAccount, err := service.CreateAccount(ctx, customer)
+
+	if err != nil {
+		t.Errorf("unexpected error when creating ISA account: %v", err)
+	}
+
+	err = service.Invest(ctx, newAccount.Id, []account.Investment{
+		{
+			FundId:          equities.Id,
+			TradeId:         uuid.New(),
+			TransactionType: account.TRANSACTION_TYPE_CUSTOMER,
+			Amount:          100,
+		},
+	})
+
+	if err != nil {
+		t.Errorf("unexpected error when investing in fund: %v", err)
+	}
+
+	type testCase struct {
+		name        string
+		from        uuid.UUID
+		to          uuid.UUID
+		amount      int
+		expectedErr error
+	}
+
+	testCases := []testCase{
+		{name: "More than the balance", from: equities.Id, to: bonds.Id, amount: 101, expectedErr: account.ErrInsufficientBalance},
+		{name: "Fund not held", from: bonds.Id, to: equities.Id, amount: 10, expectedErr: account.ErrInsufficientBalance},
+		{name: "Same fund", from: equities.Id, to: equities.Id, amount: 10, expectedErr: account.ErrSwitchInvalid},
+		{name: "Zero amount", from: equities.Id, to: bonds.Id, amount: 0, expectedErr: account.ErrSwitchInvalid},
+		{name: "Unknown fund", from: equities.Id, to: uuid.New(), amount: 10, expectedErr: account.ErrInvestmentInvalid},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := service.Switch(ctx, newAccount.Id, testCase.from, testCase.to, testCase.amount)
+
+			if !errors.Is(err, testCase.expectedErr) {
+				t.Errorf("Expected error %v, got %v", testCase.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestConcurrentSwitchesCannotOverdrawAFund(t *testing.T) {
+	repo, closeDown := NewTestRepository()
+	defer closeDown()
+
+	passingNiValidator := func(_ context.Context, _ string) error {
+		return nil
+	}
+
+	equities := NewTestFund()
+	bonds := NewTestFund()
+	catalogue := NewTestCatalogue(equities, bonds)
+
+	service := account.NewISAService(&repo, &catalogue, NewTestRules(200, 0), account.ACCOUNT_TYPE_ISA, false, account.StartOfTaxYear{1, 1}, passingNiValidator)
+
+	ctx := context.Background()
+
+	customer := account.Customer{
+		Id:           uuid.New(),
+		TaxResidency: "uk",
+		NINumber:     "AB123456A",
+		DateOfBirth:  time.Now().AddDate(-20, 0, 0),
+	}
+
+	newAccount, err := service.CreateAccount(ctx, customer)
+
+	if err != nil {
+		t.Errorf("unexpected error when creating ISA account: %v", err)
+	}
+
+	err = service.Invest(ctx, newAccount.Id, []account.Investment{
+		{
+			FundId:          equities.Id,
+			TradeId:         uuid.New(),
+			TransactionType: account.TRANSACTION_TYPE_CUSTOMER,
+			Amount:          100,
+		},
+		// Both funds are held so the switches only update existing balances
+		{
+			FundId:          bonds.Id,
+			TradeId:         uuid.New(),
+			TransactionType: account.TRANSACTION_TYPE_CUSTOMER,
+			Amount:          10,
+		},
+	})
+
+	if err != nil {
+		t.Errorf("unexpected error when investing in fund: %v", err)
+	}
+
+	// A sale checked against a balance that has since changed is refused
+	err = repo.Invest(ctx, newAccount.Id, []account.Investment{
+		{
+			FundId:          equities.Id,
+			TradeId:         uuid.New(),
+			TransactionType: account.TRANSACTION_TYPE_SWITCH,
+			Amount:          -101,
+		},
+	})
+
+	if !errors.Is(err, account.ErrInsufficientBalance) {
+		t.Errorf("Expected error %v, got %v", account.ErrInsufficientBalance, err)
+	}
+
+	var wg sync.WaitGroup
+
+	errs := make([]error, 5)
+
+	for i := range errs {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			errs[i] = service.Switch(ctx, newAccount.Id, equities.Id, bonds.Id, 30)
+		}()
+	}
+
+	wg.Wait()
+
+	var switched int
+
+	for _, err := range errs {
+		if err == nil {
+			switched++
+		} else if !errors.Is(err, account.ErrInsufficientBalance) {
+			t.Errorf("unexpected error when switching funds: %v", err)
+		}
+	}
+
+	if switched != 3 {
+		t.Errorf("Expected 3 switches to be made, got %d", switched)
+	}
+
+	accountFunds, err := repo.GetAccountFunds(ctx, newAccount.Id)
+
+	if err != nil {
+		t.Errorf("unexpected error fetching account funds: %v", err)
+	}
+
+	expected := map[uuid.UUID]int{equities.Id: 10, bonds.Id: 100}
+
+	for _, accountFund := range accountFunds {
+		if accountFund.Balance != expected[accountFund.FundId] {
+			t.Errorf("Expected fund %s to have a balance of %d, got %d", accountFund.FundId, expected[accountFund.FundId], accountFund.Balance)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package account_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/internal/account"
+)
+
+var london, _ = time.LoadLocation("Europe/London")
+
+func TestStartOfTaxYearBounds(t *testing.T) {
+	start, end := account.StartOfTaxYear{Day: 6, Month: 4}.Bounds(2024)
+
+	// Midnight on the 6th of April is still the 5th in UTC during British Summer Time
+	if !start.Equal(time.Date(2024, 4, 5, 23, 0, 0, 0, time.UTC)) || !end.Equal(time.Date(2025, 4, 5, 23, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected 2024-04-06 to 2025-04-06 UK time, got %s to %s", start, end)
+	}
+
+	if label := account.TaxYearLabel(2024); label != "2024-25" {
+		t.Errorf("Expected 2024-25, got %s", label)
+	}
+
+	if label := account.TaxYearLabel(2099); label != "2099-00" {
+		t.Errorf("Expected 2099-00, got %s", label)
+	}
+}
+
+func TestStartOfTaxYearYearOf(t *testing.T) {
+	startOfTaxYear := account.StartOfTaxYear{Day: 6, Month: 4}
+
+	type testCase struct {
+		name     string
+		date     time.Time
+		expected int
+	}
+
+	cases := []testCase{
+		{name: "Start of the calendar year", date: time.Date(2025, 1, 1, 0, 0, 0, 0, london), expected: 2024},
+		{name: "Leap day", date: time.Date(2024, 2, 29, 12, 0, 0, 0, london), expected: 2023},
+		{name: "Last second of the tax year", date: time.Date(2025, 4, 5, 23, 59, 59, 0, london), expected: 2024},
+		{name: "First second of the tax year", date: time.Date(2025, 4, 6, 0, 0, 0, 0, london), expected: 2025},
+		{name: "Last second of the tax year in UTC", date: time.Date(2025, 4, 5, 22, 59, 59, 0, time.UTC), expected: 2024},
+		{name: "First second of the tax year in UTC", date: time.Date(2025, 4, 5, 23, 0, 0, 0, time.UTC), expected: 2025},
+		{name: "End of the calendar year", date: time.Date(2025, 12, 31, 23, 59, 59, 0, london), expected: 2025},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if year := startOfTaxYear.YearOf(testCase.date); year != testCase.expected {
+				t.Errorf("Expected %d, got %d", testCase.expected, year)
+			}
+		})
+	}
+}
+
+func TestAllowanceUsed(t *testing.T) {
+	fundId := uuid.New()
+
+	subscription := func(amount int) account.Transaction {
+		return account.Transaction{FundId: fundId, TransactionType: account.TRANSACTION_TYPE_CUSTOMER, Amount: amount}
+	}
+
+	type testCase struct {
+		name                string
+		transactions        []account.Transaction
+		flexible            bool
+		expectedUsed        int
+		expectedReplaceable int
+	}
+
+	cases := []testCase{
+		{
+			name:         "No transactions",
+			transactions: []account.Transaction{},
+			flexible:     true,
+		},
+		{
+			name:         "Replacing a withdrawal counts twice if not flexible",
+			transactions: []account.Transaction{subscription(1000), subscription(-400), subscription(400)},
+			flexible:     false,
+			expectedUsed: 1400,
+		},
+		{
+			name:         "Replacing a withdrawal in a flexible ISA",
+			transactions: []account.Transaction{subscription(1000), subscription(-400), subscription(400)},
+			flexible:     true,
+			expectedUsed: 1000,
+		},
+		{
+			name:                "Partially replacing a withdrawal",
+			transactions:        []account.Transaction{subscription(1000), subscription(-400), subscription(150)},
+			flexible:            true,
+			expectedUsed:        1000,
+			expectedReplaceable: 250,
+		},
+		{
+			name:         "Subscribing more than was withdrawn",
+			transactions: []account.Transaction{subscription(1000), subscription(-400), subscription(600)},
+			flexible:     true,
+			expectedUsed: 1200,
+		},
+		{
+			// e.g. money subscribed in a previous tax year
+			name:                "Withdrawing more than was subscribed this year",
+			transactions:        []account.Transaction{subscription(100), subscription(-500)},
+			flexible:            true,
+			expectedUsed:        100,
+			expectedReplaceable: 500,
+		},
+		{
+			name:         "A subscription can't replace a later withdrawal",
+			transactions: []account.Transaction{subscription(400), subscription(-400)},
+			flexible:     true,
+			expectedUsed: 400,
+			// The withdrawal can still be replaced by a later subscription
+			expectedReplaceable: 400,
+		},
+		{
+			name: "Switches and fees are ignored",
+			transactions: []account.Transaction{
+				subscription(1000),
+				{FundId: fundId, TransactionType: account.TRANSACTION_TYPE_SWITCH, Amount: -500},
+				{FundId: fundId, TransactionType: account.TRANSACTION_TYPE_FEE, Amount: -10},
+				subscription(200),
+			},
+			flexible:     true,
+			expectedUsed: 1200,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			used, replaceable := account.AllowanceUsed(testCase.transactions, testCase.flexible)
+
+			if used != testCase.expectedUsed || replaceable != testCase.expectedReplaceable {
+				t.Errorf("Expected %d used and %d replaceable, got %d and %d", testCase.expectedUsed, testCase.expectedReplaceable, used, replaceable)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package account
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/internal/fund"
+)
+
+// Units of a fund are held to 4 decimal places, e.g. 12345 is 1.2345 units
+const UNIT_SCALE = 10000
+
+// Source of the daily price of each fund (e.g. the fund.PriceService)
+type PriceSource interface {
+	// Return the most recent price for the fund on or before the given date
+	//
+	// Returns fund.ErrPriceNotFound if the fund hasn't been priced by the date.
+	PriceAt(ctx context.Context, fundId uuid.UUID, date time.Time) (fund.Price, error)
+}
+
+// Return the value in pence of each holding at the price of the fund on the date
+//
+// The holdings must have their units calculated (e.g. from GetAccountUnitsAt).
+// Values are rounded to the nearest penny.
+func valueHoldings(ctx context.Context, prices PriceSource, holdings []AccountFund, date time.Time) ([]int, error) {
+	values := make([]int, len(holdings))
+
+	for i, holding := range holdings {
+		price, err := prices.PriceAt(ctx, holding.FundId, date)
+
+		if err != nil {
+			return []int{}, fmt.Errorf("Unable to value fund %s: %w", holding.FundId, err)
+		}
+
+		values[i] = (holding.Units*price.Price + UNIT_SCALE/2) / UNIT_SCALE
+	}
+
+	return values, nil
+}
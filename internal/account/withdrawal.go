@@ -0,0 +1,129 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+var ErrWithdrawalInvalid = errors.New("Withdrawal must be a positive amount from a held fund")
+var ErrWithdrawalNotAllowed = errors.New("Withdrawals are not allowed from this account")
+var ErrWithdrawalReasonInvalid = errors.New("Withdrawal reason invalid or missing")
+
+// Request from the customer to take money out of a fund
+//
+// The reason is only needed by account types that restrict withdrawals (e.g. LISAs).
+type WithdrawalRequest struct {
+	FundId uuid.UUID `json:"fund_id"`
+	Amount int       `json:"amount"`
+	Reason string    `json:"reason"`
+}
+
+// Money taken out of an account by the customer
+//
+// Units to the value of Amount are sold and the Payout is paid to the
+// customer's nominated bank account, less any Penalty. The Sales are the
+// fund transactions posted, a negative TRANSACTION_TYPE_CUSTOMER investment
+// for the payout and a separate TRANSACTION_TYPE_PENALTY investment for any
+// penalty. The Id is used as the payout reference.
+type Withdrawal struct {
+	Id        uuid.UUID    `json:"id"`
+	AccountId uuid.UUID    `json:"account_id"`
+	FundId    uuid.UUID    `json:"fund_id"`
+	Amount    int          `json:"amount"`
+	Reason    string       `json:"reason"`
+	Penalty   int          `json:"penalty"`
+	Payout    int          `json:"payout"`
+	Sales     []Investment `json:"-"`
+}
+
+// Generic function to build a withdrawal from a fund
+//
+// This function is designed to be used across all different account types,
+// the account's Service decides whether the withdrawal is allowed and makes
+// it through Repository.Withdraw. Returns ErrInsufficientBalance if the
+// account doesn't hold enough of the fund.
+func newWithdrawal(ctx context.Context, repo Repository, accountId uuid.UUID, request WithdrawalRequest, penalty int) (Withdrawal, error) {
+	if request.Amount <= 0 || request.FundId == (uuid.UUID{}) || penalty < 0 || penalty > request.Amount {
+		return Withdrawal{}, ErrWithdrawalInvalid
+	}
+
+	accountFunds, err := repo.GetAccountFunds(ctx, accountId)
+
+	if err != nil {
+		return Withdrawal{}, fmt.Errorf("unable to fetch account funds: %w", err)
+	}
+
+	var accountFundId int64
+
+	for _, accountFund := range accountFunds {
+		if accountFund.FundId == request.FundId {
+			accountFundId = accountFund.Id
+
+			if accountFund.Balance < request.Amount {
+				return Withdrawal{}, ErrInsufficientBalance
+			}
+		}
+	}
+
+	if accountFundId == 0 {
+		return Withdrawal{}, ErrInsufficientBalance
+	}
+
+	withdrawal := Withdrawal{
+		Id:        uuid.New(),
+		AccountId: accountId,
+		FundId:    request.FundId,
+		Amount:    request.Amount,
+		Reason:    request.Reason,
+		Penalty:   penalty,
+		Payout:    request.Amount - penalty,
+	}
+
+	if withdrawal.Payout > 0 {
+		withdrawal.Sales = append(withdrawal.Sales, Investment{
+			FundId:          request.FundId,
+			AccountFundId:   accountFundId,
+			TradeId:         uuid.New(),
+			TransactionType: TRANSACTION_TYPE_CUSTOMER,
+			Amount:          -withdrawal.Payout,
+		})
+	}
+
+	if withdrawal.Penalty > 0 {
+		withdrawal.Sales = append(withdrawal.Sales, Investment{
+			FundId:          request.FundId,
+			AccountFundId:   accountFundId,
+			TradeId:         uuid.New(),
+			TransactionType: TRANSACTION_TYPE_PENALTY,
+			Amount:          -withdrawal.Penalty,
+		})
+	}
+
+	return withdrawal, nil
+}
+
+// Generic function to withdraw money from a fund
+//
+// Used by account types that place no restrictions on withdrawals.
+func withdraw(ctx context.Context, repo Repository, accountId uuid.UUID, request WithdrawalRequest) (Withdrawal, error) {
+	withdrawal, err := newWithdrawal(ctx, repo, accountId, request, 0)
+
+	if err != nil {
+		return Withdrawal{}, err
+	}
+
+	err = repo.Withdraw(ctx, withdrawal)
+
+	if errors.Is(err, ErrInsufficientBalance) {
+		return Withdrawal{}, err
+	}
+
+	if err != nil {
+		return Withdrawal{}, fmt.Errorf("Unable to complete withdrawal: %w", err)
+	}
+
+	return withdrawal, nil
+}
@@ -0,0 +1,190 @@
+package account_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/internal/account"
+)
+
+func TestWithdrawalsCanOnlyBeReplacedInAFlexibleISA(t *testing.T) {
+	repo, closeDown := NewTestRepository()
+	defer closeDown()
+
+	passingNiValidator := func(_ context.Context, _ string) error {
+		return nil
+	}
+
+	testFund := NewTestFund()
+	catalogue := NewTestCatalogue(testFund)
+
+	ctx := context.Background()
+
+	for _, flexible := range []bool{true, false} {
+		isa := account.NewISAService(&repo, &catalogue, NewTestRules(1000, 0), account.ACCOUNT_TYPE_ISA, flexible, account.StartOfTaxYear{1, 1}, passingNiValidator)
+
+		newAccount, err := isa.CreateAccount(ctx, account.Customer{
+			Id:           uuid.New(),
+			TaxResidency: "uk",
+			NINumber:     "AB123456A",
+			DateOfBirth:  time.Now().AddDate(-20, 0, 0),
+		})
+
+		if err != nil {
+			t.Fatalf("unexpected error when creating ISA account: %v", err)
+		}
+
+		investment := func(amount int) []account.Investment {
+			return []account.Investment{
+				{
+					FundId:          testFund.Id,
+					TradeId:         uuid.New(),
+					TransactionType: account.TRANSACTION_TYPE_CUSTOMER,
+					Amount:          amount,
+				},
+			}
+		}
+
+		err = isa.Invest(ctx, newAccount.Id, investment(1000))
+
+		if err != nil {
+			t.Fatalf("unexpected error when investing in fund: %v", err)
+		}
+
+		_, err = isa.Withdraw(ctx, newAccount.Id, account.WithdrawalRequest{FundId: testFund.Id, Amount: 1001})
+
+		if !errors.Is(err, account.ErrInsufficientBalance) {
+			t.Errorf("Expected error %v, got %v", account.ErrInsufficientBalance, err)
+		}
+
+		withdrawal, err := isa.Withdraw(ctx, newAccount.Id, account.WithdrawalRequest{FundId: testFund.Id, Amount: 400})
+
+		if err != nil {
+			t.Fatalf("unexpected error when withdrawing: %v", err)
+		}
+
+		if withdrawal.Payout != 400 {
+			t.Errorf("Expected a payout of 400, got %d", withdrawal.Payout)
+		}
+
+		err = isa.Invest(ctx, newAccount.Id, investment(400))
+
+		if flexible && err != nil {
+			t.Errorf("unexpected error replacing a withdrawal in a flexible ISA: %v", err)
+		}
+
+		if !flexible && !errors.Is(err, account.ErrExceededISALimit) {
+			t.Errorf("Expected error %v, got %v", account.ErrExceededISALimit, err)
+		}
+
+		// Once replaced the allowance is used up again
+		err = isa.Invest(ctx, newAccount.Id, investment(1))
+
+		if !errors.Is(err, account.ErrExceededISALimit) {
+			t.Errorf("Expected error %v, got %v", account.ErrExceededISALimit, err)
+		}
+	}
+}
+
+func TestUnauthorisedLISAWithdrawalsRecordAPenalty(t *testing.T) {
+	repo, closeDown := NewTestRepository()
+	defer closeDown()
+
+	passingNiValidator := func(_ context.Context, _ string) error {
+		return nil
+	}
+
+	customer := account.Customer{
+		Id:           uuid.New(),
+		TaxResidency: "uk",
+		NINumber:     "AB123456A",
+		DateOfBirth:  time.Now().AddDate(-30, 0, 0),
+	}
+
+	customers := account.NewStubCustomerClient(customer)
+
+	testFund := NewTestFund()
+	testFund.AccountTypes = append(testFund.AccountTypes, account.ACCOUNT_TYPE_LISA)
+	catalogue := NewTestCatalogue(testFund)
+
+	lisa := account.NewLISAService(&repo, &catalogue, NewTestRules(2000, 1000), account.StartOfTaxYear{1, 1}, passingNiValidator, customers)
+
+	ctx := context.Background()
+
+	newAccount, err := lisa.CreateAccount(ctx, customer)
+
+	if err != nil {
+		t.Fatalf("unexpected error when creating LISA account: %v", err)
+	}
+
+	err = lisa.Invest(ctx, newAccount.Id, []account.Investment{
+		{
+			FundId:          testFund.Id,
+			TradeId:         uuid.New(),
+			TransactionType: account.TRANSACTION_TYPE_CUSTOMER,
+			Amount:          1000,
+		},
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error when investing in fund: %v", err)
+	}
+
+	_, err = lisa.Withdraw(ctx, newAccount.Id, account.WithdrawalRequest{FundId: testFund.Id, Amount: 400})
+
+	if !errors.Is(err, account.ErrWithdrawalReasonInvalid) {
+		t.Errorf("Expected error %v, got %v", account.ErrWithdrawalReasonInvalid, err)
+	}
+
+	_, err = lisa.Withdraw(ctx, newAccount.Id, account.WithdrawalRequest{FundId: testFund.Id, Amount: 400, Reason: account.LISA_WITHDRAWAL_REASON_AGE_60})
+
+	if !errors.Is(err, account.ErrLISAWithdrawalUnder60) {
+		t.Errorf("Expected error %v, got %v", account.ErrLISAWithdrawalUnder60, err)
+	}
+
+	request := account.WithdrawalRequest{FundId: testFund.Id, Amount: 400, Reason: account.LISA_WITHDRAWAL_REASON_OTHER}
+
+	quote, err := lisa.QuoteWithdrawal(ctx, newAccount.Id, request)
+
+	if err != nil {
+		t.Fatalf("unexpected error when quoting withdrawal: %v", err)
+	}
+
+	if quote.Penalty != 100 || quote.Payout != 300 {
+		t.Errorf("Expected a quote with penalty 100 and payout 300, got %d and %d", quote.Penalty, quote.Payout)
+	}
+
+	withdrawal, err := lisa.Withdraw(ctx, newAccount.Id, request)
+
+	if err != nil {
+		t.Fatalf("unexpected error when withdrawing: %v", err)
+	}
+
+	if withdrawal.Penalty != quote.Penalty || withdrawal.Payout != quote.Payout {
+		t.Errorf("Expected the withdrawal to match the quote, got penalty %d and payout %d", withdrawal.Penalty, withdrawal.Payout)
+	}
+
+	transactions, err := lisa.AccountTransactions(ctx, newAccount.Id, account.TransactionFilter{
+		StartDate: time.Now().AddDate(0, 0, -1),
+		EndDate:   time.Now().AddDate(0, 0, 1),
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error when fetching transactions: %v", err)
+	}
+
+	var penalties []account.Transaction
+
+	for _, transaction := range transactions {
+		if transaction.TransactionType == account.TRANSACTION_TYPE_PENALTY {
+			penalties = append(penalties, transaction)
+		}
+	}
+
+	if len(penalties) != 1 || penalties[0].Amount != -100 {
+		t.Errorf("Expected a single penalty transaction of -100, got %v", penalties)
+	}
+}
@@ -0,0 +1,125 @@
+// Set up shared by the retail account service and its batch jobs
+//
+// Configuration is read from the environment so both binaries are configured
+// the same way.
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/database"
+	"github.com/jameswhoughton/cushon/internal/account"
+	"github.com/jameswhoughton/cushon/internal/fund"
+	"github.com/jameswhoughton/cushon/internal/nivalidation"
+)
+
+// The UK tax year starts on the 6th of April
+var START_OF_TAX_YEAR = account.StartOfTaxYear{Day: 6, Month: 4}
+
+// How long customers fetched from the retail customer service are cached
+const CUSTOMER_CACHE_TTL = time.Minute
+
+// Client for the NI validation service at NI_VALIDATION_URL
+//
+// If the URL isn't set every check fails as unavailable rather than passing.
+func NewNIValidator() *nivalidation.Client {
+	return nivalidation.NewClient(os.Getenv("NI_VALIDATION_URL"), http.DefaultClient, nivalidation.Config{})
+}
+
+// Client for the retail customer service at RETAIL_CUSTOMER_SERVICE_URL
+func NewCustomerClient() account.CustomerClient {
+	client := account.NewHTTPCustomerClient(os.Getenv("RETAIL_CUSTOMER_SERVICE_URL"), &http.Client{Timeout: 5 * time.Second})
+
+	return account.NewCachedCustomerClient(client, CUSTOMER_CACHE_TTL)
+}
+
+// Products offered along with their eligibility and subscription rules
+//
+// The rules shipped with the service are used unless ACCOUNT_RULES_FILE is set.
+func NewRulesService() (*account.RulesService, error) {
+	rules := account.DefaultRules()
+
+	if path := os.Getenv("ACCOUNT_RULES_FILE"); path != "" {
+		var err error
+
+		rules, err = account.LoadRules(path)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return account.NewRulesService(rules)
+}
+
+// Create a service for every product in the rules
+func NewServiceFactory(conn *sql.DB, rules *account.RulesService, customers account.CustomerClient) (*account.ServiceFactory, error) {
+	var repo account.Repository = database.NewAccountRepository(conn)
+	var catalogue fund.Catalogue = database.NewFundRepository(conn)
+
+	services := rules.Services(account.ServiceDependencies{
+		Repository:     &repo,
+		Catalogue:      &catalogue,
+		StartOfTaxYear: START_OF_TAX_YEAR,
+		NIValidator:    NewNIValidator().Validate,
+		Customers:      customers,
+	})
+
+	return account.NewServiceFactory(&repo, rules, services...)
+}
+
+// Client for the payments service at PAYMENTS_SERVICE_URL
+//
+// Returns an error if the URL isn't set, so anything taking payments refuses
+// to start rather than failing every deposit.
+func NewPaymentsClient() (account.PaymentsClient, error) {
+	url := os.Getenv("PAYMENTS_SERVICE_URL")
+
+	if url == "" {
+		return nil, errors.New("PAYMENTS_SERVICE_URL is required")
+	}
+
+	return account.NewHTTPPaymentsClient(url, &http.Client{Timeout: 10 * time.Second}), nil
+}
+
+// Create the deposit service, taking payments through the payments service
+func NewDepositService(conn *sql.DB, serviceFactory *account.ServiceFactory) (*account.DepositService, error) {
+	var repo account.DepositRepository = database.NewDepositRepository(conn)
+	var catalogue fund.Catalogue = database.NewFundRepository(conn)
+
+	payments, err := NewPaymentsClient()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return account.NewDepositService(&repo, serviceFactory, &catalogue, payments), nil
+}
+
+// Create the price service used to look up prices and value holdings
+//
+// Prices are only looked up, so the maximum age used when ingesting them
+// doesn't apply.
+func NewPriceService(conn *sql.DB) *fund.PriceService {
+	var repo fund.PriceRepository = database.NewPriceRepository(conn)
+
+	return fund.NewPriceService(&repo, 0)
+}
+
+// Notifier that writes notifications to the log
+//
+// This stands in for a client for the notification service.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(_ context.Context, customerId uuid.UUID, message string) error {
+	log.Printf("notify customer %s: %s", customerId, message)
+
+	return nil
+}
@@ -0,0 +1,183 @@
+package customer
+
+import (
+	"context"
+	"errors"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/internal/nivalidation"
+)
+
+var ErrCustomerInvalid = errors.New("Customer invalid")
+var ErrCustomerNotFound = errors.New("Customer not found")
+var ErrNINumberInUse = errors.New("NI number already registered to another customer")
+
+// Longest value accepted for a free text field
+const maxFieldLength = 255
+
+// Lower case ISO 3166 alpha-2 code, with the UK as 'uk' to match the account service
+var countryCode = regexp.MustCompile(`^[a-z]{2}$`)
+
+// Digits optionally preceded by a +, spaces are allowed between digits
+var phoneNumber = regexp.MustCompile(`^\+?[0-9][0-9 ]{5,18}[0-9]$`)
+
+// UK postcode, e.g. SW1A 1AA
+var ukPostcode = regexp.MustCompile(`^[A-Z]{1,2}[0-9][A-Z0-9]? ?[0-9][A-Z]{2}$`)
+
+type Address struct {
+	Line1    string `json:"line_1"`
+	Line2    string `json:"line_2"`
+	Town     string `json:"town"`
+	Postcode string `json:"postcode"`
+	// Country code, see countryCode
+	Country string `json:"country"`
+}
+
+// A retail customer
+//
+// Holds the details needed to on-board a customer. The id, NI number, date
+// of birth and tax residency are the fields consumed by the account service.
+type Customer struct {
+	Id          uuid.UUID `json:"id"`
+	FirstName   string    `json:"first_name"`
+	LastName    string    `json:"last_name"`
+	Address     Address   `json:"address"`
+	Email       string    `json:"email"`
+	PhoneNumber string    `json:"phone_number"`
+	NINumber    string    `json:"ni_number"`
+	DateOfBirth time.Time `json:"date_of_birth"`
+	// Country of tax residency, see countryCode
+	TaxResidency string            `json:"tax_residency"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+	Errors       map[string]string `json:"errors"`
+}
+
+// Tidy up the customer's details before they are validated and stored
+//
+// Whitespace is trimmed, codes are lower case and the NI number and
+// postcode are upper case (the NI number also has spaces removed).
+func (c *Customer) Normalise() {
+	c.FirstName = strings.TrimSpace(c.FirstName)
+	c.LastName = strings.TrimSpace(c.LastName)
+	c.Address.Line1 = strings.TrimSpace(c.Address.Line1)
+	c.Address.Line2 = strings.TrimSpace(c.Address.Line2)
+	c.Address.Town = strings.TrimSpace(c.Address.Town)
+	c.Address.Postcode = strings.ToUpper(strings.TrimSpace(c.Address.Postcode))
+	c.Address.Country = strings.ToLower(strings.TrimSpace(c.Address.Country))
+	c.Email = strings.TrimSpace(c.Email)
+	c.PhoneNumber = strings.TrimSpace(c.PhoneNumber)
+	c.NINumber = nivalidation.Normalise(c.NINumber)
+	c.TaxResidency = strings.ToLower(strings.TrimSpace(c.TaxResidency))
+}
+
+// Validate the customer
+//
+// Any errors are stored in a map using the json struct tag
+// so that they can be returned straight back to the UI. Address fields are
+// prefixed with 'address.'.
+func (c *Customer) Validate() bool {
+	if c.Errors == nil {
+		c.Errors = make(map[string]string, 2)
+	}
+
+	required := map[string]string{
+		"first_name":     c.FirstName,
+		"last_name":      c.LastName,
+		"address.line_1": c.Address.Line1,
+		"address.town":   c.Address.Town,
+	}
+
+	for field, value := range required {
+		if value == "" {
+			c.Errors[field] = "Required"
+		}
+	}
+
+	optional := map[string]string{
+		"address.line_2": c.Address.Line2,
+	}
+
+	for field, value := range required {
+		optional[field] = value
+	}
+
+	for field, value := range optional {
+		if utf8.RuneCountInString(value) > maxFieldLength {
+			c.Errors[field] = "Must be 255 characters or fewer"
+		}
+	}
+
+	if !countryCode.MatchString(c.Address.Country) {
+		c.Errors["address.country"] = "Country code invalid or missing"
+	}
+
+	if c.Address.Postcode == "" {
+		c.Errors["address.postcode"] = "Required"
+	} else if c.Address.Country == "uk" && !ukPostcode.MatchString(c.Address.Postcode) {
+		c.Errors["address.postcode"] = "Postcode invalid"
+	} else if len(c.Address.Postcode) > 10 {
+		c.Errors["address.postcode"] = "Postcode invalid"
+	}
+
+	if address, err := mail.ParseAddress(c.Email); err != nil || address.Address != c.Email || len(c.Email) > maxFieldLength {
+		c.Errors["email"] = "Email address invalid or missing"
+	}
+
+	if !phoneNumber.MatchString(c.PhoneNumber) {
+		c.Errors["phone_number"] = "Phone number invalid or missing"
+	}
+
+	if err := nivalidation.CheckFormat(c.NINumber); err != nil {
+		c.Errors["ni_number"] = err.Error()
+	}
+
+	now := time.Now()
+
+	if c.DateOfBirth.IsZero() {
+		c.Errors["date_of_birth"] = "Required"
+	} else if c.DateOfBirth.After(now) || c.DateOfBirth.Before(now.AddDate(-130, 0, 0)) {
+		c.Errors["date_of_birth"] = "Date of birth invalid"
+	}
+
+	if !countryCode.MatchString(c.TaxResidency) {
+		c.Errors["tax_residency"] = "Country code invalid or missing"
+	}
+
+	return len(c.Errors) == 0
+}
+
+// Responsible for storing customers, any params passed in are assumed
+// to be valid.
+//
+// The address, NI number and date of birth are personal data and must be
+// encrypted at rest by the implementation.
+//
+// Methods should be accessed through the Service
+type Repository interface {
+	// Store a new customer, CreatedAt and UpdatedAt are populated
+	//
+	// Returns ErrNINumberInUse if another customer has the NI number.
+	Create(ctx context.Context, customer *Customer) error
+
+	// Return the customer with the id
+	//
+	// Returns ErrCustomerNotFound if the customer does not exist.
+	Get(ctx context.Context, id uuid.UUID) (Customer, error)
+
+	// Return the customer with the normalised NI number
+	//
+	// Returns ErrCustomerNotFound if no customer has the NI number.
+	GetByNINumber(ctx context.Context, niNumber string) (Customer, error)
+
+	// Replace the customer's details, UpdatedAt is populated
+	//
+	// Returns ErrCustomerNotFound if the customer does not exist or
+	// ErrNINumberInUse if another customer has the NI number.
+	Update(ctx context.Context, customer *Customer) error
+}
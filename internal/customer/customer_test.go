@@ -0,0 +1,137 @@
+package customer_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jameswhoughton/cushon/internal/customer"
+)
+
+// Return a customer that passes validation
+func newValidCustomer() customer.Customer {
+	return customer.Customer{
+		FirstName: "Jane",
+		LastName:  "Smith",
+		Address: customer.Address{
+			Line1:    "1 High Street",
+			Town:     "Bristol",
+			Postcode: "BS1 4DJ",
+			Country:  "uk",
+		},
+		Email:        "jane.smith@example.com",
+		PhoneNumber:  "07700 900123",
+		NINumber:     "AB123456A",
+		DateOfBirth:  time.Now().AddDate(-30, 0, 0),
+		TaxResidency: "uk",
+	}
+}
+
+func TestCustomerValidation(t *testing.T) {
+	type testCase struct {
+		name           string
+		modify         func(c *customer.Customer)
+		expectedErrors []string
+	}
+
+	cases := []testCase{
+		{
+			name:           "Valid customer",
+			modify:         func(c *customer.Customer) {},
+			expectedErrors: []string{},
+		},
+		{
+			name: "Names and address missing",
+			modify: func(c *customer.Customer) {
+				c.FirstName = ""
+				c.LastName = ""
+				c.Address = customer.Address{}
+			},
+			expectedErrors: []string{"first_name", "last_name", "address.line_1", "address.town", "address.postcode", "address.country"},
+		},
+		{
+			name:           "Name too long",
+			modify:         func(c *customer.Customer) { c.LastName = strings.Repeat("a", 256) },
+			expectedErrors: []string{"last_name"},
+		},
+		{
+			name:           "UK postcode invalid",
+			modify:         func(c *customer.Customer) { c.Address.Postcode = "12345" },
+			expectedErrors: []string{"address.postcode"},
+		},
+		{
+			name: "Postcode outside the UK",
+			modify: func(c *customer.Customer) {
+				c.Address.Postcode = "75001"
+				c.Address.Country = "fr"
+			},
+			expectedErrors: []string{},
+		},
+		{
+			name: "Contact details invalid",
+			modify: func(c *customer.Customer) {
+				c.Email = "Jane <jane.smith@example.com>"
+				c.PhoneNumber = "call me"
+			},
+			expectedErrors: []string{"email", "phone_number"},
+		},
+		{
+			name:           "NI number prefix not used by HMRC",
+			modify:         func(c *customer.Customer) { c.NINumber = "GB123456A" },
+			expectedErrors: []string{"ni_number"},
+		},
+		{
+			name:           "Date of birth missing",
+			modify:         func(c *customer.Customer) { c.DateOfBirth = time.Time{} },
+			expectedErrors: []string{"date_of_birth"},
+		},
+		{
+			name:           "Date of birth in the future",
+			modify:         func(c *customer.Customer) { c.DateOfBirth = time.Now().AddDate(0, 0, 1) },
+			expectedErrors: []string{"date_of_birth"},
+		},
+		{
+			name:           "Tax residency invalid",
+			modify:         func(c *customer.Customer) { c.TaxResidency = "United Kingdom" },
+			expectedErrors: []string{"tax_residency"},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			c := newValidCustomer()
+			testCase.modify(&c)
+			c.Normalise()
+
+			isValid := c.Validate()
+
+			if isValid != (len(testCase.expectedErrors) == 0) {
+				t.Errorf("Expected Validate to return %t, got %t", len(testCase.expectedErrors) == 0, isValid)
+			}
+
+			if len(c.Errors) != len(testCase.expectedErrors) {
+				t.Fatalf("Expected %d errors, got %v", len(testCase.expectedErrors), c.Errors)
+			}
+
+			for _, field := range testCase.expectedErrors {
+				if _, ok := c.Errors[field]; !ok {
+					t.Errorf("Expected an error for '%s', got %v", field, c.Errors)
+				}
+			}
+		})
+	}
+}
+
+func TestCustomerNormalise(t *testing.T) {
+	c := newValidCustomer()
+	c.FirstName = " Jane "
+	c.NINumber = "ab 12 34 56 a"
+	c.Address.Postcode = "bs1 4dj"
+	c.TaxResidency = "UK"
+
+	c.Normalise()
+
+	if c.FirstName != "Jane" || c.NINumber != "AB123456A" || c.Address.Postcode != "BS1 4DJ" || c.TaxResidency != "uk" {
+		t.Errorf("Expected details to be normalised, got %+v", c)
+	}
+}
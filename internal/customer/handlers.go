@@ -0,0 +1,119 @@
+package customer
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// Create a customer
+// POST /api/v1/customer
+//
+// Returns 201 with the customer, or 422 with the customer and its errors.
+func PostCustomerHandler(service *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var customer Customer
+
+		err := json.NewDecoder(r.Body).Decode(&customer)
+
+		if err != nil {
+			http.Error(w, "Unable to parse request body", http.StatusBadRequest)
+			return
+		}
+
+		customer, err = service.Create(r.Context(), customer)
+
+		if errors.Is(err, ErrCustomerInvalid) {
+			writeJSON(w, http.StatusUnprocessableEntity, customer)
+			return
+		}
+
+		if err != nil {
+			http.Error(w, "Unable to create customer", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, customer)
+	}
+}
+
+// Fetch a customer
+// GET /api/v1/customer/{id}
+func GetCustomerHandler(service *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(r.PathValue("id"))
+
+		if err != nil {
+			http.Error(w, ErrCustomerNotFound.Error(), http.StatusNotFound)
+			return
+		}
+
+		customer, err := service.Get(r.Context(), id)
+
+		if errors.Is(err, ErrCustomerNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		if err != nil {
+			http.Error(w, "Unable to fetch customer", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, customer)
+	}
+}
+
+// Update a customer's details
+// PUT /api/v1/customer/{id}
+//
+// The body replaces every field of the customer. Returns 200 with the
+// customer, or 422 with the customer and its errors.
+func PutCustomerHandler(service *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(r.PathValue("id"))
+
+		if err != nil {
+			http.Error(w, ErrCustomerNotFound.Error(), http.StatusNotFound)
+			return
+		}
+
+		var customer Customer
+
+		err = json.NewDecoder(r.Body).Decode(&customer)
+
+		if err != nil {
+			http.Error(w, "Unable to parse request body", http.StatusBadRequest)
+			return
+		}
+
+		customer.Id = id
+
+		customer, err = service.Update(r.Context(), customer)
+
+		if errors.Is(err, ErrCustomerInvalid) {
+			writeJSON(w, http.StatusUnprocessableEntity, customer)
+			return
+		}
+
+		if errors.Is(err, ErrCustomerNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		if err != nil {
+			http.Error(w, "Unable to update customer", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, customer)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
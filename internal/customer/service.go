@@ -0,0 +1,87 @@
+package customer
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/internal/nivalidation"
+)
+
+// Service to manage retail customers
+type Service struct {
+	repository Repository
+}
+
+func NewService(repository *Repository) *Service {
+	return &Service{repository: *repository}
+}
+
+// Create a new customer
+//
+// The customer is normalised and validated first, if it's invalid
+// ErrCustomerInvalid is returned along with the customer so the errors can
+// be shown. A NI number registered to another customer is reported in the
+// same way.
+func (s *Service) Create(ctx context.Context, customer Customer) (Customer, error) {
+	customer.Id = uuid.New()
+	customer.Normalise()
+
+	if !customer.Validate() {
+		return customer, ErrCustomerInvalid
+	}
+
+	err := s.repository.Create(ctx, &customer)
+
+	if errors.Is(err, ErrNINumberInUse) {
+		customer.Errors["ni_number"] = err.Error()
+
+		return customer, ErrCustomerInvalid
+	}
+
+	if err != nil {
+		return Customer{}, err
+	}
+
+	return customer, nil
+}
+
+// Return the customer with the id
+//
+// Returns ErrCustomerNotFound if the customer does not exist.
+func (s *Service) Get(ctx context.Context, id uuid.UUID) (Customer, error) {
+	return s.repository.Get(ctx, id)
+}
+
+// Return the customer with the NI number
+//
+// Returns ErrCustomerNotFound if no customer has the NI number.
+func (s *Service) GetByNINumber(ctx context.Context, niNumber string) (Customer, error) {
+	return s.repository.GetByNINumber(ctx, nivalidation.Normalise(niNumber))
+}
+
+// Replace the details of an existing customer
+//
+// Follows the same rules as Create, returns ErrCustomerNotFound if the
+// customer does not exist.
+func (s *Service) Update(ctx context.Context, customer Customer) (Customer, error) {
+	customer.Normalise()
+
+	if !customer.Validate() {
+		return customer, ErrCustomerInvalid
+	}
+
+	err := s.repository.Update(ctx, &customer)
+
+	if errors.Is(err, ErrNINumberInUse) {
+		customer.Errors["ni_number"] = err.Error()
+
+		return customer, ErrCustomerInvalid
+	}
+
+	if err != nil {
+		return Customer{}, err
+	}
+
+	return customer, nil
+}
@@ -0,0 +1,351 @@
+package customer_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/database"
+	"github.com/jameswhoughton/cushon/internal/customer"
+	"github.com/jameswhoughton/cushon/internal/encryption"
+)
+
+// Helper function to connect to the customer testing database
+//
+// The connection is returned along with a deferrable closedown function that
+// rolls back the database.
+func NewTestDatabase() (*sql.DB, func()) {
+	conn, err := sql.Open("mysql", "root@tcp(127.0.0.1:8003)/retail_customers?parseTime=true")
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = database.MigrateCustomers(conn)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	closeDown := func() {
+		err := database.RollbackCustomers(conn)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	return conn, closeDown
+}
+
+// Test keys, each key's secret is its id repeated to the key length
+var testKeys = map[string][]byte{
+	"2024": bytes.Repeat([]byte("2024"), encryption.KEY_LENGTH/4),
+	"2025": bytes.Repeat([]byte("2025"), encryption.KEY_LENGTH/4),
+}
+
+// Helper function to create a cipher using the test keys
+func NewTestCipher(currentKey string) *encryption.Cipher {
+	keyring, err := encryption.NewKeyring(currentKey, testKeys, bytes.Repeat([]byte("i"), encryption.KEY_LENGTH))
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return encryption.NewCipher(keyring)
+}
+
+// Helper function to create a service backed by the testing database
+func NewTestService() (*customer.Service, func()) {
+	conn, closeDown := NewTestDatabase()
+
+	var repo customer.Repository = database.NewCustomerRepository(conn, NewTestCipher("2025"))
+
+	return customer.NewService(&repo), closeDown
+}
+
+func TestCustomerCanBeCreatedAndUpdated(t *testing.T) {
+	service, closeDown := NewTestService()
+	defer closeDown()
+
+	ctx := context.Background()
+
+	created, err := service.Create(ctx, newValidCustomer())
+
+	if err != nil {
+		t.Fatalf("unexpected error when creating customer: %v", err)
+	}
+
+	created.Address.Line2 = "Flat 2"
+	created.Email = "jane@example.com"
+
+	_, err = service.Update(ctx, created)
+
+	if err != nil {
+		t.Fatalf("unexpected error when updating customer: %v", err)
+	}
+
+	stored, err := service.Get(ctx, created.Id)
+
+	if err != nil {
+		t.Fatalf("unexpected error when fetching customer: %v", err)
+	}
+
+	if stored.Address.Line2 != "Flat 2" || stored.Email != "jane@example.com" || stored.NINumber != "AB123456A" {
+		t.Errorf("Expected the updated customer, got %+v", stored)
+	}
+
+	if stored.DateOfBirth.Format("2006-01-02") != created.DateOfBirth.Format("2006-01-02") {
+		t.Errorf("Expected date of birth %s, got %s", created.DateOfBirth.Format("2006-01-02"), stored.DateOfBirth.Format("2006-01-02"))
+	}
+}
+
+func TestNINumberCanOnlyBeRegisteredOnce(t *testing.T) {
+	service, closeDown := NewTestService()
+	defer closeDown()
+
+	ctx := context.Background()
+
+	_, err := service.Create(ctx, newValidCustomer())
+
+	if err != nil {
+		t.Fatalf("unexpected error when creating customer: %v", err)
+	}
+
+	duplicate := newValidCustomer()
+	duplicate.NINumber = "ab 12 34 56 a"
+
+	duplicate, err = service.Create(ctx, duplicate)
+
+	if !errors.Is(err, customer.ErrCustomerInvalid) {
+		t.Fatalf("Expected ErrCustomerInvalid, got %v", err)
+	}
+
+	if _, ok := duplicate.Errors["ni_number"]; !ok {
+		t.Errorf("Expected an ni_number error, got %v", duplicate.Errors)
+	}
+}
+
+func TestCustomerPersonalDataIsEncryptedAtRest(t *testing.T) {
+	conn, closeDown := NewTestDatabase()
+	defer closeDown()
+
+	var repo customer.Repository = database.NewCustomerRepository(conn, NewTestCipher("2025"))
+	service := customer.NewService(&repo)
+
+	ctx := context.Background()
+
+	created, err := service.Create(ctx, newValidCustomer())
+
+	if err != nil {
+		t.Fatalf("unexpected error when creating customer: %v", err)
+	}
+
+	var address, niNumber, dateOfBirth string
+
+	err = conn.QueryRowContext(ctx, `
+		SELECT address, ni_number, date_of_birth
+		FROM customers
+		WHERE id = UUID_TO_BIN(?)
+	`, created.Id).Scan(&address, &niNumber, &dateOfBirth)
+
+	if err != nil {
+		t.Fatalf("unexpected error when fetching stored customer: %v", err)
+	}
+
+	for column, value := range map[string]string{"address": address, "ni_number": niNumber, "date_of_birth": dateOfBirth} {
+		if !strings.HasPrefix(value, "2025:") {
+			t.Errorf("Expected %s to be encrypted with key 2025, got %s", column, value)
+		}
+	}
+
+	for _, plaintext := range []string{"BS1 4DJ", "AB123456A", created.DateOfBirth.Format("2006-01-02")} {
+		if strings.Contains(address+niNumber+dateOfBirth, plaintext) {
+			t.Errorf("Expected %s not to be stored in plain text", plaintext)
+		}
+	}
+
+	found, err := service.GetByNINumber(ctx, "ab 12 34 56 a")
+
+	if err != nil {
+		t.Fatalf("unexpected error when finding customer by NI number: %v", err)
+	}
+
+	if found.Id != created.Id || found.Address.Postcode != "BS1 4DJ" {
+		t.Errorf("Expected customer %s, got %+v", created.Id, found)
+	}
+}
+
+func TestCustomerKeysCanBeRotated(t *testing.T) {
+	conn, closeDown := NewTestDatabase()
+	defer closeDown()
+
+	ctx := context.Background()
+
+	var oldRepo customer.Repository = database.NewCustomerRepository(conn, NewTestCipher("2024"))
+
+	created, err := customer.NewService(&oldRepo).Create(ctx, newValidCustomer())
+
+	if err != nil {
+		t.Fatalf("unexpected error when creating customer: %v", err)
+	}
+
+	repo := database.NewCustomerRepository(conn, NewTestCipher("2025"))
+
+	rotated, err := repo.RotateKeys(ctx)
+
+	if err != nil {
+		t.Fatalf("unexpected error when rotating keys: %v", err)
+	}
+
+	if rotated != 1 {
+		t.Errorf("Expected 1 customer to be re-encrypted, got %d", rotated)
+	}
+
+	var niNumber string
+
+	err = conn.QueryRowContext(ctx, "SELECT ni_number FROM customers WHERE id = UUID_TO_BIN(?)", created.Id).Scan(&niNumber)
+
+	if err != nil {
+		t.Fatalf("unexpected error when fetching stored customer: %v", err)
+	}
+
+	if !strings.HasPrefix(niNumber, "2025:") {
+		t.Errorf("Expected the NI number to be encrypted with key 2025, got %s", niNumber)
+	}
+
+	stored, err := repo.Get(ctx, created.Id)
+
+	if err != nil {
+		t.Fatalf("unexpected error when fetching customer: %v", err)
+	}
+
+	if stored.NINumber != "AB123456A" {
+		t.Errorf("Expected NI number AB123456A, got %s", stored.NINumber)
+	}
+
+	// The index key doesn't change so lookups still work
+	if _, err := repo.GetByNINumber(ctx, "AB123456A"); err != nil {
+		t.Errorf("unexpected error when finding customer by NI number: %v", err)
+	}
+}
+
+func TestExistingCustomersAreEncrypted(t *testing.T) {
+	conn, closeDown := NewTestDatabase()
+	defer closeDown()
+
+	ctx := context.Background()
+
+	id := uuid.New()
+
+	// As stored before encryption was added, with the address moved to JSON
+	_, err := conn.ExecContext(ctx, `
+		INSERT INTO customers
+		(id, first_name, last_name, address, email, phone_number, ni_number, date_of_birth, tax_residency)
+		VALUES (UUID_TO_BIN(?), 'Jane', 'Smith', '{"line_1": "1 Street", "line_2": "", "town": "Bristol", "postcode": "BS1 4DJ", "country": "uk"}', 'jane@example.com', '07700900000', 'AB123456A', '1990-01-02', 'uk')
+	`, id)
+
+	if err != nil {
+		t.Fatalf("unexpected error when storing customer: %v", err)
+	}
+
+	repo := database.NewCustomerRepository(conn, NewTestCipher("2025"))
+
+	encrypted, err := repo.EncryptExisting(ctx)
+
+	if err != nil {
+		t.Fatalf("unexpected error when encrypting customers: %v", err)
+	}
+
+	if encrypted != 1 {
+		t.Errorf("Expected 1 customer to be encrypted, got %d", encrypted)
+	}
+
+	stored, err := repo.GetByNINumber(ctx, "AB123456A")
+
+	if err != nil {
+		t.Fatalf("unexpected error when finding customer by NI number: %v", err)
+	}
+
+	if stored.Id != id || stored.Address.Postcode != "BS1 4DJ" || stored.DateOfBirth.Format("2006-01-02") != "1990-01-02" {
+		t.Errorf("Expected the stored customer, got %+v", stored)
+	}
+
+	// Nothing is left to encrypt or rotate
+	if encrypted, err := repo.EncryptExisting(ctx); err != nil || encrypted != 0 {
+		t.Errorf("Expected no customers to be encrypted again, got %d (%v)", encrypted, err)
+	}
+
+	if rotated, err := repo.RotateKeys(ctx); err != nil || rotated != 0 {
+		t.Errorf("Expected no customers to be re-encrypted, got %d (%v)", rotated, err)
+	}
+}
+
+func TestUpdatingAnUnknownCustomerReturnsNotFound(t *testing.T) {
+	service, closeDown := NewTestService()
+	defer closeDown()
+
+	c := newValidCustomer()
+	c.Id = uuid.New()
+
+	_, err := service.Update(context.Background(), c)
+
+	if !errors.Is(err, customer.ErrCustomerNotFound) {
+		t.Errorf("Expected ErrCustomerNotFound, got %v", err)
+	}
+}
+
+func TestCustomerHandlers(t *testing.T) {
+	service, closeDown := NewTestService()
+	defer closeDown()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/customer", customer.PostCustomerHandler(service))
+	mux.HandleFunc("GET /api/v1/customer/{id}", customer.GetCustomerHandler(service))
+
+	body := `{"first_name":"Jane","last_name":"Smith","address":{"line_1":"1 High Street","town":"Bristol","postcode":"BS1 4DJ","country":"uk"},` +
+		`"email":"jane.smith@example.com","phone_number":"07700 900123","ni_number":"AB123456A","date_of_birth":"1990-01-31T00:00:00Z","tax_residency":"uk"}`
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/customer", strings.NewReader(body)))
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var created customer.Customer
+
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatalf("unexpected error decoding response: %v", err)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/customer/"+created.Id.String(), nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/customer/"+uuid.New().String(), nil))
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/customer", strings.NewReader(`{"first_name":"Jane"}`)))
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422, got %d", w.Code)
+	}
+}
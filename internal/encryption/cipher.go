@@ -0,0 +1,156 @@
+// Field level encryption for personal data stored in the database
+//
+// Values are encrypted with AES-256-GCM and stored as '<key id>:<base64>',
+// where the base64 holds the nonce followed by the ciphertext. Keeping the
+// key id with the value allows keys to be rotated without re-encrypting
+// everything at once. Values can't be searched, so a blind index (an HMAC of
+// the value) is stored alongside any field that needs to be looked up.
+package encryption
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrCiphertextInvalid = errors.New("Ciphertext invalid")
+
+// Encrypts and decrypts fields using keys from a KeyProvider
+type Cipher struct {
+	keys KeyProvider
+}
+
+func NewCipher(keys KeyProvider) *Cipher {
+	return &Cipher{keys: keys}
+}
+
+// Encrypt the value with the current key
+//
+// The associated data isn't stored but must match when decrypting. Passing
+// the table, column and row id stops a value being copied to another field.
+func (c *Cipher) Encrypt(ctx context.Context, plaintext, associatedData string) (string, error) {
+	key, err := c.keys.CurrentKey(ctx)
+
+	if err != nil {
+		return "", fmt.Errorf("Unable to fetch current key: %w", err)
+	}
+
+	aead, err := newAEAD(key)
+
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("Unable to generate nonce: %v", err)
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(associatedData))
+
+	return key.Id + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt a value produced by Encrypt, using the key it was encrypted with
+//
+// Returns ErrCiphertextInvalid if the value is malformed, has been tampered
+// with or the associated data doesn't match.
+func (c *Cipher) Decrypt(ctx context.Context, ciphertext, associatedData string) (string, error) {
+	keyId, sealed, err := parse(ciphertext)
+
+	if err != nil {
+		return "", err
+	}
+
+	key, err := c.keys.Key(ctx, keyId)
+
+	if err != nil {
+		return "", fmt.Errorf("Unable to fetch key: %w", err)
+	}
+
+	aead, err := newAEAD(key)
+
+	if err != nil {
+		return "", err
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return "", ErrCiphertextInvalid
+	}
+
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(associatedData))
+
+	if err != nil {
+		return "", ErrCiphertextInvalid
+	}
+
+	return string(plaintext), nil
+}
+
+// Return the id of the key new values are encrypted with
+//
+// Values stored with any other key need to be re-encrypted when keys are
+// rotated.
+func (c *Cipher) CurrentKeyId(ctx context.Context) (string, error) {
+	key, err := c.keys.CurrentKey(ctx)
+
+	if err != nil {
+		return "", fmt.Errorf("Unable to fetch current key: %w", err)
+	}
+
+	return key.Id, nil
+}
+
+// Return a deterministic, hex encoded HMAC-SHA256 of the value
+//
+// The same value always gives the same index so it can be used for lookups
+// and unique constraints without storing the value. Values should be
+// normalised first.
+func (c *Cipher) BlindIndex(ctx context.Context, value string) (string, error) {
+	indexKey, err := c.keys.IndexKey(ctx)
+
+	if err != nil {
+		return "", fmt.Errorf("Unable to fetch index key: %w", err)
+	}
+
+	mac := hmac.New(sha256.New, indexKey)
+	mac.Write([]byte(value))
+
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+func newAEAD(key Key) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key.Secret)
+
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create cipher for key '%s': %v", key.Id, err)
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// Split a stored value into its key id and the nonce and ciphertext
+func parse(ciphertext string) (string, []byte, error) {
+	keyId, encoded, ok := strings.Cut(ciphertext, ":")
+
+	if !ok || keyId == "" {
+		return "", nil, ErrCiphertextInvalid
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+
+	if err != nil {
+		return "", nil, ErrCiphertextInvalid
+	}
+
+	return keyId, sealed, nil
+}
@@ -0,0 +1,218 @@
+package encryption_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jameswhoughton/cushon/internal/encryption"
+)
+
+var oldKey = bytes.Repeat([]byte("o"), encryption.KEY_LENGTH)
+var newKey = bytes.Repeat([]byte("n"), encryption.KEY_LENGTH)
+var indexKey = bytes.Repeat([]byte("i"), encryption.KEY_LENGTH)
+
+func newCipher(t *testing.T, current string) *encryption.Cipher {
+	keyring, err := encryption.NewKeyring(current, map[string][]byte{"old": oldKey, "new": newKey}, indexKey)
+
+	if err != nil {
+		t.Fatalf("unexpected error creating keyring: %v", err)
+	}
+
+	return encryption.NewCipher(keyring)
+}
+
+func TestEncryptedValuesCanBeDecrypted(t *testing.T) {
+	ctx := context.Background()
+	cipher := newCipher(t, "new")
+
+	encrypted, err := cipher.Encrypt(ctx, "AB123456A", "customers.ni_number:1")
+
+	if err != nil {
+		t.Fatalf("unexpected error encrypting: %v", err)
+	}
+
+	if !strings.HasPrefix(encrypted, "new:") || strings.Contains(encrypted, "AB123456A") {
+		t.Errorf("Expected the value to be encrypted with key 'new', got %s", encrypted)
+	}
+
+	again, _ := cipher.Encrypt(ctx, "AB123456A", "customers.ni_number:1")
+
+	if again == encrypted {
+		t.Error("Expected each encryption to use a new nonce")
+	}
+
+	decrypted, err := cipher.Decrypt(ctx, encrypted, "customers.ni_number:1")
+
+	if err != nil {
+		t.Fatalf("unexpected error decrypting: %v", err)
+	}
+
+	if decrypted != "AB123456A" {
+		t.Errorf("Expected AB123456A, got %s", decrypted)
+	}
+}
+
+func TestDecryptRejectsInvalidCiphertext(t *testing.T) {
+	ctx := context.Background()
+	cipher := newCipher(t, "new")
+
+	encrypted, _ := cipher.Encrypt(ctx, "AB123456A", "customers.ni_number:1")
+
+	type testCase struct {
+		name           string
+		ciphertext     string
+		associatedData string
+		expectedErr    error
+	}
+
+	cases := []testCase{
+		{
+			name:           "Associated data differs",
+			ciphertext:     encrypted,
+			associatedData: "customers.ni_number:2",
+			expectedErr:    encryption.ErrCiphertextInvalid,
+		},
+		{
+			name:           "Ciphertext tampered with",
+			ciphertext:     encrypted[:len(encrypted)-4] + "AAA=",
+			associatedData: "customers.ni_number:1",
+			expectedErr:    encryption.ErrCiphertextInvalid,
+		},
+		{
+			name:           "Key id missing",
+			ciphertext:     strings.TrimPrefix(encrypted, "new:"),
+			associatedData: "customers.ni_number:1",
+			expectedErr:    encryption.ErrCiphertextInvalid,
+		},
+		{
+			name:           "Unknown key",
+			ciphertext:     "retired:" + strings.TrimPrefix(encrypted, "new:"),
+			associatedData: "customers.ni_number:1",
+			expectedErr:    encryption.ErrKeyNotFound,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := cipher.Decrypt(ctx, testCase.ciphertext, testCase.associatedData)
+
+			if !errors.Is(err, testCase.expectedErr) {
+				t.Errorf("Expected %v, got %v", testCase.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestValuesEncryptedWithAnOldKeyCanBeDecryptedAfterRotation(t *testing.T) {
+	ctx := context.Background()
+
+	encrypted, err := newCipher(t, "old").Encrypt(ctx, "1990-01-31", "customers.date_of_birth:1")
+
+	if err != nil {
+		t.Fatalf("unexpected error encrypting: %v", err)
+	}
+
+	rotated := newCipher(t, "new")
+
+	decrypted, err := rotated.Decrypt(ctx, encrypted, "customers.date_of_birth:1")
+
+	if err != nil || decrypted != "1990-01-31" {
+		t.Errorf("Expected 1990-01-31, got %s (%v)", decrypted, err)
+	}
+
+	keyId, _ := rotated.CurrentKeyId(ctx)
+
+	if keyId != "new" {
+		t.Errorf("Expected current key 'new', got %s", keyId)
+	}
+}
+
+func TestBlindIndexIsDeterministic(t *testing.T) {
+	ctx := context.Background()
+
+	first, _ := newCipher(t, "old").BlindIndex(ctx, "AB123456A")
+	second, _ := newCipher(t, "new").BlindIndex(ctx, "AB123456A")
+	other, _ := newCipher(t, "new").BlindIndex(ctx, "AB123456B")
+
+	if first != second {
+		t.Errorf("Expected the index to be unaffected by key rotation, got %s and %s", first, second)
+	}
+
+	if first == other {
+		t.Error("Expected different values to have different indexes")
+	}
+
+	if len(first) != 64 {
+		t.Errorf("Expected a 64 character index, got %d", len(first))
+	}
+}
+
+func TestKeyringFile(t *testing.T) {
+	type testCase struct {
+		name        string
+		contents    string
+		expectedErr error
+	}
+
+	// base64 of 32 and 18 'k's
+	validKey := "a2tra2tra2tra2tra2tra2tra2tra2tra2tra2tra2s="
+	shortKey := "a2tra2tra2tra2tra2tra2tr"
+
+	cases := []testCase{
+		{
+			name:     "Valid keyring",
+			contents: `{"current_key": "2025", "keys": {"2024": "` + validKey + `", "2025": "` + validKey + `"}, "index_key": "` + validKey + `"}`,
+		},
+		{
+			name:        "Current key missing",
+			contents:    `{"current_key": "2026", "keys": {"2025": "` + validKey + `"}, "index_key": "` + validKey + `"}`,
+			expectedErr: encryption.ErrKeyringInvalid,
+		},
+		{
+			name:        "Key too short",
+			contents:    `{"current_key": "2025", "keys": {"2025": "` + shortKey + `"}, "index_key": "` + validKey + `"}`,
+			expectedErr: encryption.ErrKeyringInvalid,
+		},
+		{
+			name:        "Index key missing",
+			contents:    `{"current_key": "2025", "keys": {"2025": "` + validKey + `"}}`,
+			expectedErr: encryption.ErrKeyringInvalid,
+		},
+		{
+			name:        "Key id contains separator",
+			contents:    `{"current_key": "a:b", "keys": {"a:b": "` + validKey + `"}, "index_key": "` + validKey + `"}`,
+			expectedErr: encryption.ErrKeyringInvalid,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "keyring.json")
+
+			if err := os.WriteFile(path, []byte(testCase.contents), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			keyring, err := encryption.LoadKeyringFile(path)
+
+			if !errors.Is(err, testCase.expectedErr) {
+				t.Fatalf("Expected %v, got %v", testCase.expectedErr, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			key, err := keyring.CurrentKey(context.Background())
+
+			if err != nil || key.Id != "2025" || len(key.Secret) != encryption.KEY_LENGTH {
+				t.Errorf("Expected current key 2025, got %+v (%v)", key, err)
+			}
+		})
+	}
+}
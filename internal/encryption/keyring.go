@@ -0,0 +1,136 @@
+package encryption
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var ErrKeyNotFound = errors.New("Encryption key not found")
+var ErrKeyringInvalid = errors.New("Keyring invalid")
+
+// Length of AES-256 and blind index keys in bytes
+const KEY_LENGTH = 32
+
+// A data encryption key, the id is stored alongside each value it encrypts
+type Key struct {
+	Id     string
+	Secret []byte
+}
+
+// Source of encryption keys, e.g. a local keyring or a KMS
+//
+// Rotating keys means making a new key current while keeping the old keys
+// available so existing values can still be decrypted.
+type KeyProvider interface {
+	// Return the key new values are encrypted with
+	CurrentKey(ctx context.Context) (Key, error)
+
+	// Return the key with the id
+	//
+	// Returns ErrKeyNotFound if the provider doesn't have the key.
+	Key(ctx context.Context, id string) (Key, error)
+
+	// Return the key used to build blind indexes
+	//
+	// Unlike the encryption keys this must not change, otherwise existing
+	// indexes no longer match.
+	IndexKey(ctx context.Context) ([]byte, error)
+}
+
+// In-memory KeyProvider
+type Keyring struct {
+	current  string
+	keys     map[string][]byte
+	indexKey []byte
+}
+
+// Create a keyring, current is the id of the key to encrypt with
+//
+// Every key must be KEY_LENGTH bytes and key ids must not contain ':'.
+func NewKeyring(current string, keys map[string][]byte, indexKey []byte) (*Keyring, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("%w: current key '%s' is missing", ErrKeyringInvalid, current)
+	}
+
+	for id, secret := range keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("%w: key id '%s' must be set and not contain ':'", ErrKeyringInvalid, id)
+		}
+
+		if len(secret) != KEY_LENGTH {
+			return nil, fmt.Errorf("%w: key '%s' must be %d bytes", ErrKeyringInvalid, id, KEY_LENGTH)
+		}
+	}
+
+	if len(indexKey) != KEY_LENGTH {
+		return nil, fmt.Errorf("%w: index key must be %d bytes", ErrKeyringInvalid, KEY_LENGTH)
+	}
+
+	return &Keyring{current: current, keys: keys, indexKey: indexKey}, nil
+}
+
+// Load a keyring from a JSON file, intended for local development
+//
+// Keys are base64 encoded, e.g.
+//
+//	{"current_key": "2025-01", "keys": {"2024-01": "...", "2025-01": "..."}, "index_key": "..."}
+func LoadKeyringFile(path string) (*Keyring, error) {
+	var file struct {
+		CurrentKey string            `json:"current_key"`
+		Keys       map[string]string `json:"keys"`
+		IndexKey   string            `json:"index_key"`
+	}
+
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read keyring: %v", err)
+	}
+
+	err = json.Unmarshal(data, &file)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrKeyringInvalid, err)
+	}
+
+	keys := make(map[string][]byte, len(file.Keys))
+
+	for id, encoded := range file.Keys {
+		keys[id], err = base64.StdEncoding.DecodeString(encoded)
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: key '%s' is not valid base64", ErrKeyringInvalid, id)
+		}
+	}
+
+	indexKey, err := base64.StdEncoding.DecodeString(file.IndexKey)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: index key is not valid base64", ErrKeyringInvalid)
+	}
+
+	return NewKeyring(file.CurrentKey, keys, indexKey)
+}
+
+func (k *Keyring) CurrentKey(ctx context.Context) (Key, error) {
+	return k.Key(ctx, k.current)
+}
+
+func (k *Keyring) Key(_ context.Context, id string) (Key, error) {
+	secret, ok := k.keys[id]
+
+	if !ok {
+		return Key{}, fmt.Errorf("%w: '%s'", ErrKeyNotFound, id)
+	}
+
+	return Key{Id: id, Secret: secret}, nil
+}
+
+func (k *Keyring) IndexKey(_ context.Context) ([]byte, error) {
+	return k.indexKey, nil
+}
@@ -0,0 +1,66 @@
+package fund
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// The fund is open to all new investments
+	FUND_STATUS_OPEN string = "open"
+	// The fund only accepts new investments from accounts that already hold it
+	FUND_STATUS_SOFT_CLOSED string = "soft-closed"
+	// The fund does not accept any new investments
+	FUND_STATUS_CLOSED string = "closed"
+)
+
+var ErrFundNotFound = errors.New("Fund not found")
+
+// A fund available on the platform
+//
+// RiskRating follows the 1 (lowest) to 7 (highest) synthetic risk scale.
+// AccountTypes lists the account types the fund can be held in.
+type Fund struct {
+	Id           uuid.UUID `json:"id"`
+	Name         string    `json:"name"`
+	Status       string    `json:"status"`
+	RiskRating   int       `json:"risk_rating"`
+	AccountTypes []string  `json:"account_types"`
+}
+
+// Returns true if new money can be invested in the fund from an account of
+// the given type.
+//
+// held should be true if the account already holds the fund, this allows
+// existing holders to continue investing in soft-closed funds.
+func (f Fund) AvailableFor(accountType string, held bool) bool {
+	if !slices.Contains(f.AccountTypes, accountType) {
+		return false
+	}
+
+	switch f.Status {
+	case FUND_STATUS_OPEN:
+		return true
+	case FUND_STATUS_SOFT_CLOSED:
+		return held
+	default:
+		return false
+	}
+}
+
+// Catalogue of the funds available on the platform
+//
+// This could be implemented by a local table or a client for the
+// existing funds service.
+type Catalogue interface {
+	// Add a fund to the catalogue
+	Create(ctx context.Context, fund Fund) error
+
+	// Return the fund with the given id
+	//
+	// Returns ErrFundNotFound if the fund does not exist.
+	Fund(ctx context.Context, id uuid.UUID) (Fund, error)
+}
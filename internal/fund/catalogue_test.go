@@ -0,0 +1,37 @@
+package fund_test
+
+import (
+	"testing"
+
+	"github.com/jameswhoughton/cushon/internal/fund"
+)
+
+func TestFundAvailability(t *testing.T) {
+	type testCase struct {
+		name        string
+		status      string
+		accountType string
+		held        bool
+		available   bool
+	}
+
+	cases := []testCase{
+		{name: "Open fund", status: fund.FUND_STATUS_OPEN, accountType: "isa", available: true},
+		{name: "Open fund not available to account type", status: fund.FUND_STATUS_OPEN, accountType: "lisa", available: false},
+		{name: "Soft-closed fund already held", status: fund.FUND_STATUS_SOFT_CLOSED, accountType: "isa", held: true, available: true},
+		{name: "Soft-closed fund not held", status: fund.FUND_STATUS_SOFT_CLOSED, accountType: "isa", available: false},
+		{name: "Closed fund already held", status: fund.FUND_STATUS_CLOSED, accountType: "isa", held: true, available: false},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			f := fund.Fund{Status: testCase.status, AccountTypes: []string{"isa"}}
+
+			available := f.AvailableFor(testCase.accountType, testCase.held)
+
+			if available != testCase.available {
+				t.Errorf("Expected AvailableFor to return %t, got %t", testCase.available, available)
+			}
+		})
+	}
+}
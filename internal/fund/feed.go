@@ -0,0 +1,192 @@
+package fund
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	FEED_FORMAT_CSV  string = "csv"
+	FEED_FORMAT_JSON string = "json"
+)
+
+var ErrFeedFormatInvalid = errors.New("Feed format invalid")
+
+// Source of daily fund prices
+type Feed interface {
+	// Returns every price in the feed
+	//
+	// Prices are not validated here, that is the responsibility of the PriceService.
+	Prices(ctx context.Context) ([]Price, error)
+}
+
+// Feed backed by a local file
+type FileFeed struct {
+	path   string
+	format string
+}
+
+func NewFileFeed(path string, format string) *FileFeed {
+	return &FileFeed{
+		path:   path,
+		format: format,
+	}
+}
+
+func (f *FileFeed) Prices(ctx context.Context) ([]Price, error) {
+	file, err := os.Open(f.path)
+
+	if err != nil {
+		return []Price{}, fmt.Errorf("FileFeed.Prices: Unable to open feed: %v", err)
+	}
+
+	defer file.Close()
+
+	return parsePrices(file, f.format)
+}
+
+// Feed backed by an HTTP endpoint
+type HTTPFeed struct {
+	url    string
+	format string
+	client *http.Client
+}
+
+func NewHTTPFeed(url string, format string, client *http.Client) *HTTPFeed {
+	return &HTTPFeed{
+		url:    url,
+		format: format,
+		client: client,
+	}
+}
+
+func (f *HTTPFeed) Prices(ctx context.Context) ([]Price, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.url, nil)
+
+	if err != nil {
+		return []Price{}, fmt.Errorf("HTTPFeed.Prices: Unable to create request: %v", err)
+	}
+
+	resp, err := f.client.Do(req)
+
+	if err != nil {
+		return []Price{}, fmt.Errorf("HTTPFeed.Prices: Unable to fetch feed: %v", err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return []Price{}, fmt.Errorf("HTTPFeed.Prices: Unexpected status code %d", resp.StatusCode)
+	}
+
+	return parsePrices(resp.Body, f.format)
+}
+
+func parsePrices(r io.Reader, format string) ([]Price, error) {
+	switch format {
+	case FEED_FORMAT_CSV:
+		return parseCSVPrices(r)
+	case FEED_FORMAT_JSON:
+		return parseJSONPrices(r)
+	default:
+		return []Price{}, ErrFeedFormatInvalid
+	}
+}
+
+// Parse a CSV price feed
+//
+// The feed must have a header row followed by rows of fund_id,date,price
+// where the date is formatted as YYYY-MM-DD and the price is in pence.
+func parseCSVPrices(r io.Reader) ([]Price, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+
+	if err != nil {
+		return []Price{}, fmt.Errorf("Unable to parse CSV feed: %v", err)
+	}
+
+	if len(rows) == 0 {
+		return []Price{}, nil
+	}
+
+	prices := make([]Price, 0, len(rows)-1)
+
+	for i, row := range rows[1:] {
+		if len(row) != 3 {
+			return []Price{}, fmt.Errorf("Unable to parse CSV feed: row %d has %d columns, expected 3", i+2, len(row))
+		}
+
+		price, err := newPrice(row[0], row[1], row[2])
+
+		if err != nil {
+			return []Price{}, fmt.Errorf("Unable to parse CSV feed: row %d: %v", i+2, err)
+		}
+
+		prices = append(prices, price)
+	}
+
+	return prices, nil
+}
+
+// Parse a JSON price feed
+//
+// The feed must be an array of objects with the keys fund_id, date and price
+// where the date is formatted as YYYY-MM-DD and the price is in pence.
+func parseJSONPrices(r io.Reader) ([]Price, error) {
+	var rows []struct {
+		FundId string `json:"fund_id"`
+		Date   string `json:"date"`
+		Price  int    `json:"price"`
+	}
+
+	err := json.NewDecoder(r).Decode(&rows)
+
+	if err != nil {
+		return []Price{}, fmt.Errorf("Unable to parse JSON feed: %v", err)
+	}
+
+	prices := make([]Price, 0, len(rows))
+
+	for i, row := range rows {
+		price, err := newPrice(row.FundId, row.Date, strconv.Itoa(row.Price))
+
+		if err != nil {
+			return []Price{}, fmt.Errorf("Unable to parse JSON feed: item %d: %v", i, err)
+		}
+
+		prices = append(prices, price)
+	}
+
+	return prices, nil
+}
+
+func newPrice(fundId string, date string, price string) (Price, error) {
+	id, err := uuid.Parse(fundId)
+
+	if err != nil {
+		return Price{}, fmt.Errorf("invalid fund_id '%s'", fundId)
+	}
+
+	d, err := time.Parse(PRICE_DATE_FORMAT, date)
+
+	if err != nil {
+		return Price{}, fmt.Errorf("invalid date '%s'", date)
+	}
+
+	p, err := strconv.Atoi(price)
+
+	if err != nil {
+		return Price{}, fmt.Errorf("invalid price '%s'", price)
+	}
+
+	return Price{FundId: id, Date: d, Price: p}, nil
+}
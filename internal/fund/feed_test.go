@@ -0,0 +1,74 @@
+package fund_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jameswhoughton/cushon/internal/fund"
+)
+
+func TestFileFeedParsesCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.csv")
+
+	csv := "fund_id,date,price\n" +
+		"0196fd3c-8e2a-7b0e-9a4e-3c1f8b2d4e5a,2025-05-01,12345\n" +
+		"0196fd3c-8e2a-7b0e-9a4e-3c1f8b2d4e5b,2025-05-01,678\n"
+
+	err := os.WriteFile(path, []byte(csv), 0600)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prices, err := fund.NewFileFeed(path, fund.FEED_FORMAT_CSV).Prices(context.Background())
+
+	if err != nil {
+		t.Fatalf("unexpected error reading feed: %v", err)
+	}
+
+	if len(prices) != 2 {
+		t.Fatalf("Expected 2 prices, got %d", len(prices))
+	}
+
+	if prices[0].Price != 12345 {
+		t.Errorf("Expected price 12345, got %d", prices[0].Price)
+	}
+}
+
+func TestHTTPFeedParsesJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"fund_id": "0196fd3c-8e2a-7b0e-9a4e-3c1f8b2d4e5a", "date": "2025-05-01", "price": 12345}]`))
+	}))
+	defer server.Close()
+
+	prices, err := fund.NewHTTPFeed(server.URL, fund.FEED_FORMAT_JSON, server.Client()).Prices(context.Background())
+
+	if err != nil {
+		t.Fatalf("unexpected error reading feed: %v", err)
+	}
+
+	if len(prices) != 1 {
+		t.Fatalf("Expected 1 price, got %d", len(prices))
+	}
+
+	if prices[0].Date.Format(fund.PRICE_DATE_FORMAT) != "2025-05-01" {
+		t.Errorf("Expected date 2025-05-01, got %s", prices[0].Date.Format(fund.PRICE_DATE_FORMAT))
+	}
+}
+
+func TestHTTPFeedReturnsErrorOnBadStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	_, err := fund.NewHTTPFeed(server.URL, fund.FEED_FORMAT_JSON, server.Client()).Prices(context.Background())
+
+	if err == nil {
+		t.Error("Expected error, got nil")
+	}
+}
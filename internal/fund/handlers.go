@@ -0,0 +1,52 @@
+package fund
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Get the price of a fund
+// GET /api/v1/fund/{id}/price?date=YYYY-MM-DD
+//
+// The date is optional and defaults to today, the most recent price
+// on or before the date is returned.
+func GetFundPriceHandler(service *PriceService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fundId, err := uuid.Parse(r.PathValue("id"))
+
+		if err != nil {
+			http.Error(w, "Fund ID invalid", http.StatusNotFound)
+			return
+		}
+
+		date := time.Now()
+
+		if d := r.URL.Query().Get("date"); d != "" {
+			date, err = time.Parse(PRICE_DATE_FORMAT, d)
+
+			if err != nil {
+				http.Error(w, "Date must be formatted as YYYY-MM-DD", http.StatusUnprocessableEntity)
+				return
+			}
+		}
+
+		price, err := service.PriceAt(r.Context(), fundId, date)
+
+		if errors.Is(err, ErrPriceNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		if err != nil {
+			http.Error(w, "Unable to fetch price", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(price)
+	}
+}
@@ -0,0 +1,172 @@
+package fund
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const PRICE_DATE_FORMAT = "2006-01-02"
+
+var ErrPriceNotFound = errors.New("No price found for fund")
+var ErrPriceDuplicate = errors.New("Price already exists for the fund on this date")
+var ErrPriceStale = errors.New("Price is older than the latest price for the fund")
+
+// Daily price of a single unit of a fund
+//
+// Prices are stored in pence as ints, in line with the rest of
+// the monetary values in the service.
+type Price struct {
+	FundId uuid.UUID         `json:"fund_id"`
+	Date   time.Time         `json:"date"`
+	Price  int               `json:"price"`
+	Errors map[string]string `json:"errors"`
+}
+
+// Validate a Price entity
+//
+// Any errors are stored in a map using the json struct tag
+// so that they can be reported back by the ingestion job.
+func (p *Price) Validate() bool {
+	if p.Errors == nil {
+		p.Errors = make(map[string]string, 3)
+	}
+
+	if p.FundId == (uuid.UUID{}) {
+		p.Errors["fund_id"] = "Fund ID missing"
+	}
+
+	if p.Date.IsZero() {
+		p.Errors["date"] = "Date missing"
+	}
+
+	if p.Price <= 0 {
+		p.Errors["price"] = "Price must be greater than zero"
+	}
+
+	return len(p.Errors) == 0
+}
+
+// Responsible for storing the price history of each fund, any params
+// passed in are assumed to be valid.
+//
+// Methods should be accessed through the PriceService
+type PriceRepository interface {
+	// Store a new price
+	//
+	// Returns ErrPriceDuplicate if a price already exists for the fund on the same date.
+	Create(ctx context.Context, price Price) error
+
+	// Return the most recent price for the fund on or before the given date
+	//
+	// Returns ErrPriceNotFound if there are no prices for the fund on or before the date.
+	PriceAt(ctx context.Context, fundId uuid.UUID, date time.Time) (Price, error)
+}
+
+// A price that was not stored during ingestion along with the reason why
+type RejectedPrice struct {
+	Price  Price
+	Reason string
+}
+
+// Summary of a single ingestion run
+type IngestResult struct {
+	Stored   int
+	Rejected []RejectedPrice
+}
+
+// Service to ingest and look up fund prices
+//
+// Prices are loaded from a Feed, prices that are invalid, duplicated or
+// stale are rejected rather than failing the whole feed. A price is stale
+// if it is older than the latest stored price for the fund, or older
+// than maxAge.
+type PriceService struct {
+	repository PriceRepository
+	maxAge     time.Duration
+}
+
+func NewPriceService(repository *PriceRepository, maxAge time.Duration) *PriceService {
+	return &PriceService{
+		repository: *repository,
+		maxAge:     maxAge,
+	}
+}
+
+// Load all the prices from the feed into the price history
+//
+// Returns an error if the feed cannot be read or the repository fails,
+// individual rejected prices are returned in the IngestResult.
+func (s *PriceService) Ingest(ctx context.Context, feed Feed) (IngestResult, error) {
+	var result IngestResult
+
+	prices, err := feed.Prices(ctx)
+
+	if err != nil {
+		return result, fmt.Errorf("Unable to read price feed: %w", err)
+	}
+
+	cutOff := time.Now().Add(-s.maxAge)
+
+	for _, price := range prices {
+		if !price.Validate() {
+			result.Rejected = append(result.Rejected, RejectedPrice{price, "Price invalid"})
+			continue
+		}
+
+		if price.Date.Before(cutOff) {
+			result.Rejected = append(result.Rejected, RejectedPrice{price, ErrPriceStale.Error()})
+			continue
+		}
+
+		latest, err := s.repository.PriceAt(ctx, price.FundId, price.Date)
+
+		if err != nil && !errors.Is(err, ErrPriceNotFound) {
+			return result, fmt.Errorf("Unable to fetch latest price: %w", err)
+		}
+
+		if err == nil && latest.Date.Equal(price.Date) {
+			result.Rejected = append(result.Rejected, RejectedPrice{price, ErrPriceDuplicate.Error()})
+			continue
+		}
+
+		// The latest price on or before the date will be earlier than the new price,
+		// we also need to make sure there isn't a newer price already stored.
+		newest, err := s.repository.PriceAt(ctx, price.FundId, time.Now())
+
+		if err != nil && !errors.Is(err, ErrPriceNotFound) {
+			return result, fmt.Errorf("Unable to fetch latest price: %w", err)
+		}
+
+		if err == nil && newest.Date.After(price.Date) {
+			result.Rejected = append(result.Rejected, RejectedPrice{price, ErrPriceStale.Error()})
+			continue
+		}
+
+		err = s.repository.Create(ctx, price)
+
+		if errors.Is(err, ErrPriceDuplicate) {
+			result.Rejected = append(result.Rejected, RejectedPrice{price, ErrPriceDuplicate.Error()})
+			continue
+		}
+
+		if err != nil {
+			return result, fmt.Errorf("Unable to store price: %w", err)
+		}
+
+		result.Stored++
+	}
+
+	return result, nil
+}
+
+// Look up the price of a fund on a given date
+//
+// If there is no price for the exact date, the most recent price
+// before it is returned (e.g. for weekends and bank holidays).
+func (s *PriceService) PriceAt(ctx context.Context, fundId uuid.UUID, date time.Time) (Price, error) {
+	return s.repository.PriceAt(ctx, fundId, date)
+}
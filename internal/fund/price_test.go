@@ -0,0 +1,180 @@
+package fund_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jameswhoughton/cushon/internal/fund"
+)
+
+// In-memory implementation of the PriceRepository
+type stubPriceRepository struct {
+	prices []fund.Price
+}
+
+func (r *stubPriceRepository) Create(_ context.Context, price fund.Price) error {
+	for _, p := range r.prices {
+		if p.FundId == price.FundId && p.Date.Equal(price.Date) {
+			return fund.ErrPriceDuplicate
+		}
+	}
+
+	r.prices = append(r.prices, price)
+
+	return nil
+}
+
+func (r *stubPriceRepository) PriceAt(_ context.Context, fundId uuid.UUID, date time.Time) (fund.Price, error) {
+	var latest fund.Price
+
+	for _, p := range r.prices {
+		if p.FundId == fundId && !p.Date.After(date) && p.Date.After(latest.Date) {
+			latest = p
+		}
+	}
+
+	if latest.FundId == (uuid.UUID{}) {
+		return fund.Price{}, fund.ErrPriceNotFound
+	}
+
+	return latest, nil
+}
+
+type stubFeed []fund.Price
+
+func (f stubFeed) Prices(_ context.Context) ([]fund.Price, error) {
+	return f, nil
+}
+
+func TestPriceValidation(t *testing.T) {
+	type testCase struct {
+		name           string
+		price          fund.Price
+		isValid        bool
+		expectedErrors []string
+	}
+
+	cases := []testCase{
+		{
+			name:           "FundID missing",
+			price:          fund.Price{Date: time.Now(), Price: 100},
+			isValid:        false,
+			expectedErrors: []string{"fund_id"},
+		},
+		{
+			name:           "Date missing",
+			price:          fund.Price{FundId: uuid.New(), Price: 100},
+			isValid:        false,
+			expectedErrors: []string{"date"},
+		},
+		{
+			name:           "Price is zero",
+			price:          fund.Price{FundId: uuid.New(), Date: time.Now()},
+			isValid:        false,
+			expectedErrors: []string{"price"},
+		},
+		{
+			name:           "Valid price",
+			price:          fund.Price{FundId: uuid.New(), Date: time.Now(), Price: 100},
+			isValid:        true,
+			expectedErrors: []string{},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			isValid := testCase.price.Validate()
+
+			if isValid != testCase.isValid {
+				t.Errorf("Expected Validate to return %t, got %t", testCase.isValid, isValid)
+			}
+
+			if len(testCase.price.Errors) != len(testCase.expectedErrors) {
+				t.Errorf("Expected %d validation errors, got %d", len(testCase.expectedErrors), len(testCase.price.Errors))
+			}
+
+			for _, field := range testCase.expectedErrors {
+				if _, ok := testCase.price.Errors[field]; !ok {
+					t.Errorf("expected validation error field '%s' missing", field)
+				}
+			}
+		})
+	}
+}
+
+func TestIngestRejectsStaleAndDuplicatePrices(t *testing.T) {
+	var repo fund.PriceRepository = &stubPriceRepository{}
+
+	service := fund.NewPriceService(&repo, 7*24*time.Hour)
+
+	ctx := context.Background()
+
+	fundId := uuid.New()
+	today := time.Now().Truncate(24 * time.Hour)
+
+	feed := stubFeed{
+		{FundId: fundId, Date: today.AddDate(0, 0, -1), Price: 100},
+		// Duplicate of the first price
+		{FundId: fundId, Date: today.AddDate(0, 0, -1), Price: 101},
+		{FundId: fundId, Date: today, Price: 102},
+		// Older than the latest stored price
+		{FundId: fundId, Date: today.AddDate(0, 0, -2), Price: 99},
+		// Older than the maximum age
+		{FundId: uuid.New(), Date: today.AddDate(0, 0, -30), Price: 100},
+		// Invalid
+		{FundId: uuid.New(), Date: today, Price: 0},
+	}
+
+	result, err := service.Ingest(ctx, feed)
+
+	if err != nil {
+		t.Fatalf("unexpected error ingesting prices: %v", err)
+	}
+
+	if result.Stored != 2 {
+		t.Errorf("Expected 2 prices to be stored, got %d", result.Stored)
+	}
+
+	if len(result.Rejected) != 4 {
+		t.Errorf("Expected 4 prices to be rejected, got %d", len(result.Rejected))
+	}
+}
+
+func TestPriceAtReturnsMostRecentPriceOnOrBeforeDate(t *testing.T) {
+	var repo fund.PriceRepository = &stubPriceRepository{}
+
+	service := fund.NewPriceService(&repo, 7*24*time.Hour)
+
+	ctx := context.Background()
+
+	fundId := uuid.New()
+	today := time.Now().Truncate(24 * time.Hour)
+
+	_, err := service.Ingest(ctx, stubFeed{
+		{FundId: fundId, Date: today.AddDate(0, 0, -3), Price: 100},
+		{FundId: fundId, Date: today.AddDate(0, 0, -1), Price: 110},
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error ingesting prices: %v", err)
+	}
+
+	price, err := service.PriceAt(ctx, fundId, today.AddDate(0, 0, -2))
+
+	if err != nil {
+		t.Fatalf("unexpected error fetching price: %v", err)
+	}
+
+	if price.Price != 100 {
+		t.Errorf("Expected price 100, got %d", price.Price)
+	}
+
+	_, err = service.PriceAt(ctx, fundId, today.AddDate(0, 0, -4))
+
+	if !errors.Is(err, fund.ErrPriceNotFound) {
+		t.Errorf("Expected error %v, got %v", fund.ErrPriceNotFound, err)
+	}
+}
@@ -0,0 +1,67 @@
+package nivalidation
+
+import (
+	"sync"
+	"time"
+)
+
+// Circuit breaker around calls to the validation service
+//
+// The breaker opens after threshold consecutive failures and rejects calls
+// until the cooldown has passed. A single trial call is then let through,
+// if it succeeds the breaker closes, otherwise it opens for another cooldown.
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+	failures  int
+	openedAt  time.Time
+	trial     bool
+}
+
+func newBreaker(threshold int, cooldown time.Duration, now func() time.Time) *breaker {
+	return &breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       now,
+	}
+}
+
+// Returns true if a call can be made
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+
+	if b.trial || b.now().Before(b.openedAt.Add(b.cooldown)) {
+		return false
+	}
+
+	b.trial = true
+
+	return true
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.trial = false
+}
+
+func (b *breaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.trial = false
+
+	if b.failures >= b.threshold {
+		b.openedAt = b.now()
+	}
+}
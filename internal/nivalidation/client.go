@@ -0,0 +1,233 @@
+// Client for the NI number validation service
+//
+// Calls are retried with exponential backoff when the service fails or
+// times out, and a circuit breaker stops calls for a while once the service
+// has failed repeatedly. Callers can tell a number that was checked and
+// rejected (ErrInvalid) apart from one that couldn't be checked
+// (ErrUnavailable) using errors.Is.
+package nivalidation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+var ErrInvalid = errors.New("NI number is invalid")
+var ErrUnavailable = errors.New("NI validation service unavailable")
+
+// Client settings, zero values are replaced with the defaults below
+type Config struct {
+	// Timeout for a single attempt (default 2s)
+	Timeout time.Duration
+	// Attempts made before giving up, including the first (default 3)
+	MaxAttempts int
+	// Wait before the first retry, doubled for each retry after (default 100ms)
+	Backoff time.Duration
+	// Longest wait between retries (default 1s)
+	MaxBackoff time.Duration
+	// Consecutive failed calls before the circuit breaker opens (default 5)
+	FailureThreshold int
+	// How long the breaker stays open before a trial call is allowed (default 30s)
+	Cooldown time.Duration
+}
+
+func (c Config) withDefaults() Config {
+	if c.Timeout <= 0 {
+		c.Timeout = 2 * time.Second
+	}
+
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 3
+	}
+
+	if c.Backoff <= 0 {
+		c.Backoff = 100 * time.Millisecond
+	}
+
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = time.Second
+	}
+
+	if c.FailureThreshold <= 0 {
+		c.FailureThreshold = 5
+	}
+
+	if c.Cooldown <= 0 {
+		c.Cooldown = 30 * time.Second
+	}
+
+	return c
+}
+
+// Client for the validation service's HTTP API
+//
+// The service accepts POST {url}/validate with the body {"ni_number": "..."}
+// and responds 200 with {"valid": true} or {"valid": false, "reason": "..."}.
+// A Client is safe for concurrent use.
+type Client struct {
+	url     string
+	client  *http.Client
+	config  Config
+	breaker *breaker
+}
+
+func NewClient(url string, client *http.Client, config Config) *Client {
+	config = config.withDefaults()
+
+	return &Client{
+		url:     url,
+		client:  client,
+		config:  config,
+		breaker: newBreaker(config.FailureThreshold, config.Cooldown, time.Now),
+	}
+}
+
+type validateRequest struct {
+	NINumber string `json:"ni_number"`
+}
+
+type validateResponse struct {
+	Valid  bool   `json:"valid"`
+	Reason string `json:"reason"`
+}
+
+// Check the NI number with the validation service
+//
+// The number is normalised and its format checked first, the service is only
+// called if it passes. Returns an error wrapping ErrInvalid if the number is
+// rejected, or ErrUnavailable if it couldn't be checked (including when the
+// breaker is open).
+func (c *Client) Validate(ctx context.Context, ni string) error {
+	ni = Normalise(ni)
+
+	if err := CheckFormat(ni); err != nil {
+		return err
+	}
+
+	if !c.breaker.allow() {
+		return fmt.Errorf("%w: circuit breaker open", ErrUnavailable)
+	}
+
+	result, err := c.validate(ctx, ni)
+
+	if err != nil {
+		c.breaker.failure()
+
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+
+	c.breaker.success()
+
+	if !result.Valid {
+		if result.Reason == "" {
+			return ErrInvalid
+		}
+
+		return fmt.Errorf("%w: %s", ErrInvalid, result.Reason)
+	}
+
+	return nil
+}
+
+func (c *Client) validate(ctx context.Context, ni string) (validateResponse, error) {
+	body, err := json.Marshal(validateRequest{NINumber: ni})
+
+	if err != nil {
+		return validateResponse{}, fmt.Errorf("Unable to encode request: %v", err)
+	}
+
+	var lastErr error
+
+	for attempt := 1; attempt <= c.config.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			if err := sleep(ctx, c.backoff(attempt)); err != nil {
+				return validateResponse{}, fmt.Errorf("%v (last error: %v)", err, lastErr)
+			}
+		}
+
+		result, retry, err := c.attempt(ctx, body)
+
+		if err == nil {
+			return result, nil
+		}
+
+		lastErr = fmt.Errorf("attempt %d: %v", attempt, err)
+
+		if !retry || ctx.Err() != nil {
+			break
+		}
+	}
+
+	return validateResponse{}, lastErr
+}
+
+// Wait before the given attempt, doubling from the initial backoff
+func (c *Client) backoff(attempt int) time.Duration {
+	wait := c.config.Backoff
+
+	for i := 2; i < attempt && wait < c.config.MaxBackoff; i++ {
+		wait *= 2
+	}
+
+	return min(wait, c.config.MaxBackoff)
+}
+
+// Make a single request, returns whether a failure is worth retrying
+func (c *Client) attempt(ctx context.Context, body []byte) (validateResponse, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+"/validate", bytes.NewReader(body))
+
+	if err != nil {
+		return validateResponse{}, false, fmt.Errorf("Unable to create request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+
+	if err != nil {
+		return validateResponse{}, true, fmt.Errorf("Unable to call service: %v", err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+		io.Copy(io.Discard, resp.Body)
+
+		return validateResponse{}, true, fmt.Errorf("Unexpected status code %d", resp.StatusCode)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return validateResponse{}, false, fmt.Errorf("Unexpected status code %d", resp.StatusCode)
+	}
+
+	var result validateResponse
+
+	err = json.NewDecoder(resp.Body).Decode(&result)
+
+	if err != nil {
+		return validateResponse{}, true, fmt.Errorf("Unable to decode response: %v", err)
+	}
+
+	return result, false, nil
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
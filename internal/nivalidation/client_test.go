@@ -0,0 +1,166 @@
+package nivalidation_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jameswhoughton/cushon/internal/nivalidation"
+)
+
+// Short waits so the tests run quickly
+var testConfig = nivalidation.Config{
+	Timeout:          50 * time.Millisecond,
+	MaxAttempts:      3,
+	Backoff:          time.Millisecond,
+	MaxBackoff:       5 * time.Millisecond,
+	FailureThreshold: 2,
+	Cooldown:         50 * time.Millisecond,
+}
+
+func TestClientValidatesNINumbers(t *testing.T) {
+	server := nivalidation.NewFakeServer()
+	defer server.Close()
+
+	server.Reject("AB123456B", "Number has not been issued")
+
+	client := nivalidation.NewClient(server.URL, server.Client(), testConfig)
+
+	ctx := context.Background()
+
+	if err := client.Validate(ctx, "AB123456A"); err != nil {
+		t.Errorf("unexpected error validating a valid number: %v", err)
+	}
+
+	err := client.Validate(ctx, "AB123456B")
+
+	if !errors.Is(err, nivalidation.ErrInvalid) {
+		t.Errorf("Expected error %v, got %v", nivalidation.ErrInvalid, err)
+	}
+
+	if errors.Is(err, nivalidation.ErrUnavailable) {
+		t.Errorf("Expected an invalid number not to be reported as unavailable, got %v", err)
+	}
+
+	requests := server.Requests()
+
+	// Numbers with the wrong format are rejected without calling the service
+	err = client.Validate(ctx, "ZZ123456A")
+
+	if !errors.Is(err, nivalidation.ErrInvalid) {
+		t.Errorf("Expected error %v, got %v", nivalidation.ErrInvalid, err)
+	}
+
+	if server.Requests() != requests {
+		t.Errorf("Expected no request for a badly formatted number, got %d", server.Requests()-requests)
+	}
+}
+
+func TestClientRetriesFailures(t *testing.T) {
+	type testCase struct {
+		name             string
+		failures         int
+		expectedErr      error
+		expectedRequests int
+	}
+
+	cases := []testCase{
+		{
+			name:             "Succeeds after retrying",
+			failures:         2,
+			expectedErr:      nil,
+			expectedRequests: 3,
+		},
+		{
+			name:             "Unavailable once the attempts are used up",
+			failures:         3,
+			expectedErr:      nivalidation.ErrUnavailable,
+			expectedRequests: 3,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := nivalidation.NewFakeServer()
+			defer server.Close()
+
+			server.FailNext(tc.failures)
+
+			client := nivalidation.NewClient(server.URL, server.Client(), testConfig)
+
+			err := client.Validate(context.Background(), "AB123456A")
+
+			if !errors.Is(err, tc.expectedErr) {
+				t.Errorf("Expected error %v, got %v", tc.expectedErr, err)
+			}
+
+			if server.Requests() != tc.expectedRequests {
+				t.Errorf("Expected %d requests, got %d", tc.expectedRequests, server.Requests())
+			}
+		})
+	}
+}
+
+func TestClientTimesOutSlowResponses(t *testing.T) {
+	server := nivalidation.NewFakeServer()
+	defer server.Close()
+
+	server.SetDelay(500 * time.Millisecond)
+
+	client := nivalidation.NewClient(server.URL, server.Client(), testConfig)
+
+	start := time.Now()
+
+	err := client.Validate(context.Background(), "AB123456A")
+
+	if !errors.Is(err, nivalidation.ErrUnavailable) {
+		t.Errorf("Expected error %v, got %v", nivalidation.ErrUnavailable, err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 400*time.Millisecond {
+		t.Errorf("Expected the call to time out quickly, took %v", elapsed)
+	}
+}
+
+func TestClientCircuitBreaker(t *testing.T) {
+	server := nivalidation.NewFakeServer()
+	defer server.Close()
+
+	client := nivalidation.NewClient(server.URL, server.Client(), testConfig)
+
+	ctx := context.Background()
+
+	// Two failed calls open the breaker
+	server.FailNext(2 * testConfig.MaxAttempts)
+
+	for i := 0; i < 2; i++ {
+		if err := client.Validate(ctx, "AB123456A"); !errors.Is(err, nivalidation.ErrUnavailable) {
+			t.Fatalf("Expected error %v, got %v", nivalidation.ErrUnavailable, err)
+		}
+	}
+
+	requests := server.Requests()
+
+	// While open calls fail without reaching the service
+	err := client.Validate(ctx, "AB123456A")
+
+	if !errors.Is(err, nivalidation.ErrUnavailable) {
+		t.Errorf("Expected error %v, got %v", nivalidation.ErrUnavailable, err)
+	}
+
+	if server.Requests() != requests {
+		t.Errorf("Expected no requests while the breaker is open, got %d", server.Requests()-requests)
+	}
+
+	// After the cooldown a successful trial closes the breaker
+	time.Sleep(testConfig.Cooldown)
+
+	if err := client.Validate(ctx, "AB123456A"); err != nil {
+		t.Errorf("unexpected error after the cooldown: %v", err)
+	}
+
+	if err := client.Validate(ctx, "AB123456A"); err != nil {
+		t.Errorf("unexpected error once the breaker has closed: %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package nivalidation
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+// Local stand-in for the validation service, used in tests
+//
+// Every number is valid unless it has been rejected. The server can be made
+// to fail or respond slowly to exercise the client's retries and breaker.
+type FakeServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	rejected map[string]string
+	failures int
+	delay    time.Duration
+	requests int
+}
+
+func NewFakeServer() *FakeServer {
+	s := &FakeServer{rejected: make(map[string]string)}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+
+	return s
+}
+
+// Treat the number as invalid, the reason is returned in the response
+func (s *FakeServer) Reject(ni string, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rejected[ni] = reason
+}
+
+// Respond to the next n requests with 503 Service Unavailable
+func (s *FakeServer) FailNext(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures = n
+}
+
+// Wait before responding to every request
+func (s *FakeServer) SetDelay(delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.delay = delay
+}
+
+// Returns the number of requests received
+func (s *FakeServer) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests
+}
+
+func (s *FakeServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests++
+	fail := s.failures > 0
+	if fail {
+		s.failures--
+	}
+	delay := s.delay
+	s.mu.Unlock()
+
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if fail {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	if r.Method != http.MethodPost || r.URL.Path != "/validate" {
+		http.NotFound(w, r)
+		return
+	}
+
+	var request validateRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Unable to parse request body", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	reason, rejected := s.rejected[request.NINumber]
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	json.NewEncoder(w).Encode(validateResponse{Valid: !rejected, Reason: reason})
+}
@@ -0,0 +1,53 @@
+package nivalidation
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Two letter prefix, six digits and a suffix of A to D
+var niFormat = regexp.MustCompile(`^[A-Z]{2}[0-9]{6}[A-D]$`)
+
+// Letters HMRC never uses in the prefix
+const (
+	disallowedFirstLetters  = "DFIQUV"
+	disallowedSecondLetters = "DFIOQUV"
+)
+
+// Prefixes HMRC never allocates
+var disallowedPrefixes = []string{"BG", "GB", "KN", "NK", "NT", "TN", "ZZ"}
+
+// Return the NI number in upper case with any spaces removed
+func Normalise(ni string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+
+		return unicode.ToUpper(r)
+	}, ni)
+}
+
+// Check the NI number is structurally valid without calling the service
+//
+// The number is normalised first. Returns an error wrapping ErrInvalid
+// describing the problem if the format is wrong or the prefix is one HMRC
+// doesn't allocate.
+func CheckFormat(ni string) error {
+	ni = Normalise(ni)
+
+	if !niFormat.MatchString(ni) {
+		return fmt.Errorf("%w: must be 2 letters, 6 digits and a letter from A to D", ErrInvalid)
+	}
+
+	prefix := ni[:2]
+
+	if strings.ContainsRune(disallowedFirstLetters, rune(prefix[0])) || strings.ContainsRune(disallowedSecondLetters, rune(prefix[1])) || slices.Contains(disallowedPrefixes, prefix) {
+		return fmt.Errorf("%w: prefix %s is not used by HMRC", ErrInvalid, prefix)
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package nivalidation_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jameswhoughton/cushon/internal/nivalidation"
+)
+
+func TestNormalise(t *testing.T) {
+	cases := map[string]string{
+		"AB123456C":     "AB123456C",
+		"ab 12 34 56 c": "AB123456C",
+		" ab123456c\t":  "AB123456C",
+	}
+
+	for ni, expected := range cases {
+		if normalised := nivalidation.Normalise(ni); normalised != expected {
+			t.Errorf("Expected %q to be normalised to %q, got %q", ni, expected, normalised)
+		}
+	}
+}
+
+func TestCheckFormat(t *testing.T) {
+	type testCase struct {
+		name    string
+		ni      string
+		isValid bool
+	}
+
+	cases := []testCase{
+		{name: "Valid number", ni: "AB123456C", isValid: true},
+		{name: "Valid number with spaces and lower case", ni: "ab 12 34 56 d", isValid: true},
+		{name: "Empty", ni: "", isValid: false},
+		{name: "Too few digits", ni: "AB12345C", isValid: false},
+		{name: "Suffix after D", ni: "AB123456E", isValid: false},
+		{name: "Digit in the prefix", ni: "A1123456C", isValid: false},
+		{name: "Disallowed first letter D", ni: "DA123456C", isValid: false},
+		{name: "Disallowed first letter F", ni: "FA123456C", isValid: false},
+		{name: "Disallowed first letter I", ni: "IA123456C", isValid: false},
+		{name: "Disallowed first letter Q", ni: "QA123456C", isValid: false},
+		{name: "Disallowed first letter U", ni: "UA123456C", isValid: false},
+		{name: "Disallowed first letter V", ni: "VA123456C", isValid: false},
+		{name: "Disallowed second letter D", ni: "AD123456C", isValid: false},
+		{name: "Disallowed second letter O", ni: "AO123456C", isValid: false},
+		{name: "Disallowed second letter V", ni: "AV123456C", isValid: false},
+		{name: "O allowed as the first letter", ni: "OA123456C", isValid: true},
+		{name: "Disallowed prefix BG", ni: "BG123456C", isValid: false},
+		{name: "Disallowed prefix GB", ni: "GB123456C", isValid: false},
+		{name: "Disallowed prefix KN", ni: "KN123456C", isValid: false},
+		{name: "Disallowed prefix NK", ni: "NK123456C", isValid: false},
+		{name: "Disallowed prefix NT", ni: "NT123456C", isValid: false},
+		{name: "Disallowed prefix TN", ni: "TN123456C", isValid: false},
+		{name: "Disallowed prefix ZZ", ni: "ZZ123456C", isValid: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := nivalidation.CheckFormat(tc.ni)
+
+			if tc.isValid && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			if !tc.isValid && !errors.Is(err, nivalidation.ErrInvalid) {
+				t.Errorf("Expected error %v, got %v", nivalidation.ErrInvalid, err)
+			}
+		})
+	}
+}
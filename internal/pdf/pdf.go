@@ -0,0 +1,173 @@
+// Minimal PDF writer for plain text documents
+//
+// Only what is needed for generated documents such as statements is
+// supported: A4 pages of left aligned lines in the standard Helvetica
+// fonts. Text is encoded as WinAnsi so characters outside Latin-1
+// (other than the few mapped below) are replaced with '?'.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const (
+	pageWidth  = 595
+	pageHeight = 842
+	margin     = 50
+)
+
+const (
+	FONT_REGULAR string = "F1"
+	FONT_BOLD    string = "F2"
+)
+
+type line struct {
+	text string
+	font string
+	size int
+}
+
+// A text document made up of lines flowed onto A4 pages
+type Document struct {
+	pages [][]line
+	y     int
+}
+
+func New() *Document {
+	d := &Document{}
+	d.newPage()
+
+	return d
+}
+
+func (d *Document) newPage() {
+	d.pages = append(d.pages, []line{})
+	d.y = pageHeight - margin
+}
+
+// Add a line of text, a new page is started when the current one is full
+func (d *Document) Line(text string, font string, size int) {
+	leading := size + size/2
+
+	if d.y-leading < margin {
+		d.newPage()
+	}
+
+	d.y -= leading
+	d.pages[len(d.pages)-1] = append(d.pages[len(d.pages)-1], line{text: text, font: font, size: size})
+}
+
+// Add a regular 10pt line of text
+func (d *Document) Text(text string) {
+	d.Line(text, FONT_REGULAR, 10)
+}
+
+// Add a bold heading
+func (d *Document) Heading(text string) {
+	d.Line(text, FONT_BOLD, 14)
+}
+
+// Add an empty line
+func (d *Document) Blank() {
+	d.Line("", FONT_REGULAR, 10)
+}
+
+// Render the document as a PDF file
+func (d *Document) Bytes() []byte {
+	var objects []string
+
+	// Object numbers are fixed for the catalogue, page tree and fonts, pages
+	// and their content streams follow.
+	const catalogueObj, pagesObj, regularObj, boldObj = 1, 2, 3, 4
+
+	kids := make([]string, len(d.pages))
+
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+i*2)
+	}
+
+	objects = append(objects,
+		fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesObj),
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+	)
+
+	for i, page := range d.pages {
+		content := pageContent(page)
+
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /%s %d 0 R /%s %d 0 R >> >> /Contents %d 0 R >>",
+				pagesObj, pageWidth, pageHeight, FONT_REGULAR, regularObj, FONT_BOLD, boldObj, 6+i*2),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+		)
+	}
+
+	var buf bytes.Buffer
+
+	buf.WriteString("%PDF-1.4\n")
+
+	offsets := make([]int, len(objects))
+
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := buf.Len()
+
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, catalogueObj, xref)
+
+	return buf.Bytes()
+}
+
+func pageContent(lines []line) string {
+	var b strings.Builder
+
+	y := pageHeight - margin
+
+	for _, l := range lines {
+		y -= l.size + l.size/2
+
+		if l.text == "" {
+			continue
+		}
+
+		fmt.Fprintf(&b, "BT /%s %d Tf %d %d Td (%s) Tj ET\n", l.font, l.size, margin, y, escape(l.text))
+	}
+
+	return b.String()
+}
+
+// Encode the text as WinAnsi and escape it for a PDF string literal
+func escape(text string) string {
+	var b strings.Builder
+
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '€':
+			b.WriteString(`\200`)
+		case r < 0x20:
+			b.WriteByte(' ')
+		case r < 0x80:
+			b.WriteRune(r)
+		case r >= 0xA0 && r <= 0xFF:
+			fmt.Fprintf(&b, `\%03o`, r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,63 @@
+package pdf_test
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/jameswhoughton/cushon/internal/pdf"
+)
+
+func TestDocumentIsAValidPDF(t *testing.T) {
+	doc := pdf.New()
+
+	doc.Heading("Statement (2024-25)")
+	doc.Text("Total: £1,234.56")
+
+	out := doc.Bytes()
+
+	if !bytes.HasPrefix(out, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(out, []byte("%%EOF\n")) {
+		t.Fatalf("Expected a PDF header and trailer, got %q", out)
+	}
+
+	// Brackets are escaped and the pound sign is encoded as WinAnsi
+	if !bytes.Contains(out, []byte(`(Statement \(2024-25\))`)) || !bytes.Contains(out, []byte(`(Total: \2431,234.56)`)) {
+		t.Errorf("Expected the text to be escaped, got %q", out)
+	}
+
+	// Every cross reference entry must point at the start of its object
+	match := regexp.MustCompile(`startxref\n(\d+)`).FindSubmatch(out)
+
+	if match == nil {
+		t.Fatal("startxref missing")
+	}
+
+	xref, _ := strconv.Atoi(string(match[1]))
+
+	if !bytes.HasPrefix(out[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref does not point at the xref table")
+	}
+
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(out[xref:], -1)
+
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(string(entry[1]))
+
+		if !bytes.HasPrefix(out[offset:], []byte(strconv.Itoa(i+1)+" 0 obj")) {
+			t.Errorf("xref entry for object %d does not point at the object", i+1)
+		}
+	}
+}
+
+func TestDocumentFlowsOntoNewPages(t *testing.T) {
+	doc := pdf.New()
+
+	for i := 0; i < 60; i++ {
+		doc.Text("Line " + strconv.Itoa(i))
+	}
+
+	if !bytes.Contains(doc.Bytes(), []byte("/Count 2")) {
+		t.Errorf("Expected 60 lines to span 2 pages")
+	}
+}